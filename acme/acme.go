@@ -0,0 +1,179 @@
+package acme
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/shogo82148/goat/jwa"
+	"github.com/shogo82148/goat/jwk"
+	"github.com/shogo82148/goat/jws"
+	"github.com/shogo82148/goat/sig"
+)
+
+// Signer signs ACME requests defined in RFC 8555 Section 6.2.
+type Signer struct {
+	_NamedFieldsRequired struct{}
+
+	// Algorithm is the signature algorithm.
+	// It must not be "none" or a MAC algorithm.
+	Algorithm jwa.SignatureAlgorithm
+
+	// Key is the account key.
+	// It is typically a [*jwk.Key] that has a private key,
+	// or a key created by [sig.NewSignerKey].
+	Key sig.Key
+
+	// KeyID is the account URL.
+	// It is set to the "kid" header parameter.
+	// If it is empty, the public key of Key is set to the "jwk" header parameter instead,
+	// e.g. for newAccount requests and revokeCert requests signed by the certificate key.
+	KeyID string
+}
+
+// Sign returns the ACME request in Flattened JWS JSON Serialization.
+// url is the URL that the request is sent to, and nonce is the anti-replay nonce from the server.
+// If payload is nil, the payload is empty string for POST-as-GET requests defined in RFC 8555 Section 6.3.
+func (s *Signer) Sign(ctx context.Context, url, nonce string, payload []byte) ([]byte, error) {
+	_ = s._NamedFieldsRequired
+	if err := checkAlgorithm(s.Algorithm); err != nil {
+		return nil, err
+	}
+	if !s.Algorithm.Available() {
+		return nil, errors.New("acme: algorithm not available")
+	}
+	if s.Key == nil {
+		return nil, errors.New("acme: key is missing")
+	}
+	if url == "" {
+		return nil, errors.New("acme: url is missing")
+	}
+	if nonce == "" {
+		return nil, errors.New("acme: nonce is missing")
+	}
+
+	// RFC 8555 Section 6.2:
+	// > The JWS Protected Header MUST include the following fields:
+	// >
+	// > - "alg" (Algorithm)
+	// > - "jwk" (JSON Web Key) or "kid" (Key ID)
+	// > - "nonce"
+	// > - "url"
+	protected := jws.NewHeader()
+	protected.SetAlgorithm(s.Algorithm)
+	if s.KeyID != "" {
+		protected.SetKeyID(s.KeyID)
+	} else {
+		pub, err := jwk.NewPublicKey(s.Key.PublicKey())
+		if err != nil {
+			return nil, fmt.Errorf("acme: failed to encode the public key: %w", err)
+		}
+		protected.SetJWK(pub)
+	}
+	protected.Set(jwa.NonceKey, nonce)
+	protected.Set(jwa.URLKey, url)
+
+	msg := jws.NewMessage(payload)
+	if err := msg.SignContext(ctx, protected, nil, s.Algorithm.New().NewSigningKey(s.Key)); err != nil {
+		return nil, err
+	}
+	return msg.MarshalFlattenedJSON()
+}
+
+// ExternalAccountBinding is a key for external account binding defined in RFC 8555 Section 7.3.4.
+type ExternalAccountBinding struct {
+	_NamedFieldsRequired struct{}
+
+	// KeyID is the key identifier provided by the CA.
+	KeyID string
+
+	// MACKey is the MAC key provided by the CA.
+	MACKey []byte
+
+	// Algorithm is the MAC algorithm.
+	// If it is empty, HS256 is used.
+	Algorithm jwa.SignatureAlgorithm
+}
+
+// Sign returns the value of the "externalAccountBinding" field of the newAccount request.
+// url is the URL of the newAccount resource, and accountKey is the account key.
+func (b *ExternalAccountBinding) Sign(url string, accountKey sig.Key) (json.RawMessage, error) {
+	_ = b._NamedFieldsRequired
+	alg := b.algorithm()
+	if !isMAC(alg) {
+		return nil, fmt.Errorf("acme: %q is not a MAC algorithm", alg)
+	}
+	if b.KeyID == "" {
+		return nil, errors.New("acme: key id is missing")
+	}
+	if len(b.MACKey) == 0 {
+		return nil, errors.New("acme: mac key is missing")
+	}
+	if accountKey == nil {
+		return nil, errors.New("acme: account key is missing")
+	}
+
+	// RFC 8555 Section 7.3.4:
+	// > The "payload" field of the JWS object contains the account key, i.e.,
+	// > the contents of the "jwk" field of the outer JWS.
+	pub, err := jwk.NewPublicKey(accountKey.PublicKey())
+	if err != nil {
+		return nil, fmt.Errorf("acme: failed to encode the account key: %w", err)
+	}
+	payload, err := pub.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("acme: failed to encode the account key: %w", err)
+	}
+
+	macKey, err := jwk.NewPrivateKey(b.MACKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// RFC 8555 Section 7.3.4:
+	// > The "alg" field MUST indicate a MAC-based algorithm
+	// > The "kid" field MUST contain the key identifier provided by the CA
+	// > The "nonce" field MUST NOT be present
+	// > The "url" field MUST be set to the same value as the outer JWS
+	protected := jws.NewHeader()
+	protected.SetAlgorithm(alg)
+	protected.SetKeyID(b.KeyID)
+	protected.Set(jwa.URLKey, url)
+
+	msg := jws.NewMessage(payload)
+	if err := msg.Sign(protected, nil, alg.New().NewSigningKey(macKey)); err != nil {
+		return nil, err
+	}
+	data, err := msg.MarshalFlattenedJSON()
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(data), nil
+}
+
+func (b *ExternalAccountBinding) algorithm() jwa.SignatureAlgorithm {
+	if b.Algorithm == "" {
+		return jwa.SignatureAlgorithmHS256
+	}
+	return b.Algorithm
+}
+
+// checkAlgorithm checks alg is usable for ACME requests.
+//
+// RFC 8555 Section 6.2:
+// > The JWS Protected Header MUST NOT use the "none" algorithm or a MAC algorithm.
+func checkAlgorithm(alg jwa.SignatureAlgorithm) error {
+	if alg == "" || alg == jwa.SignatureAlgorithmNone || isMAC(alg) {
+		return fmt.Errorf("acme: algorithm %q is not allowed", alg)
+	}
+	return nil
+}
+
+func isMAC(alg jwa.SignatureAlgorithm) bool {
+	switch alg {
+	case jwa.SignatureAlgorithmHS256, jwa.SignatureAlgorithmHS384, jwa.SignatureAlgorithmHS512:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,536 @@
+package acme
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shogo82148/goat"
+	"github.com/shogo82148/goat/jwa"
+	_ "github.com/shogo82148/goat/jwa/es" // for ECDSA
+	_ "github.com/shogo82148/goat/jwa/hs" // for HMAC SHA-256
+	"github.com/shogo82148/goat/jwk"
+	"github.com/shogo82148/goat/jws"
+)
+
+const (
+	testAccountURL    = "https://example.com/acme/acct/evOfKhNU60wg"
+	testNewAccountURL = "https://example.com/acme/new-account"
+	testOrderURL      = "https://example.com/acme/new-order"
+)
+
+func newTestKey(t *testing.T) *jwk.Key {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := jwk.NewPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newTestVerifier(t *testing.T, store NonceStore, account *jwk.Key) *Verifier {
+	t.Helper()
+	pub, err := jwk.NewPublicKey(account.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Verifier{
+		AlgorithmVerifier: jws.AllowedAlgorithms{jwa.SignatureAlgorithmES256},
+		NonceStore:        store,
+		AccountKeyFinder: FindAccountKeyFunc(func(ctx context.Context, kid string) (*jwk.Key, error) {
+			if kid != testAccountURL {
+				return nil, errors.New("account does not exist")
+			}
+			return pub, nil
+		}),
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	ctx := context.Background()
+	key := newTestKey(t)
+	store := &MemoryNonceStore{}
+	v := newTestVerifier(t, store, key)
+
+	t.Run("kid", func(t *testing.T) {
+		nonce, err := store.NewNonce(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := &Signer{
+			Algorithm: jwa.SignatureAlgorithmES256,
+			Key:       key,
+			KeyID:     testAccountURL,
+		}
+		data, err := s.Sign(ctx, testOrderURL, nonce, []byte(`{"identifiers":[]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := v.Verify(ctx, testOrderURL, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if req.KeyID != testAccountURL {
+			t.Errorf("unexpected kid: %q", req.KeyID)
+		}
+		if req.JWK != nil {
+			t.Error("want no jwk")
+		}
+		if req.Nonce != nonce {
+			t.Errorf("unexpected nonce: %q", req.Nonce)
+		}
+		if string(req.Payload) != `{"identifiers":[]}` {
+			t.Errorf("unexpected payload: %q", req.Payload)
+		}
+
+		// the nonce can't be reused.
+		_, err = v.Verify(ctx, testOrderURL, data)
+		if !errors.Is(err, ErrBadNonce) {
+			t.Errorf("want ErrBadNonce, got %v", err)
+		}
+	})
+
+	t.Run("jwk", func(t *testing.T) {
+		nonce, err := store.NewNonce(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := &Signer{
+			Algorithm: jwa.SignatureAlgorithmES256,
+			Key:       newTestKey(t),
+		}
+		data, err := s.Sign(ctx, testNewAccountURL, nonce, []byte(`{}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := v.Verify(ctx, testNewAccountURL, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if req.KeyID != "" {
+			t.Errorf("unexpected kid: %q", req.KeyID)
+		}
+		if req.JWK == nil || req.JWK.PrivateKey() != nil {
+			t.Error("want the public key in jwk")
+		}
+	})
+
+	t.Run("POST-as-GET", func(t *testing.T) {
+		nonce, err := store.NewNonce(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := &Signer{
+			Algorithm: jwa.SignatureAlgorithmES256,
+			Key:       key,
+			KeyID:     testAccountURL,
+		}
+		data, err := s.Sign(ctx, testAccountURL, nonce, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var raw map[string]any
+		if err := json.Unmarshal(data, &raw); err != nil {
+			t.Fatal(err)
+		}
+		if raw["payload"] != "" {
+			t.Errorf("want empty payload, got %v", raw["payload"])
+		}
+
+		req, err := v.Verify(ctx, testAccountURL, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(req.Payload) != 0 {
+			t.Errorf("unexpected payload: %q", req.Payload)
+		}
+	})
+
+	t.Run("url mismatch", func(t *testing.T) {
+		nonce, err := store.NewNonce(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := &Signer{
+			Algorithm: jwa.SignatureAlgorithmES256,
+			Key:       key,
+			KeyID:     testAccountURL,
+		}
+		data, err := s.Sign(ctx, testOrderURL, nonce, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := v.Verify(ctx, testAccountURL, data); err == nil {
+			t.Error("want error, but not")
+		}
+	})
+
+	t.Run("unknown nonce", func(t *testing.T) {
+		s := &Signer{
+			Algorithm: jwa.SignatureAlgorithmES256,
+			Key:       key,
+			KeyID:     testAccountURL,
+		}
+		data, err := s.Sign(ctx, testOrderURL, "bm9uY2U", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = v.Verify(ctx, testOrderURL, data)
+		if !errors.Is(err, ErrBadNonce) {
+			t.Errorf("want ErrBadNonce, got %v", err)
+		}
+	})
+
+	t.Run("unknown account", func(t *testing.T) {
+		nonce, err := store.NewNonce(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := &Signer{
+			Algorithm: jwa.SignatureAlgorithmES256,
+			Key:       key,
+			KeyID:     "https://example.com/acme/acct/unknown",
+		}
+		data, err := s.Sign(ctx, testOrderURL, nonce, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := v.Verify(ctx, testOrderURL, data); err == nil {
+			t.Error("want error, but not")
+		}
+	})
+}
+
+func TestVerify_Invalid(t *testing.T) {
+	ctx := context.Background()
+	key := newTestKey(t)
+	pub, err := jwk.NewPublicKey(key.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &MemoryNonceStore{}
+	v := newTestVerifier(t, store, key)
+	signingKey := jwa.SignatureAlgorithmES256.New().NewSigningKey(key)
+
+	// sign signs an empty payload with the headers, without any checks by Signer.
+	sign := func(t *testing.T, protected, unprotected *jws.Header) *jws.Message {
+		t.Helper()
+		nonce, err := store.NewNonce(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		protected.Set(jwa.NonceKey, nonce)
+		protected.Set(jwa.URLKey, testOrderURL)
+		msg := jws.NewMessage(nil)
+		if err := msg.Sign(protected, unprotected, signingKey); err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+
+	t.Run("both jwk and kid", func(t *testing.T) {
+		h := jws.NewHeader()
+		h.SetAlgorithm(jwa.SignatureAlgorithmES256)
+		h.SetKeyID(testAccountURL)
+		h.SetJWK(pub)
+		data, err := sign(t, h, nil).MarshalFlattenedJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := v.Verify(ctx, testOrderURL, data); err == nil {
+			t.Error("want error, but not")
+		}
+	})
+
+	t.Run("private key in jwk", func(t *testing.T) {
+		h := jws.NewHeader()
+		h.SetAlgorithm(jwa.SignatureAlgorithmES256)
+		h.SetJWK(key)
+		data, err := sign(t, h, nil).MarshalFlattenedJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := v.Verify(ctx, testOrderURL, data); err == nil {
+			t.Error("want error, but not")
+		}
+	})
+
+	t.Run("neither jwk nor kid", func(t *testing.T) {
+		h := jws.NewHeader()
+		h.SetAlgorithm(jwa.SignatureAlgorithmES256)
+		data, err := sign(t, h, nil).MarshalFlattenedJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := v.Verify(ctx, testOrderURL, data); err == nil {
+			t.Error("want error, but not")
+		}
+	})
+
+	t.Run("compact serialization", func(t *testing.T) {
+		h := jws.NewHeader()
+		h.SetAlgorithm(jwa.SignatureAlgorithmES256)
+		h.SetKeyID(testAccountURL)
+		data, err := sign(t, h, nil).Compact()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := v.Verify(ctx, testOrderURL, data); err == nil {
+			t.Error("want error, but not")
+		}
+	})
+
+	t.Run("unprotected header", func(t *testing.T) {
+		h := jws.NewHeader()
+		h.SetAlgorithm(jwa.SignatureAlgorithmES256)
+		h.SetKeyID(testAccountURL)
+		u := jws.NewHeader()
+		u.SetType("example")
+		data, err := sign(t, h, u).MarshalFlattenedJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := v.Verify(ctx, testOrderURL, data); err == nil {
+			t.Error("want error, but not")
+		}
+	})
+
+	t.Run("MAC algorithm", func(t *testing.T) {
+		v := &Verifier{
+			AlgorithmVerifier: jws.UnsecureAnyAlgorithm,
+			NonceStore:        store,
+		}
+		nonce, err := store.NewNonce(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mac, err := jwk.NewPrivateKey([]byte("a secret key for HMAC using SHA-256"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := jws.NewHeader()
+		h.SetAlgorithm(jwa.SignatureAlgorithmHS256)
+		h.SetJWK(mac)
+		h.Set(jwa.NonceKey, nonce)
+		h.Set(jwa.URLKey, testOrderURL)
+		msg := jws.NewMessage(nil)
+		if err := msg.Sign(h, nil, jwa.SignatureAlgorithmHS256.New().NewSigningKey(mac)); err != nil {
+			t.Fatal(err)
+		}
+		data, err := msg.MarshalFlattenedJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := v.Verify(ctx, testOrderURL, data); err == nil {
+			t.Error("want error, but not")
+		}
+	})
+}
+
+func TestExternalAccountBinding(t *testing.T) {
+	ctx := context.Background()
+	key := newTestKey(t)
+	store := &MemoryNonceStore{}
+	v := newTestVerifier(t, store, key)
+	macKey := []byte("a very secret mac key provided by the CA")
+	finder := FindMACKeyFunc(func(ctx context.Context, kid string) ([]byte, error) {
+		if kid != "kid-1" {
+			return nil, errors.New("unknown kid")
+		}
+		return macKey, nil
+	})
+
+	// newAccount signs a newAccount request with the external account binding eab.
+	newAccount := func(t *testing.T, eab json.RawMessage) *Request {
+		t.Helper()
+		nonce, err := store.NewNonce(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payload, err := json.Marshal(map[string]any{
+			"termsOfServiceAgreed":   true,
+			"externalAccountBinding": eab,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := &Signer{
+			Algorithm: jwa.SignatureAlgorithmES256,
+			Key:       key,
+		}
+		data, err := s.Sign(ctx, testNewAccountURL, nonce, payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := v.Verify(ctx, testNewAccountURL, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		b := &ExternalAccountBinding{
+			KeyID:  "kid-1",
+			MACKey: macKey,
+		}
+		eab, err := b.Sign(testNewAccountURL, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := newAccount(t, eab)
+		kid, err := VerifyExternalAccountBinding(ctx, req, eab, finder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kid != "kid-1" {
+			t.Errorf("unexpected kid: %q", kid)
+		}
+	})
+
+	t.Run("wrong mac key", func(t *testing.T) {
+		b := &ExternalAccountBinding{
+			KeyID:  "kid-1",
+			MACKey: []byte("a wrong mac key that is long enough"),
+		}
+		eab, err := b.Sign(testNewAccountURL, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := newAccount(t, eab)
+		if _, err := VerifyExternalAccountBinding(ctx, req, eab, finder); err == nil {
+			t.Error("want error, but not")
+		}
+	})
+
+	t.Run("another account key", func(t *testing.T) {
+		b := &ExternalAccountBinding{
+			KeyID:  "kid-1",
+			MACKey: macKey,
+		}
+		eab, err := b.Sign(testNewAccountURL, newTestKey(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := newAccount(t, eab)
+		if _, err := VerifyExternalAccountBinding(ctx, req, eab, finder); err == nil {
+			t.Error("want error, but not")
+		}
+	})
+
+	t.Run("url mismatch", func(t *testing.T) {
+		b := &ExternalAccountBinding{
+			KeyID:  "kid-1",
+			MACKey: macKey,
+		}
+		eab, err := b.Sign(testOrderURL, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := newAccount(t, eab)
+		if _, err := VerifyExternalAccountBinding(ctx, req, eab, finder); err == nil {
+			t.Error("want error, but not")
+		}
+	})
+}
+
+func TestMemoryNonceStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	s := &MemoryNonceStore{
+		TTL:   time.Minute,
+		Clock: goat.ClockFunc(func() time.Time { return now }),
+	}
+	n1, err := s.NewNonce(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n2, err := s.NewNonce(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n1 == n2 {
+		t.Fatal("nonces must be unique")
+	}
+
+	if err := s.ConsumeNonce(ctx, n1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ConsumeNonce(ctx, n1); !errors.Is(err, ErrBadNonce) {
+		t.Errorf("want ErrBadNonce, got %v", err)
+	}
+	if err := s.ConsumeNonce(ctx, "!invalid!"); !errors.Is(err, ErrBadNonce) {
+		t.Errorf("want ErrBadNonce, got %v", err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if err := s.ConsumeNonce(ctx, n2); !errors.Is(err, ErrBadNonce) {
+		t.Errorf("want ErrBadNonce, got %v", err)
+	}
+}
+
+func TestMemoryNonceStore_Expire(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	s := &MemoryNonceStore{
+		TTL:   time.Minute,
+		Clock: goat.ClockFunc(func() time.Time { return now }),
+	}
+	for range 10 {
+		if _, err := s.NewNonce(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the expired nonces are removed when a new nonce is issued.
+	now = now.Add(2 * time.Minute)
+	nonce, err := s.NewNonce(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(s.nonces); got != 1 {
+		t.Errorf("want 1 nonce, got %d", got)
+	}
+	if err := s.ConsumeNonce(ctx, nonce); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMemoryNonceStore_MaxNonces(t *testing.T) {
+	ctx := context.Background()
+	s := &MemoryNonceStore{
+		MaxNonces: 2,
+	}
+	var nonces []string
+	for range 3 {
+		nonce, err := s.NewNonce(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nonces = append(nonces, nonce)
+	}
+	if got := len(s.nonces); got != 2 {
+		t.Errorf("want 2 nonces, got %d", got)
+	}
+
+	// the oldest nonce is invalidated.
+	if err := s.ConsumeNonce(ctx, nonces[0]); !errors.Is(err, ErrBadNonce) {
+		t.Errorf("want ErrBadNonce, got %v", err)
+	}
+	for _, nonce := range nonces[1:] {
+		if err := s.ConsumeNonce(ctx, nonce); err != nil {
+			t.Error(err)
+		}
+	}
+}
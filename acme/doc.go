@@ -0,0 +1,2 @@
+// Package acme handles the JWS-signed requests of Automatic Certificate Management Environment (ACME) defined in RFC 8555.
+package acme
@@ -0,0 +1,133 @@
+package acme
+
+import (
+	"container/list"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/shogo82148/goat"
+	"github.com/shogo82148/goat/internal/clockutils"
+)
+
+// ErrBadNonce means the "nonce" header parameter is invalid, expired, or already used.
+// The server should respond with the "urn:ietf:params:acme:error:badNonce" error
+// and a fresh nonce, as described in RFC 8555 Section 6.5.
+var ErrBadNonce = errors.New("acme: bad nonce")
+
+// NonceStore issues and consumes the anti-replay nonces defined in RFC 8555 Section 6.5.
+type NonceStore interface {
+	// NewNonce returns a fresh nonce for the Replay-Nonce response header field.
+	NewNonce(ctx context.Context) (string, error)
+
+	// ConsumeNonce invalidates nonce.
+	// It returns an error wrapping [ErrBadNonce] if nonce was not issued by the store,
+	// has expired, or has already been consumed.
+	ConsumeNonce(ctx context.Context, nonce string) error
+}
+
+var _ NonceStore = (*MemoryNonceStore)(nil)
+
+// MemoryNonceStore is a [NonceStore] that keeps the nonces in memory.
+// It is suitable for a single server process.
+// The zero value is ready to use.
+type MemoryNonceStore struct {
+	// TTL is the lifetime of the nonces.
+	// If it is zero, one hour is used.
+	TTL time.Duration
+
+	// Clock provides the current time to expire the nonces.
+	// If it is nil, the system clock is used.
+	Clock goat.Clock
+
+	// MaxNonces is the maximum number of the nonces that are not consumed yet.
+	// The oldest nonce is invalidated when the limit is exceeded.
+	// If it is zero, 65536 is used.
+	MaxNonces int
+
+	mu     sync.Mutex
+	queue  list.List                // of *nonceEntry, the oldest first
+	nonces map[string]*list.Element // nonce -> element of queue
+}
+
+type nonceEntry struct {
+	nonce     string
+	expiresAt time.Time
+}
+
+const (
+	defaultNonceTTL = time.Hour
+
+	// The default maximum number of the nonces in MemoryNonceStore.
+	defaultMaxNonces = 1 << 16
+)
+
+var b64 = base64.RawURLEncoding
+
+// NewNonce implements [NonceStore].
+func (s *MemoryNonceStore) NewNonce(ctx context.Context) (string, error) {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", err
+	}
+	nonce := b64.EncodeToString(buf[:])
+
+	ttl := s.TTL
+	if ttl <= 0 {
+		ttl = defaultNonceTTL
+	}
+	limit := s.MaxNonces
+	if limit <= 0 {
+		limit = defaultMaxNonces
+	}
+	now := clockutils.Now(s.Clock)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.nonces == nil {
+		s.nonces = make(map[string]*list.Element)
+	}
+
+	// the nonces are queued in the order of issue, so the expired ones are at the front.
+	for e := s.queue.Front(); e != nil; e = s.queue.Front() {
+		entry := e.Value.(*nonceEntry)
+		if !now.After(entry.expiresAt) && s.queue.Len() < limit {
+			break
+		}
+		s.queue.Remove(e)
+		delete(s.nonces, entry.nonce)
+	}
+	s.nonces[nonce] = s.queue.PushBack(&nonceEntry{
+		nonce:     nonce,
+		expiresAt: now.Add(ttl),
+	})
+	return nonce, nil
+}
+
+// ConsumeNonce implements [NonceStore].
+func (s *MemoryNonceStore) ConsumeNonce(ctx context.Context, nonce string) error {
+	// RFC 8555 Section 6.5.2:
+	// > The value of the "nonce" header parameter MUST be an octet string,
+	// > encoded according to the base64url encoding described in Section 2 of [RFC7515].
+	// > If the value of a "nonce" header parameter is not valid
+	// > according to this encoding, then the server MUST reject the request as malformed.
+	if _, err := b64.DecodeString(nonce); err != nil || nonce == "" {
+		return ErrBadNonce
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.nonces[nonce]
+	if !ok {
+		return ErrBadNonce
+	}
+	s.queue.Remove(e)
+	delete(s.nonces, nonce)
+	if clockutils.Now(s.Clock).After(e.Value.(*nonceEntry).expiresAt) {
+		return ErrBadNonce
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package acme
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+
+	"github.com/shogo82148/goat/jwa"
+	"github.com/shogo82148/goat/jwk"
+	"github.com/shogo82148/goat/jws"
+	"github.com/shogo82148/goat/sig"
+)
+
+// AccountKeyFinder finds the account key identified by the "kid" header parameter.
+type AccountKeyFinder interface {
+	// FindAccountKey returns the public key of the account.
+	// kid is the account URL.
+	FindAccountKey(ctx context.Context, kid string) (*jwk.Key, error)
+}
+
+// FindAccountKeyFunc is an adapter to allow the use of ordinary functions as AccountKeyFinder.
+type FindAccountKeyFunc func(ctx context.Context, kid string) (*jwk.Key, error)
+
+// FindAccountKey calls f(ctx, kid).
+func (f FindAccountKeyFunc) FindAccountKey(ctx context.Context, kid string) (*jwk.Key, error) {
+	return f(ctx, kid)
+}
+
+// Request is a verified ACME request.
+type Request struct {
+	// Protected is the JWS Protected Header of the request.
+	Protected *jws.Header
+
+	// Payload is the payload of the request.
+	// It is empty for POST-as-GET requests.
+	Payload []byte
+
+	// URL is the "url" header parameter.
+	URL string
+
+	// Nonce is the "nonce" header parameter.
+	Nonce string
+
+	// KeyID is the account URL in the "kid" header parameter.
+	// It is empty if the request is signed by the key in the "jwk" header parameter.
+	KeyID string
+
+	// JWK is the public key in the "jwk" header parameter.
+	// It is nil if the request is signed by the account key identified by KeyID.
+	JWK *jwk.Key
+}
+
+// Verifier verifies ACME requests defined in RFC 8555 Section 6.2.
+type Verifier struct {
+	_NamedFieldsRequired struct{}
+
+	// AlgorithmVerifier verifies the "alg" header parameter.
+	// "none" and the MAC algorithms are always rejected.
+	AlgorithmVerifier jws.AlgorithmVerifier
+
+	// NonceStore validates and consumes the "nonce" header parameter.
+	NonceStore NonceStore
+
+	// AccountKeyFinder finds the account key for the requests that have the "kid" header parameter.
+	// If it is nil, such requests are rejected.
+	AccountKeyFinder AccountKeyFinder
+}
+
+// Verify verifies the ACME request data in Flattened JWS JSON Serialization.
+// url is the URL that the client requested, which must be equal to the "url" header parameter.
+// The nonce is consumed only if the signature is valid.
+// If the nonce is invalid, the returned error wraps [ErrBadNonce].
+func (v *Verifier) Verify(ctx context.Context, url string, data []byte) (*Request, error) {
+	_ = v._NamedFieldsRequired
+	if v.AlgorithmVerifier == nil || v.NonceStore == nil {
+		return nil, errors.New("acme: verifier is not configured")
+	}
+
+	msg, err := jws.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// RFC 8555 Section 6.2:
+	// > The JWS MUST use the Flattened JSON Serialization
+	// > The JWS MUST NOT have multiple signatures
+	// > The JWS Unprotected Header [RFC7515] MUST NOT be used
+	if msg.Format() != jws.FormatFlattenedJSON {
+		return nil, errors.New("acme: the request must use the flattened JSON serialization")
+	}
+	if len(msg.Signatures) != 1 {
+		return nil, errors.New("acme: the request must have exactly one signature")
+	}
+	if msg.Signatures[0].Unprotected() != nil {
+		return nil, errors.New("acme: the unprotected header must not be used")
+	}
+	if msg.Signatures[0].Protected() == nil {
+		return nil, errors.New("acme: the protected header is missing")
+	}
+
+	verifier := &jws.Verifier{
+		AlgorithmVerifier: algorithmVerifier{v.AlgorithmVerifier},
+		KeyFinder:         jws.FindKeyFunc(v.findKey),
+	}
+	protected, _, payload, err := verifier.Verify(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	// RFC 8555 Section 6.4:
+	// > If the two do not match, then the server MUST reject the request as unauthorized.
+	reqURL, ok := jws.Get[string](protected, jwa.URLKey)
+	if !ok || reqURL == "" {
+		return nil, errors.New("acme: url is missing")
+	}
+	if reqURL != url {
+		return nil, fmt.Errorf("acme: url %q doesn't match the request url", reqURL)
+	}
+
+	// RFC 8555 Section 6.5:
+	// > If the value of the "nonce" header parameter is not a valid nonce,
+	// > then the server MUST reject the JWS as unacceptable.
+	nonce, ok := jws.Get[string](protected, jwa.NonceKey)
+	if !ok || nonce == "" {
+		return nil, fmt.Errorf("%w: nonce is missing", ErrBadNonce)
+	}
+	if err := v.NonceStore.ConsumeNonce(ctx, nonce); err != nil {
+		return nil, err
+	}
+
+	return &Request{
+		Protected: protected,
+		Payload:   payload,
+		URL:       reqURL,
+		Nonce:     nonce,
+		KeyID:     protected.KeyID(),
+		JWK:       protected.JWK(),
+	}, nil
+}
+
+// embeddedJWKKeyFinder finds the key in the "jwk" header parameter.
+// The requests signed by the embedded key, such as newAccount requests, introduce a new key,
+// so any key is trusted here.
+var embeddedJWKKeyFinder = &jws.EmbeddedJWKKeyFinder{
+	Trust: jws.VerifyJWKFunc(func(ctx context.Context, key *jwk.Key, thumbprint []byte) error {
+		return nil
+	}),
+}
+
+func (v *Verifier) findKey(ctx context.Context, protected, unprotected *jws.Header) (sig.SigningKey, error) {
+	// RFC 8555 Section 6.2:
+	// > The "jwk" and "kid" fields are mutually exclusive.
+	// > Servers MUST reject requests that contain both.
+	kid := protected.KeyID()
+	hasJWK := protected.JWK() != nil
+	switch {
+	case kid != "" && hasJWK:
+		return nil, errors.New("acme: jwk and kid are mutually exclusive")
+	case hasJWK:
+		return embeddedJWKKeyFinder.FindKey(ctx, protected, unprotected)
+	case kid == "":
+		return nil, errors.New("acme: either jwk or kid is required")
+	}
+
+	if v.AccountKeyFinder == nil {
+		return nil, errors.New("acme: account key finder is not configured")
+	}
+	key, err := v.AccountKeyFinder.FindAccountKey(ctx, kid)
+	if err != nil {
+		return nil, err
+	}
+	alg := protected.Algorithm()
+	if !alg.Available() {
+		return nil, errors.New("acme: algorithm not available")
+	}
+	if keyAlg := key.Algorithm(); keyAlg != "" && keyAlg != jwa.KeyAlgorithm(alg) {
+		return nil, fmt.Errorf("acme: requested alg %q is not supported by the key", alg)
+	}
+	return alg.New().NewSigningKey(key), nil
+}
+
+type algorithmVerifier struct {
+	jws.AlgorithmVerifier
+}
+
+func (v algorithmVerifier) VerifyAlgorithm(ctx context.Context, alg jwa.SignatureAlgorithm) error {
+	if err := checkAlgorithm(alg); err != nil {
+		return err
+	}
+	return v.AlgorithmVerifier.VerifyAlgorithm(ctx, alg)
+}
+
+// MACKeyFinder finds the MAC key for external account binding.
+type MACKeyFinder interface {
+	// FindMACKey returns the MAC key identified by kid.
+	FindMACKey(ctx context.Context, kid string) ([]byte, error)
+}
+
+// FindMACKeyFunc is an adapter to allow the use of ordinary functions as MACKeyFinder.
+type FindMACKeyFunc func(ctx context.Context, kid string) ([]byte, error)
+
+// FindMACKey calls f(ctx, kid).
+func (f FindMACKeyFunc) FindMACKey(ctx context.Context, kid string) ([]byte, error) {
+	return f(ctx, kid)
+}
+
+// VerifyExternalAccountBinding verifies the "externalAccountBinding" field of the newAccount request
+// defined in RFC 8555 Section 7.3.4.
+// req is the verified newAccount request, and eab is the value of the field.
+// It returns the key identifier of the external account.
+func VerifyExternalAccountBinding(ctx context.Context, req *Request, eab []byte, finder MACKeyFinder) (kid string, err error) {
+	if req == nil || req.JWK == nil {
+		return "", errors.New("acme: newAccount request must have the jwk header parameter")
+	}
+	if finder == nil {
+		return "", errors.New("acme: mac key finder is not configured")
+	}
+
+	msg, err := jws.Parse(eab)
+	if err != nil {
+		return "", err
+	}
+	if msg.Format() != jws.FormatFlattenedJSON || len(msg.Signatures) != 1 {
+		return "", errors.New("acme: external account binding must be a flattened JWS with one signature")
+	}
+	if msg.Signatures[0].Unprotected() != nil {
+		return "", errors.New("acme: the unprotected header must not be used")
+	}
+
+	verifier := &jws.Verifier{
+		AlgorithmVerifier: jws.AllowedAlgorithms{
+			jwa.SignatureAlgorithmHS256,
+			jwa.SignatureAlgorithmHS384,
+			jwa.SignatureAlgorithmHS512,
+		},
+		KeyFinder: jws.FindKeyFunc(func(ctx context.Context, protected, unprotected *jws.Header) (sig.SigningKey, error) {
+			if protected == nil || protected.KeyID() == "" {
+				return nil, errors.New("acme: kid is missing")
+			}
+			macKey, err := finder.FindMACKey(ctx, protected.KeyID())
+			if err != nil {
+				return nil, err
+			}
+			key, err := jwk.NewPrivateKey(macKey)
+			if err != nil {
+				return nil, err
+			}
+			return protected.Algorithm().New().NewSigningKey(key), nil
+		}),
+	}
+	protected, _, payload, err := verifier.Verify(ctx, msg)
+	if err != nil {
+		return "", err
+	}
+
+	if _, ok := protected.Raw[jwa.NonceKey]; ok {
+		return "", errors.New("acme: nonce must not be present in external account binding")
+	}
+	eabURL, ok := jws.Get[string](protected, jwa.URLKey)
+	if !ok || eabURL != req.URL {
+		return "", errors.New("acme: url of external account binding doesn't match the request")
+	}
+
+	// the payload must be the account key in the outer JWS.
+	key, err := jwk.ParseKey(bytes.TrimSpace(payload))
+	if err != nil {
+		return "", fmt.Errorf("acme: failed to parse the account key: %w", err)
+	}
+	if key.PrivateKey() != nil {
+		return "", errors.New("acme: account key must not contain any private key")
+	}
+	got, err := key.Thumbprint(crypto.SHA256.New())
+	if err != nil {
+		return "", err
+	}
+	want, err := req.JWK.Thumbprint(crypto.SHA256.New())
+	if err != nil {
+		return "", err
+	}
+	if subtle.ConstantTimeCompare(got, want) != 1 {
+		return "", errors.New("acme: account key of external account binding doesn't match the request")
+	}
+	return protected.KeyID(), nil
+}
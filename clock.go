@@ -0,0 +1,18 @@
+package goat
+
+import "time"
+
+// Clock provides the current time for time-based validations,
+// e.g. the validity period of certificates and the "exp", "nbf" and "iat" claims of tokens.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc is an adapter to allow the use of ordinary functions as Clock interfaces.
+// If f is a function with the appropriate signature, ClockFunc(f) is a Clock that calls f.
+type ClockFunc func() time.Time
+
+// Now calls f().
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
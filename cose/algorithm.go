@@ -0,0 +1,428 @@
+package cose
+
+import (
+	"fmt"
+
+	"github.com/shogo82148/goat/jwa"
+)
+
+// Algorithm is an algorithm identifier
+// defined in the IANA "COSE Algorithms" registry.
+type Algorithm int64
+
+const (
+	// AlgorithmReserved is a reserved algorithm.
+	// It is used for unknown algorithms.
+	AlgorithmReserved Algorithm = 0
+
+	// AlgorithmES256 is ECDSA w/ SHA-256.
+	// import github.com/shogo82148/goat/jwa/es
+	AlgorithmES256 Algorithm = -7
+
+	// AlgorithmES384 is ECDSA w/ SHA-384.
+	// import github.com/shogo82148/goat/jwa/es
+	AlgorithmES384 Algorithm = -35
+
+	// AlgorithmES512 is ECDSA w/ SHA-512.
+	// import github.com/shogo82148/goat/jwa/es
+	AlgorithmES512 Algorithm = -36
+
+	// AlgorithmES256K is ECDSA using secp256k1 curve and SHA-256.
+	// import github.com/shogo82148/goat/jwa/es
+	AlgorithmES256K Algorithm = -47
+
+	// AlgorithmEdDSA is EdDSA.
+	// import github.com/shogo82148/goat/jwa/eddsa
+	AlgorithmEdDSA Algorithm = -8
+
+	// AlgorithmEd25519 is EdDSA using the Ed25519 parameter set.
+	// import github.com/shogo82148/goat/jwa/ed25519
+	AlgorithmEd25519 Algorithm = -19
+
+	// AlgorithmEd448 is EdDSA using the Ed448 parameter set.
+	// import github.com/shogo82148/goat/jwa/ed448
+	AlgorithmEd448 Algorithm = -53
+
+	// AlgorithmPS256 is RSASSA-PSS w/ SHA-256.
+	// import github.com/shogo82148/goat/jwa/ps
+	AlgorithmPS256 Algorithm = -37
+
+	// AlgorithmPS384 is RSASSA-PSS w/ SHA-384.
+	// import github.com/shogo82148/goat/jwa/ps
+	AlgorithmPS384 Algorithm = -38
+
+	// AlgorithmPS512 is RSASSA-PSS w/ SHA-512.
+	// import github.com/shogo82148/goat/jwa/ps
+	AlgorithmPS512 Algorithm = -39
+
+	// AlgorithmRS256 is RSASSA-PKCS1-v1_5 using SHA-256.
+	// import github.com/shogo82148/goat/jwa/rs
+	AlgorithmRS256 Algorithm = -257
+
+	// AlgorithmRS384 is RSASSA-PKCS1-v1_5 using SHA-384.
+	// import github.com/shogo82148/goat/jwa/rs
+	AlgorithmRS384 Algorithm = -258
+
+	// AlgorithmRS512 is RSASSA-PKCS1-v1_5 using SHA-512.
+	// import github.com/shogo82148/goat/jwa/rs
+	AlgorithmRS512 Algorithm = -259
+
+	// AlgorithmHMAC256_64 is HMAC w/ SHA-256 truncated to 64 bits.
+	AlgorithmHMAC256_64 Algorithm = 4
+
+	// AlgorithmHMAC256_256 is HMAC w/ SHA-256.
+	AlgorithmHMAC256_256 Algorithm = 5
+
+	// AlgorithmHMAC384_384 is HMAC w/ SHA-384.
+	AlgorithmHMAC384_384 Algorithm = 6
+
+	// AlgorithmHMAC512_512 is HMAC w/ SHA-512.
+	AlgorithmHMAC512_512 Algorithm = 7
+
+	// AlgorithmAESMAC128_64 is AES-MAC 128-bit key, 64-bit tag.
+	AlgorithmAESMAC128_64 Algorithm = 14
+
+	// AlgorithmAESMAC256_64 is AES-MAC 256-bit key, 64-bit tag.
+	AlgorithmAESMAC256_64 Algorithm = 15
+
+	// AlgorithmAESMAC128_128 is AES-MAC 128-bit key, 128-bit tag.
+	AlgorithmAESMAC128_128 Algorithm = 25
+
+	// AlgorithmAESMAC256_128 is AES-MAC 256-bit key, 128-bit tag.
+	AlgorithmAESMAC256_128 Algorithm = 26
+
+	// AlgorithmA128GCM is AES-GCM mode w/ 128-bit key, 128-bit tag.
+	// import github.com/shogo82148/goat/jwa/agcm
+	AlgorithmA128GCM Algorithm = 1
+
+	// AlgorithmA192GCM is AES-GCM mode w/ 192-bit key, 128-bit tag.
+	// import github.com/shogo82148/goat/jwa/agcm
+	AlgorithmA192GCM Algorithm = 2
+
+	// AlgorithmA256GCM is AES-GCM mode w/ 256-bit key, 128-bit tag.
+	// import github.com/shogo82148/goat/jwa/agcm
+	AlgorithmA256GCM Algorithm = 3
+
+	// AlgorithmDirect is direct use of CEK.
+	// import github.com/shogo82148/goat/jwa/dir
+	AlgorithmDirect Algorithm = -6
+
+	// AlgorithmA128KW is AES Key Wrap w/ 128-bit key.
+	// import github.com/shogo82148/goat/jwa/akw
+	AlgorithmA128KW Algorithm = -3
+
+	// AlgorithmA192KW is AES Key Wrap w/ 192-bit key.
+	// import github.com/shogo82148/goat/jwa/akw
+	AlgorithmA192KW Algorithm = -4
+
+	// AlgorithmA256KW is AES Key Wrap w/ 256-bit key.
+	// import github.com/shogo82148/goat/jwa/akw
+	AlgorithmA256KW Algorithm = -5
+
+	// AlgorithmECDH_ES_HKDF_256 is ECDH ES w/ HKDF - generate key directly.
+	AlgorithmECDH_ES_HKDF_256 Algorithm = -25
+
+	// AlgorithmECDH_ES_HKDF_512 is ECDH ES w/ HKDF - generate key directly.
+	AlgorithmECDH_ES_HKDF_512 Algorithm = -26
+
+	// AlgorithmECDH_SS_HKDF_256 is ECDH SS w/ HKDF - generate key directly.
+	AlgorithmECDH_SS_HKDF_256 Algorithm = -27
+
+	// AlgorithmECDH_SS_HKDF_512 is ECDH SS w/ HKDF - generate key directly.
+	AlgorithmECDH_SS_HKDF_512 Algorithm = -28
+
+	// AlgorithmECDH_ES_A128KW is ECDH ES w/ HKDF and AES Key Wrap w/ 128-bit key.
+	// import github.com/shogo82148/goat/jwa/akw
+	AlgorithmECDH_ES_A128KW Algorithm = -29
+
+	// AlgorithmECDH_ES_A192KW is ECDH ES w/ HKDF and AES Key Wrap w/ 192-bit key.
+	// import github.com/shogo82148/goat/jwa/akw
+	AlgorithmECDH_ES_A192KW Algorithm = -30
+
+	// AlgorithmECDH_ES_A256KW is ECDH ES w/ HKDF and AES Key Wrap w/ 256-bit key.
+	// import github.com/shogo82148/goat/jwa/akw
+	AlgorithmECDH_ES_A256KW Algorithm = -31
+
+	// AlgorithmECDH_SS_A128KW is ECDH SS w/ HKDF and AES Key Wrap w/ 128-bit key.
+	// import github.com/shogo82148/goat/jwa/akw
+	AlgorithmECDH_SS_A128KW Algorithm = -32
+
+	// AlgorithmECDH_SS_A192KW is ECDH SS w/ HKDF and AES Key Wrap w/ 192-bit key.
+	// import github.com/shogo82148/goat/jwa/akw
+	AlgorithmECDH_SS_A192KW Algorithm = -33
+
+	// AlgorithmECDH_SS_A256KW is ECDH SS w/ HKDF and AES Key Wrap w/ 256-bit key.
+	// import github.com/shogo82148/goat/jwa/akw
+	AlgorithmECDH_SS_A256KW Algorithm = -34
+
+	// AlgorithmSHA1 is SHA-1 Hash.
+	// It is used only for the "x5t" header parameter.
+	AlgorithmSHA1 Algorithm = -14
+
+	// AlgorithmSHA256_64 is SHA-2 256-bit Hash truncated to 64-bits.
+	AlgorithmSHA256_64 Algorithm = -15
+
+	// AlgorithmSHA256 is SHA-2 256-bit Hash.
+	AlgorithmSHA256 Algorithm = -16
+
+	// AlgorithmSHA512_256 is SHA-2 512-bit Hash truncated to 256-bits.
+	AlgorithmSHA512_256 Algorithm = -17
+
+	// AlgorithmSHA384 is SHA-2 384-bit Hash.
+	AlgorithmSHA384 Algorithm = -43
+
+	// AlgorithmSHA512 is SHA-2 512-bit Hash.
+	AlgorithmSHA512 Algorithm = -44
+)
+
+// String returns the name of the algorithm.
+func (alg Algorithm) String() string {
+	switch alg {
+	case AlgorithmES256:
+		return "ES256"
+	case AlgorithmES384:
+		return "ES384"
+	case AlgorithmES512:
+		return "ES512"
+	case AlgorithmES256K:
+		return "ES256K"
+	case AlgorithmEdDSA:
+		return "EdDSA"
+	case AlgorithmEd25519:
+		return "Ed25519"
+	case AlgorithmEd448:
+		return "Ed448"
+	case AlgorithmPS256:
+		return "PS256"
+	case AlgorithmPS384:
+		return "PS384"
+	case AlgorithmPS512:
+		return "PS512"
+	case AlgorithmRS256:
+		return "RS256"
+	case AlgorithmRS384:
+		return "RS384"
+	case AlgorithmRS512:
+		return "RS512"
+	case AlgorithmHMAC256_64:
+		return "HMAC 256/64"
+	case AlgorithmHMAC256_256:
+		return "HMAC 256/256"
+	case AlgorithmHMAC384_384:
+		return "HMAC 384/384"
+	case AlgorithmHMAC512_512:
+		return "HMAC 512/512"
+	case AlgorithmAESMAC128_64:
+		return "AES-MAC 128/64"
+	case AlgorithmAESMAC256_64:
+		return "AES-MAC 256/64"
+	case AlgorithmAESMAC128_128:
+		return "AES-MAC 128/128"
+	case AlgorithmAESMAC256_128:
+		return "AES-MAC 256/128"
+	case AlgorithmA128GCM:
+		return "A128GCM"
+	case AlgorithmA192GCM:
+		return "A192GCM"
+	case AlgorithmA256GCM:
+		return "A256GCM"
+	case AlgorithmDirect:
+		return "direct"
+	case AlgorithmA128KW:
+		return "A128KW"
+	case AlgorithmA192KW:
+		return "A192KW"
+	case AlgorithmA256KW:
+		return "A256KW"
+	case AlgorithmECDH_ES_HKDF_256:
+		return "ECDH-ES + HKDF-256"
+	case AlgorithmECDH_ES_HKDF_512:
+		return "ECDH-ES + HKDF-512"
+	case AlgorithmECDH_SS_HKDF_256:
+		return "ECDH-SS + HKDF-256"
+	case AlgorithmECDH_SS_HKDF_512:
+		return "ECDH-SS + HKDF-512"
+	case AlgorithmECDH_ES_A128KW:
+		return "ECDH-ES + A128KW"
+	case AlgorithmECDH_ES_A192KW:
+		return "ECDH-ES + A192KW"
+	case AlgorithmECDH_ES_A256KW:
+		return "ECDH-ES + A256KW"
+	case AlgorithmECDH_SS_A128KW:
+		return "ECDH-SS + A128KW"
+	case AlgorithmECDH_SS_A192KW:
+		return "ECDH-SS + A192KW"
+	case AlgorithmECDH_SS_A256KW:
+		return "ECDH-SS + A256KW"
+	case AlgorithmSHA1:
+		return "SHA-1"
+	case AlgorithmSHA256_64:
+		return "SHA-256/64"
+	case AlgorithmSHA256:
+		return "SHA-256"
+	case AlgorithmSHA512_256:
+		return "SHA-512/256"
+	case AlgorithmSHA384:
+		return "SHA-384"
+	case AlgorithmSHA512:
+		return "SHA-512"
+	default:
+		return fmt.Sprintf("Algorithm(%d)", int64(alg))
+	}
+}
+
+// SignatureAlgorithm returns the equivalent signature algorithm of JWA.
+// It returns [jwa.SignatureAlgorithmUnknown] if alg is not a signature algorithm.
+func (alg Algorithm) SignatureAlgorithm() jwa.SignatureAlgorithm {
+	switch alg {
+	case AlgorithmES256:
+		return jwa.SignatureAlgorithmES256
+	case AlgorithmES384:
+		return jwa.SignatureAlgorithmES384
+	case AlgorithmES512:
+		return jwa.SignatureAlgorithmES512
+	case AlgorithmES256K:
+		return jwa.SignatureAlgorithmES256K
+	case AlgorithmEdDSA:
+		return jwa.SignatureAlgorithmEdDSA //nolint:staticcheck // EdDSA is still used in COSE.
+	case AlgorithmEd25519:
+		return jwa.SignatureAlgorithmEd25519
+	case AlgorithmEd448:
+		return jwa.SignatureAlgorithmEd448
+	case AlgorithmPS256:
+		return jwa.SignatureAlgorithmPS256
+	case AlgorithmPS384:
+		return jwa.SignatureAlgorithmPS384
+	case AlgorithmPS512:
+		return jwa.SignatureAlgorithmPS512
+	case AlgorithmRS256:
+		return jwa.SignatureAlgorithmRS256
+	case AlgorithmRS384:
+		return jwa.SignatureAlgorithmRS384
+	case AlgorithmRS512:
+		return jwa.SignatureAlgorithmRS512
+	default:
+		return jwa.SignatureAlgorithmUnknown
+	}
+}
+
+// KeyManagementAlgorithm returns the equivalent key management algorithm of JWA.
+// It returns [jwa.KeyManagementAlgorithmUnknown] if alg is not a key management algorithm
+// or if JWA has no equivalent algorithm.
+// Note that the ECDH algorithms of COSE have no equivalent,
+// because JWA uses Concat KDF instead of HKDF.
+func (alg Algorithm) KeyManagementAlgorithm() jwa.KeyManagementAlgorithm {
+	switch alg {
+	case AlgorithmDirect:
+		return jwa.KeyManagementAlgorithmDirect
+	case AlgorithmA128KW:
+		return jwa.KeyManagementAlgorithmA128KW
+	case AlgorithmA192KW:
+		return jwa.KeyManagementAlgorithmA192KW
+	case AlgorithmA256KW:
+		return jwa.KeyManagementAlgorithmA256KW
+	default:
+		return jwa.KeyManagementAlgorithmUnknown
+	}
+}
+
+// EncryptionAlgorithm returns the equivalent content encryption algorithm of JWA.
+// It returns an empty string if alg is not a content encryption algorithm.
+func (alg Algorithm) EncryptionAlgorithm() jwa.EncryptionAlgorithm {
+	switch alg {
+	case AlgorithmA128GCM:
+		return jwa.EncryptionAlgorithmA128GCM
+	case AlgorithmA192GCM:
+		return jwa.EncryptionAlgorithmA192GCM
+	case AlgorithmA256GCM:
+		return jwa.EncryptionAlgorithmA256GCM
+	default:
+		return ""
+	}
+}
+
+// KeyAlgorithm returns the equivalent algorithm of JWA
+// that is used in the "alg" parameter of JWK.
+// It returns an empty string if JWA has no equivalent algorithm.
+func (alg Algorithm) KeyAlgorithm() jwa.KeyAlgorithm {
+	if sig := alg.SignatureAlgorithm(); sig != jwa.SignatureAlgorithmUnknown {
+		return sig.KeyAlgorithm()
+	}
+	if kma := alg.KeyManagementAlgorithm(); kma != jwa.KeyManagementAlgorithmUnknown {
+		return kma.KeyAlgorithm()
+	}
+	if enc := alg.EncryptionAlgorithm(); enc != "" {
+		return jwa.KeyAlgorithm(enc)
+	}
+	switch alg {
+	case AlgorithmHMAC256_256:
+		return jwa.SignatureAlgorithmHS256.KeyAlgorithm()
+	case AlgorithmHMAC384_384:
+		return jwa.SignatureAlgorithmHS384.KeyAlgorithm()
+	case AlgorithmHMAC512_512:
+		return jwa.SignatureAlgorithmHS512.KeyAlgorithm()
+	default:
+		return ""
+	}
+}
+
+// knownAlgorithms is the list of the algorithms that this package knows.
+var knownAlgorithms = []Algorithm{
+	AlgorithmES256, AlgorithmES384, AlgorithmES512, AlgorithmES256K,
+	AlgorithmEdDSA, AlgorithmEd25519, AlgorithmEd448,
+	AlgorithmPS256, AlgorithmPS384, AlgorithmPS512,
+	AlgorithmRS256, AlgorithmRS384, AlgorithmRS512,
+	AlgorithmHMAC256_64, AlgorithmHMAC256_256, AlgorithmHMAC384_384, AlgorithmHMAC512_512,
+	AlgorithmAESMAC128_64, AlgorithmAESMAC256_64, AlgorithmAESMAC128_128, AlgorithmAESMAC256_128,
+	AlgorithmA128GCM, AlgorithmA192GCM, AlgorithmA256GCM,
+	AlgorithmDirect, AlgorithmA128KW, AlgorithmA192KW, AlgorithmA256KW,
+	AlgorithmECDH_ES_HKDF_256, AlgorithmECDH_ES_HKDF_512, AlgorithmECDH_SS_HKDF_256, AlgorithmECDH_SS_HKDF_512,
+	AlgorithmECDH_ES_A128KW, AlgorithmECDH_ES_A192KW, AlgorithmECDH_ES_A256KW,
+	AlgorithmECDH_SS_A128KW, AlgorithmECDH_SS_A192KW, AlgorithmECDH_SS_A256KW,
+}
+
+// AlgorithmFromKeyAlgorithm returns the algorithm equivalent to alg of JWA.
+// It is the inverse of [Algorithm.KeyAlgorithm].
+// It returns [AlgorithmReserved] and false if COSE has no equivalent algorithm.
+func AlgorithmFromKeyAlgorithm(alg jwa.KeyAlgorithm) (Algorithm, bool) {
+	if alg == "" {
+		return AlgorithmReserved, false
+	}
+	for _, a := range knownAlgorithms {
+		if a.KeyAlgorithm() == alg {
+			return a, true
+		}
+	}
+	return AlgorithmReserved, false
+}
+
+// isMAC reports whether alg is a MAC algorithm.
+func (alg Algorithm) isMAC() bool {
+	switch alg {
+	case AlgorithmHMAC256_64, AlgorithmHMAC256_256, AlgorithmHMAC384_384, AlgorithmHMAC512_512,
+		AlgorithmAESMAC128_64, AlgorithmAESMAC256_64, AlgorithmAESMAC128_128, AlgorithmAESMAC256_128:
+		return true
+	default:
+		return false
+	}
+}
+
+// keySize returns the byte size of the key used by alg.
+// It returns 0 if the size is unknown.
+func (alg Algorithm) keySize() int {
+	switch alg {
+	case AlgorithmA128GCM, AlgorithmA128KW, AlgorithmAESMAC128_64, AlgorithmAESMAC128_128:
+		return 16
+	case AlgorithmA192GCM, AlgorithmA192KW:
+		return 24
+	case AlgorithmA256GCM, AlgorithmA256KW, AlgorithmAESMAC256_64, AlgorithmAESMAC256_128,
+		AlgorithmHMAC256_64, AlgorithmHMAC256_256:
+		return 32
+	case AlgorithmHMAC384_384:
+		return 48
+	case AlgorithmHMAC512_512:
+		return 64
+	default:
+		return 0
+	}
+}
@@ -1,6 +1,10 @@
+// Package cose handles CBOR Object Signing and Encryption (COSE) defined in RFC 9052.
 package cose
 
 import (
+	"bytes"
+	"fmt"
+
 	"github.com/shogo82148/go-cbor"
 )
 
@@ -23,3 +27,177 @@ const (
 	// TagNumberCOSEMac0 is the CBOR tag number for COSE_Mac0.
 	TagNumberCOSEMac0 cbor.TagNumber = 17
 )
+
+// context strings of Sig_structure defined in RFC 9052 Section 4.4.
+const (
+	contextSignature  = "Signature"
+	contextSignature1 = "Signature1"
+)
+
+// context strings of MAC_structure defined in RFC 9052 Section 6.3.
+const (
+	contextMAC  = "MAC"
+	contextMAC0 = "MAC0"
+)
+
+// context strings of Enc_structure defined in RFC 9052 Section 5.3.
+const (
+	contextEncrypt  = "Encrypt"
+	contextEncrypt0 = "Encrypt0"
+)
+
+// decodeMessage decodes a COSE message.
+// The message may be tagged by tag or may be untagged.
+// It returns the elements of the message array.
+func decodeMessage(data []byte, tag cbor.TagNumber, n int) ([]any, error) {
+	var v any
+	dec := cbor.NewDecoder(bytes.NewReader(data))
+	dec.UseAnyKey()
+	dec.UseInteger()
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("cose: failed to parse message: %w", err)
+	}
+
+	switch t := v.(type) {
+	case cbor.Tag:
+		if t.Number != tag {
+			return nil, fmt.Errorf("cose: unexpected tag number: %d", t.Number)
+		}
+		v = t.Content
+	case *cbor.Tag:
+		if t.Number != tag {
+			return nil, fmt.Errorf("cose: unexpected tag number: %d", t.Number)
+		}
+		v = t.Content
+	case cbor.RawTag:
+		if t.Number != tag {
+			return nil, fmt.Errorf("cose: unexpected tag number: %d", t.Number)
+		}
+		opts := cbor.Options{
+			UseAnyKey:  true,
+			UseInteger: true,
+		}
+		v = nil
+		if err := opts.Unmarshal(t.Content, &v); err != nil {
+			return nil, fmt.Errorf("cose: failed to parse message: %w", err)
+		}
+	}
+
+	array, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("cose: invalid type of message: %T", v)
+	}
+	if len(array) != n {
+		return nil, fmt.Errorf("cose: invalid length of message: %d", len(array))
+	}
+	return array, nil
+}
+
+// decodeHeaders decodes the protected header and the unprotected header of a COSE message.
+func decodeHeaders(rawProtected, rawUnprotected any) (protected, unprotected *Header, serialized []byte, err error) {
+	serialized, ok := rawProtected.([]byte)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("cose: invalid type of protected header: %T", rawProtected)
+	}
+	protected, err = decodeProtectedHeader(serialized)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	unprotectedMap, ok := rawUnprotected.(map[any]any)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("cose: invalid type of unprotected header: %T", rawUnprotected)
+	}
+	unprotected, err = decodeHeader(unprotectedMap)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if err := checkHeaders(protected, unprotected); err != nil {
+		return nil, nil, nil, err
+	}
+	return protected, unprotected, serialized, nil
+}
+
+// decodePayload decodes the payload of a COSE message.
+// nil is decoded as detached content.
+func decodePayload(v any) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return v, nil
+	default:
+		return nil, fmt.Errorf("cose: invalid type of payload: %T", v)
+	}
+}
+
+// encodePayload encodes the payload of a COSE message.
+// nil payload means detached content and it is encoded as nil.
+func encodePayload(payload []byte) any {
+	if payload == nil {
+		return nil
+	}
+	return payload
+}
+
+// sigStructure returns the ToBeSigned value defined in RFC 9052 Section 4.4.
+// signProtected is ignored if context is "Signature1".
+func sigStructure(context string, bodyProtected, signProtected, external, payload []byte) ([]byte, error) {
+	var structure []any
+	if context == contextSignature1 {
+		structure = []any{
+			context,
+			emptyProtected(bodyProtected),
+			nonNil(external),
+			nonNil(payload),
+		}
+	} else {
+		structure = []any{
+			context,
+			emptyProtected(bodyProtected),
+			emptyProtected(signProtected),
+			nonNil(external),
+			nonNil(payload),
+		}
+	}
+	return cbor.Marshal(structure)
+}
+
+// macStructure returns the ToBeMaced value defined in RFC 9052 Section 6.3.
+func macStructure(context string, protected, external, payload []byte) ([]byte, error) {
+	return cbor.Marshal([]any{
+		context,
+		emptyProtected(protected),
+		nonNil(external),
+		nonNil(payload),
+	})
+}
+
+// encStructure returns the additional authenticated data defined in RFC 9052 Section 5.3.
+func encStructure(context string, protected, external []byte) ([]byte, error) {
+	return cbor.Marshal([]any{
+		context,
+		emptyProtected(protected),
+		nonNil(external),
+	})
+}
+
+// nonNil converts nil to an empty byte slice,
+// because a nil byte slice may be encoded as CBOR null.
+func nonNil(b []byte) []byte {
+	if b == nil {
+		return []byte{}
+	}
+	return b
+}
+
+// emptyProtected returns the serialized protected header for the structures to be signed, MACed, or encrypted.
+// RFC 9052 says that a zero-length byte string is used if there are no protected attributes,
+// so a serialized empty map is also treated as a zero-length byte string.
+func emptyProtected(b []byte) []byte {
+	if len(b) == 0 || (len(b) == 1 && b[0] == 0xa0) {
+		return []byte{}
+	}
+	return b
+}
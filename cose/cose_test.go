@@ -0,0 +1,274 @@
+package cose
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shogo82148/goat/jwk"
+)
+
+// testVector is a test vector of https://github.com/cose-wg/Examples
+type testVector struct {
+	Title         string            `json:"title"`
+	Fail          bool              `json:"fail"`
+	Input         testVectorInput   `json:"input"`
+	Intermediates testIntermediates `json:"intermediates"`
+	Output        testVectorOutput  `json:"output"`
+}
+
+type testVectorInput struct {
+	Plaintext string         `json:"plaintext"`
+	Sign0     *testSigner    `json:"sign0"`
+	Sign      *testSign      `json:"sign"`
+	Mac       *testMac       `json:"mac"`
+	Mac0      *testMac       `json:"mac0"`
+	Enveloped *testEnveloped `json:"enveloped"`
+	Encrypted *testEnveloped `json:"encrypted"`
+	Failures  map[string]any `json:"failures"`
+	RNGStream []string       `json:"rng_stream"`
+}
+
+type testSign struct {
+	Protected   map[string]any `json:"protected"`
+	Unprotected map[string]any `json:"unprotected"`
+	Signers     []*testSigner  `json:"signers"`
+
+	Countersign  *testCountersign `json:"countersign"`
+	Countersign0 *testCountersign `json:"countersign0"`
+}
+
+type testSigner struct {
+	Key         map[string]any `json:"key"`
+	Protected   map[string]any `json:"protected"`
+	Unprotected map[string]any `json:"unprotected"`
+	Alg         string         `json:"alg"`
+	External    string         `json:"external"`
+
+	Countersign  *testCountersign `json:"countersign"`
+	Countersign0 *testCountersign `json:"countersign0"`
+}
+
+type testMac struct {
+	Alg         string           `json:"alg"`
+	Protected   map[string]any   `json:"protected"`
+	Unprotected map[string]any   `json:"unprotected"`
+	External    string           `json:"external"`
+	Recipients  []*testRecipient `json:"recipients"`
+
+	Countersign  *testCountersign `json:"countersign"`
+	Countersign0 *testCountersign `json:"countersign0"`
+}
+
+type testEnveloped struct {
+	Alg         string           `json:"alg"`
+	Protected   map[string]any   `json:"protected"`
+	Unprotected map[string]any   `json:"unprotected"`
+	External    string           `json:"external"`
+	Unsent      map[string]any   `json:"unsent"`
+	Recipients  []*testRecipient `json:"recipients"`
+
+	Countersign  *testCountersign `json:"countersign"`
+	Countersign0 *testCountersign `json:"countersign0"`
+}
+
+type testRecipient struct {
+	Key         map[string]any `json:"key"`
+	SenderKey   map[string]any `json:"sender_key"`
+	Protected   map[string]any `json:"protected"`
+	Unprotected map[string]any `json:"unprotected"`
+	Alg         string         `json:"alg"`
+
+	Countersign  *testCountersign `json:"countersign"`
+	Countersign0 *testCountersign `json:"countersign0"`
+}
+
+type testCountersign struct {
+	Signers []*testCountersigner `json:"signers"`
+}
+
+type testCountersigner struct {
+	Key         map[string]any `json:"key"`
+	Protected   map[string]any `json:"protected"`
+	Unprotected map[string]any `json:"unprotected"`
+	Unsent      map[string]any `json:"unsent"`
+}
+
+type testIntermediates struct {
+	ToBeSignHex string `json:"ToBeSign_hex"`
+	ToMacHex    string `json:"ToMac_hex"`
+	CEKHex      string `json:"CEK_hex"`
+	AADHex      string `json:"AAD_hex"`
+	Signers     []struct {
+		ToBeSignHex    string                 `json:"ToBeSign_hex"`
+		Countersigners []testCountersignValue `json:"countersigners"`
+		Countersign0   []testCountersignValue `json:"countersign0"`
+	} `json:"signers"`
+	Recipients []struct {
+		Countersigners []testCountersignValue `json:"countersigners"`
+		Countersign0   []testCountersignValue `json:"countersign0"`
+	} `json:"recipients"`
+	Countersigners []testCountersignValue `json:"countersigners"`
+	Countersign0   []testCountersignValue `json:"countersign0"`
+}
+
+type testCountersignValue struct {
+	ToBeSignHex string `json:"ToBeSign_hex"`
+}
+
+type testVectorOutput struct {
+	CBOR string `json:"cbor"`
+}
+
+// readTestVectors reads all test vectors in the directory.
+func readTestVectors(t *testing.T, dir string) map[string]*testVector {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join("testdata", "cose-wg-examples", dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vectors := make(map[string]*testVector, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var v testVector
+		if err := json.Unmarshal(data, &v); err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		vectors[filepath.Base(file)] = &v
+	}
+	return vectors
+}
+
+// parseTestKey parses a key in test vectors.
+// The key is a JWK, but some parameters are encoded in hex with the "_hex" suffix.
+func parseTestKey(t *testing.T, raw map[string]any) *jwk.Key {
+	t.Helper()
+
+	key := make(map[string]any, len(raw))
+	for k, v := range raw {
+		name, ok := strings.CutSuffix(k, "_hex")
+		if !ok {
+			key[k] = v
+			continue
+		}
+		data, err := hex.DecodeString(v.(string))
+		if err != nil {
+			t.Fatal(err)
+		}
+		key[strings.ToLower(name)] = base64.RawURLEncoding.EncodeToString(data)
+	}
+	k, err := jwk.ParseMap(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// testAlgorithms maps the algorithm names in test vectors to COSE algorithms.
+var testAlgorithms = map[string]Algorithm{
+	"ES256":       AlgorithmES256,
+	"ES384":       AlgorithmES384,
+	"ES512":       AlgorithmES512,
+	"EdDSA":       AlgorithmEdDSA,
+	"RSA-PSS-256": AlgorithmPS256,
+	"RSA-PSS-384": AlgorithmPS384,
+	"RSA-PSS-512": AlgorithmPS512,
+
+	"HS256/64":        AlgorithmHMAC256_64,
+	"HS256":           AlgorithmHMAC256_256,
+	"HS384":           AlgorithmHMAC384_384,
+	"HS512":           AlgorithmHMAC512_512,
+	"AES-MAC-128/64":  AlgorithmAESMAC128_64,
+	"AES-MAC-256/64":  AlgorithmAESMAC256_64,
+	"AES-MAC-128/128": AlgorithmAESMAC128_128,
+	"AES-MAC-256/128": AlgorithmAESMAC256_128,
+
+	"A128GCM": AlgorithmA128GCM,
+	"A192GCM": AlgorithmA192GCM,
+	"A256GCM": AlgorithmA256GCM,
+
+	"direct":         AlgorithmDirect,
+	"A128KW":         AlgorithmA128KW,
+	"A192KW":         AlgorithmA192KW,
+	"A256KW":         AlgorithmA256KW,
+	"ECDH-ES":        AlgorithmECDH_ES_HKDF_256,
+	"ECDH-ES-512":    AlgorithmECDH_ES_HKDF_512,
+	"ECDH-SS":        AlgorithmECDH_SS_HKDF_256,
+	"ECDH-SS-256":    AlgorithmECDH_SS_HKDF_256,
+	"ECDH-SS-512":    AlgorithmECDH_SS_HKDF_512,
+	"ECDH-ES-A128KW": AlgorithmECDH_ES_A128KW,
+	"ECDH-ES-A192KW": AlgorithmECDH_ES_A192KW,
+	"ECDH-ES-A256KW": AlgorithmECDH_ES_A256KW,
+	"ECDH-SS-A128KW": AlgorithmECDH_SS_A128KW,
+	"ECDH-SS-A192KW": AlgorithmECDH_SS_A192KW,
+	"ECDH-SS-A256KW": AlgorithmECDH_SS_A256KW,
+}
+
+// algorithm returns the algorithm of the signer.
+func (s *testSigner) algorithm() Algorithm {
+	if s.Alg != "" {
+		return testAlgorithms[s.Alg]
+	}
+	if alg, ok := s.Protected["alg"].(string); ok {
+		return testAlgorithms[alg]
+	}
+	if alg, ok := s.Unprotected["alg"].(string); ok {
+		return testAlgorithms[alg]
+	}
+	return AlgorithmReserved
+}
+
+// algorithm returns the algorithm of the recipient.
+func (r *testRecipient) algorithm() Algorithm {
+	if r.Alg != "" {
+		return testAlgorithms[r.Alg]
+	}
+	if alg, ok := r.Protected["alg"].(string); ok {
+		return testAlgorithms[alg]
+	}
+	if alg, ok := r.Unprotected["alg"].(string); ok {
+		return testAlgorithms[alg]
+	}
+	return AlgorithmReserved
+}
+
+// algorithm returns the algorithm of the countersigner.
+func (s *testCountersigner) algorithm() Algorithm {
+	for _, h := range []map[string]any{s.Protected, s.Unprotected, s.Unsent} {
+		if alg, ok := h["alg"].(string); ok {
+			return testAlgorithms[alg]
+		}
+	}
+	return AlgorithmReserved
+}
+
+// algorithm returns the content encryption algorithm of the message.
+func (e *testEnveloped) algorithm() Algorithm {
+	if e.Alg != "" {
+		return testAlgorithms[e.Alg]
+	}
+	if alg, ok := e.Protected["alg"].(string); ok {
+		return testAlgorithms[alg]
+	}
+	if alg, ok := e.Unprotected["alg"].(string); ok {
+		return testAlgorithms[alg]
+	}
+	return AlgorithmReserved
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
@@ -0,0 +1,415 @@
+package cose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/shogo82148/go-cbor"
+	"github.com/shogo82148/goat/jwa"
+	"github.com/shogo82148/goat/sig"
+)
+
+// context strings of Countersign_structure defined in RFC 9338 Section 3.3.
+const (
+	// version 1 contexts are defined in RFC 8152.
+	// They are only used to check the test vectors.
+	contextCounterSignature  = "CounterSignature"
+	contextCounterSignature0 = "CounterSignature0"
+
+	contextCounterSignatureV2  = "CounterSignatureV2"
+	contextCounterSignature0V2 = "CounterSignature0V2"
+)
+
+// Countersignature is a COSE_Countersignature defined in RFC 9338 Section 3.1.
+type Countersignature struct {
+	// Protected is the protected header of the countersigner.
+	Protected *Header
+
+	// Unprotected is the unprotected header of the countersigner.
+	Unprotected *Header
+
+	// Signature is the countersignature.
+	Signature []byte
+
+	rawProtected []byte
+}
+
+// CountersignTarget is a COSE structure that can be countersigned.
+// It is implemented by *Sign1Message, *SignMessage, *Signature, *Encrypt0Message,
+// *EncryptMessage, *Mac0Message, *MacMessage, *Recipient and *Countersignature.
+type CountersignTarget interface {
+	// countersignFields returns the fields of the target structure used in Countersign_structure,
+	// and the unprotected header where the countersignatures are placed.
+	countersignFields() (bodyProtected, payload []byte, otherFields [][]byte, unprotected *Header, err error)
+}
+
+func decodeCountersignature(v any) (*Countersignature, error) {
+	array, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("cose: invalid type of countersignature: %T", v)
+	}
+	if len(array) != 3 {
+		return nil, fmt.Errorf("cose: invalid length of countersignature: %d", len(array))
+	}
+
+	protected, unprotected, rawProtected, err := decodeHeaders(array[0], array[1])
+	if err != nil {
+		return nil, err
+	}
+	signature, ok := array[2].([]byte)
+	if !ok {
+		return nil, fmt.Errorf("cose: invalid type of countersignature: %T", array[2])
+	}
+	return &Countersignature{
+		Protected:    protected,
+		Unprotected:  unprotected,
+		Signature:    signature,
+		rawProtected: rawProtected,
+	}, nil
+}
+
+// decodeCountersignatures decodes the value of the "Countersignature version 2" header parameter.
+// It is a single COSE_Countersignature or an array of COSE_Countersignature.
+func decodeCountersignatures(v any) ([]*Countersignature, error) {
+	array, ok := v.([]any)
+	if !ok || len(array) == 0 {
+		return nil, fmt.Errorf("cose: invalid type of countersignature: %T", v)
+	}
+
+	// a single COSE_Countersignature starts with the serialized protected header.
+	if _, ok := array[0].([]byte); ok {
+		cs, err := decodeCountersignature(array)
+		if err != nil {
+			return nil, err
+		}
+		return []*Countersignature{cs}, nil
+	}
+
+	list := make([]*Countersignature, 0, len(array))
+	for _, v := range array {
+		cs, err := decodeCountersignature(v)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, cs)
+	}
+	return list, nil
+}
+
+func (cs *Countersignature) encode() ([]any, error) {
+	rawProtected, err := cs.serializedProtected()
+	if err != nil {
+		return nil, err
+	}
+	unprotected, err := encodeHeader(cs.Unprotected)
+	if err != nil {
+		return nil, err
+	}
+	return []any{
+		rawProtected,
+		unprotected,
+		cs.Signature,
+	}, nil
+}
+
+// encodeCountersignatures encodes the value of the "Countersignature version 2" header parameter.
+func encodeCountersignatures(list []*Countersignature) (any, error) {
+	if len(list) == 1 {
+		return list[0].encode()
+	}
+	array := make([]any, 0, len(list))
+	for _, cs := range list {
+		v, err := cs.encode()
+		if err != nil {
+			return nil, err
+		}
+		array = append(array, v)
+	}
+	return array, nil
+}
+
+func (cs *Countersignature) serializedProtected() ([]byte, error) {
+	if cs.rawProtected != nil {
+		return cs.rawProtected, nil
+	}
+	return encodeProtectedHeader(cs.Protected)
+}
+
+// countersignStructure returns the ToBeSigned value defined in RFC 9338 Section 3.3.
+func countersignStructure(context string, bodyProtected, signProtected, external, payload []byte, otherFields [][]byte) ([]byte, error) {
+	structure := []any{
+		context,
+		emptyProtected(bodyProtected),
+		emptyProtected(signProtected),
+		nonNil(external),
+		nonNil(payload),
+	}
+	if len(otherFields) > 0 {
+		fields := make([]any, 0, len(otherFields))
+		for _, f := range otherFields {
+			fields = append(fields, nonNil(f))
+		}
+		structure = append(structure, fields)
+	}
+	return cbor.Marshal(structure)
+}
+
+// Countersign computes a countersignature of target with key,
+// and adds it to the unprotected header of target.
+// protected and unprotected are the headers of the countersigner.
+// external is the externally supplied data.
+// target must be signed, MACed or encrypted before it is countersigned.
+func Countersign(target CountersignTarget, protected, unprotected *Header, key sig.SigningKey, external []byte) (*Countersignature, error) {
+	if protected == nil {
+		protected = NewHeader()
+	}
+	if unprotected == nil {
+		unprotected = NewHeader()
+	}
+	if err := checkHeaders(protected, unprotected); err != nil {
+		return nil, err
+	}
+	if lookupAlgorithm(protected, unprotected) == AlgorithmReserved {
+		return nil, errors.New("cose: failed to countersign: algorithm is not set")
+	}
+
+	bodyProtected, payload, otherFields, targetUnprotected, err := target.countersignFields()
+	if err != nil {
+		return nil, err
+	}
+	signProtected, err := encodeProtectedHeader(protected)
+	if err != nil {
+		return nil, err
+	}
+	toBeSigned, err := countersignStructure(contextCounterSignatureV2, bodyProtected, signProtected, external, payload, otherFields)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := key.Sign(toBeSigned)
+	if err != nil {
+		return nil, fmt.Errorf("cose: failed to countersign: %w", err)
+	}
+
+	cs := &Countersignature{
+		Protected:    protected,
+		Unprotected:  unprotected,
+		Signature:    signature,
+		rawProtected: signProtected,
+	}
+	targetUnprotected.countersignatures = append(targetUnprotected.countersignatures, cs)
+	return cs, nil
+}
+
+// Verify verifies the countersignature cs of target with key.
+// external is the externally supplied data.
+func (cs *Countersignature) Verify(target CountersignTarget, key sig.SigningKey, external []byte) error {
+	bodyProtected, payload, otherFields, _, err := target.countersignFields()
+	if err != nil {
+		return err
+	}
+	signProtected, err := cs.serializedProtected()
+	if err != nil {
+		return err
+	}
+	toBeSigned, err := countersignStructure(contextCounterSignatureV2, bodyProtected, signProtected, external, payload, otherFields)
+	if err != nil {
+		return err
+	}
+	if err := key.Verify(toBeSigned, cs.Signature); err != nil {
+		return fmt.Errorf("cose: failed to verify: %w", err)
+	}
+	return nil
+}
+
+// Countersign0 computes an abbreviated countersignature of target with key,
+// and sets it to the unprotected header of target.
+// The algorithm and the key are not included in the message,
+// so the verifier must know them by the context.
+// external is the externally supplied data.
+func Countersign0(target CountersignTarget, key sig.SigningKey, external []byte) error {
+	bodyProtected, payload, otherFields, targetUnprotected, err := target.countersignFields()
+	if err != nil {
+		return err
+	}
+	toBeSigned, err := countersignStructure(contextCounterSignature0V2, bodyProtected, nil, external, payload, otherFields)
+	if err != nil {
+		return err
+	}
+	signature, err := key.Sign(toBeSigned)
+	if err != nil {
+		return fmt.Errorf("cose: failed to countersign: %w", err)
+	}
+	targetUnprotected.countersignature0 = signature
+	return nil
+}
+
+// VerifyCountersignature0 verifies the abbreviated countersignature of target with key.
+// external is the externally supplied data.
+func VerifyCountersignature0(target CountersignTarget, key sig.SigningKey, external []byte) error {
+	bodyProtected, payload, otherFields, targetUnprotected, err := target.countersignFields()
+	if err != nil {
+		return err
+	}
+	signature := targetUnprotected.countersignature0
+	if signature == nil {
+		return errors.New("cose: countersignature0 is not found")
+	}
+	toBeSigned, err := countersignStructure(contextCounterSignature0V2, bodyProtected, nil, external, payload, otherFields)
+	if err != nil {
+		return err
+	}
+	if err := key.Verify(toBeSigned, signature); err != nil {
+		return fmt.Errorf("cose: failed to verify: %w", err)
+	}
+	return nil
+}
+
+// VerifyCountersignature verifies the countersignature cs of target.
+// The algorithm and the key are chosen by the AlgorithmVerifier and the KeyFinder
+// from the headers of the countersigner.
+// external is the externally supplied data.
+func (v *Verifier) VerifyCountersignature(ctx context.Context, target CountersignTarget, cs *Countersignature, external []byte) error {
+	_ = v._NamedFieldsRequired
+	if v.AlgorithmVerifier == nil || v.KeyFinder == nil {
+		return errors.New("cose: verifier is not configured")
+	}
+
+	alg := lookupAlgorithm(cs.Protected, cs.Unprotected)
+	if alg.SignatureAlgorithm() == jwa.SignatureAlgorithmUnknown {
+		return errVerifyFailed
+	}
+	if err := v.AlgorithmVerifier.VerifyAlgorithm(ctx, alg); err != nil {
+		return errVerifyFailed
+	}
+	key, err := v.KeyFinder.FindKey(ctx, cs.Protected, cs.Unprotected)
+	if err != nil {
+		return errVerifyFailed
+	}
+	if err := cs.Verify(target, key, external); err != nil {
+		return errVerifyFailed
+	}
+	return nil
+}
+
+// RFC 9338 Section 3.3: other_fields contains the third to Nth byte string fields in the target structure.
+
+func (msg *Sign1Message) countersignFields() (bodyProtected, payload []byte, otherFields [][]byte, unprotected *Header, err error) {
+	if msg.Signature == nil {
+		return nil, nil, nil, nil, errors.New("cose: the message is not signed")
+	}
+	bodyProtected, err = msg.serializedProtected()
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if msg.Unprotected == nil {
+		msg.Unprotected = NewHeader()
+	}
+	return bodyProtected, msg.Payload, [][]byte{msg.Signature}, msg.Unprotected, nil
+}
+
+func (msg *SignMessage) countersignFields() (bodyProtected, payload []byte, otherFields [][]byte, unprotected *Header, err error) {
+	if len(msg.Signatures) == 0 {
+		return nil, nil, nil, nil, errors.New("cose: the message is not signed")
+	}
+	bodyProtected, err = msg.serializedProtected()
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if msg.Unprotected == nil {
+		msg.Unprotected = NewHeader()
+	}
+	return bodyProtected, msg.Payload, nil, msg.Unprotected, nil
+}
+
+func (s *Signature) countersignFields() (bodyProtected, payload []byte, otherFields [][]byte, unprotected *Header, err error) {
+	if s.Signature == nil {
+		return nil, nil, nil, nil, errors.New("cose: the signature is not computed")
+	}
+	bodyProtected, err = s.serializedProtected()
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if s.Unprotected == nil {
+		s.Unprotected = NewHeader()
+	}
+	return bodyProtected, s.Signature, nil, s.Unprotected, nil
+}
+
+func (msg *Encrypt0Message) countersignFields() (bodyProtected, payload []byte, otherFields [][]byte, unprotected *Header, err error) {
+	if msg.Ciphertext == nil {
+		return nil, nil, nil, nil, errors.New("cose: the message is not encrypted")
+	}
+	bodyProtected, err = msg.serializedProtected()
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if msg.Unprotected == nil {
+		msg.Unprotected = NewHeader()
+	}
+	return bodyProtected, msg.Ciphertext, nil, msg.Unprotected, nil
+}
+
+func (msg *EncryptMessage) countersignFields() (bodyProtected, payload []byte, otherFields [][]byte, unprotected *Header, err error) {
+	if msg.Ciphertext == nil {
+		return nil, nil, nil, nil, errors.New("cose: the message is not encrypted")
+	}
+	bodyProtected, err = msg.serializedProtected()
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if msg.Unprotected == nil {
+		msg.Unprotected = NewHeader()
+	}
+	return bodyProtected, msg.Ciphertext, nil, msg.Unprotected, nil
+}
+
+func (msg *Mac0Message) countersignFields() (bodyProtected, payload []byte, otherFields [][]byte, unprotected *Header, err error) {
+	if msg.Tag == nil {
+		return nil, nil, nil, nil, errors.New("cose: the message is not MACed")
+	}
+	bodyProtected, err = msg.serializedProtected()
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if msg.Unprotected == nil {
+		msg.Unprotected = NewHeader()
+	}
+	return bodyProtected, msg.Payload, [][]byte{msg.Tag}, msg.Unprotected, nil
+}
+
+func (msg *MacMessage) countersignFields() (bodyProtected, payload []byte, otherFields [][]byte, unprotected *Header, err error) {
+	if msg.Tag == nil {
+		return nil, nil, nil, nil, errors.New("cose: the message is not MACed")
+	}
+	bodyProtected, err = msg.serializedProtected()
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if msg.Unprotected == nil {
+		msg.Unprotected = NewHeader()
+	}
+	return bodyProtected, msg.Payload, [][]byte{msg.Tag}, msg.Unprotected, nil
+}
+
+func (r *Recipient) countersignFields() (bodyProtected, payload []byte, otherFields [][]byte, unprotected *Header, err error) {
+	bodyProtected, err = r.serializedProtected()
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if r.Unprotected == nil {
+		r.Unprotected = NewHeader()
+	}
+	return bodyProtected, r.Ciphertext, nil, r.Unprotected, nil
+}
+
+func (cs *Countersignature) countersignFields() (bodyProtected, payload []byte, otherFields [][]byte, unprotected *Header, err error) {
+	bodyProtected, err = cs.serializedProtected()
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if cs.Unprotected == nil {
+		cs.Unprotected = NewHeader()
+	}
+	return bodyProtected, cs.Signature, nil, cs.Unprotected, nil
+}
@@ -0,0 +1,435 @@
+package cose
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+
+	"github.com/shogo82148/goat/internal/cborutils"
+	_ "github.com/shogo82148/goat/jwa/eddsa"
+	_ "github.com/shogo82148/goat/jwa/es"
+	"github.com/shogo82148/goat/jwk"
+	"github.com/shogo82148/goat/sig"
+)
+
+// countersignTestCase is a countersigned structure in the test vectors.
+type countersignTestCase struct {
+	name         string
+	target       CountersignTarget
+	unprotected  *Header
+	countersign  *testCountersign
+	countersign0 *testCountersign
+	want         []testCountersignValue
+	want0        []testCountersignValue
+}
+
+// countersignTestCases returns the countersigned structures in the test vector.
+func countersignTestCases(t *testing.T, v *testVector) []countersignTestCase {
+	t.Helper()
+	data := mustHex(t, v.Output.CBOR)
+	input := v.Input
+	var cases []countersignTestCase
+	switch {
+	case input.Sign0 != nil:
+		msg, err := ParseSign1(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cases = append(cases, countersignTestCase{
+			name: "message", target: msg, unprotected: msg.Unprotected,
+			countersign: input.Sign0.Countersign, countersign0: input.Sign0.Countersign0,
+			want: v.Intermediates.Countersigners, want0: v.Intermediates.Countersign0,
+		})
+	case input.Sign != nil:
+		msg, err := ParseSign(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cases = append(cases, countersignTestCase{
+			name: "message", target: msg, unprotected: msg.Unprotected,
+			countersign: input.Sign.Countersign, countersign0: input.Sign.Countersign0,
+			want: v.Intermediates.Countersigners, want0: v.Intermediates.Countersign0,
+		})
+		signer := input.Sign.Signers[0]
+		cases = append(cases, countersignTestCase{
+			name: "signer", target: msg.Signatures[0], unprotected: msg.Signatures[0].Unprotected,
+			countersign: signer.Countersign, countersign0: signer.Countersign0,
+			want: v.Intermediates.Signers[0].Countersigners, want0: v.Intermediates.Signers[0].Countersign0,
+		})
+	case input.Mac0 != nil:
+		msg, err := ParseMac0(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cases = append(cases, countersignTestCase{
+			name: "message", target: msg, unprotected: msg.Unprotected,
+			countersign: input.Mac0.Countersign, countersign0: input.Mac0.Countersign0,
+			want: v.Intermediates.Countersigners, want0: v.Intermediates.Countersign0,
+		})
+	case input.Mac != nil:
+		msg, err := ParseMac(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cases = append(cases, countersignTestCase{
+			name: "message", target: msg, unprotected: msg.Unprotected,
+			countersign: input.Mac.Countersign, countersign0: input.Mac.Countersign0,
+			want: v.Intermediates.Countersigners, want0: v.Intermediates.Countersign0,
+		})
+	case input.Encrypted != nil:
+		msg, err := ParseEncrypt0(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cases = append(cases, countersignTestCase{
+			name: "message", target: msg, unprotected: msg.Unprotected,
+			countersign: input.Encrypted.Countersign, countersign0: input.Encrypted.Countersign0,
+			want: v.Intermediates.Countersigners, want0: v.Intermediates.Countersign0,
+		})
+	case input.Enveloped != nil:
+		msg, err := ParseEncrypt(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cases = append(cases, countersignTestCase{
+			name: "message", target: msg, unprotected: msg.Unprotected,
+			countersign: input.Enveloped.Countersign, countersign0: input.Enveloped.Countersign0,
+			want: v.Intermediates.Countersigners, want0: v.Intermediates.Countersign0,
+		})
+		recipient := input.Enveloped.Recipients[0]
+		cases = append(cases, countersignTestCase{
+			name: "recipient", target: msg.Recipients[0], unprotected: msg.Recipients[0].Unprotected,
+			countersign: recipient.Countersign, countersign0: recipient.Countersign0,
+			want: v.Intermediates.Recipients[0].Countersigners, want0: v.Intermediates.Recipients[0].Countersign0,
+		})
+	}
+	return cases
+}
+
+// The test vectors use version 1 countersignatures defined in RFC 8152.
+// The labels and the context strings are different from version 2,
+// but they are useful to check the fields of the target structures.
+func TestCountersignature_V1(t *testing.T) {
+	const (
+		headerLabelCountersignatureV1  = 7
+		headerLabelCountersignature0V1 = 9
+	)
+
+	for _, dir := range []string{"countersign", "countersign1"} {
+		for name, v := range readTestVectors(t, dir) {
+			t.Run(dir+"/"+name, func(t *testing.T) {
+				for _, tc := range countersignTestCases(t, v) {
+					bodyProtected, payload, _, _, err := tc.target.countersignFields()
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					if tc.countersign != nil {
+						raw, ok := tc.unprotected.Raw[cborutils.IntegerFromInt64(headerLabelCountersignatureV1)]
+						if !ok {
+							t.Fatalf("%s: countersignature is not found", tc.name)
+						}
+						list, err := decodeCountersignatures(raw)
+						if err != nil {
+							t.Fatal(err)
+						}
+						if len(list) != len(tc.countersign.Signers) {
+							t.Fatalf("%s: unexpected number of countersignatures: %d", tc.name, len(list))
+						}
+						for i, cs := range list {
+							toBeSigned, err := countersignStructure(contextCounterSignature, bodyProtected, cs.rawProtected, nil, payload, nil)
+							if err != nil {
+								t.Fatal(err)
+							}
+							if want := mustHex(t, tc.want[i].ToBeSignHex); !bytes.Equal(toBeSigned, want) {
+								t.Errorf("%s: unexpected ToBeSign: got %x, want %x", tc.name, toBeSigned, want)
+							}
+							signer := tc.countersign.Signers[i]
+							key := signer.algorithm().SignatureAlgorithm().New().NewSigningKey(parseTestKey(t, signer.Key))
+							if err := key.Verify(toBeSigned, cs.Signature); err != nil {
+								t.Errorf("%s: failed to verify: %v", tc.name, err)
+							}
+						}
+					}
+
+					if tc.countersign0 != nil {
+						signature, ok := tc.unprotected.Raw[cborutils.IntegerFromInt64(headerLabelCountersignature0V1)].([]byte)
+						if !ok {
+							t.Fatalf("%s: countersignature0 is not found", tc.name)
+						}
+						toBeSigned, err := countersignStructure(contextCounterSignature0, bodyProtected, nil, nil, payload, nil)
+						if err != nil {
+							t.Fatal(err)
+						}
+						want := mustHex(t, tc.want0[0].ToBeSignHex)
+						if name == "mac0-01.json" {
+							// the intermediate value of countersign1/mac0-01.json is not the one signed in the output.
+							// it is built as a full countersignature, i.e. the "CounterSignature" context
+							// and the protected header of the signer {1: -8 (EdDSA)}.
+							// check the difference, and verify the output signature with our structure below.
+							got, err := countersignStructure(contextCounterSignature, bodyProtected, mustHex(t, "a10127"), nil, payload, nil)
+							if err != nil {
+								t.Fatal(err)
+							}
+							if !bytes.Equal(got, want) {
+								t.Errorf("%s: unexpected ToBeSign: got %x, want %x", tc.name, got, want)
+							}
+						} else if !bytes.Equal(toBeSigned, want) {
+							t.Errorf("%s: unexpected ToBeSign: got %x, want %x", tc.name, toBeSigned, want)
+						}
+						signer := tc.countersign0.Signers[0]
+						key := signer.algorithm().SignatureAlgorithm().New().NewSigningKey(parseTestKey(t, signer.Key))
+						if err := key.Verify(toBeSigned, signature); err != nil {
+							t.Errorf("%s: failed to verify: %v", tc.name, err)
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+// testCountersignTarget is a countersign target for testing.
+type testCountersignTarget struct {
+	target CountersignTarget
+
+	// marshal encodes the message that contains the target.
+	marshal func() ([]byte, error)
+
+	// parse parses the encoded message, and returns the target in it.
+	parse func(data []byte) CountersignTarget
+}
+
+// newTestCountersignTargets returns signed, MACed and encrypted messages for testing countersignatures.
+func newTestCountersignTargets(t *testing.T, signer sig.SigningKey) map[string]func() testCountersignTarget {
+	t.Helper()
+	payload := []byte("This is the content.")
+	cek := []byte("0123456789abcdef")
+	macKey := AlgorithmHMAC256_256.NewMACKey(symmetricKey("0123456789abcdef0123456789abcdef"))
+	kw := AlgorithmA128KW.NewKeyWrapper(symmetricKey("0123456789abcdef"))
+
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	parse := func(target CountersignTarget, err error) CountersignTarget {
+		t.Helper()
+		must(err)
+		return target
+	}
+	edHeader := func() *Header {
+		h := NewHeader()
+		h.SetAlgorithm(AlgorithmEdDSA)
+		return h
+	}
+	kwHeader := func() *Header {
+		h := NewHeader()
+		h.SetAlgorithm(AlgorithmA128KW)
+		return h
+	}
+
+	return map[string]func() testCountersignTarget{
+		"Sign1": func() testCountersignTarget {
+			msg := NewSign1Message(payload)
+			msg.Protected.SetAlgorithm(AlgorithmEdDSA)
+			must(msg.Sign(signer, nil))
+			return testCountersignTarget{msg, msg.MarshalCBOR, func(data []byte) CountersignTarget { return parse(ParseSign1(data)) }}
+		},
+		"Sign": func() testCountersignTarget {
+			msg := NewSignMessage(payload)
+			must(msg.AddSignature(edHeader(), nil, signer, nil))
+			return testCountersignTarget{msg, msg.MarshalCBOR, func(data []byte) CountersignTarget { return parse(ParseSign(data)) }}
+		},
+		"Signature": func() testCountersignTarget {
+			msg := NewSignMessage(payload)
+			must(msg.AddSignature(edHeader(), nil, signer, nil))
+			return testCountersignTarget{msg.Signatures[0], msg.MarshalCBOR, func(data []byte) CountersignTarget {
+				msg, err := ParseSign(data)
+				must(err)
+				return msg.Signatures[0]
+			}}
+		},
+		"Mac0": func() testCountersignTarget {
+			msg := NewMac0Message(payload)
+			msg.Protected.SetAlgorithm(AlgorithmHMAC256_256)
+			must(msg.Sign(macKey, nil))
+			return testCountersignTarget{msg, msg.MarshalCBOR, func(data []byte) CountersignTarget { return parse(ParseMac0(data)) }}
+		},
+		"Mac": func() testCountersignTarget {
+			msg := NewMacMessage(payload)
+			msg.Protected.SetAlgorithm(AlgorithmHMAC256_256)
+			must(msg.Sign([]byte("0123456789abcdef0123456789abcdef"), nil))
+			must(msg.AddRecipient(nil, kwHeader(), kw))
+			return testCountersignTarget{msg, msg.MarshalCBOR, func(data []byte) CountersignTarget { return parse(ParseMac(data)) }}
+		},
+		"Encrypt0": func() testCountersignTarget {
+			msg := NewEncrypt0Message()
+			msg.Protected.SetAlgorithm(AlgorithmA128GCM)
+			must(msg.Encrypt(cek, payload, nil))
+			return testCountersignTarget{msg, msg.MarshalCBOR, func(data []byte) CountersignTarget { return parse(ParseEncrypt0(data)) }}
+		},
+		"Encrypt": func() testCountersignTarget {
+			msg := NewEncryptMessage()
+			msg.Protected.SetAlgorithm(AlgorithmA128GCM)
+			must(msg.Encrypt(cek, payload, nil))
+			must(msg.AddRecipient(nil, kwHeader(), kw))
+			return testCountersignTarget{msg, msg.MarshalCBOR, func(data []byte) CountersignTarget { return parse(ParseEncrypt(data)) }}
+		},
+		"Recipient": func() testCountersignTarget {
+			msg := NewEncryptMessage()
+			msg.Protected.SetAlgorithm(AlgorithmA128GCM)
+			must(msg.Encrypt(cek, payload, nil))
+			must(msg.AddRecipient(nil, kwHeader(), kw))
+			return testCountersignTarget{msg.Recipients[0], msg.MarshalCBOR, func(data []byte) CountersignTarget {
+				msg, err := ParseEncrypt(data)
+				must(err)
+				return msg.Recipients[0]
+			}}
+		},
+	}
+}
+
+func TestCountersign(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := jwk.NewPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := AlgorithmEdDSA.SignatureAlgorithm().New().NewSigningKey(key)
+	external := []byte("external")
+
+	for name, newTarget := range newTestCountersignTargets(t, signer) {
+		t.Run(name, func(t *testing.T) {
+			tt := newTarget()
+			target := tt.target
+
+			// a timestamp authority countersigns the message.
+			protected := NewHeader()
+			protected.SetAlgorithm(AlgorithmEdDSA)
+			unprotected := NewHeader()
+			unprotected.SetKeyID([]byte("tsa"))
+			if _, err := Countersign(target, protected, unprotected, signer, external); err != nil {
+				t.Fatal(err)
+			}
+			if err := Countersign0(target, signer, external); err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := tt.marshal()
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed := tt.parse(data)
+			_, _, _, h, err := parsed.countersignFields()
+			if err != nil {
+				t.Fatal(err)
+			}
+			list := h.Countersignatures()
+			if len(list) != 1 {
+				t.Fatalf("unexpected number of countersignatures: %d", len(list))
+			}
+			cs := list[0]
+			if err := cs.Verify(parsed, signer, external); err != nil {
+				t.Error(err)
+			}
+			if err := cs.Verify(parsed, signer, nil); err == nil {
+				t.Error("want error, but not")
+			}
+			if err := VerifyCountersignature0(parsed, signer, external); err != nil {
+				t.Error(err)
+			}
+
+			v := &Verifier{
+				AlgorithmVerifier: AllowedAlgorithms{AlgorithmEdDSA},
+				KeyFinder: FindKeyFunc(func(ctx context.Context, protected, unprotected *Header) (sig.SigningKey, error) {
+					if string(lookupKeyID(protected, unprotected)) != "tsa" {
+						return nil, errVerifyFailed
+					}
+					return signer, nil
+				}),
+			}
+			if err := v.VerifyCountersignature(t.Context(), parsed, cs, external); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestCountersign_Multiple(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := jwk.NewPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := AlgorithmEdDSA.SignatureAlgorithm().New().NewSigningKey(key)
+	protected := NewHeader()
+	protected.SetAlgorithm(AlgorithmEdDSA)
+
+	msg := NewSign1Message([]byte("This is the content."))
+	msg.Protected.SetAlgorithm(AlgorithmEdDSA)
+	if err := msg.Sign(signer, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Countersign(msg, protected, nil, signer, nil); err != nil {
+		t.Fatal(err)
+	}
+	cs, err := Countersign(msg, protected, nil, signer, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// countersign the countersignature.
+	if _, err := Countersign(cs, protected, nil, signer, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := msg.MarshalCBOR()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseSign1(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := parsed.Unprotected.Countersignatures()
+	if len(list) != 2 {
+		t.Fatalf("unexpected number of countersignatures: %d", len(list))
+	}
+	for _, cs := range list {
+		if err := cs.Verify(parsed, signer, nil); err != nil {
+			t.Error(err)
+		}
+	}
+	nested := list[1].Unprotected.Countersignatures()
+	if len(nested) != 1 {
+		t.Fatalf("unexpected number of nested countersignatures: %d", len(nested))
+	}
+	if err := nested[0].Verify(list[1], signer, nil); err != nil {
+		t.Error(err)
+	}
+
+	// the countersignature covers the signature of COSE_Sign1.
+	parsed.Signature[0] ^= 0xff
+	if err := list[0].Verify(parsed, signer, nil); err == nil {
+		t.Error("want error, but not")
+	}
+}
+
+func TestCountersign_NotSigned(t *testing.T) {
+	protected := NewHeader()
+	protected.SetAlgorithm(AlgorithmEdDSA)
+	msg := NewSign1Message([]byte("This is the content."))
+	if _, err := Countersign(msg, protected, nil, sig.NewErrorKey(errVerifyFailed), nil); err == nil {
+		t.Error("want error, but not")
+	}
+}
@@ -0,0 +1,394 @@
+package cose
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/hkdf"
+	"crypto/rand"
+	_ "crypto/sha256" // for crypto.SHA256
+	_ "crypto/sha512" // for crypto.SHA512
+	"errors"
+	"fmt"
+
+	"github.com/shogo82148/go-cbor"
+	"github.com/shogo82148/goat/internal/cborutils"
+	"github.com/shogo82148/goat/jwk/jwktypes"
+	"github.com/shogo82148/goat/keymanage"
+	"github.com/shogo82148/goat/x25519"
+	"github.com/shogo82148/goat/x448"
+)
+
+// NewKeyWrapper returns a key wrapper for alg.
+// alg must be one of the key management algorithms defined in RFC 9053 Section 6.
+//
+// For direct and AES Key Wrap, key is the shared secret.
+// For ECDH, key is the key of the recipient.
+// Its public key is used for encryption, and its private key is used for decryption.
+// Use [Algorithm.NewECDHKeyWrapper] to encrypt with ECDH-SS.
+func (alg Algorithm) NewKeyWrapper(key keymanage.Key) keymanage.KeyWrapper {
+	switch alg {
+	case AlgorithmDirect, AlgorithmA128KW, AlgorithmA192KW, AlgorithmA256KW:
+		kma := alg.KeyManagementAlgorithm()
+		if !kma.Available() {
+			return keymanage.NewInvalidKeyWrapper(fmt.Errorf("cose: requested key management algorithm %v is not available", alg))
+		}
+		return kma.New().NewKeyWrapper(key)
+	default:
+		return alg.NewECDHKeyWrapper(key, nil)
+	}
+}
+
+// NewECDHKeyWrapper returns a key wrapper for ECDH defined in RFC 9053 Section 6.3.
+// recipient is the key of the recipient.
+// Its public key is used for encryption, and its private key is used for decryption.
+// sender is the static key of the sender for ECDH-SS, and it is ignored for ECDH-ES.
+// The private key of sender is used for encryption.
+// On decryption, the public key of sender is used
+// if the recipient doesn't contain the static key of the sender.
+func (alg Algorithm) NewECDHKeyWrapper(recipient, sender keymanage.Key) keymanage.KeyWrapper {
+	w := &ecdhKeyWrapper{
+		alg:       alg,
+		canDerive: jwktypes.CanUseFor(recipient, jwktypes.KeyOpDeriveKey),
+	}
+	switch alg {
+	case AlgorithmECDH_ES_HKDF_256:
+		w.hash, w.kw = crypto.SHA256, AlgorithmDirect
+	case AlgorithmECDH_ES_HKDF_512:
+		w.hash, w.kw = crypto.SHA512, AlgorithmDirect
+	case AlgorithmECDH_SS_HKDF_256:
+		w.hash, w.kw, w.static = crypto.SHA256, AlgorithmDirect, true
+	case AlgorithmECDH_SS_HKDF_512:
+		w.hash, w.kw, w.static = crypto.SHA512, AlgorithmDirect, true
+	case AlgorithmECDH_ES_A128KW:
+		w.hash, w.kw = crypto.SHA256, AlgorithmA128KW
+	case AlgorithmECDH_ES_A192KW:
+		w.hash, w.kw = crypto.SHA256, AlgorithmA192KW
+	case AlgorithmECDH_ES_A256KW:
+		w.hash, w.kw = crypto.SHA256, AlgorithmA256KW
+	case AlgorithmECDH_SS_A128KW:
+		w.hash, w.kw, w.static = crypto.SHA256, AlgorithmA128KW, true
+	case AlgorithmECDH_SS_A192KW:
+		w.hash, w.kw, w.static = crypto.SHA256, AlgorithmA192KW, true
+	case AlgorithmECDH_SS_A256KW:
+		w.hash, w.kw, w.static = crypto.SHA256, AlgorithmA256KW, true
+	default:
+		return keymanage.NewInvalidKeyWrapper(fmt.Errorf("cose: unknown key management algorithm: %v", alg))
+	}
+
+	w.recipientPriv = recipient.PrivateKey()
+	w.recipientPub = recipient.PublicKey()
+	if w.static && sender != nil {
+		w.senderPriv = sender.PrivateKey()
+		w.senderPub = sender.PublicKey()
+		w.canDerive = w.canDerive && jwktypes.CanUseFor(sender, jwktypes.KeyOpDeriveKey)
+	}
+	return w
+}
+
+var _ keymanage.KeyWrapper = (*ecdhKeyWrapper)(nil)
+var _ keymanage.KeyDeriver = (*ecdhKeyWrapper)(nil)
+
+// ecdhKeyWrapper is a key wrapper for ECDH w/ HKDF.
+type ecdhKeyWrapper struct {
+	alg  Algorithm
+	hash crypto.Hash
+
+	// kw is the key wrap algorithm.
+	// It is AlgorithmDirect if the derived key is used directly.
+	kw Algorithm
+
+	// static reports whether the sender uses a static key.
+	static bool
+
+	recipientPriv any
+	recipientPub  any
+	senderPriv    any
+	senderPub     any
+	canDerive     bool
+}
+
+// WrapKey implements [github.com/shogo82148/goat/keymanage.KeyWrapper].
+// It wraps cek with the key derived by ECDH.
+func (w *ecdhKeyWrapper) WrapKey(cek []byte, opts any) ([]byte, error) {
+	if w.kw == AlgorithmDirect {
+		return nil, fmt.Errorf("cose: %v derives the key directly, use DeriveKey instead", w.alg)
+	}
+	kek, err := w.deriveSenderKey(opts)
+	if err != nil {
+		return nil, err
+	}
+	return w.kw.NewKeyWrapper(symmetricKey(kek)).WrapKey(cek, nil)
+}
+
+// UnwrapKey implements [github.com/shogo82148/goat/keymanage.KeyWrapper].
+// It unwraps data with the key derived by ECDH.
+// For direct key agreement, data must be empty and the derived key is returned.
+func (w *ecdhKeyWrapper) UnwrapKey(data []byte, opts any) ([]byte, error) {
+	key, err := w.deriveRecipientKey(opts)
+	if err != nil {
+		return nil, err
+	}
+	if w.kw == AlgorithmDirect {
+		if len(data) != 0 {
+			return nil, errors.New("cose: the encrypted key must be empty for direct key agreement")
+		}
+		return key, nil
+	}
+	return w.kw.NewKeyWrapper(symmetricKey(key)).UnwrapKey(data, nil)
+}
+
+// DeriveKey implements [github.com/shogo82148/goat/keymanage.KeyDeriver].
+// It derives the key for direct key agreement.
+func (w *ecdhKeyWrapper) DeriveKey(opts any) (cek, encryptedCEK []byte, err error) {
+	if w.kw != AlgorithmDirect {
+		return nil, nil, fmt.Errorf("cose: %v wraps the key, use WrapKey instead", w.alg)
+	}
+	cek, err = w.deriveSenderKey(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cek, []byte{}, nil
+}
+
+// deriveSenderKey derives the key on the sender side.
+// It updates the unprotected header of the recipient to inform the recipient of the sender's key.
+func (w *ecdhKeyWrapper) deriveSenderKey(opts any) ([]byte, error) {
+	if !w.canDerive {
+		return nil, errors.New("cose: key derive operation is not allowed")
+	}
+	o, ok := opts.(*recipientOptions)
+	if !ok {
+		return nil, fmt.Errorf("cose: unexpected options: %T", opts)
+	}
+	if w.recipientPub == nil {
+		return nil, errors.New("cose: the public key of the recipient is required")
+	}
+
+	var priv any
+	if w.static {
+		if w.senderPriv == nil {
+			return nil, errors.New("cose: the static private key of the sender is required")
+		}
+		priv = w.senderPriv
+		_, hasKey := lookupRaw(o.protected, o.unprotected, headerLabelStaticKey)
+		_, hasKeyID := lookupRaw(o.protected, o.unprotected, headerLabelStaticKeyID)
+		if !hasKey && !hasKeyID {
+			key, err := encodeECDHPublicKey(w.senderPub)
+			if err != nil {
+				return nil, err
+			}
+			setRaw(o.unprotected, headerLabelStaticKey, key)
+		}
+
+		// RFC 9053 Section 6.3.1: a nonce or a salt is used to generate different keys.
+		_, hasSalt := lookupRaw(o.protected, o.unprotected, headerLabelSalt)
+		_, hasNonce := lookupRaw(o.protected, o.unprotected, headerLabelPartyUNonce)
+		if !hasSalt && !hasNonce {
+			nonce := make([]byte, 32)
+			if _, err := rand.Read(nonce); err != nil {
+				return nil, err
+			}
+			setRaw(o.unprotected, headerLabelPartyUNonce, nonce)
+		}
+	} else {
+		ephemeralPriv, ephemeralPub, err := generateEphemeralKey(w.recipientPub)
+		if err != nil {
+			return nil, err
+		}
+		key, err := encodeECDHPublicKey(ephemeralPub)
+		if err != nil {
+			return nil, err
+		}
+		setRaw(o.unprotected, headerLabelEphemeralKey, key)
+		priv = ephemeralPriv
+	}
+
+	z, err := deriveZ(priv, w.recipientPub)
+	if err != nil {
+		return nil, err
+	}
+	return w.kdf(z, o)
+}
+
+// deriveRecipientKey derives the key on the recipient side.
+func (w *ecdhKeyWrapper) deriveRecipientKey(opts any) ([]byte, error) {
+	if !w.canDerive {
+		return nil, errors.New("cose: key derive operation is not allowed")
+	}
+	o, ok := opts.(*recipientOptions)
+	if !ok {
+		return nil, fmt.Errorf("cose: unexpected options: %T", opts)
+	}
+	if w.recipientPriv == nil {
+		return nil, errors.New("cose: the private key of the recipient is required")
+	}
+
+	var pub any
+	label := int64(headerLabelEphemeralKey)
+	if w.static {
+		label = headerLabelStaticKey
+	}
+	if raw, ok := lookupRaw(o.protected, o.unprotected, label); ok {
+		m, ok := raw.(map[any]any)
+		if !ok {
+			return nil, fmt.Errorf("cose: invalid type of the sender key: %T", raw)
+		}
+		key, err := ParseMap(m)
+		if err != nil {
+			return nil, fmt.Errorf("cose: failed to parse the sender key: %w", err)
+		}
+		pub = key.PublicKey()
+	} else if w.static && w.senderPub != nil {
+		pub = w.senderPub
+	} else {
+		return nil, errors.New("cose: the public key of the sender is not found")
+	}
+
+	z, err := deriveZ(w.recipientPriv, pub)
+	if err != nil {
+		return nil, err
+	}
+	return w.kdf(z, o)
+}
+
+// kdf derives the key from the shared secret z using HKDF defined in RFC 9053 Section 5.1.
+func (w *ecdhKeyWrapper) kdf(z []byte, o *recipientOptions) ([]byte, error) {
+	alg := w.kw
+	if alg == AlgorithmDirect {
+		alg = o.alg
+	}
+	size := alg.keySize()
+	if size == 0 {
+		return nil, fmt.Errorf("cose: unknown key size of the algorithm: %v", alg)
+	}
+
+	context, err := kdfContext(alg, size, o)
+	if err != nil {
+		return nil, err
+	}
+	var salt []byte
+	if raw, ok := lookupRaw(o.protected, o.unprotected, headerLabelSalt); ok {
+		salt, ok = raw.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("cose: invalid type of salt: %T", raw)
+		}
+	}
+	if !w.hash.Available() {
+		return nil, errors.New("cose: requested hash function is not available")
+	}
+	return hkdf.Key(w.hash.New, z, salt, string(context), size)
+}
+
+// kdfContext returns the COSE_KDF_Context defined in RFC 9053 Section 5.2.
+func kdfContext(alg Algorithm, size int, o *recipientOptions) ([]byte, error) {
+	partyInfo := func(identity, nonce, other int64) []any {
+		info := make([]any, 3)
+		for i, label := range [...]int64{identity, nonce, other} {
+			if v, ok := lookupRaw(o.protected, o.unprotected, label); ok {
+				info[i] = v
+			}
+		}
+		return info
+	}
+	return cbor.Marshal([]any{
+		cborutils.IntegerFromInt64(int64(alg)),
+		partyInfo(headerLabelPartyUIdentity, headerLabelPartyUNonce, headerLabelPartyUOther),
+		partyInfo(headerLabelPartyVIdentity, headerLabelPartyVNonce, headerLabelPartyVOther),
+		[]any{
+			cbor.Integer{Value: uint64(size * 8)},
+			emptyProtected(o.rawProtected),
+		},
+	})
+}
+
+// setRaw sets the raw value of label in h.
+func setRaw(h *Header, label int64, v any) {
+	if h.Raw == nil {
+		h.Raw = map[any]any{}
+	}
+	h.Raw[cborutils.IntegerFromInt64(label)] = v
+}
+
+// generateEphemeralKey generates a new key pair on the same curve as pub.
+func generateEphemeralKey(pub any) (priv, ephemeralPub any, err error) {
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		key, err := ecdsa.GenerateKey(pub.Curve, rand.Reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		return key, &key.PublicKey, nil
+	case x25519.PublicKey:
+		pub, priv, err := x25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		return priv, pub, nil
+	case x448.PublicKey:
+		pub, priv, err := x448.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		return priv, pub, nil
+	default:
+		return nil, nil, fmt.Errorf("cose: unknown public key type: %T", pub)
+	}
+}
+
+// encodeECDHPublicKey encodes the public key as a COSE_Key.
+func encodeECDHPublicKey(pub any) (map[any]any, error) {
+	key, err := NewPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	return key.encode()
+}
+
+// deriveZ computes the shared secret of ECDH.
+func deriveZ(priv, pub any) ([]byte, error) {
+	switch priv := priv.(type) {
+	case *ecdsa.PrivateKey:
+		pubkey, ok := pub.(*ecdsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("cose: want *ecdsa.PublicKey but got %T", pub)
+		}
+		privECDH, err := priv.ECDH()
+		if err != nil {
+			return nil, err
+		}
+		pubECDH, err := pubkey.ECDH()
+		if err != nil {
+			return nil, err
+		}
+		return privECDH.ECDH(pubECDH)
+	case *ecdh.PrivateKey:
+		pubkey, ok := pub.(*ecdh.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("cose: want *ecdh.PublicKey but got %T", pub)
+		}
+		return priv.ECDH(pubkey)
+	case x25519.PrivateKey:
+		pubkey, ok := pub.(x25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("cose: want x25519.PublicKey but got %T", pub)
+		}
+		privECDH, err := priv.ECDH()
+		if err != nil {
+			return nil, err
+		}
+		pubECDH, err := pubkey.ECDH()
+		if err != nil {
+			return nil, err
+		}
+		return privECDH.ECDH(pubECDH)
+	case x448.PrivateKey:
+		pubkey, ok := pub.(x448.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("cose: want x448.PublicKey but got %T", pub)
+		}
+		return x448.X448(priv[:x448.SeedSize], pubkey)
+	default:
+		return nil, fmt.Errorf("cose: unknown private key type: %T", priv)
+	}
+}
@@ -0,0 +1,215 @@
+package cose
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/shogo82148/go-cbor"
+	"github.com/shogo82148/goat/keymanage"
+)
+
+// EncryptMessage is a COSE_Encrypt message defined in RFC 9052 Section 5.1.
+type EncryptMessage struct {
+	// Protected is the protected header.
+	Protected *Header
+
+	// Unprotected is the unprotected header.
+	Unprotected *Header
+
+	// Ciphertext is the encrypted content including the authentication tag.
+	// nil means that the ciphertext is detached.
+	Ciphertext []byte
+
+	// BaseIV is the context IV used with the "Partial IV" header parameter.
+	// It is not transmitted in the message.
+	// See RFC 9052 Section 3.1.
+	BaseIV []byte
+
+	// Recipients is the list of the recipients.
+	Recipients []*Recipient
+
+	rawProtected []byte
+	cek          []byte
+}
+
+// NewEncryptMessage returns a new COSE_Encrypt message that has no content.
+func NewEncryptMessage() *EncryptMessage {
+	return &EncryptMessage{
+		Protected:   NewHeader(),
+		Unprotected: NewHeader(),
+	}
+}
+
+// ParseEncrypt parses a tagged or untagged COSE_Encrypt message.
+func ParseEncrypt(data []byte) (*EncryptMessage, error) {
+	var msg EncryptMessage
+	if err := msg.UnmarshalCBOR(data); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// UnmarshalCBOR implements [cbor.Unmarshaler].
+// It accepts both tagged and untagged COSE_Encrypt messages.
+func (msg *EncryptMessage) UnmarshalCBOR(data []byte) error {
+	array, err := decodeMessage(data, TagNumberCOSEEncrypt, 4)
+	if err != nil {
+		return err
+	}
+
+	protected, unprotected, rawProtected, err := decodeHeaders(array[0], array[1])
+	if err != nil {
+		return err
+	}
+	ciphertext, err := decodePayload(array[2])
+	if err != nil {
+		return err
+	}
+	recipients, err := decodeRecipients(array[3])
+	if err != nil {
+		return err
+	}
+
+	*msg = EncryptMessage{
+		Protected:    protected,
+		Unprotected:  unprotected,
+		Ciphertext:   ciphertext,
+		Recipients:   recipients,
+		rawProtected: rawProtected,
+	}
+	return nil
+}
+
+// MarshalCBOR implements [cbor.CBORMarshaler].
+// It encodes the message as a tagged COSE_Encrypt message.
+func (msg *EncryptMessage) MarshalCBOR() ([]byte, error) {
+	array, err := msg.encode()
+	if err != nil {
+		return nil, err
+	}
+	return cbor.Marshal(cbor.Tag{
+		Number:  TagNumberCOSEEncrypt,
+		Content: array,
+	})
+}
+
+// MarshalCBORUntagged encodes the message as an untagged COSE_Encrypt message.
+func (msg *EncryptMessage) MarshalCBORUntagged() ([]byte, error) {
+	array, err := msg.encode()
+	if err != nil {
+		return nil, err
+	}
+	return cbor.Marshal(array)
+}
+
+func (msg *EncryptMessage) encode() ([]any, error) {
+	if len(msg.Recipients) == 0 {
+		return nil, errors.New("cose: no recipients")
+	}
+	rawProtected, err := msg.serializedProtected()
+	if err != nil {
+		return nil, err
+	}
+	unprotected, err := encodeHeader(msg.Unprotected)
+	if err != nil {
+		return nil, err
+	}
+	recipients, err := encodeRecipients(msg.Recipients)
+	if err != nil {
+		return nil, err
+	}
+	return []any{
+		rawProtected,
+		unprotected,
+		encodePayload(msg.Ciphertext),
+		recipients,
+	}, nil
+}
+
+func (msg *EncryptMessage) serializedProtected() ([]byte, error) {
+	if msg.rawProtected != nil {
+		return msg.rawProtected, nil
+	}
+	return encodeProtectedHeader(msg.Protected)
+}
+
+// Encrypt encrypts plaintext with cek.
+// The content encryption algorithm is specified by the "alg" header parameter.
+// If the IV is not set, a new IV is generated and set to the unprotected header.
+// external is the externally supplied data described in RFC 9052 Section 4.3.
+// Use AddRecipient to distribute cek to the recipients.
+// Encrypt fails if the message is already encrypted, because it would reuse the IV with the same key.
+func (msg *EncryptMessage) Encrypt(cek, plaintext, external []byte) error {
+	if msg.rawProtected != nil {
+		return errors.New("cose: failed to encrypt: the message is already encrypted")
+	}
+	if msg.cek != nil && !bytes.Equal(msg.cek, cek) {
+		return errors.New("cose: failed to encrypt: cek doesn't match the derived key")
+	}
+	rawProtected, ciphertext, err := encryptContent(contextEncrypt, msg.Protected, msg.Unprotected, msg.BaseIV, cek, plaintext, external)
+	if err != nil {
+		return err
+	}
+	msg.rawProtected = rawProtected
+	msg.Ciphertext = ciphertext
+	msg.cek = cek
+	return nil
+}
+
+// AddRecipient adds a new recipient that receives the key used by Encrypt.
+// kw wraps the key for the recipient.
+func (msg *EncryptMessage) AddRecipient(protected, unprotected *Header, kw keymanage.KeyWrapper) error {
+	if msg.cek == nil {
+		return errors.New("cose: the message is not encrypted")
+	}
+	alg := lookupAlgorithm(msg.Protected, msg.Unprotected)
+	r, err := newRecipient(alg, protected, unprotected, kw, msg.cek)
+	if err != nil {
+		return err
+	}
+	msg.Recipients = append(msg.Recipients, r)
+	return nil
+}
+
+// DeriveKey adds a new recipient that uses direct key agreement,
+// and returns the content encryption key agreed with the recipient.
+// The key must be passed to Encrypt, so DeriveKey must be called before Encrypt.
+// The content encryption algorithm must be set before calling DeriveKey,
+// because the derived key depends on it.
+func (msg *EncryptMessage) DeriveKey(protected, unprotected *Header, kd keymanage.KeyDeriver) ([]byte, error) {
+	if msg.cek != nil {
+		return nil, errors.New("cose: the content encryption key is already determined")
+	}
+	alg := lookupAlgorithm(msg.Protected, msg.Unprotected)
+	r, cek, err := deriveRecipient(alg, protected, unprotected, kd)
+	if err != nil {
+		return nil, err
+	}
+	msg.Recipients = append(msg.Recipients, r)
+	msg.cek = cek
+	return cek, nil
+}
+
+// Decrypt decrypts the message and returns the plaintext.
+// finder finds the key wrapper that unwraps the key for one of the recipients.
+// external is the externally supplied data described in RFC 9052 Section 4.3.
+// If the ciphertext is detached, set Ciphertext before calling Decrypt.
+func (msg *EncryptMessage) Decrypt(ctx context.Context, finder KeyWrapperFinder, external []byte) ([]byte, error) {
+	alg := lookupAlgorithm(msg.Protected, msg.Unprotected)
+	if alg.EncryptionAlgorithm() == "" {
+		return nil, fmt.Errorf("cose: unknown content encryption algorithm: %v", alg)
+	}
+
+	cek, err := findCEK(ctx, finder, msg.Protected, msg.Unprotected, msg.Recipients)
+	if err != nil {
+		return nil, err
+	}
+
+	rawProtected, err := msg.serializedProtected()
+	if err != nil {
+		return nil, err
+	}
+	return decryptContent(contextEncrypt, msg.Protected, msg.Unprotected, msg.BaseIV, rawProtected, cek, msg.Ciphertext, external)
+}
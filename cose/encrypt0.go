@@ -0,0 +1,259 @@
+package cose
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/shogo82148/go-cbor"
+)
+
+// Encrypt0Message is a COSE_Encrypt0 message defined in RFC 9052 Section 5.2.
+type Encrypt0Message struct {
+	// Protected is the protected header.
+	Protected *Header
+
+	// Unprotected is the unprotected header.
+	Unprotected *Header
+
+	// Ciphertext is the encrypted content including the authentication tag.
+	// nil means that the ciphertext is detached.
+	Ciphertext []byte
+
+	// BaseIV is the context IV used with the "Partial IV" header parameter.
+	// It is not transmitted in the message.
+	// See RFC 9052 Section 3.1.
+	BaseIV []byte
+
+	rawProtected []byte
+}
+
+// NewEncrypt0Message returns a new COSE_Encrypt0 message that has no content.
+func NewEncrypt0Message() *Encrypt0Message {
+	return &Encrypt0Message{
+		Protected:   NewHeader(),
+		Unprotected: NewHeader(),
+	}
+}
+
+// ParseEncrypt0 parses a tagged or untagged COSE_Encrypt0 message.
+func ParseEncrypt0(data []byte) (*Encrypt0Message, error) {
+	var msg Encrypt0Message
+	if err := msg.UnmarshalCBOR(data); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// UnmarshalCBOR implements [cbor.Unmarshaler].
+// It accepts both tagged and untagged COSE_Encrypt0 messages.
+func (msg *Encrypt0Message) UnmarshalCBOR(data []byte) error {
+	array, err := decodeMessage(data, TagNumberCOSEEncrypt0, 3)
+	if err != nil {
+		return err
+	}
+
+	protected, unprotected, rawProtected, err := decodeHeaders(array[0], array[1])
+	if err != nil {
+		return err
+	}
+	ciphertext, err := decodePayload(array[2])
+	if err != nil {
+		return err
+	}
+
+	*msg = Encrypt0Message{
+		Protected:    protected,
+		Unprotected:  unprotected,
+		Ciphertext:   ciphertext,
+		rawProtected: rawProtected,
+	}
+	return nil
+}
+
+// MarshalCBOR implements [cbor.CBORMarshaler].
+// It encodes the message as a tagged COSE_Encrypt0 message.
+func (msg *Encrypt0Message) MarshalCBOR() ([]byte, error) {
+	array, err := msg.encode()
+	if err != nil {
+		return nil, err
+	}
+	return cbor.Marshal(cbor.Tag{
+		Number:  TagNumberCOSEEncrypt0,
+		Content: array,
+	})
+}
+
+// MarshalCBORUntagged encodes the message as an untagged COSE_Encrypt0 message.
+func (msg *Encrypt0Message) MarshalCBORUntagged() ([]byte, error) {
+	array, err := msg.encode()
+	if err != nil {
+		return nil, err
+	}
+	return cbor.Marshal(array)
+}
+
+func (msg *Encrypt0Message) encode() ([]any, error) {
+	rawProtected, err := msg.serializedProtected()
+	if err != nil {
+		return nil, err
+	}
+	unprotected, err := encodeHeader(msg.Unprotected)
+	if err != nil {
+		return nil, err
+	}
+	return []any{
+		rawProtected,
+		unprotected,
+		encodePayload(msg.Ciphertext),
+	}, nil
+}
+
+func (msg *Encrypt0Message) serializedProtected() ([]byte, error) {
+	if msg.rawProtected != nil {
+		return msg.rawProtected, nil
+	}
+	return encodeProtectedHeader(msg.Protected)
+}
+
+// Encrypt encrypts plaintext with cek.
+// The content encryption algorithm is specified by the "alg" header parameter.
+// If the IV is not set, a new IV is generated and set to the unprotected header.
+// external is the externally supplied data described in RFC 9052 Section 4.3.
+// Encrypt fails if the message is already encrypted, because it would reuse the IV with the same key.
+func (msg *Encrypt0Message) Encrypt(cek, plaintext, external []byte) error {
+	if msg.rawProtected != nil {
+		return errors.New("cose: failed to encrypt: the message is already encrypted")
+	}
+	rawProtected, ciphertext, err := encryptContent(contextEncrypt0, msg.Protected, msg.Unprotected, msg.BaseIV, cek, plaintext, external)
+	if err != nil {
+		return err
+	}
+	msg.rawProtected = rawProtected
+	msg.Ciphertext = ciphertext
+	return nil
+}
+
+// Decrypt decrypts the message with cek and returns the plaintext.
+// external is the externally supplied data described in RFC 9052 Section 4.3.
+// If the ciphertext is detached, set Ciphertext before calling Decrypt.
+func (msg *Encrypt0Message) Decrypt(cek, external []byte) ([]byte, error) {
+	rawProtected, err := msg.serializedProtected()
+	if err != nil {
+		return nil, err
+	}
+	return decryptContent(contextEncrypt0, msg.Protected, msg.Unprotected, msg.BaseIV, rawProtected, cek, msg.Ciphertext, external)
+}
+
+// encryptContent encrypts plaintext with cek, and returns the serialized protected header and the ciphertext.
+// The authentication tag is appended to the ciphertext.
+func encryptContent(context string, protected, unprotected *Header, baseIV, cek, plaintext, external []byte) (rawProtected, ciphertext []byte, err error) {
+	if protected == nil || unprotected == nil {
+		return nil, nil, errors.New("cose: failed to encrypt: headers are not initialized")
+	}
+	alg := lookupAlgorithm(protected, unprotected)
+	encAlg := alg.EncryptionAlgorithm()
+	if encAlg == "" {
+		return nil, nil, fmt.Errorf("cose: failed to encrypt: unknown content encryption algorithm: %v", alg)
+	}
+	if !encAlg.Available() {
+		return nil, nil, fmt.Errorf("cose: failed to encrypt: requested content encryption algorithm %v is not available", alg)
+	}
+	enc := encAlg.New()
+
+	iv, err := lookupContentIV(protected, unprotected, baseIV)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cose: failed to encrypt: %w", err)
+	}
+	if iv == nil {
+		iv, err = enc.GenerateIV()
+		if err != nil {
+			return nil, nil, fmt.Errorf("cose: failed to generate IV: %w", err)
+		}
+		unprotected.SetIV(iv)
+	}
+	if err := checkHeaders(protected, unprotected); err != nil {
+		return nil, nil, err
+	}
+
+	rawProtected, err = encodeProtectedHeader(protected)
+	if err != nil {
+		return nil, nil, err
+	}
+	aad, err := encStructure(context, rawProtected, external)
+	if err != nil {
+		return nil, nil, err
+	}
+	ciphertext, tag, err := enc.Encrypt(cek, iv, aad, plaintext)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cose: failed to encrypt: %w", err)
+	}
+	return rawProtected, append(ciphertext, tag...), nil
+}
+
+// gcmTagSize is the size of the authentication tag of AES-GCM defined in RFC 9053 Section 4.1.
+const gcmTagSize = 16
+
+// decryptContent decrypts ciphertext with cek and returns the plaintext.
+func decryptContent(context string, protected, unprotected *Header, baseIV, rawProtected, cek, ciphertext, external []byte) ([]byte, error) {
+	alg := lookupAlgorithm(protected, unprotected)
+	encAlg := alg.EncryptionAlgorithm()
+	if encAlg == "" {
+		return nil, fmt.Errorf("cose: unknown content encryption algorithm: %v", alg)
+	}
+	if !encAlg.Available() {
+		return nil, fmt.Errorf("cose: requested content encryption algorithm %v is not available", alg)
+	}
+	iv, err := lookupContentIV(protected, unprotected, baseIV)
+	if err != nil {
+		return nil, err
+	}
+	if iv == nil {
+		return nil, errors.New("cose: IV is not found")
+	}
+	if len(ciphertext) < gcmTagSize {
+		return nil, errors.New("cose: ciphertext is too short")
+	}
+	if len(cek) != alg.keySize() {
+		return nil, fmt.Errorf("cose: invalid key size: %d", len(cek))
+	}
+
+	aad, err := encStructure(context, rawProtected, external)
+	if err != nil {
+		return nil, err
+	}
+	n := len(ciphertext) - gcmTagSize
+	plaintext, err := encAlg.New().Decrypt(cek, iv, aad, ciphertext[:n], ciphertext[n:])
+	if err != nil {
+		return nil, fmt.Errorf("cose: failed to decrypt: %w", err)
+	}
+	return plaintext, nil
+}
+
+// lookupContentIV returns the IV for the content encryption.
+// If the "Partial IV" header parameter is present, the IV is computed from baseIV
+// as described in RFC 9052 Section 3.1.
+// It returns nil if neither the IV nor the Partial IV is present.
+func lookupContentIV(protected, unprotected *Header, baseIV []byte) ([]byte, error) {
+	if iv := lookupIV(protected, unprotected); iv != nil {
+		return iv, nil
+	}
+	partialIV := lookupPartialIV(protected, unprotected)
+	if partialIV == nil {
+		return nil, nil
+	}
+	if baseIV == nil {
+		return nil, errors.New("cose: base IV is required for Partial IV")
+	}
+	if len(partialIV) > len(baseIV) {
+		return nil, errors.New("cose: Partial IV is longer than base IV")
+	}
+
+	// left-pad the Partial IV with zeros, and XOR it with the base IV.
+	iv := make([]byte, len(baseIV))
+	copy(iv, baseIV)
+	offset := len(baseIV) - len(partialIV)
+	for i, b := range partialIV {
+		iv[offset+i] ^= b
+	}
+	return iv, nil
+}
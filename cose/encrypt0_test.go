@@ -0,0 +1,189 @@
+package cose
+
+import (
+	"bytes"
+	"testing"
+
+	_ "github.com/shogo82148/goat/jwa/agcm"
+)
+
+func TestEncrypt0Message_Decrypt(t *testing.T) {
+	dirs := []string{"aes-gcm-examples"}
+	for _, dirname := range dirs {
+		for name, v := range readTestVectors(t, dirname) {
+			if v.Input.Encrypted == nil {
+				continue
+			}
+			t.Run(dirname+"/"+name, func(t *testing.T) {
+				input := v.Input.Encrypted
+				key := parseTestKey(t, input.Recipients[0].Key)
+				external := mustHex(t, input.External)
+				data := mustHex(t, v.Output.CBOR)
+
+				msg, err := ParseEncrypt0(data)
+				if err != nil {
+					if v.Fail {
+						return
+					}
+					t.Fatal(err)
+				}
+				plaintext, err := msg.Decrypt(key.PrivateKey().([]byte), external)
+				if v.Fail {
+					if err == nil {
+						t.Error("want error, but not")
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(plaintext, []byte(v.Input.Plaintext)) {
+					t.Errorf("unexpected plaintext: got %q, want %q", plaintext, v.Input.Plaintext)
+				}
+
+				// check the intermediate value
+				aad, err := encStructure(contextEncrypt0, msg.rawProtected, external)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := mustHex(t, v.Intermediates.AADHex); !bytes.Equal(aad, want) {
+					t.Errorf("unexpected AAD: got %x, want %x", aad, want)
+				}
+
+				// round trip
+				got, err := msg.MarshalCBOR()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, data) {
+					t.Errorf("unexpected encoding: got %x, want %x", got, data)
+				}
+			})
+		}
+	}
+}
+
+func TestEncrypt0Message_Encrypt(t *testing.T) {
+	for name, v := range readTestVectors(t, "aes-gcm-examples") {
+		if v.Input.Encrypted == nil || v.Fail {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			input := v.Input.Encrypted
+			key := parseTestKey(t, input.Recipients[0].Key)
+			want := mustHex(t, v.Output.CBOR)
+
+			msg := NewEncrypt0Message()
+			msg.Protected.SetAlgorithm(input.algorithm())
+			msg.Unprotected.SetIV(mustHex(t, v.Input.RNGStream[0]))
+			if err := msg.Encrypt(key.PrivateKey().([]byte), []byte(v.Input.Plaintext), nil); err != nil {
+				t.Fatal(err)
+			}
+			got, err := msg.MarshalCBOR()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("unexpected result: got %x, want %x", got, want)
+			}
+		})
+	}
+}
+
+func TestEncrypt0Message_EncryptDecrypt(t *testing.T) {
+	cek := []byte("0123456789abcdef")
+	plaintext := []byte("This is the content.")
+	external := []byte("external data")
+
+	msg := NewEncrypt0Message()
+	msg.Protected.SetAlgorithm(AlgorithmA128GCM)
+	if err := msg.Encrypt(cek, plaintext, external); err != nil {
+		t.Fatal(err)
+	}
+	if len(msg.Unprotected.IV()) != 12 {
+		t.Errorf("unexpected IV: %x", msg.Unprotected.IV())
+	}
+	data, err := msg.MarshalCBOR()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseEncrypt0(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := parsed.Decrypt(cek, external)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("unexpected plaintext: got %q, want %q", got, plaintext)
+	}
+
+	// wrong external data
+	if _, err := parsed.Decrypt(cek, nil); err == nil {
+		t.Error("want error, but not")
+	}
+}
+
+func TestEncrypt0Message_EncryptTwice(t *testing.T) {
+	cek := []byte("0123456789abcdef")
+
+	msg := NewEncrypt0Message()
+	msg.Protected.SetAlgorithm(AlgorithmA128GCM)
+	if err := msg.Encrypt(cek, []byte("first"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// the generated IV must not be reused with the same key.
+	if err := msg.Encrypt(cek, []byte("second"), nil); err == nil {
+		t.Error("want error, but not")
+	}
+
+	// the parsed message is also encrypted.
+	data, err := msg.MarshalCBOR()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseEncrypt0(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := parsed.Encrypt(cek, []byte("second"), nil); err == nil {
+		t.Error("want error, but not")
+	}
+}
+
+func TestEncrypt0Message_PartialIV(t *testing.T) {
+	cek := []byte("0123456789abcdef")
+	baseIV := mustHex(t, "89F52F65A1C580933B5261A7")
+	plaintext := []byte("This is the content.")
+
+	msg := NewEncrypt0Message()
+	msg.BaseIV = baseIV
+	msg.Protected.SetAlgorithm(AlgorithmA128GCM)
+	msg.Unprotected.SetPartialIV([]byte{0x61, 0xa7})
+	if err := msg.Encrypt(cek, plaintext, nil); err != nil {
+		t.Fatal(err)
+	}
+	data, err := msg.MarshalCBOR()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseEncrypt0(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parsed.Decrypt(cek, nil); err == nil {
+		t.Error("want error without base IV, but not")
+	}
+	parsed.BaseIV = baseIV
+	got, err := parsed.Decrypt(cek, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("unexpected plaintext: got %q, want %q", got, plaintext)
+	}
+}
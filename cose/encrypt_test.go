@@ -0,0 +1,314 @@
+package cose
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	_ "github.com/shogo82148/goat/jwa/agcm"
+	_ "github.com/shogo82148/goat/jwa/akw"
+	_ "github.com/shogo82148/goat/jwa/dir"
+	"github.com/shogo82148/goat/jwk"
+	"github.com/shogo82148/goat/keymanage"
+)
+
+// testKeyWrapperFinder returns a KeyWrapperFinder that uses the key of the recipient in the test vector.
+// It doesn't check the key ID, because some test vectors use different key IDs in the key and the header.
+func testKeyWrapperFinder(t *testing.T, r *testRecipient) KeyWrapperFinder {
+	t.Helper()
+	key := parseTestKey(t, r.Key)
+	key.SetPublicKeyUse("")
+	return FindKeyWrapperFunc(func(ctx context.Context, protected, unprotected *Header, recipient *Recipient) (keymanage.KeyWrapper, error) {
+		alg := lookupAlgorithm(recipient.Protected, recipient.Unprotected)
+		return alg.NewKeyWrapper(key), nil
+	})
+}
+
+func TestEncryptMessage_Decrypt(t *testing.T) {
+	dirs := []string{"aes-gcm-examples", "aes-wrap-examples", "ecdh-direct-examples", "ecdh-wrap-examples", "enveloped-tests"}
+	for _, dirname := range dirs {
+		for name, v := range readTestVectors(t, dirname) {
+			if v.Input.Enveloped == nil {
+				continue
+			}
+			t.Run(dirname+"/"+name, func(t *testing.T) {
+				input := v.Input.Enveloped
+				external := mustHex(t, input.External)
+				data := mustHex(t, v.Output.CBOR)
+
+				msg, err := ParseEncrypt(data)
+				if err != nil {
+					if v.Fail {
+						return
+					}
+					t.Fatal(err)
+				}
+				if iv, ok := input.Unsent["IV_hex"].(string); ok {
+					// IV_hex is the IV that is actually used.
+					// recover the base IV from it.
+					msg.BaseIV = mustHex(t, iv)
+					partialIV := msg.Unprotected.PartialIV()
+					offset := len(msg.BaseIV) - len(partialIV)
+					for i, b := range partialIV {
+						msg.BaseIV[offset+i] ^= b
+					}
+				}
+				finder := testKeyWrapperFinder(t, input.Recipients[0])
+				plaintext, err := msg.Decrypt(t.Context(), finder, external)
+				if v.Fail {
+					if err == nil {
+						t.Error("want error, but not")
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(plaintext, []byte(v.Input.Plaintext)) {
+					t.Errorf("unexpected plaintext: got %q, want %q", plaintext, v.Input.Plaintext)
+				}
+
+				// check the intermediate values
+				cek, err := findCEK(t.Context(), finder, msg.Protected, msg.Unprotected, msg.Recipients)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := mustHex(t, v.Intermediates.CEKHex); !bytes.Equal(cek, want) {
+					t.Errorf("unexpected CEK: got %x, want %x", cek, want)
+				}
+				aad, err := encStructure(contextEncrypt, msg.rawProtected, external)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := mustHex(t, v.Intermediates.AADHex); !bytes.Equal(aad, want) {
+					t.Errorf("unexpected AAD: got %x, want %x", aad, want)
+				}
+
+				// round trip
+				if dirname == "ecdh-direct-examples" || dirname == "ecdh-wrap-examples" {
+					// the ephemeral keys in these examples are not encoded in the deterministic order.
+					return
+				}
+				var got []byte
+				if data[0] == 0xd8 { // tag 96
+					got, err = msg.MarshalCBOR()
+				} else {
+					got, err = msg.MarshalCBORUntagged()
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, data) {
+					t.Errorf("unexpected encoding: got %x, want %x", got, data)
+				}
+			})
+		}
+	}
+}
+
+func TestEncryptMessage_Encrypt(t *testing.T) {
+	// the results of AES-GCM and AES Key Wrap are deterministic.
+	dirs := []string{"aes-gcm-examples", "aes-wrap-examples"}
+	for _, dirname := range dirs {
+		for name, v := range readTestVectors(t, dirname) {
+			if v.Input.Enveloped == nil || v.Fail || v.Input.Enveloped.Unsent != nil {
+				continue
+			}
+			t.Run(dirname+"/"+name, func(t *testing.T) {
+				input := v.Input.Enveloped
+				want := mustHex(t, v.Output.CBOR)
+
+				// use the IV and the headers of the test vector.
+				parsed, err := ParseEncrypt(want)
+				if err != nil {
+					t.Fatal(err)
+				}
+				key := parseTestKey(t, input.Recipients[0].Key)
+				r := parsed.Recipients[0]
+
+				msg := NewEncryptMessage()
+				msg.Protected.SetAlgorithm(input.algorithm())
+				msg.Unprotected.SetIV(parsed.Unprotected.IV())
+				if err := msg.Encrypt(mustHex(t, v.Intermediates.CEKHex), []byte(v.Input.Plaintext), nil); err != nil {
+					t.Fatal(err)
+				}
+				kw := lookupAlgorithm(r.Protected, r.Unprotected).NewKeyWrapper(key)
+				if err := msg.AddRecipient(r.Protected, r.Unprotected, kw); err != nil {
+					t.Fatal(err)
+				}
+				got, err := msg.MarshalCBOR()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("unexpected result: got %x, want %x", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestEncryptMessage_ECDH(t *testing.T) {
+	dirs := []string{"ecdh-direct-examples", "ecdh-wrap-examples"}
+	for _, dirname := range dirs {
+		for name, v := range readTestVectors(t, dirname) {
+			if v.Input.Enveloped == nil || v.Fail {
+				continue
+			}
+			t.Run(dirname+"/"+name, func(t *testing.T) {
+				input := v.Input.Enveloped
+				recipient := input.Recipients[0]
+				alg := recipient.algorithm()
+				plaintext := []byte(v.Input.Plaintext)
+
+				recipientKey := parseTestKey(t, recipient.Key)
+				recipientKey.SetPublicKeyUse("")
+				recipientPublicKey, err := jwk.NewPublicKey(recipientKey.PublicKey())
+				if err != nil {
+					t.Fatal(err)
+				}
+				var kw keymanage.KeyWrapper
+				if recipient.SenderKey != nil {
+					senderKey := parseTestKey(t, recipient.SenderKey)
+					kw = alg.NewECDHKeyWrapper(recipientPublicKey, senderKey)
+				} else {
+					kw = alg.NewKeyWrapper(recipientPublicKey)
+				}
+
+				msg := NewEncryptMessage()
+				msg.Protected.SetAlgorithm(input.algorithm())
+				protected := NewHeader()
+				protected.SetAlgorithm(alg)
+				unprotected := NewHeader()
+				unprotected.SetKeyID([]byte(recipientKey.KeyID()))
+				if isDirectKeyAgreement(alg) {
+					cek, err := msg.DeriveKey(protected, unprotected, kw.(keymanage.KeyDeriver))
+					if err != nil {
+						t.Fatal(err)
+					}
+					if err := msg.Encrypt(cek, plaintext, nil); err != nil {
+						t.Fatal(err)
+					}
+				} else {
+					cek := make([]byte, input.algorithm().keySize())
+					if _, err := rand.Read(cek); err != nil {
+						t.Fatal(err)
+					}
+					if err := msg.Encrypt(cek, plaintext, nil); err != nil {
+						t.Fatal(err)
+					}
+					if err := msg.AddRecipient(protected, unprotected, kw); err != nil {
+						t.Fatal(err)
+					}
+				}
+				data, err := msg.MarshalCBOR()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				parsed, err := ParseEncrypt(data)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := parsed.Decrypt(t.Context(), testKeyWrapperFinder(t, recipient), nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, plaintext) {
+					t.Errorf("unexpected plaintext: got %q, want %q", got, plaintext)
+				}
+			})
+		}
+	}
+}
+
+func isDirectKeyAgreement(alg Algorithm) bool {
+	switch alg {
+	case AlgorithmECDH_ES_HKDF_256, AlgorithmECDH_ES_HKDF_512, AlgorithmECDH_SS_HKDF_256, AlgorithmECDH_SS_HKDF_512:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestEncryptMessage_MultipleRecipients(t *testing.T) {
+	priv1, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key1, err := jwk.NewPrivateKey(priv1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key1.SetKeyID("ecdh")
+	key2 := symmetricKey("0123456789abcdef")
+
+	cek := []byte("0123456789abcdef0123456789abcdef")
+	plaintext := []byte("This is the content.")
+
+	msg := NewEncryptMessage()
+	msg.Protected.SetAlgorithm(AlgorithmA256GCM)
+	if err := msg.Encrypt(cek, plaintext, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// the generated IV must not be reused with the same key.
+	if err := msg.Encrypt(cek, plaintext, nil); err == nil {
+		t.Error("want error, but not")
+	}
+
+	h1 := NewHeader()
+	h1.SetAlgorithm(AlgorithmECDH_ES_A256KW)
+	u1 := NewHeader()
+	u1.SetKeyID([]byte("ecdh"))
+	pub1, err := jwk.NewPublicKey(priv1.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := msg.AddRecipient(h1, u1, AlgorithmECDH_ES_A256KW.NewKeyWrapper(pub1)); err != nil {
+		t.Fatal(err)
+	}
+
+	u2 := NewHeader()
+	u2.SetAlgorithm(AlgorithmA128KW)
+	u2.SetKeyID([]byte("aes"))
+	if err := msg.AddRecipient(nil, u2, AlgorithmA128KW.NewKeyWrapper(key2)); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := msg.MarshalCBOR()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseEncrypt(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.Recipients) != 2 {
+		t.Fatalf("unexpected number of recipients: %d", len(parsed.Recipients))
+	}
+
+	// each recipient can decrypt the message.
+	finders := map[string]keymanage.Key{
+		"ecdh": key1,
+		"aes":  key2,
+	}
+	for kid, key := range finders {
+		finder := FindKeyWrapperFunc(func(ctx context.Context, protected, unprotected *Header, recipient *Recipient) (keymanage.KeyWrapper, error) {
+			if string(lookupKeyID(recipient.Protected, recipient.Unprotected)) != kid {
+				return nil, errVerifyFailed
+			}
+			return lookupAlgorithm(recipient.Protected, recipient.Unprotected).NewKeyWrapper(key), nil
+		})
+		got, err := parsed.Decrypt(t.Context(), finder, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", kid, err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Errorf("%s: unexpected plaintext: got %q, want %q", kid, got, plaintext)
+		}
+	}
+}
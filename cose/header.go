@@ -0,0 +1,613 @@
+package cose
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+
+	"github.com/shogo82148/go-cbor"
+	"github.com/shogo82148/goat/internal/cborutils"
+)
+
+// https://www.iana.org/assignments/cose/cose.xhtml#header-parameters
+const (
+	headerLabelAlgorithm   = 1 // Cryptographic algorithm to use
+	headerLabelCritical    = 2 // Critical headers to be understood
+	headerLabelContentType = 3 // Content type of the payload
+	headerLabelKeyID       = 4 // Key identifier
+	headerLabelIV          = 5 // Full Initialization Vector
+	headerLabelPartialIV   = 6 // Partial Initialization Vector
+
+	// Countersignature Header Parameters defined in RFC 9338 Section 3.
+	headerLabelCountersignature  = 11 // V2 countersignature attribute
+	headerLabelCountersignature0 = 12 // V2 Abbreviated Countersignature
+
+	// X.509 Certificate Header Parameters defined in RFC 9360 Section 2.
+	headerLabelX5Bag   = 32 // An unordered bag of X.509 certificates
+	headerLabelX5Chain = 33 // An ordered chain of X.509 certificates
+	headerLabelX5T     = 34 // Hash of an X.509 certificate
+	headerLabelX5U     = 35 // URI pointing to an X.509 certificate
+
+	// Header Algorithm Parameters for ECDH defined in RFC 9053 Section 6.
+	headerLabelEphemeralKey   = -1  // Ephemeral public key for the sender
+	headerLabelStaticKey      = -2  // Static public key for the sender
+	headerLabelStaticKeyID    = -3  // Static public key identifier for the sender
+	headerLabelSalt           = -20 // Random salt
+	headerLabelPartyUIdentity = -21 // Party U identity information
+	headerLabelPartyUNonce    = -22 // Party U provided nonce
+	headerLabelPartyUOther    = -23 // Party U other provided information
+	headerLabelPartyVIdentity = -24 // Party V identity information
+	headerLabelPartyVNonce    = -25 // Party V provided nonce
+	headerLabelPartyVOther    = -26 // Party V other provided information
+)
+
+// knownLabels is the list of header labels that this package understands.
+// They can be appeared in the "crit" header parameter.
+var knownLabels = [...]int64{
+	headerLabelAlgorithm,
+	headerLabelCritical,
+	headerLabelContentType,
+	headerLabelKeyID,
+	headerLabelIV,
+	headerLabelPartialIV,
+	headerLabelCountersignature,
+	headerLabelCountersignature0,
+	headerLabelX5Bag,
+	headerLabelX5Chain,
+	headerLabelX5T,
+	headerLabelX5U,
+	headerLabelEphemeralKey,
+	headerLabelStaticKey,
+	headerLabelStaticKeyID,
+	headerLabelSalt,
+	headerLabelPartyUIdentity,
+	headerLabelPartyUNonce,
+	headerLabelPartyUOther,
+	headerLabelPartyVIdentity,
+	headerLabelPartyVNonce,
+	headerLabelPartyVOther,
+}
+
+// Header is a decoded COSE header map.
+type Header struct {
+	// Raw is the raw data of CBOR-decoded COSE header.
+	// CBOR integers are decoded as cbor.Integer to avoid data loss.
+	Raw map[any]any
+
+	alg       Algorithm
+	crit      []any
+	cty       any
+	kid       []byte
+	iv        []byte
+	partialIV []byte
+
+	countersignatures []*Countersignature
+	countersignature0 []byte
+
+	x5bag   []*x509.Certificate
+	x5chain []*x509.Certificate
+	x5t     *CertificateHash
+	x5u     *url.URL
+}
+
+// NewHeader returns a new Header.
+func NewHeader() *Header {
+	return &Header{
+		Raw: map[any]any{},
+	}
+}
+
+// Algorithm is RFC 9052 Section 3.1. "alg" Header Parameter.
+func (h *Header) Algorithm() Algorithm {
+	return h.alg
+}
+
+// SetAlgorithm sets RFC 9052 Section 3.1. "alg" Header Parameter.
+func (h *Header) SetAlgorithm(alg Algorithm) {
+	h.alg = alg
+}
+
+// Critical is RFC 9052 Section 3.1. "crit" Header Parameter.
+// Each element is an int64 label or a string label.
+func (h *Header) Critical() []any {
+	return h.crit
+}
+
+// SetCritical sets RFC 9052 Section 3.1. "crit" Header Parameter.
+// Each element must be an int64 label or a string label.
+func (h *Header) SetCritical(crit []any) {
+	h.crit = crit
+}
+
+// ContentType is RFC 9052 Section 3.1. "content type" Header Parameter.
+// It is a string or an uint64 CoAP Content-Format.
+func (h *Header) ContentType() any {
+	return h.cty
+}
+
+// SetContentType sets RFC 9052 Section 3.1. "content type" Header Parameter.
+// cty must be a string or an uint64 CoAP Content-Format.
+func (h *Header) SetContentType(cty any) {
+	h.cty = cty
+}
+
+// KeyID is RFC 9052 Section 3.1. "kid" Header Parameter.
+func (h *Header) KeyID() []byte {
+	return h.kid
+}
+
+// SetKeyID sets RFC 9052 Section 3.1. "kid" Header Parameter.
+func (h *Header) SetKeyID(kid []byte) {
+	h.kid = kid
+}
+
+// IV is RFC 9052 Section 3.1. "IV" Header Parameter.
+func (h *Header) IV() []byte {
+	return h.iv
+}
+
+// SetIV sets RFC 9052 Section 3.1. "IV" Header Parameter.
+func (h *Header) SetIV(iv []byte) {
+	h.iv = iv
+}
+
+// PartialIV is RFC 9052 Section 3.1. "Partial IV" Header Parameter.
+func (h *Header) PartialIV() []byte {
+	return h.partialIV
+}
+
+// SetPartialIV sets RFC 9052 Section 3.1. "Partial IV" Header Parameter.
+func (h *Header) SetPartialIV(partialIV []byte) {
+	h.partialIV = partialIV
+}
+
+// Countersignatures is RFC 9338 Section 3.1. "Countersignature version 2" Header Parameter.
+func (h *Header) Countersignatures() []*Countersignature {
+	return h.countersignatures
+}
+
+// SetCountersignatures sets RFC 9338 Section 3.1. "Countersignature version 2" Header Parameter.
+// Typically, countersignatures are added by [Countersign].
+func (h *Header) SetCountersignatures(countersignatures []*Countersignature) {
+	h.countersignatures = countersignatures
+}
+
+// Countersignature0 is RFC 9338 Section 3.2. "Countersignature0 version 2" Header Parameter.
+func (h *Header) Countersignature0() []byte {
+	return h.countersignature0
+}
+
+// SetCountersignature0 sets RFC 9338 Section 3.2. "Countersignature0 version 2" Header Parameter.
+// Typically, it is set by [Countersign0].
+func (h *Header) SetCountersignature0(countersignature0 []byte) {
+	h.countersignature0 = countersignature0
+}
+
+// X509Bag is RFC 9360 Section 2. "x5bag" Header Parameter.
+func (h *Header) X509Bag() []*x509.Certificate {
+	return h.x5bag
+}
+
+// SetX509Bag sets RFC 9360 Section 2. "x5bag" Header Parameter.
+func (h *Header) SetX509Bag(x5bag []*x509.Certificate) {
+	h.x5bag = x5bag
+}
+
+// X509CertificateChain is RFC 9360 Section 2. "x5chain" Header Parameter.
+// The first certificate is the one containing the public key of the signer.
+func (h *Header) X509CertificateChain() []*x509.Certificate {
+	return h.x5chain
+}
+
+// SetX509CertificateChain sets RFC 9360 Section 2. "x5chain" Header Parameter.
+// The first certificate must be the one containing the public key of the signer,
+// and each following certificate must directly certify the one preceding it.
+func (h *Header) SetX509CertificateChain(x5chain []*x509.Certificate) {
+	h.x5chain = x5chain
+}
+
+// X509CertificateHash is RFC 9360 Section 2. "x5t" Header Parameter.
+func (h *Header) X509CertificateHash() *CertificateHash {
+	return h.x5t
+}
+
+// SetX509CertificateHash sets RFC 9360 Section 2. "x5t" Header Parameter.
+// Typically, x5t is created by [NewCertificateHash].
+func (h *Header) SetX509CertificateHash(x5t *CertificateHash) {
+	h.x5t = x5t
+}
+
+// X509URL is RFC 9360 Section 2. "x5u" Header Parameter.
+func (h *Header) X509URL() *url.URL {
+	return h.x5u
+}
+
+// SetX509URL sets RFC 9360 Section 2. "x5u" Header Parameter.
+func (h *Header) SetX509URL(x5u *url.URL) {
+	h.x5u = x5u
+}
+
+func decodeHeader(raw map[any]any) (*Header, error) {
+	d := cborutils.NewDecoder("cose", raw)
+	h := &Header{
+		Raw: raw,
+	}
+
+	if v, ok := d.Get(headerLabelAlgorithm); ok {
+		switch v := v.(type) {
+		case cbor.Integer:
+			alg, err := v.Int64()
+			if err != nil {
+				d.SaveError(fmt.Errorf("cose: invalid algorithm: %w", err))
+			}
+			h.alg = Algorithm(alg)
+		case string:
+			// text string algorithms are not supported.
+			// the raw value is still available in h.Raw.
+		default:
+			d.SaveError(fmt.Errorf("cose: invalid type of alg: %T", v))
+		}
+	}
+
+	if v, ok := d.Get(headerLabelCritical); ok {
+		crit, ok := v.([]any)
+		if !ok || len(crit) == 0 {
+			d.SaveError(errors.New("cose: crit must be a non-empty array"))
+		}
+		h.crit = make([]any, 0, len(crit))
+		for _, label := range crit {
+			switch label := label.(type) {
+			case cbor.Integer:
+				i, err := label.Int64()
+				if err != nil {
+					d.SaveError(fmt.Errorf("cose: invalid label in crit: %w", err))
+				}
+				h.crit = append(h.crit, i)
+			case string:
+				h.crit = append(h.crit, label)
+			default:
+				d.SaveError(fmt.Errorf("cose: invalid type of crit: %T", label))
+			}
+		}
+	}
+
+	if v, ok := d.Get(headerLabelContentType); ok {
+		switch v := v.(type) {
+		case cbor.Integer:
+			cty, err := v.Uint64()
+			if err != nil {
+				d.SaveError(fmt.Errorf("cose: invalid content type: %w", err))
+			}
+			h.cty = cty
+		case string:
+			h.cty = v
+		default:
+			d.SaveError(fmt.Errorf("cose: invalid type of content type: %T", v))
+		}
+	}
+
+	h.kid = getBytes(d, headerLabelKeyID)
+	h.iv = getBytes(d, headerLabelIV)
+	h.partialIV = getBytes(d, headerLabelPartialIV)
+
+	if v, ok := d.Get(headerLabelCountersignature); ok {
+		countersignatures, err := decodeCountersignatures(v)
+		if err != nil {
+			d.SaveError(err)
+		}
+		h.countersignatures = countersignatures
+	}
+	h.countersignature0 = getBytes(d, headerLabelCountersignature0)
+
+	h.x5bag = getCertificates(d, headerLabelX5Bag)
+	h.x5chain = getCertificates(d, headerLabelX5Chain)
+	if v, ok := d.Get(headerLabelX5T); ok {
+		x5t, err := decodeCertificateHash(v)
+		if err != nil {
+			d.SaveError(err)
+		}
+		h.x5t = x5t
+	}
+	if d.Has(headerLabelX5U) {
+		if s, ok := d.GetString(headerLabelX5U); ok {
+			u, err := url.Parse(s)
+			if err != nil {
+				d.SaveError(fmt.Errorf("cose: failed to parse x5u: %w", err))
+			}
+			h.x5u = u
+		} else {
+			d.SaveError(fmt.Errorf("cose: invalid type for the label %d", headerLabelX5U))
+		}
+	}
+
+	// verify critical parameter
+CRIT_LOOP:
+	for _, param1 := range h.crit {
+		for _, param2 := range knownLabels {
+			if param1 == param2 {
+				continue CRIT_LOOP
+			}
+		}
+		d.SaveError(fmt.Errorf("cose: unknown parameter is in crit: %v", param1))
+	}
+
+	if err := d.Err(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// getBytes gets a byte string parameter.
+// It saves an error if the parameter has an unexpected type.
+func getBytes(d *cborutils.Decoder, label int64) []byte {
+	if !d.Has(label) {
+		return nil
+	}
+	b, ok := d.GetBytes(label)
+	if !ok {
+		d.SaveError(fmt.Errorf("cose: invalid type for the label %d", label))
+		return nil
+	}
+	return b
+}
+
+// getCertificates gets a COSE_X509 parameter defined in RFC 9360 Section 2.
+// It is a single DER-encoded certificate or an array of them.
+func getCertificates(d *cborutils.Decoder, label int64) []*x509.Certificate {
+	v, ok := d.Get(label)
+	if !ok {
+		return nil
+	}
+	var ders [][]byte
+	switch v := v.(type) {
+	case []byte:
+		ders = [][]byte{v}
+	case []any:
+		if len(v) < 2 {
+			// RFC 9360 Section 2: a single certificate is encoded as a byte string, not an array.
+			d.SaveError(fmt.Errorf("cose: array for the label %d must contain two or more certificates", label))
+			return nil
+		}
+		for _, item := range v {
+			der, ok := item.([]byte)
+			if !ok {
+				d.SaveError(fmt.Errorf("cose: invalid type of certificate for the label %d: %T", label, item))
+				return nil
+			}
+			ders = append(ders, der)
+		}
+	default:
+		d.SaveError(fmt.Errorf("cose: invalid type for the label %d: %T", label, v))
+		return nil
+	}
+
+	certs := make([]*x509.Certificate, 0, len(ders))
+	for _, der := range ders {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			d.SaveError(fmt.Errorf("cose: failed to parse certificate for the label %d: %w", label, err))
+			return nil
+		}
+		certs = append(certs, cert)
+	}
+	return certs
+}
+
+// encodeCertificates encodes a COSE_X509 parameter defined in RFC 9360 Section 2.
+func encodeCertificates(certs []*x509.Certificate) any {
+	if len(certs) == 1 {
+		return certs[0].Raw
+	}
+	array := make([]any, 0, len(certs))
+	for _, cert := range certs {
+		array = append(array, cert.Raw)
+	}
+	return array
+}
+
+func encodeHeader(h *Header) (map[any]any, error) {
+	if h == nil {
+		return map[any]any{}, nil
+	}
+	raw := make(map[any]any, len(h.Raw))
+	maps.Copy(raw, h.Raw)
+	e := cborutils.NewEncoder(raw)
+
+	if alg := h.alg; alg != AlgorithmReserved {
+		e.Set(headerLabelAlgorithm, cborutils.IntegerFromInt64(int64(alg)))
+	}
+
+	if crit := h.crit; len(crit) > 0 {
+		labels := make([]any, 0, len(crit))
+		for _, label := range crit {
+			switch label := label.(type) {
+			case int64:
+				labels = append(labels, cborutils.IntegerFromInt64(label))
+			case int:
+				labels = append(labels, cborutils.IntegerFromInt64(int64(label)))
+			case string:
+				labels = append(labels, label)
+			default:
+				e.SaveError(fmt.Errorf("cose: invalid type of crit: %T", label))
+			}
+		}
+		e.Set(headerLabelCritical, labels)
+	}
+
+	switch cty := h.cty.(type) {
+	case nil:
+	case string:
+		e.Set(headerLabelContentType, cty)
+	case uint64:
+		e.Set(headerLabelContentType, cbor.Integer{Value: cty})
+	case int:
+		if cty < 0 {
+			e.SaveError(fmt.Errorf("cose: invalid content type: %d", cty))
+		}
+		e.Set(headerLabelContentType, cbor.Integer{Value: uint64(cty)})
+	default:
+		e.SaveError(fmt.Errorf("cose: invalid type of content type: %T", cty))
+	}
+
+	if kid := h.kid; kid != nil {
+		e.Set(headerLabelKeyID, kid)
+	}
+	if iv := h.iv; iv != nil {
+		e.Set(headerLabelIV, iv)
+	}
+	if partialIV := h.partialIV; partialIV != nil {
+		e.Set(headerLabelPartialIV, partialIV)
+	}
+	if countersignatures := h.countersignatures; len(countersignatures) > 0 {
+		v, err := encodeCountersignatures(countersignatures)
+		e.SaveError(err)
+		e.Set(headerLabelCountersignature, v)
+	}
+	if countersignature0 := h.countersignature0; countersignature0 != nil {
+		e.Set(headerLabelCountersignature0, countersignature0)
+	}
+	if x5bag := h.x5bag; len(x5bag) > 0 {
+		e.Set(headerLabelX5Bag, encodeCertificates(x5bag))
+	}
+	if x5chain := h.x5chain; len(x5chain) > 0 {
+		e.Set(headerLabelX5Chain, encodeCertificates(x5chain))
+	}
+	if x5t := h.x5t; x5t != nil {
+		e.Set(headerLabelX5T, x5t.encode())
+	}
+	if x5u := h.x5u; x5u != nil {
+		e.Set(headerLabelX5U, x5u.String())
+	}
+
+	if err := e.Err(); err != nil {
+		return nil, err
+	}
+	return e.Data(), nil
+}
+
+// encodeProtectedHeader encodes h as a serialized protected header.
+// An empty header is encoded as a zero-length byte string.
+func encodeProtectedHeader(h *Header) ([]byte, error) {
+	raw, err := encodeHeader(h)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 {
+		return []byte{}, nil
+	}
+	return cbor.Marshal(raw)
+}
+
+// decodeProtectedHeader decodes a serialized protected header.
+func decodeProtectedHeader(data []byte) (*Header, error) {
+	if len(data) == 0 {
+		return NewHeader(), nil
+	}
+	var raw map[any]any
+	dec := cbor.NewDecoder(bytes.NewReader(data))
+	dec.UseAnyKey()
+	dec.UseInteger()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("cose: failed to parse protected header: %w", err)
+	}
+	if raw == nil {
+		return nil, errors.New("cose: failed to parse protected header: not a map")
+	}
+	return decodeHeader(raw)
+}
+
+// checkHeaders validates the pair of a protected header and an unprotected header.
+func checkHeaders(protected, unprotected *Header) error {
+	// RFC 9052 Section 3: The same label MUST NOT occur in both the protected and unprotected headers.
+	p, err := encodeHeader(protected)
+	if err != nil {
+		return err
+	}
+	u, err := encodeHeader(unprotected)
+	if err != nil {
+		return err
+	}
+	for label := range p {
+		if _, ok := u[label]; ok {
+			return fmt.Errorf("cose: the label %v occurs in both protected and unprotected headers", label)
+		}
+	}
+
+	// RFC 9052 Section 3.1: The "crit" parameter MUST be placed in the protected header.
+	if _, ok := u[cborutils.IntegerFromInt64(headerLabelCritical)]; ok {
+		return errors.New("cose: crit must be placed in the protected header")
+	}
+
+	// RFC 9052 Section 3.1: The "IV" and "Partial IV" parameters MUST NOT both be present.
+	_, hasIV1 := p[cborutils.IntegerFromInt64(headerLabelIV)]
+	_, hasIV2 := u[cborutils.IntegerFromInt64(headerLabelIV)]
+	_, hasPartialIV1 := p[cborutils.IntegerFromInt64(headerLabelPartialIV)]
+	_, hasPartialIV2 := u[cborutils.IntegerFromInt64(headerLabelPartialIV)]
+	if (hasIV1 || hasIV2) && (hasPartialIV1 || hasPartialIV2) {
+		return errors.New("cose: both IV and Partial IV are present")
+	}
+	return nil
+}
+
+// lookupAlgorithm returns the algorithm from the protected header or the unprotected header.
+func lookupAlgorithm(protected, unprotected *Header) Algorithm {
+	if protected != nil && protected.alg != AlgorithmReserved {
+		return protected.alg
+	}
+	if unprotected != nil {
+		return unprotected.alg
+	}
+	return AlgorithmReserved
+}
+
+// lookupKeyID returns the key ID from the protected header or the unprotected header.
+func lookupKeyID(protected, unprotected *Header) []byte {
+	if protected != nil && protected.kid != nil {
+		return protected.kid
+	}
+	if unprotected != nil {
+		return unprotected.kid
+	}
+	return nil
+}
+
+// lookupIV returns the IV from the protected header or the unprotected header.
+func lookupIV(protected, unprotected *Header) []byte {
+	if protected != nil && protected.iv != nil {
+		return protected.iv
+	}
+	if unprotected != nil {
+		return unprotected.iv
+	}
+	return nil
+}
+
+// lookupPartialIV returns the Partial IV from the protected header or the unprotected header.
+func lookupPartialIV(protected, unprotected *Header) []byte {
+	if protected != nil && protected.partialIV != nil {
+		return protected.partialIV
+	}
+	if unprotected != nil {
+		return unprotected.partialIV
+	}
+	return nil
+}
+
+// lookupRaw returns the raw value of label from the protected header or the unprotected header.
+func lookupRaw(protected, unprotected *Header, label int64) (any, bool) {
+	key := cborutils.IntegerFromInt64(label)
+	if protected != nil {
+		if v, ok := protected.Raw[key]; ok {
+			return v, true
+		}
+	}
+	if unprotected != nil {
+		if v, ok := unprotected.Raw[key]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
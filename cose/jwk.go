@@ -0,0 +1,219 @@
+package cose
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/shogo82148/goat"
+	"github.com/shogo82148/goat/internal/cborutils"
+	"github.com/shogo82148/goat/jwk"
+	"github.com/shogo82148/goat/jwk/jwktypes"
+	"github.com/shogo82148/goat/x25519"
+)
+
+// FromJWK converts the JWK into a COSE_Key.
+// The key ID, the algorithm, the key operations and the curve are preserved.
+// It returns an error if the JWK has a parameter that has no equivalent in COSE_Key,
+// e.g. "use", "x5u", "x5c", "x5t" and "x5t#S256".
+func FromJWK(key *jwk.Key) (*Key, error) {
+	if key.PublicKeyUse() != jwktypes.KeyUseUnknown {
+		return nil, errors.New("cose: the JWK parameter use has no equivalent in COSE_Key")
+	}
+	if key.X509URL() != nil || key.X509CertificateChain() != nil ||
+		key.X509CertificateSHA1() != nil || key.X509CertificateSHA256() != nil {
+		return nil, errors.New("cose: the JWK X.509 parameters have no equivalent in COSE_Key")
+	}
+
+	var k *Key
+	var err error
+	if priv := key.PrivateKey(); priv != nil {
+		priv, err = fromECDHPrivateKey(priv)
+		if err != nil {
+			return nil, err
+		}
+		k, err = NewPrivateKey(priv)
+	} else {
+		var pub goat.PublicKey
+		pub, err = fromECDHPublicKey(key.PublicKey())
+		if err != nil {
+			return nil, err
+		}
+		k, err = NewPublicKey(pub)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if kid := key.KeyID(); kid != "" {
+		k.kid = []byte(kid)
+	}
+
+	if alg := key.Algorithm(); alg != "" {
+		a, ok := AlgorithmFromKeyAlgorithm(alg)
+		if !ok {
+			return nil, fmt.Errorf("cose: the algorithm %s has no equivalent in COSE", alg)
+		}
+		k.alg = a
+	}
+
+	if ops := key.KeyOperations(); ops != nil {
+		k.keyOps = make([]KeyOp, 0, len(ops))
+		for _, op := range ops {
+			o, err := keyOpFromJWK(op, k.kty)
+			if err != nil {
+				return nil, err
+			}
+			k.keyOps = append(k.keyOps, o)
+		}
+	}
+	return k, nil
+}
+
+// ToJWK converts the COSE_Key into a JWK.
+// The key ID, the algorithm, the key operations and the curve are preserved.
+// It returns an error if the COSE_Key has a parameter that has no equivalent in JWK,
+// e.g. "Base IV".
+func ToJWK(key *Key) (*jwk.Key, error) {
+	d := cborutils.NewDecoder("cose", key.Raw)
+	if d.Has(keyLabelBaseIV) {
+		return nil, errors.New("cose: the COSE_Key parameter Base IV has no equivalent in JWK")
+	}
+
+	var k *jwk.Key
+	var err error
+	if priv := key.PrivateKey(); priv != nil {
+		k, err = jwk.NewPrivateKey(priv)
+	} else {
+		k, err = jwk.NewPublicKey(key.PublicKey())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if kid := key.kid; kid != nil {
+		if !utf8.Valid(kid) {
+			return nil, errors.New("cose: the key ID is not a valid UTF-8 string")
+		}
+		k.SetKeyID(string(kid))
+	}
+
+	if alg := key.alg; alg != AlgorithmReserved {
+		a := alg.KeyAlgorithm()
+		if a == "" {
+			return nil, fmt.Errorf("cose: the algorithm %v has no equivalent in JWA", alg)
+		}
+		k.SetAlgorithm(a)
+	}
+
+	if ops := key.keyOps; ops != nil {
+		keyOps := make([]jwktypes.KeyOp, 0, len(ops))
+		for _, op := range ops {
+			o, err := keyOpToJWK(op)
+			if err != nil {
+				return nil, err
+			}
+			keyOps = append(keyOps, o)
+		}
+		k.SetKeyOperation(keyOps)
+	}
+	return k, nil
+}
+
+func keyOpFromJWK(op jwktypes.KeyOp, kty KeyType) (KeyOp, error) {
+	switch op {
+	case jwktypes.KeyOpSign:
+		// JWK uses "sign" for both digital signatures and MACs.
+		if kty == KeyTypeSymmetric {
+			return KeyOpMACCreate, nil
+		}
+		return KeyOpSign, nil
+	case jwktypes.KeyOpVerify:
+		if kty == KeyTypeSymmetric {
+			return KeyOpMACVerify, nil
+		}
+		return KeyOpVerify, nil
+	case jwktypes.KeyOpEncrypt:
+		return KeyOpEncrypt, nil
+	case jwktypes.KeyOpDecrypt:
+		return KeyOpDecrypt, nil
+	case jwktypes.KeyOpWrapKey:
+		return KeyOpWrapKey, nil
+	case jwktypes.KeyOpUnwrapKey:
+		return KeyOpUnwrapKey, nil
+	case jwktypes.KeyOpDeriveKey:
+		return KeyOpDeriveKey, nil
+	case jwktypes.KeyOpDeriveBits:
+		return KeyOpDeriveBits, nil
+	default:
+		return 0, fmt.Errorf("cose: the key operation %q has no equivalent in COSE", op)
+	}
+}
+
+func keyOpToJWK(op KeyOp) (jwktypes.KeyOp, error) {
+	switch op {
+	case KeyOpSign, KeyOpMACCreate:
+		return jwktypes.KeyOpSign, nil
+	case KeyOpVerify, KeyOpMACVerify:
+		return jwktypes.KeyOpVerify, nil
+	case KeyOpEncrypt:
+		return jwktypes.KeyOpEncrypt, nil
+	case KeyOpDecrypt:
+		return jwktypes.KeyOpDecrypt, nil
+	case KeyOpWrapKey:
+		return jwktypes.KeyOpWrapKey, nil
+	case KeyOpUnwrapKey:
+		return jwktypes.KeyOpUnwrapKey, nil
+	case KeyOpDeriveKey:
+		return jwktypes.KeyOpDeriveKey, nil
+	case KeyOpDeriveBits:
+		return jwktypes.KeyOpDeriveBits, nil
+	default:
+		return "", fmt.Errorf("cose: the key operation %v has no equivalent in JWK", op)
+	}
+}
+
+// fromECDHPrivateKey converts *ecdh.PrivateKey into the key type that COSE_Key supports.
+// Other types are returned as is.
+func fromECDHPrivateKey(priv goat.PrivateKey) (goat.PrivateKey, error) {
+	key, ok := priv.(*ecdh.PrivateKey)
+	if !ok {
+		return priv, nil
+	}
+	switch key.Curve() {
+	case ecdh.P256():
+		return ecdsa.ParseRawPrivateKey(elliptic.P256(), key.Bytes())
+	case ecdh.P384():
+		return ecdsa.ParseRawPrivateKey(elliptic.P384(), key.Bytes())
+	case ecdh.P521():
+		return ecdsa.ParseRawPrivateKey(elliptic.P521(), key.Bytes())
+	case ecdh.X25519():
+		return x25519.NewKeyFromSeed(key.Bytes()), nil
+	default:
+		return nil, fmt.Errorf("cose: unknown ecdh curve: %s", key.Curve())
+	}
+}
+
+// fromECDHPublicKey converts *ecdh.PublicKey into the key type that COSE_Key supports.
+// Other types are returned as is.
+func fromECDHPublicKey(pub goat.PublicKey) (goat.PublicKey, error) {
+	key, ok := pub.(*ecdh.PublicKey)
+	if !ok {
+		return pub, nil
+	}
+	switch key.Curve() {
+	case ecdh.P256():
+		return ecdsa.ParseUncompressedPublicKey(elliptic.P256(), key.Bytes())
+	case ecdh.P384():
+		return ecdsa.ParseUncompressedPublicKey(elliptic.P384(), key.Bytes())
+	case ecdh.P521():
+		return ecdsa.ParseUncompressedPublicKey(elliptic.P521(), key.Bytes())
+	case ecdh.X25519():
+		return x25519.PublicKey(key.Bytes()), nil
+	default:
+		return nil, fmt.Errorf("cose: unknown ecdh curve: %s", key.Curve())
+	}
+}
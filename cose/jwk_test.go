@@ -0,0 +1,326 @@
+package cose
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/shogo82148/goat/ed448"
+	"github.com/shogo82148/goat/internal/cborutils"
+	"github.com/shogo82148/goat/jwa"
+	"github.com/shogo82148/goat/jwk"
+	"github.com/shogo82148/goat/jwk/jwktypes"
+	"github.com/shogo82148/goat/x25519"
+	"github.com/shogo82148/goat/x448"
+)
+
+func TestFromJWK(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ed448Key, err := ed448.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, x25519Key, err := x25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, x448Key, err := x448.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		priv    crypto.PrivateKey
+		kty     KeyType
+		alg     jwa.KeyAlgorithm
+		coseAlg Algorithm
+		ops     []jwktypes.KeyOp
+		coseOps []KeyOp
+	}{
+		{
+			priv:    ecdsaKey,
+			kty:     KeyTypeEC2,
+			alg:     jwa.SignatureAlgorithmES512.KeyAlgorithm(),
+			coseAlg: AlgorithmES512,
+			ops:     []jwktypes.KeyOp{jwktypes.KeyOpSign, jwktypes.KeyOpVerify},
+			coseOps: []KeyOp{KeyOpSign, KeyOpVerify},
+		},
+		{
+			priv:    rsaKey,
+			kty:     KeyTypeRSA,
+			alg:     jwa.SignatureAlgorithmPS256.KeyAlgorithm(),
+			coseAlg: AlgorithmPS256,
+			ops:     []jwktypes.KeyOp{jwktypes.KeyOpSign},
+			coseOps: []KeyOp{KeyOpSign},
+		},
+		{
+			priv:    ed25519Key,
+			kty:     KeyTypeOKP,
+			alg:     jwa.SignatureAlgorithmEd25519.KeyAlgorithm(),
+			coseAlg: AlgorithmEd25519,
+		},
+		{
+			priv:    ed448Key,
+			kty:     KeyTypeOKP,
+			alg:     jwa.SignatureAlgorithmEd448.KeyAlgorithm(),
+			coseAlg: AlgorithmEd448,
+		},
+		{
+			priv:    x25519Key,
+			kty:     KeyTypeOKP,
+			ops:     []jwktypes.KeyOp{jwktypes.KeyOpDeriveKey, jwktypes.KeyOpDeriveBits},
+			coseOps: []KeyOp{KeyOpDeriveKey, KeyOpDeriveBits},
+		},
+		{
+			priv: x448Key,
+			kty:  KeyTypeOKP,
+		},
+		{
+			priv:    []byte("0123456789abcdef0123456789abcdef"),
+			kty:     KeyTypeSymmetric,
+			alg:     jwa.SignatureAlgorithmHS256.KeyAlgorithm(),
+			coseAlg: AlgorithmHMAC256_256,
+			ops:     []jwktypes.KeyOp{jwktypes.KeyOpSign, jwktypes.KeyOpVerify},
+			coseOps: []KeyOp{KeyOpMACCreate, KeyOpMACVerify},
+		},
+		{
+			priv:    []byte("0123456789abcdef"),
+			kty:     KeyTypeSymmetric,
+			alg:     jwa.KeyManagementAlgorithmA128KW.KeyAlgorithm(),
+			coseAlg: AlgorithmA128KW,
+			ops:     []jwktypes.KeyOp{jwktypes.KeyOpWrapKey, jwktypes.KeyOpUnwrapKey},
+			coseOps: []KeyOp{KeyOpWrapKey, KeyOpUnwrapKey},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%T/%s", tt.priv, tt.alg), func(t *testing.T) {
+			key, err := jwk.NewPrivateKey(tt.priv)
+			if err != nil {
+				t.Fatal(err)
+			}
+			key.SetKeyID("my-key")
+			key.SetAlgorithm(tt.alg)
+			key.SetKeyOperation(tt.ops)
+
+			// JWK -> COSE_Key
+			coseKey, err := FromJWK(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if coseKey.KeyType() != tt.kty {
+				t.Errorf("unexpected key type: got %v, want %v", coseKey.KeyType(), tt.kty)
+			}
+			if string(coseKey.KeyID()) != "my-key" {
+				t.Errorf("unexpected key id: %q", coseKey.KeyID())
+			}
+			if coseKey.Algorithm() != tt.coseAlg {
+				t.Errorf("unexpected algorithm: got %v, want %v", coseKey.Algorithm(), tt.coseAlg)
+			}
+			if !slices.Equal(coseKey.KeyOperations(), tt.coseOps) {
+				t.Errorf("unexpected key operations: got %v, want %v", coseKey.KeyOperations(), tt.coseOps)
+			}
+
+			// encode and decode COSE_Key
+			data, err := coseKey.MarshalCBOR()
+			if err != nil {
+				t.Fatal(err)
+			}
+			coseKey, err = ParseKey(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// COSE_Key -> JWK
+			got, err := ToJWK(coseKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.KeyID() != "my-key" {
+				t.Errorf("unexpected key id: %q", got.KeyID())
+			}
+			if got.Algorithm() != tt.alg {
+				t.Errorf("unexpected algorithm: got %v, want %v", got.Algorithm(), tt.alg)
+			}
+			if !slices.Equal(got.KeyOperations(), tt.ops) {
+				t.Errorf("unexpected key operations: got %v, want %v", got.KeyOperations(), tt.ops)
+			}
+			want, err := key.MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotJSON, err := got.MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(gotJSON) != string(want) {
+				t.Errorf("unexpected JWK: got %s, want %s", gotJSON, want)
+			}
+		})
+	}
+}
+
+func TestFromJWK_PublicKey(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := jwk.NewPublicKey(priv.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	coseKey, err := FromJWK(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if coseKey.PrivateKey() != nil {
+		t.Error("unexpected private key")
+	}
+	if !priv.PublicKey.Equal(coseKey.PublicKey()) {
+		t.Error("unexpected public key")
+	}
+
+	got, err := ToJWK(coseKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.PrivateKey() != nil {
+		t.Error("unexpected private key")
+	}
+	if !priv.PublicKey.Equal(got.PublicKey()) {
+		t.Error("unexpected public key")
+	}
+}
+
+func TestFromJWK_ECDH(t *testing.T) {
+	t.Run("P-256", func(t *testing.T) {
+		priv, err := ecdh.P256().GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := jwk.NewPrivateKey(priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		coseKey, err := FromJWK(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if coseKey.KeyType() != KeyTypeEC2 {
+			t.Errorf("unexpected key type: %v", coseKey.KeyType())
+		}
+		got, err := coseKey.PrivateKey().(*ecdsa.PrivateKey).ECDH()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !priv.Equal(got) {
+			t.Error("unexpected private key")
+		}
+	})
+
+	t.Run("X25519", func(t *testing.T) {
+		priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := jwk.NewPublicKey(priv.PublicKey())
+		if err != nil {
+			t.Fatal(err)
+		}
+		coseKey, err := FromJWK(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if coseKey.KeyType() != KeyTypeOKP {
+			t.Errorf("unexpected key type: %v", coseKey.KeyType())
+		}
+		got, err := coseKey.PublicKey().(x25519.PublicKey).ECDH()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !priv.PublicKey().Equal(got) {
+			t.Error("unexpected public key")
+		}
+	})
+}
+
+func TestFromJWK_Error(t *testing.T) {
+	t.Run("use", func(t *testing.T) {
+		key, err := jwk.NewPrivateKey([]byte("secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		key.SetPublicKeyUse(jwktypes.KeyUseSig)
+		if _, err := FromJWK(key); err == nil {
+			t.Error("want error, but not")
+		}
+	})
+
+	t.Run("alg", func(t *testing.T) {
+		key, err := jwk.NewPrivateKey([]byte("secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// JWA ECDH-ES uses Concat KDF, but COSE uses HKDF.
+		key.SetAlgorithm(jwa.KeyManagementAlgorithmECDH_ES.KeyAlgorithm())
+		if _, err := FromJWK(key); err == nil {
+			t.Error("want error, but not")
+		}
+	})
+}
+
+func TestToJWK_Error(t *testing.T) {
+	t.Run("Base IV", func(t *testing.T) {
+		key, err := ParseMap(map[any]any{
+			cborutils.IntegerFromInt64(keyLabelKeyType):    cborutils.IntegerFromInt64(int64(KeyTypeSymmetric)),
+			cborutils.IntegerFromInt64(keyLabelBaseIV):     []byte("base iv"),
+			cborutils.IntegerFromInt64(keyLabelSymmetricK): []byte("secret"),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ToJWK(key); err == nil {
+			t.Error("want error, but not")
+		}
+	})
+
+	t.Run("alg", func(t *testing.T) {
+		key, err := NewPrivateKey([]byte("secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		key.SetAlgorithm(AlgorithmHMAC256_64)
+		if _, err := ToJWK(key); err == nil {
+			t.Error("want error, but not")
+		}
+	})
+
+	t.Run("kid", func(t *testing.T) {
+		key, err := NewPrivateKey([]byte("secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		key.SetKeyID([]byte{0xff, 0xfe})
+		if _, err := ToJWK(key); err == nil {
+			t.Error("want error, but not")
+		}
+	})
+}
@@ -3,13 +3,20 @@ package cose
 import (
 	"bytes"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
+	"crypto/rsa"
 	"errors"
 	"fmt"
+	"maps"
 
 	"github.com/shogo82148/go-cbor"
 	"github.com/shogo82148/goat"
+	"github.com/shogo82148/goat/ed448"
 	"github.com/shogo82148/goat/internal/cborutils"
+	"github.com/shogo82148/goat/jwk/jwktypes"
+	"github.com/shogo82148/goat/x25519"
+	"github.com/shogo82148/goat/x448"
 )
 
 // KeyType represents a COSE_Key type.
@@ -86,6 +93,70 @@ func parseKeyType(s string) (KeyType, error) {
 	}
 }
 
+// KeyOp represents a value of the "key_ops" parameter of COSE_Key
+// defined in RFC 9052 Section 7.1.
+type KeyOp int64
+
+const (
+	// KeyOpSign is used for computing digital signature.
+	KeyOpSign KeyOp = 1
+
+	// KeyOpVerify is used for verifying digital signature.
+	KeyOpVerify KeyOp = 2
+
+	// KeyOpEncrypt is used for encrypting content.
+	KeyOpEncrypt KeyOp = 3
+
+	// KeyOpDecrypt is used for decrypting content.
+	KeyOpDecrypt KeyOp = 4
+
+	// KeyOpWrapKey is used for encrypting key.
+	KeyOpWrapKey KeyOp = 5
+
+	// KeyOpUnwrapKey is used for decrypting key.
+	KeyOpUnwrapKey KeyOp = 6
+
+	// KeyOpDeriveKey is used for deriving key.
+	KeyOpDeriveKey KeyOp = 7
+
+	// KeyOpDeriveBits is used for deriving bits not to be used as a key.
+	KeyOpDeriveBits KeyOp = 8
+
+	// KeyOpMACCreate is used for creating MAC.
+	KeyOpMACCreate KeyOp = 9
+
+	// KeyOpMACVerify is used for validating MAC.
+	KeyOpMACVerify KeyOp = 10
+)
+
+// String returns the name of the key operation.
+func (op KeyOp) String() string {
+	switch op {
+	case KeyOpSign:
+		return "sign"
+	case KeyOpVerify:
+		return "verify"
+	case KeyOpEncrypt:
+		return "encrypt"
+	case KeyOpDecrypt:
+		return "decrypt"
+	case KeyOpWrapKey:
+		return "wrap key"
+	case KeyOpUnwrapKey:
+		return "unwrap key"
+	case KeyOpDeriveKey:
+		return "derive key"
+	case KeyOpDeriveBits:
+		return "derive bits"
+	case KeyOpMACCreate:
+		return "MAC create"
+	case KeyOpMACVerify:
+		return "MAC verify"
+	default:
+		return fmt.Sprintf("KeyOp(%d)", int64(op))
+	}
+}
+
 // https://www.iana.org/assignments/cose/cose.xhtml#key-common-parameters
 const (
 	keyLabelKeyType   = 1 // Identification of the key type
@@ -123,8 +194,10 @@ type Key struct {
 	// CBOR integers are decoded as cbor.Integer to avoid data loss.
 	Raw map[any]any
 
-	kty KeyType
-	kid []byte
+	kty    KeyType
+	kid    []byte
+	alg    Algorithm
+	keyOps []KeyOp
 
 	priv goat.PrivateKey
 	pub  goat.PublicKey
@@ -140,6 +213,47 @@ func (key *Key) KeyID() []byte {
 	return key.kid
 }
 
+// SetKeyID sets the key ID of the key.
+func (key *Key) SetKeyID(kid []byte) {
+	key.kid = kid
+}
+
+// Algorithm returns the algorithm that the key is restricted to.
+// It returns [AlgorithmReserved] if the key has no restriction.
+func (key *Key) Algorithm() Algorithm {
+	return key.alg
+}
+
+// SetAlgorithm restricts the key to alg.
+func (key *Key) SetAlgorithm(alg Algorithm) {
+	key.alg = alg
+}
+
+// KeyOperations returns the permissible operations of the key.
+// nil means that the key has no restriction.
+func (key *Key) KeyOperations() []KeyOp {
+	return key.keyOps
+}
+
+// SetKeyOperations sets the permissible operations of the key.
+func (key *Key) SetKeyOperations(keyOps []KeyOp) {
+	key.keyOps = keyOps
+}
+
+// CanUseFor reports whether the key operations permit op.
+// The algorithms in the jwa packages use it to enforce the "key_ops" parameter of COSE_Key.
+func (key *Key) CanUseFor(op jwktypes.KeyOp) bool {
+	if key.keyOps == nil {
+		return true
+	}
+	for _, o := range key.keyOps {
+		if jwkOp, err := keyOpToJWK(o); err == nil && jwkOp == op {
+			return true
+		}
+	}
+	return false
+}
+
 // PrivateKey returns the private key of the key.
 func (key *Key) PrivateKey() goat.PrivateKey {
 	return key.priv
@@ -174,6 +288,56 @@ func decodeCommonKeyParameters(d *cborutils.Decoder, key *Key) {
 	if kid, ok := d.GetBytes(keyLabelKeyID); ok {
 		key.kid = kid
 	}
+
+	// alg
+	if alg, ok := d.GetInteger(keyLabelAlgorithm); ok {
+		i64, err := alg.Int64()
+		if err != nil {
+			d.SaveError(err)
+			return
+		}
+		key.alg = Algorithm(i64)
+	}
+
+	// key_ops
+	if d.Has(keyLabelKeyOps) {
+		ops, ok := d.GetArray(keyLabelKeyOps)
+		if !ok {
+			d.SaveError(errors.New("cose: invalid type for key_ops"))
+			return
+		}
+		key.keyOps = make([]KeyOp, 0, len(ops))
+		for _, op := range ops {
+			i, ok := op.(cbor.Integer)
+			if !ok {
+				d.SaveError(fmt.Errorf("cose: unsupported key operation: %v", op))
+				return
+			}
+			i64, err := i.Int64()
+			if err != nil {
+				d.SaveError(err)
+				return
+			}
+			key.keyOps = append(key.keyOps, KeyOp(i64))
+		}
+	}
+}
+
+func encodeCommonKeyParameters(e *cborutils.Encoder, key *Key) {
+	e.Set(keyLabelKeyType, cborutils.IntegerFromInt64(int64(key.kty)))
+	if kid := key.kid; kid != nil {
+		e.Set(keyLabelKeyID, kid)
+	}
+	if alg := key.alg; alg != AlgorithmReserved {
+		e.Set(keyLabelAlgorithm, cborutils.IntegerFromInt64(int64(alg)))
+	}
+	if keyOps := key.keyOps; keyOps != nil {
+		ops := make([]any, 0, len(keyOps))
+		for _, op := range keyOps {
+			ops = append(ops, cborutils.IntegerFromInt64(int64(op)))
+		}
+		e.Set(keyLabelKeyOps, ops)
+	}
 }
 
 // ParseKey parses a COSE_Key.
@@ -201,19 +365,16 @@ func ParseMap(raw map[any]any) (*Key, error) {
 
 	switch key.kty {
 	case KeyTypeOKP:
-		// TODO: implement me
-		return nil, fmt.Errorf("not implemented yet")
+		parseOKPKey(d, key)
 
 	case KeyTypeEC2:
 		parseEcdsaKey(d, key)
 
 	case KeyTypeRSA:
-		// TODO: implement me
-		return nil, fmt.Errorf("not implemented yet")
+		parseRSAKey(d, key)
 
 	case KeyTypeSymmetric:
-		// TODO: implement me
-		return nil, fmt.Errorf("not implemented yet")
+		parseSymmetricKey(d, key)
 
 	case KeyTypeHSS_LMS:
 		// TODO: implement me
@@ -233,6 +394,243 @@ func ParseMap(raw map[any]any) (*Key, error) {
 	return key, nil
 }
 
+var _ cbor.Unmarshaler = (*Key)(nil)
+
+// UnmarshalCBOR implements [cbor.Unmarshaler].
+func (key *Key) UnmarshalCBOR(data []byte) error {
+	k, err := ParseKey(data)
+	if err != nil {
+		return err
+	}
+	*key = *k
+	return nil
+}
+
+var _ cbor.CBORMarshaler = (*Key)(nil)
+
+// MarshalCBOR implements [cbor.CBORMarshaler].
+// The key is encoded with the core deterministic encoding defined in RFC 8949 Section 4.2.1.
+func (key *Key) MarshalCBOR() ([]byte, error) {
+	raw, err := key.encode()
+	if err != nil {
+		return nil, err
+	}
+	return cbor.Marshal(raw)
+}
+
+// encode encodes the key into a map.
+// The parameters in key.Raw that are not known are kept.
+func (key *Key) encode() (map[any]any, error) {
+	raw := make(map[any]any, len(key.Raw))
+	maps.Copy(raw, key.Raw)
+	e := cborutils.NewEncoder(raw)
+	encodeCommonKeyParameters(e, key)
+
+	switch priv := key.priv.(type) {
+	case *ecdsa.PrivateKey:
+		pub, ok := key.pub.(*ecdsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("cose: public key type is mismatch for ecdsa: %T", key.pub)
+		}
+		encodeEcdsaKey(e, priv, pub)
+	case *rsa.PrivateKey:
+		pub, ok := key.pub.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("cose: public key type is mismatch for rsa: %T", key.pub)
+		}
+		encodeRSAKey(e, priv, pub)
+	case ed25519.PrivateKey:
+		pub, ok := key.pub.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("cose: public key type is mismatch for ed25519: %T", key.pub)
+		}
+		encodeEd25519Key(e, priv, pub)
+	case ed448.PrivateKey:
+		pub, ok := key.pub.(ed448.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("cose: public key type is mismatch for ed448: %T", key.pub)
+		}
+		encodeEd448Key(e, priv, pub)
+	case x25519.PrivateKey:
+		pub, ok := key.pub.(x25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("cose: public key type is mismatch for x25519: %T", key.pub)
+		}
+		encodeX25519Key(e, priv, pub)
+	case x448.PrivateKey:
+		pub, ok := key.pub.(x448.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("cose: public key type is mismatch for x448: %T", key.pub)
+		}
+		encodeX448Key(e, priv, pub)
+	case []byte:
+		if key.pub != nil {
+			return nil, errors.New("cose: public key is not allowed for symmetric keys")
+		}
+		encodeSymmetricKey(e, priv)
+	case nil:
+		// the key has only public key.
+		switch pub := key.pub.(type) {
+		case *ecdsa.PublicKey:
+			encodeEcdsaKey(e, nil, pub)
+		case *rsa.PublicKey:
+			encodeRSAKey(e, nil, pub)
+		case ed25519.PublicKey:
+			encodeEd25519Key(e, nil, pub)
+		case ed448.PublicKey:
+			encodeEd448Key(e, nil, pub)
+		case x25519.PublicKey:
+			encodeX25519Key(e, nil, pub)
+		case x448.PublicKey:
+			encodeX448Key(e, nil, pub)
+		default:
+			return nil, fmt.Errorf("cose: unknown public key type: %T", key.pub)
+		}
+	default:
+		return nil, fmt.Errorf("cose: unknown private key type: %T", key.priv)
+	}
+
+	if err := e.Err(); err != nil {
+		return nil, err
+	}
+	return e.Data(), nil
+}
+
+// NewPrivateKey returns a new COSE_Key from the private key.
+//
+// key must be one of [*crypto/ecdsa.PrivateKey], [*crypto/rsa.PrivateKey], [crypto/ed25519.PrivateKey],
+// [x25519.PrivateKey], [ed448.PrivateKey], [x448.PrivateKey] or []byte.
+func NewPrivateKey(key goat.PrivateKey) (*Key, error) {
+	switch key := key.(type) {
+	case *ecdsa.PrivateKey:
+		if _, err := ecdsaCurve(key.Curve); err != nil {
+			return nil, err
+		}
+		if _, err := key.Bytes(); err != nil {
+			return nil, err
+		}
+		return &Key{
+			kty:  KeyTypeEC2,
+			priv: key,
+			pub:  key.Public(),
+		}, nil
+	case *rsa.PrivateKey:
+		if err := validateRSAPrivateKey(key); err != nil {
+			return nil, err
+		}
+		return &Key{
+			kty:  KeyTypeRSA,
+			priv: key,
+			pub:  key.Public(),
+		}, nil
+	case ed25519.PrivateKey:
+		if len(key) != ed25519.PrivateKeySize {
+			return nil, errors.New("cose: invalid ed25519 private key size")
+		}
+		return &Key{
+			kty:  KeyTypeOKP,
+			priv: key,
+			pub:  key.Public(),
+		}, nil
+	case ed448.PrivateKey:
+		if len(key) != ed448.PrivateKeySize {
+			return nil, errors.New("cose: invalid ed448 private key size")
+		}
+		return &Key{
+			kty:  KeyTypeOKP,
+			priv: key,
+			pub:  key.Public(),
+		}, nil
+	case x25519.PrivateKey:
+		if len(key) != x25519.PrivateKeySize {
+			return nil, errors.New("cose: invalid x25519 private key size")
+		}
+		return &Key{
+			kty:  KeyTypeOKP,
+			priv: key,
+			pub:  key.Public(),
+		}, nil
+	case x448.PrivateKey:
+		if len(key) != x448.PrivateKeySize {
+			return nil, errors.New("cose: invalid x448 private key size")
+		}
+		return &Key{
+			kty:  KeyTypeOKP,
+			priv: key,
+			pub:  key.Public(),
+		}, nil
+	case []byte:
+		return &Key{
+			kty:  KeyTypeSymmetric,
+			priv: append([]byte(nil), key...),
+		}, nil
+	default:
+		return nil, fmt.Errorf("cose: unknown private key type: %T", key)
+	}
+}
+
+// NewPublicKey returns a new COSE_Key from the public key.
+//
+// key must be one of [*crypto/ecdsa.PublicKey], [*crypto/rsa.PublicKey], [crypto/ed25519.PublicKey],
+// [x25519.PublicKey], [ed448.PublicKey] or [x448.PublicKey].
+func NewPublicKey(key goat.PublicKey) (*Key, error) {
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		if _, err := ecdsaCurve(key.Curve); err != nil {
+			return nil, err
+		}
+		if _, err := key.Bytes(); err != nil {
+			return nil, err
+		}
+		return &Key{
+			kty: KeyTypeEC2,
+			pub: key,
+		}, nil
+	case *rsa.PublicKey:
+		if err := validateRSAPublicKey(key); err != nil {
+			return nil, err
+		}
+		return &Key{
+			kty: KeyTypeRSA,
+			pub: key,
+		}, nil
+	case ed25519.PublicKey:
+		if len(key) != ed25519.PublicKeySize {
+			return nil, errors.New("cose: invalid ed25519 public key size")
+		}
+		return &Key{
+			kty: KeyTypeOKP,
+			pub: key,
+		}, nil
+	case ed448.PublicKey:
+		if len(key) != ed448.PublicKeySize {
+			return nil, errors.New("cose: invalid ed448 public key size")
+		}
+		return &Key{
+			kty: KeyTypeOKP,
+			pub: key,
+		}, nil
+	case x25519.PublicKey:
+		if len(key) != x25519.PublicKeySize {
+			return nil, errors.New("cose: invalid x25519 public key size")
+		}
+		return &Key{
+			kty: KeyTypeOKP,
+			pub: key,
+		}, nil
+	case x448.PublicKey:
+		if len(key) != x448.PublicKeySize {
+			return nil, errors.New("cose: invalid x448 public key size")
+		}
+		return &Key{
+			kty: KeyTypeOKP,
+			pub: key,
+		}, nil
+	default:
+		return nil, fmt.Errorf("cose: unknown public key type: %T", key)
+	}
+}
+
 // KeySet represents a COSE_KeySet.
 type KeySet struct {
 	Keys []*Key
@@ -262,40 +660,65 @@ func ParseKeySet(data []byte) (*KeySet, error) {
 	return keySet, nil
 }
 
+var _ cbor.Unmarshaler = (*KeySet)(nil)
+
+// UnmarshalCBOR implements [cbor.Unmarshaler].
+func (set *KeySet) UnmarshalCBOR(data []byte) error {
+	s, err := ParseKeySet(data)
+	if err != nil {
+		return err
+	}
+	*set = *s
+	return nil
+}
+
+var _ cbor.CBORMarshaler = (*KeySet)(nil)
+
+// MarshalCBOR implements [cbor.CBORMarshaler].
+// The keys are encoded with the core deterministic encoding defined in RFC 8949 Section 4.2.1.
+func (set *KeySet) MarshalCBOR() ([]byte, error) {
+	keys := make([]any, 0, len(set.Keys))
+	for _, key := range set.Keys {
+		raw, err := key.encode()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, raw)
+	}
+	return cbor.Marshal(keys)
+}
+
+// Find finds the key that has the key ID.
+func (set *KeySet) Find(kid []byte) (key *Key, found bool) {
+	for _, k := range set.Keys {
+		if bytes.Equal(k.kid, kid) {
+			return k, true
+		}
+	}
+	return nil, false
+}
+
 func parseEcdsaKey(d *cborutils.Decoder, key *Key) {
 	// curve
 	var curve elliptic.Curve
 	var size int
-	if crv, ok := d.GetInteger(-1); ok {
-		i64, err := crv.Int64()
-		if err != nil {
-			d.SaveError(err)
-		}
-		switch i64 {
-		case curveP256:
-			curve = elliptic.P256()
-			size = 32
-		case curveP384:
-			curve = elliptic.P384()
-			size = 48
-		case curveP521:
-			curve = elliptic.P521()
-			size = 66
-		}
-	} else if crv, ok := d.GetString(-1); ok {
-		switch crv {
-		case curveNameP256:
-			curve = elliptic.P256()
-			size = 32
-		case curveNameP384:
-			curve = elliptic.P384()
-			size = 48
-		case curveNameP521:
-			curve = elliptic.P521()
-			size = 66
-		}
-	} else {
-		d.SaveError(errors.New("missing curve"))
+	crv := decodeCurve(d)
+	if err := d.Err(); err != nil {
+		return
+	}
+	switch crv {
+	case curveP256:
+		curve = elliptic.P256()
+		size = 32
+	case curveP384:
+		curve = elliptic.P384()
+		size = 48
+	case curveP521:
+		curve = elliptic.P521()
+		size = 66
+	default:
+		d.SaveError(fmt.Errorf("cose: unknown curve for EC2: %d", crv))
+		return
 	}
 
 	// parameters for public key
@@ -329,3 +752,51 @@ func parseEcdsaKey(d *cborutils.Decoder, key *Key) {
 		key.priv = priv
 	}
 }
+
+// ecdsaCurve returns the COSE curve identifier of the curve.
+func ecdsaCurve(curve elliptic.Curve) (int64, error) {
+	switch curve {
+	case elliptic.P256():
+		return curveP256, nil
+	case elliptic.P384():
+		return curveP384, nil
+	case elliptic.P521():
+		return curveP521, nil
+	default:
+		return 0, fmt.Errorf("cose: unknown curve: %q", curve.Params().Name)
+	}
+}
+
+func encodeEcdsaKey(e *cborutils.Encoder, priv *ecdsa.PrivateKey, pub *ecdsa.PublicKey) {
+	crv, err := ecdsaCurve(pub.Curve)
+	if err != nil {
+		e.SaveError(err)
+		return
+	}
+	e.Set(keyLabelKeyType, cborutils.IntegerFromInt64(int64(KeyTypeEC2)))
+	e.Set(-1, cborutils.IntegerFromInt64(crv))
+
+	// encode the public key.
+	data, err := pub.Bytes()
+	if err != nil {
+		e.SaveError(err)
+		return
+	}
+	size := (len(data) - 1) / 2
+	e.Set(-2, data[1:1+size])
+	e.Set(-3, data[1+size:])
+
+	// encode the private key.
+	if priv != nil {
+		if !priv.PublicKey.Equal(pub) {
+			e.SaveError(errors.New("cose: invalid key pair"))
+			return
+		}
+		data, err := priv.Bytes()
+		if err != nil {
+			e.SaveError(err)
+			return
+		}
+		e.Set(-4, data)
+	}
+}
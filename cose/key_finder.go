@@ -0,0 +1,133 @@
+package cose
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/shogo82148/goat"
+	"github.com/shogo82148/goat/internal/clockutils"
+	"github.com/shogo82148/goat/sig"
+)
+
+// KeyFinder finds a signing key for the COSE message.
+// protected and unprotected are the headers of the signer.
+type KeyFinder interface {
+	FindKey(ctx context.Context, protected, unprotected *Header) (key sig.SigningKey, err error)
+}
+
+// FindKeyFunc is an adapter to allow the use of ordinary functions as KeyFinder.
+type FindKeyFunc func(ctx context.Context, protected, unprotected *Header) (key sig.SigningKey, err error)
+
+func (f FindKeyFunc) FindKey(ctx context.Context, protected, unprotected *Header) (key sig.SigningKey, err error) {
+	return f(ctx, protected, unprotected)
+}
+
+// KeySetKeyFinder finds a signing key from the key set by the "kid" header parameter.
+// If the key is restricted to an algorithm, it must match the "alg" header parameter.
+type KeySetKeyFinder struct {
+	KeySet *KeySet
+}
+
+func (f *KeySetKeyFinder) FindKey(ctx context.Context, protected, unprotected *Header) (key sig.SigningKey, err error) {
+	kid := lookupKeyID(protected, unprotected)
+	if kid == nil {
+		return nil, errors.New("cose: kid is missing")
+	}
+	k, ok := f.KeySet.Find(kid)
+	if !ok {
+		return nil, errors.New("cose: key not found")
+	}
+
+	alg := lookupAlgorithm(protected, unprotected)
+	if keyAlg := k.Algorithm(); keyAlg != AlgorithmReserved && keyAlg != alg {
+		return nil, fmt.Errorf("cose: requested alg %v is not supported by the key", alg)
+	}
+	sigAlg := alg.SignatureAlgorithm()
+	if !sigAlg.Available() {
+		return nil, errors.New("cose: algorithm not available")
+	}
+	return sigAlg.New().NewSigningKey(k), nil
+}
+
+// X509KeyFinder finds a signing key from the X.509 certificate header parameters defined in RFC 9360.
+// The public key is extracted from the first certificate of the "x5chain" header parameter.
+// If the message has no "x5chain", the certificate that matches the "x5t" header parameter
+// is searched from the "x5bag" header parameter and Certificates.
+type X509KeyFinder struct {
+	// Roots is the set of trusted root certificates.
+	// If it is nil, the certificate chain is NOT validated
+	// and the certificate is trusted as is.
+	Roots *x509.CertPool
+
+	// Certificates are the certificates known by the verifier.
+	// They are used to resolve the "x5t" header parameter and as intermediate certificates.
+	Certificates []*x509.Certificate
+
+	// KeyUsages specifies which Extended Key Usage values are acceptable.
+	// If it is empty, any usage is acceptable.
+	KeyUsages []x509.ExtKeyUsage
+
+	// Clock is used to check the validity of the certificates.
+	// If it is nil, the system clock is used.
+	Clock goat.Clock
+}
+
+func (f *X509KeyFinder) FindKey(ctx context.Context, protected, unprotected *Header) (key sig.SigningKey, err error) {
+	chain := lookupX509CertificateChain(protected, unprotected)
+	bag := lookupX509Bag(protected, unprotected)
+	x5t := lookupX509CertificateHash(protected, unprotected)
+
+	var leaf *x509.Certificate
+	switch {
+	case len(chain) > 0:
+		leaf = chain[0]
+		if x5t != nil && !x5t.Match(leaf) {
+			return nil, errors.New("cose: x5t does not match the certificate")
+		}
+	case x5t != nil:
+		for _, cert := range slices.Concat(bag, f.Certificates) {
+			if x5t.Match(cert) {
+				leaf = cert
+				break
+			}
+		}
+		if leaf == nil {
+			return nil, errors.New("cose: certificate not found")
+		}
+	default:
+		return nil, errors.New("cose: x5chain is missing")
+	}
+
+	if f.Roots != nil {
+		intermediates := x509.NewCertPool()
+		for _, cert := range slices.Concat(chain[min(1, len(chain)):], bag, f.Certificates) {
+			intermediates.AddCert(cert)
+		}
+		usages := f.KeyUsages
+		if len(usages) == 0 {
+			usages = []x509.ExtKeyUsage{x509.ExtKeyUsageAny}
+		}
+		opts := x509.VerifyOptions{
+			Roots:         f.Roots,
+			Intermediates: intermediates,
+			KeyUsages:     usages,
+			CurrentTime:   clockutils.Now(f.Clock),
+		}
+		if _, err := leaf.Verify(opts); err != nil {
+			return nil, fmt.Errorf("cose: failed to verify the certificate chain: %w", err)
+		}
+	}
+
+	k, err := NewPublicKey(leaf.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	alg := lookupAlgorithm(protected, unprotected).SignatureAlgorithm()
+	if !alg.Available() {
+		return nil, errors.New("cose: algorithm not available")
+	}
+	return alg.New().NewSigningKey(k), nil
+}
@@ -2,11 +2,25 @@ package cose
 
 import (
 	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"fmt"
+	"math/big"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/shogo82148/go-cbor"
+	"github.com/shogo82148/goat/ed448"
+	"github.com/shogo82148/goat/internal/cborutils"
+	"github.com/shogo82148/goat/x25519"
+	"github.com/shogo82148/goat/x448"
 )
 
 func TestParseMap(t *testing.T) {
@@ -39,4 +53,422 @@ func TestParseMap(t *testing.T) {
 			t.Errorf("unexpected key id: %v,  want 11", key.kid)
 		}
 	})
+
+	t.Run("2", func(t *testing.T) {
+		key, err := ParseMap(set[2])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key.KeyType() != KeyTypeSymmetric {
+			t.Errorf("unexpected key type: %v", key.kty)
+		}
+		if string(key.KeyID()) != "our-secret" {
+			t.Errorf("unexpected key id: %v,  want our-secret", key.kid)
+		}
+		want, _ := hex.DecodeString("849b57219dae48de646d07dbb533566e976686457c1491be3a76dcea6c427188")
+		if !bytes.Equal(key.PrivateKey().([]byte), want) {
+			t.Errorf("unexpected key: %x", key.PrivateKey())
+		}
+		if key.PublicKey() != nil {
+			t.Errorf("unexpected public key: %v", key.PublicKey())
+		}
+	})
+}
+
+func TestParseMap_OKP(t *testing.T) {
+	tests := []struct {
+		crv  int64
+		x    string
+		d    string
+		priv any
+		pub  any
+	}{
+		{
+			// from eddsa-examples/eddsa-01.json
+			crv:  curveEd25519,
+			x:    "d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a",
+			d:    "9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60",
+			priv: ed25519.PrivateKey(nil),
+			pub:  ed25519.PublicKey(nil),
+		},
+		{
+			// from eddsa-examples/eddsa-02.json
+			crv:  curveEd448,
+			x:    "5fd7449b59b461fd2ce787ec616ad46a1da1342485a70e1f8a0ea75d80e96778edf124769b46c7061bd6783df1e50f6cd1fa1abeafe8256180",
+			d:    "6c82a562cb808d10d632be89c8513ebf6c929f34ddfa8c9f63c9960ef6e348a3528c8a3fcc2f044e39a3fc5b94492f8f032e7549a20098f95b",
+			priv: ed448.PrivateKey(nil),
+			pub:  ed448.PublicKey(nil),
+		},
+		{
+			// from X25519-tests/x25519-hkdf-256-direct.json
+			crv:  curveX25519,
+			x:    "7ffe91f5f932dae92be603f55fac0f4c4c9328906ee550edcb7f6f7626ebc07e",
+			d:    "00a943daa2e38b2edbf0da0434eaaec6016fe25dcd5ecacbc07dc30300567655",
+			priv: x25519.PrivateKey(nil),
+			pub:  x25519.PublicKey(nil),
+		},
+		{
+			// from RFC 7748 Section 6.2.
+			crv:  curveX448,
+			x:    "9b08f7cc31b7e3e67d22d5aea121074a273bd2b83de09c63faa73d2c22c5d9bbc836647241d953d40c5b12da88120d53177f80e532c41fa0",
+			d:    "9a8f4925d1519f5775cf46b04b5800d4ee9ee8bae8bc5565d498c28dd9c9baf574a9419744897391006382a6f127ab1d9ac2d8c0a598726b",
+			priv: x448.PrivateKey(nil),
+			pub:  x448.PublicKey(nil),
+		},
+	}
+
+	for _, tt := range tests {
+		x, _ := hex.DecodeString(tt.x)
+		d, _ := hex.DecodeString(tt.d)
+
+		// private key
+		key, err := ParseMap(map[any]any{
+			cborutils.IntegerFromInt64(keyLabelKeyType): cborutils.IntegerFromInt64(int64(KeyTypeOKP)),
+			cborutils.IntegerFromInt64(-1):              cborutils.IntegerFromInt64(tt.crv),
+			cborutils.IntegerFromInt64(-2):              x,
+			cborutils.IntegerFromInt64(-4):              d,
+		})
+		if err != nil {
+			t.Fatalf("crv %d: %v", tt.crv, err)
+		}
+		if reflect.TypeOf(key.PrivateKey()) != reflect.TypeOf(tt.priv) {
+			t.Errorf("crv %d: unexpected private key type: %T", tt.crv, key.PrivateKey())
+		}
+		if reflect.TypeOf(key.PublicKey()) != reflect.TypeOf(tt.pub) {
+			t.Errorf("crv %d: unexpected public key type: %T", tt.crv, key.PublicKey())
+		}
+		pub := key.PrivateKey().(interface{ Public() crypto.PublicKey }).Public().(interface{ Equal(crypto.PublicKey) bool })
+		if !pub.Equal(key.PublicKey()) {
+			t.Errorf("crv %d: key pair mismatch", tt.crv)
+		}
+
+		// the public key can be omitted.
+		key, err = ParseMap(map[any]any{
+			cborutils.IntegerFromInt64(keyLabelKeyType): cborutils.IntegerFromInt64(int64(KeyTypeOKP)),
+			cborutils.IntegerFromInt64(-1):              cborutils.IntegerFromInt64(tt.crv),
+			cborutils.IntegerFromInt64(-4):              d,
+		})
+		if err != nil {
+			t.Fatalf("crv %d: %v", tt.crv, err)
+		}
+		if !pub.Equal(key.PublicKey()) {
+			t.Errorf("crv %d: unexpected public key", tt.crv)
+		}
+
+		// public key
+		key, err = ParseMap(map[any]any{
+			cborutils.IntegerFromInt64(keyLabelKeyType): cborutils.IntegerFromInt64(int64(KeyTypeOKP)),
+			cborutils.IntegerFromInt64(-1):              cborutils.IntegerFromInt64(tt.crv),
+			cborutils.IntegerFromInt64(-2):              x,
+		})
+		if err != nil {
+			t.Fatalf("crv %d: %v", tt.crv, err)
+		}
+		if key.PrivateKey() != nil {
+			t.Errorf("crv %d: unexpected private key", tt.crv)
+		}
+		if !pub.Equal(key.PublicKey()) {
+			t.Errorf("crv %d: unexpected public key", tt.crv)
+		}
+
+		// invalid key pair
+		x[0] ^= 0xff
+		_, err = ParseMap(map[any]any{
+			cborutils.IntegerFromInt64(keyLabelKeyType): cborutils.IntegerFromInt64(int64(KeyTypeOKP)),
+			cborutils.IntegerFromInt64(-1):              cborutils.IntegerFromInt64(tt.crv),
+			cborutils.IntegerFromInt64(-2):              x,
+			cborutils.IntegerFromInt64(-4):              d,
+		})
+		if err == nil {
+			t.Errorf("crv %d: want error, but not", tt.crv)
+		}
+	}
+}
+
+func TestParseMap_RSA(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := map[any]any{
+		cborutils.IntegerFromInt64(keyLabelKeyType): cborutils.IntegerFromInt64(int64(KeyTypeRSA)),
+		cborutils.IntegerFromInt64(keyLabelRSAN):    priv.N.Bytes(),
+		cborutils.IntegerFromInt64(keyLabelRSAE):    big.NewInt(int64(priv.E)).Bytes(),
+	}
+
+	t.Run("public", func(t *testing.T) {
+		key, err := ParseMap(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key.PrivateKey() != nil {
+			t.Errorf("unexpected private key")
+		}
+		if !priv.PublicKey.Equal(key.PublicKey()) {
+			t.Errorf("unexpected public key")
+		}
+	})
+
+	raw[cborutils.IntegerFromInt64(keyLabelRSAD)] = priv.D.Bytes()
+	raw[cborutils.IntegerFromInt64(keyLabelRSAP)] = priv.Primes[0].Bytes()
+	raw[cborutils.IntegerFromInt64(keyLabelRSAQ)] = priv.Primes[1].Bytes()
+	t.Run("private", func(t *testing.T) {
+		key, err := ParseMap(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := key.PrivateKey().(*rsa.PrivateKey)
+		if !priv.Equal(got) {
+			t.Errorf("unexpected private key")
+		}
+		if got.Precomputed.Dp == nil {
+			t.Errorf("precomputed values are not computed")
+		}
+	})
+
+	raw[cborutils.IntegerFromInt64(keyLabelRSADP)] = priv.Precomputed.Dp.Bytes()
+	raw[cborutils.IntegerFromInt64(keyLabelRSADQ)] = priv.Precomputed.Dq.Bytes()
+	raw[cborutils.IntegerFromInt64(keyLabelRSAQInv)] = priv.Precomputed.Qinv.Bytes()
+	t.Run("crt", func(t *testing.T) {
+		key, err := ParseMap(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := key.PrivateKey().(*rsa.PrivateKey)
+		if !priv.Equal(got) {
+			t.Errorf("unexpected private key")
+		}
+		if got.Precomputed.Dp.Cmp(priv.Precomputed.Dp) != 0 ||
+			got.Precomputed.Dq.Cmp(priv.Precomputed.Dq) != 0 ||
+			got.Precomputed.Qinv.Cmp(priv.Precomputed.Qinv) != 0 {
+			t.Errorf("unexpected precomputed values")
+		}
+	})
+
+	raw[cborutils.IntegerFromInt64(keyLabelRSAQ)] = priv.Primes[0].Bytes()
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := ParseMap(raw); err == nil {
+			t.Error("want error, but not")
+		}
+	})
+}
+
+func TestKey_MarshalCBOR(t *testing.T) {
+	t.Run("deterministic encoding", func(t *testing.T) {
+		// the key "our-secret2" in KeySet.txt
+		data, err := cbor.DecodeEDN([]byte(`{1: 4, 2: 'our-secret2', -1: h'849b5786457c1491be3a76dcea6c4271'}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := ParseKey(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := key.MarshalCBOR()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := hex.DecodeString("a30104024b6f75722d7365637265743220" + "50849b5786457c1491be3a76dcea6c4271")
+		if !bytes.Equal(got, want) {
+			t.Errorf("unexpected encoding: got %x, want %x", got, want)
+		}
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		data, err := os.ReadFile(filepath.Join("testdata", "cose-wg-examples", "KeySet.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, err := cbor.DecodeEDN(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var set []map[any]any
+		dec := cbor.NewDecoder(bytes.NewReader(msg))
+		dec.UseAnyKey()
+		dec.UseInteger()
+		if err := dec.Decode(&set); err != nil {
+			t.Fatal(err)
+		}
+
+		// the first 7 keys are COSE_Key; the others use text string labels.
+		for _, raw := range set[:7] {
+			key, err := ParseMap(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := key.MarshalCBOR()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ParseKey(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got.KeyID(), key.KeyID()) {
+				t.Errorf("unexpected key id: got %q, want %q", got.KeyID(), key.KeyID())
+			}
+			if got.KeyType() != key.KeyType() {
+				t.Errorf("%q: unexpected key type: got %v, want %v", key.KeyID(), got.KeyType(), key.KeyType())
+			}
+			if !reflect.DeepEqual(got.PrivateKey(), key.PrivateKey()) {
+				t.Errorf("%q: unexpected private key", key.KeyID())
+			}
+		}
+	})
+}
+
+func TestNewPrivateKey(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ed448Key, err := ed448.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, x25519Key, err := x25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, x448Key, err := x448.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		kty  KeyType
+		priv interface {
+			Public() crypto.PublicKey
+			Equal(crypto.PrivateKey) bool
+		}
+	}{
+		{KeyTypeEC2, ecdsaKey},
+		{KeyTypeRSA, rsaKey},
+		{KeyTypeOKP, ed25519Key},
+		{KeyTypeOKP, ed448Key},
+		{KeyTypeOKP, x25519Key},
+		{KeyTypeOKP, x448Key},
+	}
+	for _, tt := range tests {
+		name := fmt.Sprintf("%T", tt.priv)
+
+		// private key
+		key, err := NewPrivateKey(tt.priv)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		key.SetKeyID([]byte("kid"))
+		data, err := key.MarshalCBOR()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, err := ParseKey(data)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got.KeyType() != tt.kty {
+			t.Errorf("%s: unexpected key type: %v", name, got.KeyType())
+		}
+		if string(got.KeyID()) != "kid" {
+			t.Errorf("%s: unexpected key id: %q", name, got.KeyID())
+		}
+		if !tt.priv.Equal(got.PrivateKey()) {
+			t.Errorf("%s: unexpected private key", name)
+		}
+
+		// public key
+		pub := tt.priv.Public()
+		key, err = NewPublicKey(pub)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		data, err = key.MarshalCBOR()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, err = ParseKey(data)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got.PrivateKey() != nil {
+			t.Errorf("%s: unexpected private key", name)
+		}
+		if !pub.(interface{ Equal(crypto.PublicKey) bool }).Equal(got.PublicKey()) {
+			t.Errorf("%s: unexpected public key", name)
+		}
+	}
+
+	t.Run("symmetric", func(t *testing.T) {
+		key, err := NewPrivateKey([]byte("secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := key.MarshalCBOR()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ParseKey(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.KeyType() != KeyTypeSymmetric {
+			t.Errorf("unexpected key type: %v", got.KeyType())
+		}
+		if string(got.PrivateKey().([]byte)) != "secret" {
+			t.Errorf("unexpected key: %q", got.PrivateKey())
+		}
+	})
+}
+
+func TestKeySet_MarshalCBOR(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key1, err := NewPublicKey(priv.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key1.SetKeyID([]byte("ec2"))
+	key2, err := NewPrivateKey([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key2.SetKeyID([]byte("symmetric"))
+
+	set := &KeySet{Keys: []*Key{key1, key2}}
+	data, err := set.MarshalCBOR()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseKeySet(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Keys) != 2 {
+		t.Fatalf("unexpected number of keys: %d", len(got.Keys))
+	}
+	key, ok := got.Find([]byte("ec2"))
+	if !ok {
+		t.Fatal("key ec2 is not found")
+	}
+	if !priv.PublicKey.Equal(key.PublicKey()) {
+		t.Error("unexpected public key")
+	}
+	key, ok = got.Find([]byte("symmetric"))
+	if !ok {
+		t.Fatal("key symmetric is not found")
+	}
+	if string(key.PrivateKey().([]byte)) != "secret" {
+		t.Errorf("unexpected key: %q", key.PrivateKey())
+	}
 }
@@ -0,0 +1,219 @@
+package cose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/shogo82148/go-cbor"
+	"github.com/shogo82148/goat/keymanage"
+)
+
+// MacMessage is a COSE_Mac message defined in RFC 9052 Section 6.1.
+type MacMessage struct {
+	// Protected is the protected header.
+	Protected *Header
+
+	// Unprotected is the unprotected header.
+	Unprotected *Header
+
+	// Payload is the content of the message.
+	// nil means that the content is detached.
+	Payload []byte
+
+	// Tag is the authentication tag of the message.
+	Tag []byte
+
+	// Recipients is the list of the recipients.
+	Recipients []*Recipient
+
+	rawProtected []byte
+	cek          []byte
+}
+
+// NewMacMessage returns a new COSE_Mac message that has no authentication tag.
+func NewMacMessage(payload []byte) *MacMessage {
+	return &MacMessage{
+		Protected:   NewHeader(),
+		Unprotected: NewHeader(),
+		Payload:     payload,
+	}
+}
+
+// ParseMac parses a tagged or untagged COSE_Mac message.
+func ParseMac(data []byte) (*MacMessage, error) {
+	var msg MacMessage
+	if err := msg.UnmarshalCBOR(data); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// UnmarshalCBOR implements [cbor.Unmarshaler].
+// It accepts both tagged and untagged COSE_Mac messages.
+func (msg *MacMessage) UnmarshalCBOR(data []byte) error {
+	array, err := decodeMessage(data, TagNumberCOSEMac, 5)
+	if err != nil {
+		return err
+	}
+
+	protected, unprotected, rawProtected, err := decodeHeaders(array[0], array[1])
+	if err != nil {
+		return err
+	}
+	payload, err := decodePayload(array[2])
+	if err != nil {
+		return err
+	}
+	tag, ok := array[3].([]byte)
+	if !ok {
+		return fmt.Errorf("cose: invalid type of tag: %T", array[3])
+	}
+	recipients, err := decodeRecipients(array[4])
+	if err != nil {
+		return err
+	}
+
+	*msg = MacMessage{
+		Protected:    protected,
+		Unprotected:  unprotected,
+		Payload:      payload,
+		Tag:          tag,
+		Recipients:   recipients,
+		rawProtected: rawProtected,
+	}
+	return nil
+}
+
+// MarshalCBOR implements [cbor.CBORMarshaler].
+// It encodes the message as a tagged COSE_Mac message.
+func (msg *MacMessage) MarshalCBOR() ([]byte, error) {
+	array, err := msg.encode()
+	if err != nil {
+		return nil, err
+	}
+	return cbor.Marshal(cbor.Tag{
+		Number:  TagNumberCOSEMac,
+		Content: array,
+	})
+}
+
+// MarshalCBORUntagged encodes the message as an untagged COSE_Mac message.
+func (msg *MacMessage) MarshalCBORUntagged() ([]byte, error) {
+	array, err := msg.encode()
+	if err != nil {
+		return nil, err
+	}
+	return cbor.Marshal(array)
+}
+
+func (msg *MacMessage) encode() ([]any, error) {
+	if msg.Tag == nil {
+		return nil, errors.New("cose: the message is not authenticated")
+	}
+	if len(msg.Recipients) == 0 {
+		return nil, errors.New("cose: no recipients")
+	}
+	rawProtected, err := msg.serializedProtected()
+	if err != nil {
+		return nil, err
+	}
+	unprotected, err := encodeHeader(msg.Unprotected)
+	if err != nil {
+		return nil, err
+	}
+	recipients, err := encodeRecipients(msg.Recipients)
+	if err != nil {
+		return nil, err
+	}
+	return []any{
+		rawProtected,
+		unprotected,
+		encodePayload(msg.Payload),
+		msg.Tag,
+		recipients,
+	}, nil
+}
+
+func (msg *MacMessage) serializedProtected() ([]byte, error) {
+	if msg.rawProtected != nil {
+		return msg.rawProtected, nil
+	}
+	return encodeProtectedHeader(msg.Protected)
+}
+
+// Sign computes the authentication tag of the message with cek.
+// The MAC algorithm is specified by the "alg" header parameter.
+// external is the externally supplied data described in RFC 9052 Section 4.3.
+// Use AddRecipient to distribute cek to the recipients.
+func (msg *MacMessage) Sign(cek []byte, external []byte) error {
+	if err := checkHeaders(msg.Protected, msg.Unprotected); err != nil {
+		return err
+	}
+	alg := lookupAlgorithm(msg.Protected, msg.Unprotected)
+	if !alg.isMAC() {
+		return errors.New("cose: failed to compute tag: MAC algorithm is not set")
+	}
+
+	rawProtected, err := encodeProtectedHeader(msg.Protected)
+	if err != nil {
+		return err
+	}
+	toBeMaced, err := macStructure(contextMAC, rawProtected, external, msg.Payload)
+	if err != nil {
+		return err
+	}
+	tag, err := alg.NewMACKey(symmetricKey(cek)).Sign(toBeMaced)
+	if err != nil {
+		return fmt.Errorf("cose: failed to compute tag: %w", err)
+	}
+
+	msg.rawProtected = rawProtected
+	msg.Tag = tag
+	msg.cek = cek
+	return nil
+}
+
+// AddRecipient adds a new recipient that receives the key used by Sign.
+// kw wraps the key for the recipient.
+func (msg *MacMessage) AddRecipient(protected, unprotected *Header, kw keymanage.KeyWrapper) error {
+	if msg.cek == nil {
+		return errors.New("cose: the message is not authenticated")
+	}
+	alg := lookupAlgorithm(msg.Protected, msg.Unprotected)
+	r, err := newRecipient(alg, protected, unprotected, kw, msg.cek)
+	if err != nil {
+		return err
+	}
+	msg.Recipients = append(msg.Recipients, r)
+	return nil
+}
+
+// Verify verifies the authentication tag of the message.
+// finder finds the key wrapper that unwraps the key for one of the recipients.
+// external is the externally supplied data described in RFC 9052 Section 4.3.
+// If the content is detached, set Payload before calling Verify.
+func (msg *MacMessage) Verify(ctx context.Context, finder KeyWrapperFinder, external []byte) error {
+	alg := lookupAlgorithm(msg.Protected, msg.Unprotected)
+	if !alg.isMAC() {
+		return fmt.Errorf("cose: unknown MAC algorithm: %v", alg)
+	}
+
+	cek, err := findCEK(ctx, finder, msg.Protected, msg.Unprotected, msg.Recipients)
+	if err != nil {
+		return err
+	}
+
+	rawProtected, err := msg.serializedProtected()
+	if err != nil {
+		return err
+	}
+	toBeMaced, err := macStructure(contextMAC, rawProtected, external, msg.Payload)
+	if err != nil {
+		return err
+	}
+	if err := alg.NewMACKey(symmetricKey(cek)).Verify(toBeMaced, msg.Tag); err != nil {
+		return fmt.Errorf("cose: failed to verify: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package cose
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/shogo82148/go-cbor"
+	"github.com/shogo82148/goat/sig"
+)
+
+// Mac0Message is a COSE_Mac0 message defined in RFC 9052 Section 6.2.
+type Mac0Message struct {
+	// Protected is the protected header.
+	Protected *Header
+
+	// Unprotected is the unprotected header.
+	Unprotected *Header
+
+	// Payload is the content of the message.
+	// nil means that the content is detached.
+	Payload []byte
+
+	// Tag is the authentication tag of the message.
+	Tag []byte
+
+	rawProtected []byte
+}
+
+// NewMac0Message returns a new COSE_Mac0 message that has no authentication tag.
+func NewMac0Message(payload []byte) *Mac0Message {
+	return &Mac0Message{
+		Protected:   NewHeader(),
+		Unprotected: NewHeader(),
+		Payload:     payload,
+	}
+}
+
+// ParseMac0 parses a tagged or untagged COSE_Mac0 message.
+func ParseMac0(data []byte) (*Mac0Message, error) {
+	var msg Mac0Message
+	if err := msg.UnmarshalCBOR(data); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// UnmarshalCBOR implements [cbor.Unmarshaler].
+// It accepts both tagged and untagged COSE_Mac0 messages.
+func (msg *Mac0Message) UnmarshalCBOR(data []byte) error {
+	array, err := decodeMessage(data, TagNumberCOSEMac0, 4)
+	if err != nil {
+		return err
+	}
+
+	protected, unprotected, rawProtected, err := decodeHeaders(array[0], array[1])
+	if err != nil {
+		return err
+	}
+	payload, err := decodePayload(array[2])
+	if err != nil {
+		return err
+	}
+	tag, ok := array[3].([]byte)
+	if !ok {
+		return fmt.Errorf("cose: invalid type of tag: %T", array[3])
+	}
+
+	*msg = Mac0Message{
+		Protected:    protected,
+		Unprotected:  unprotected,
+		Payload:      payload,
+		Tag:          tag,
+		rawProtected: rawProtected,
+	}
+	return nil
+}
+
+// MarshalCBOR implements [cbor.CBORMarshaler].
+// It encodes the message as a tagged COSE_Mac0 message.
+func (msg *Mac0Message) MarshalCBOR() ([]byte, error) {
+	array, err := msg.encode()
+	if err != nil {
+		return nil, err
+	}
+	return cbor.Marshal(cbor.Tag{
+		Number:  TagNumberCOSEMac0,
+		Content: array,
+	})
+}
+
+// MarshalCBORUntagged encodes the message as an untagged COSE_Mac0 message.
+func (msg *Mac0Message) MarshalCBORUntagged() ([]byte, error) {
+	array, err := msg.encode()
+	if err != nil {
+		return nil, err
+	}
+	return cbor.Marshal(array)
+}
+
+func (msg *Mac0Message) encode() ([]any, error) {
+	if msg.Tag == nil {
+		return nil, errors.New("cose: the message is not authenticated")
+	}
+	rawProtected, err := msg.serializedProtected()
+	if err != nil {
+		return nil, err
+	}
+	unprotected, err := encodeHeader(msg.Unprotected)
+	if err != nil {
+		return nil, err
+	}
+	return []any{
+		rawProtected,
+		unprotected,
+		encodePayload(msg.Payload),
+		msg.Tag,
+	}, nil
+}
+
+func (msg *Mac0Message) serializedProtected() ([]byte, error) {
+	if msg.rawProtected != nil {
+		return msg.rawProtected, nil
+	}
+	return encodeProtectedHeader(msg.Protected)
+}
+
+// Sign computes the authentication tag of the message with key.
+// key is typically created by [Algorithm.NewMACKey].
+// external is the externally supplied data described in RFC 9052 Section 4.3.
+func (msg *Mac0Message) Sign(key sig.SigningKey, external []byte) error {
+	if err := checkHeaders(msg.Protected, msg.Unprotected); err != nil {
+		return err
+	}
+	if !lookupAlgorithm(msg.Protected, msg.Unprotected).isMAC() {
+		return errors.New("cose: failed to compute tag: MAC algorithm is not set")
+	}
+
+	rawProtected, err := encodeProtectedHeader(msg.Protected)
+	if err != nil {
+		return err
+	}
+	toBeMaced, err := macStructure(contextMAC0, rawProtected, external, msg.Payload)
+	if err != nil {
+		return err
+	}
+	tag, err := key.Sign(toBeMaced)
+	if err != nil {
+		return fmt.Errorf("cose: failed to compute tag: %w", err)
+	}
+
+	msg.rawProtected = rawProtected
+	msg.Tag = tag
+	return nil
+}
+
+// Verify verifies the authentication tag of the message with key.
+// key is typically created by [Algorithm.NewMACKey].
+// external is the externally supplied data described in RFC 9052 Section 4.3.
+// If the content is detached, set Payload before calling Verify.
+func (msg *Mac0Message) Verify(key sig.SigningKey, external []byte) error {
+	alg := lookupAlgorithm(msg.Protected, msg.Unprotected)
+	if !alg.isMAC() {
+		return fmt.Errorf("cose: unknown MAC algorithm: %v", alg)
+	}
+
+	rawProtected, err := msg.serializedProtected()
+	if err != nil {
+		return err
+	}
+	toBeMaced, err := macStructure(contextMAC0, rawProtected, external, msg.Payload)
+	if err != nil {
+		return err
+	}
+	if err := key.Verify(toBeMaced, msg.Tag); err != nil {
+		return fmt.Errorf("cose: failed to verify: %w", err)
+	}
+	return nil
+}
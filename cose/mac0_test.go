@@ -0,0 +1,101 @@
+package cose
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMac0Message_Verify(t *testing.T) {
+	dirs := []string{"mac0-tests", "hmac-examples", "cbc-mac-examples"}
+	for _, dir := range dirs {
+		for name, v := range readTestVectors(t, dir) {
+			if v.Input.Mac0 == nil {
+				continue
+			}
+			t.Run(dir+"/"+name, func(t *testing.T) {
+				input := v.Input.Mac0
+				alg := testAlgorithms[input.Alg]
+				secret := parseTestKey(t, input.Recipients[0].Key).PrivateKey().([]byte)
+				key := alg.NewMACKey(symmetricKey(secret))
+				external := mustHex(t, input.External)
+				data := mustHex(t, v.Output.CBOR)
+
+				msg, err := ParseMac0(data)
+				if err != nil {
+					if v.Fail {
+						return
+					}
+					t.Fatal(err)
+				}
+				err = msg.Verify(key, external)
+				if v.Fail {
+					if err == nil {
+						t.Error("want error, but not")
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !bytes.Equal(msg.Payload, []byte(v.Input.Plaintext)) {
+					t.Errorf("unexpected payload: got %q, want %q", msg.Payload, v.Input.Plaintext)
+				}
+
+				// check the intermediate value
+				toBeMaced, err := macStructure(contextMAC0, msg.rawProtected, external, msg.Payload)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := mustHex(t, v.Intermediates.ToMacHex); !bytes.Equal(toBeMaced, want) {
+					t.Errorf("unexpected ToMac: got %x, want %x", toBeMaced, want)
+				}
+
+				// round trip
+				var got []byte
+				if data[0] == 0xd1 { // tag 17
+					got, err = msg.MarshalCBOR()
+				} else {
+					got, err = msg.MarshalCBORUntagged()
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, data) {
+					t.Errorf("unexpected encoding: got %x, want %x", got, data)
+				}
+			})
+		}
+	}
+}
+
+func TestMac0Message_Sign(t *testing.T) {
+	dirs := []string{"hmac-examples", "cbc-mac-examples"}
+	for _, dir := range dirs {
+		for name, v := range readTestVectors(t, dir) {
+			if v.Input.Mac0 == nil || v.Fail {
+				continue
+			}
+			t.Run(dir+"/"+name, func(t *testing.T) {
+				input := v.Input.Mac0
+				alg := testAlgorithms[input.Alg]
+				secret := parseTestKey(t, input.Recipients[0].Key).PrivateKey().([]byte)
+				key := alg.NewMACKey(symmetricKey(secret))
+				want := mustHex(t, v.Output.CBOR)
+
+				msg := NewMac0Message([]byte(v.Input.Plaintext))
+				msg.Protected.SetAlgorithm(alg)
+				if err := msg.Sign(key, nil); err != nil {
+					t.Fatal(err)
+				}
+				got, err := msg.MarshalCBOR()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("unexpected result: got %x, want %x", got, want)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package cose
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/subtle"
+	"fmt"
+
+	"github.com/shogo82148/goat"
+	"github.com/shogo82148/goat/jwk/jwktypes"
+	"github.com/shogo82148/goat/sig"
+)
+
+// NewMACKey returns a key for computing and verifying the authentication tags with alg.
+// The PrivateKey method of key must return the secret key as []byte.
+// alg must be one of the MAC algorithms defined in RFC 9053 Section 3.
+func (alg Algorithm) NewMACKey(key sig.Key) sig.SigningKey {
+	switch alg {
+	case AlgorithmHMAC256_64:
+		return newHMACKey(alg, crypto.SHA256, 8, key)
+	case AlgorithmHMAC256_256:
+		return newHMACKey(alg, crypto.SHA256, 32, key)
+	case AlgorithmHMAC384_384:
+		return newHMACKey(alg, crypto.SHA384, 48, key)
+	case AlgorithmHMAC512_512:
+		return newHMACKey(alg, crypto.SHA512, 64, key)
+	case AlgorithmAESMAC128_64:
+		return newAESMACKey(alg, 16, 8, key)
+	case AlgorithmAESMAC256_64:
+		return newAESMACKey(alg, 32, 8, key)
+	case AlgorithmAESMAC128_128:
+		return newAESMACKey(alg, 16, 16, key)
+	case AlgorithmAESMAC256_128:
+		return newAESMACKey(alg, 32, 16, key)
+	default:
+		return sig.NewErrorKey(fmt.Errorf("cose: unknown MAC algorithm: %v", alg))
+	}
+}
+
+// symmetricKey is a raw symmetric key.
+// It implements [sig.Key] and [github.com/shogo82148/goat/keymanage.Key].
+type symmetricKey []byte
+
+func (key symmetricKey) PrivateKey() goat.PrivateKey {
+	return []byte(key)
+}
+
+func (key symmetricKey) PublicKey() goat.PublicKey {
+	return nil
+}
+
+var _ sig.SigningKey = (*hmacKey)(nil)
+
+// hmacKey is a key for HMAC defined in RFC 9053 Section 3.1.
+type hmacKey struct {
+	hash      crypto.Hash
+	size      int
+	key       []byte
+	canSign   bool
+	canVerify bool
+}
+
+func newHMACKey(alg Algorithm, hash crypto.Hash, size int, key sig.Key) sig.SigningKey {
+	priv := key.PrivateKey()
+	pub := key.PublicKey()
+	secret, ok := priv.([]byte)
+	if !ok || pub != nil {
+		return sig.NewInvalidKey(alg.String(), priv, pub)
+	}
+	if len(secret) < hash.Size() {
+		return sig.NewErrorKey(fmt.Errorf("cose: weak key size: %d", len(secret)))
+	}
+	return &hmacKey{
+		hash:      hash,
+		size:      size,
+		key:       secret,
+		canSign:   jwktypes.CanUseFor(key, jwktypes.KeyOpSign),
+		canVerify: jwktypes.CanUseFor(key, jwktypes.KeyOpVerify),
+	}
+}
+
+func (key *hmacKey) mac(payload []byte) ([]byte, error) {
+	if !key.hash.Available() {
+		return nil, sig.ErrHashUnavailable
+	}
+	mac := hmac.New(key.hash.New, key.key)
+	if _, err := mac.Write(payload); err != nil {
+		return nil, err
+	}
+	return mac.Sum(nil)[:key.size], nil
+}
+
+// Sign implements [github.com/shogo82148/goat/sig.SigningKey].
+// It computes the authentication tag of payload.
+func (key *hmacKey) Sign(payload []byte) (signature []byte, err error) {
+	if !key.canSign {
+		return nil, sig.ErrSignUnavailable
+	}
+	return key.mac(payload)
+}
+
+// Verify implements [github.com/shogo82148/goat/sig.SigningKey].
+// It verifies the authentication tag of payload.
+func (key *hmacKey) Verify(payload, signature []byte) error {
+	if !key.canVerify {
+		return sig.ErrSignUnavailable
+	}
+	sum, err := key.mac(payload)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(signature, sum) {
+		return sig.ErrSignatureMismatch
+	}
+	return nil
+}
+
+var _ sig.SigningKey = (*aesMACKey)(nil)
+
+// aesMACKey is a key for AES-CBC-MAC defined in RFC 9053 Section 3.2.
+type aesMACKey struct {
+	block     cipher.Block
+	size      int
+	canSign   bool
+	canVerify bool
+}
+
+func newAESMACKey(alg Algorithm, keySize, size int, key sig.Key) sig.SigningKey {
+	priv := key.PrivateKey()
+	pub := key.PublicKey()
+	secret, ok := priv.([]byte)
+	if !ok || pub != nil {
+		return sig.NewInvalidKey(alg.String(), priv, pub)
+	}
+	if len(secret) != keySize {
+		return sig.NewErrorKey(fmt.Errorf("cose: invalid key size: %d", len(secret)))
+	}
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return sig.NewErrorKey(err)
+	}
+	return &aesMACKey{
+		block:     block,
+		size:      size,
+		canSign:   jwktypes.CanUseFor(key, jwktypes.KeyOpSign),
+		canVerify: jwktypes.CanUseFor(key, jwktypes.KeyOpVerify),
+	}
+}
+
+func (key *aesMACKey) mac(payload []byte) []byte {
+	// CBC-MAC uses the IV of all zeros,
+	// and the message is padded with zeros to a multiple of the block size.
+	size := (len(payload) + aes.BlockSize - 1) / aes.BlockSize * aes.BlockSize
+	if size == 0 {
+		size = aes.BlockSize
+	}
+	buf := make([]byte, size)
+	copy(buf, payload)
+	iv := make([]byte, aes.BlockSize)
+	cipher.NewCBCEncrypter(key.block, iv).CryptBlocks(buf, buf)
+	return buf[size-aes.BlockSize : size-aes.BlockSize+key.size]
+}
+
+// Sign implements [github.com/shogo82148/goat/sig.SigningKey].
+// It computes the authentication tag of payload.
+func (key *aesMACKey) Sign(payload []byte) (signature []byte, err error) {
+	if !key.canSign {
+		return nil, sig.ErrSignUnavailable
+	}
+	return key.mac(payload), nil
+}
+
+// Verify implements [github.com/shogo82148/goat/sig.SigningKey].
+// It verifies the authentication tag of payload.
+func (key *aesMACKey) Verify(payload, signature []byte) error {
+	if !key.canVerify {
+		return sig.ErrSignUnavailable
+	}
+	sum := key.mac(payload)
+	if subtle.ConstantTimeCompare(signature, sum) == 0 {
+		return sig.ErrSignatureMismatch
+	}
+	return nil
+}
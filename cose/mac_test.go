@@ -0,0 +1,114 @@
+package cose
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/shogo82148/goat/jwa/dir"
+	"github.com/shogo82148/goat/keymanage"
+)
+
+func TestMacMessage_Verify(t *testing.T) {
+	dirs := []string{"mac-tests", "hmac-examples", "cbc-mac-examples"}
+	for _, dirname := range dirs {
+		for name, v := range readTestVectors(t, dirname) {
+			if v.Input.Mac == nil {
+				continue
+			}
+			t.Run(dirname+"/"+name, func(t *testing.T) {
+				input := v.Input.Mac
+				key := parseTestKey(t, input.Recipients[0].Key)
+				external := mustHex(t, input.External)
+				data := mustHex(t, v.Output.CBOR)
+
+				msg, err := ParseMac(data)
+				if err != nil {
+					if v.Fail {
+						return
+					}
+					t.Fatal(err)
+				}
+				finder := FindKeyWrapperFunc(func(ctx context.Context, protected, unprotected *Header, recipient *Recipient) (keymanage.KeyWrapper, error) {
+					if !bytes.Equal(recipient.Unprotected.KeyID(), []byte(key.KeyID())) {
+						t.Errorf("unexpected kid: %q", recipient.Unprotected.KeyID())
+					}
+					return dir.New().NewKeyWrapper(key), nil
+				})
+				err = msg.Verify(t.Context(), finder, external)
+				if v.Fail {
+					if err == nil {
+						t.Error("want error, but not")
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !bytes.Equal(msg.Payload, []byte(v.Input.Plaintext)) {
+					t.Errorf("unexpected payload: got %q, want %q", msg.Payload, v.Input.Plaintext)
+				}
+
+				// check the intermediate value
+				toBeMaced, err := macStructure(contextMAC, msg.rawProtected, external, msg.Payload)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := mustHex(t, v.Intermediates.ToMacHex); !bytes.Equal(toBeMaced, want) {
+					t.Errorf("unexpected ToMac: got %x, want %x", toBeMaced, want)
+				}
+
+				// round trip
+				var got []byte
+				if data[0] == 0xd8 { // tag 97
+					got, err = msg.MarshalCBOR()
+				} else {
+					got, err = msg.MarshalCBORUntagged()
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, data) {
+					t.Errorf("unexpected encoding: got %x, want %x", got, data)
+				}
+			})
+		}
+	}
+}
+
+func TestMacMessage_Sign(t *testing.T) {
+	dirs := []string{"hmac-examples", "cbc-mac-examples"}
+	for _, dirname := range dirs {
+		for name, v := range readTestVectors(t, dirname) {
+			if v.Input.Mac == nil || v.Fail {
+				continue
+			}
+			t.Run(dirname+"/"+name, func(t *testing.T) {
+				input := v.Input.Mac
+				alg := testAlgorithms[input.Alg]
+				key := parseTestKey(t, input.Recipients[0].Key)
+				want := mustHex(t, v.Output.CBOR)
+
+				msg := NewMacMessage([]byte(v.Input.Plaintext))
+				msg.Protected.SetAlgorithm(alg)
+				if err := msg.Sign(key.PrivateKey().([]byte), nil); err != nil {
+					t.Fatal(err)
+				}
+				unprotected := NewHeader()
+				unprotected.SetAlgorithm(AlgorithmDirect)
+				unprotected.SetKeyID([]byte(key.KeyID()))
+				if err := msg.AddRecipient(nil, unprotected, dir.New().NewKeyWrapper(key)); err != nil {
+					t.Fatal(err)
+				}
+				got, err := msg.MarshalCBOR()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("unexpected result: got %x, want %x", got, want)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package cose
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+
+	"github.com/shogo82148/goat/ed448"
+	"github.com/shogo82148/goat/internal/cborutils"
+	"github.com/shogo82148/goat/x25519"
+	"github.com/shogo82148/goat/x448"
+)
+
+// decodeCurve decodes the "crv" parameter.
+// It accepts both the integer and the text string representations.
+func decodeCurve(d *cborutils.Decoder) int64 {
+	if crv, ok := d.GetInteger(-1); ok {
+		i64, err := crv.Int64()
+		if err != nil {
+			d.SaveError(err)
+			return 0
+		}
+		return i64
+	}
+	if crv, ok := d.GetString(-1); ok {
+		switch crv {
+		case curveNameP256:
+			return curveP256
+		case curveNameP384:
+			return curveP384
+		case curveNameP521:
+			return curveP521
+		case curveNameX25519:
+			return curveX25519
+		case curveNameX448:
+			return curveX448
+		case curveNameEd25519:
+			return curveEd25519
+		case curveNameEd448:
+			return curveEd448
+		case curveNameSecp256k1:
+			return curveSecp256k1
+		default:
+			d.SaveError(fmt.Errorf("cose: unknown curve: %q", crv))
+			return 0
+		}
+	}
+	d.SaveError(errors.New("cose: missing curve"))
+	return 0
+}
+
+// RFC 9053 Section 7.2. Octet Key Pair
+func parseOKPKey(d *cborutils.Decoder, key *Key) {
+	crv := decodeCurve(d)
+	if d.Err() != nil {
+		return
+	}
+	switch crv {
+	case curveEd25519:
+		parseEd25519Key(d, key)
+	case curveEd448:
+		parseEd448Key(d, key)
+	case curveX25519:
+		parseX25519Key(d, key)
+	case curveX448:
+		parseX448Key(d, key)
+	default:
+		d.SaveError(fmt.Errorf("cose: unknown curve for OKP: %d", crv))
+	}
+}
+
+// parseOKPParameters returns the public key x and the private key d.
+// x may be omitted if d is present.
+func parseOKPParameters(d *cborutils.Decoder) (x, priv []byte) {
+	priv, hasPrivate := d.GetBytes(-4)
+	if hasPrivate {
+		x, _ = d.GetBytes(-2)
+		return x, priv
+	}
+	return d.MustBytes(-2), nil
+}
+
+func parseEd25519Key(d *cborutils.Decoder, key *Key) {
+	x, seed := parseOKPParameters(d)
+	if d.Err() != nil {
+		return
+	}
+
+	if seed != nil {
+		if len(seed) != ed25519.SeedSize {
+			d.SaveError(errors.New("cose: the parameter d has invalid size"))
+			return
+		}
+		priv := ed25519.NewKeyFromSeed(seed)
+		key.priv = priv
+		if x == nil {
+			x = priv[ed25519.SeedSize:]
+		} else if !bytes.Equal(priv[ed25519.SeedSize:], x) {
+			d.SaveError(errors.New("cose: invalid key pair"))
+			return
+		}
+	}
+
+	if len(x) != ed25519.PublicKeySize {
+		d.SaveError(errors.New("cose: the parameter x has invalid size"))
+		return
+	}
+	pub := make(ed25519.PublicKey, ed25519.PublicKeySize)
+	copy(pub, x)
+	key.pub = pub
+}
+
+func parseEd448Key(d *cborutils.Decoder, key *Key) {
+	x, seed := parseOKPParameters(d)
+	if d.Err() != nil {
+		return
+	}
+
+	if seed != nil {
+		if len(seed) != ed448.SeedSize {
+			d.SaveError(errors.New("cose: the parameter d has invalid size"))
+			return
+		}
+		priv := ed448.NewKeyFromSeed(seed)
+		key.priv = priv
+		if x == nil {
+			x = priv[ed448.SeedSize:]
+		} else if !bytes.Equal(priv[ed448.SeedSize:], x) {
+			d.SaveError(errors.New("cose: invalid key pair"))
+			return
+		}
+	}
+
+	if len(x) != ed448.PublicKeySize {
+		d.SaveError(errors.New("cose: the parameter x has invalid size"))
+		return
+	}
+	pub := make(ed448.PublicKey, ed448.PublicKeySize)
+	copy(pub, x)
+	key.pub = pub
+}
+
+func parseX25519Key(d *cborutils.Decoder, key *Key) {
+	x, seed := parseOKPParameters(d)
+	if d.Err() != nil {
+		return
+	}
+
+	if seed != nil {
+		if len(seed) != x25519.SeedSize {
+			d.SaveError(errors.New("cose: the parameter d has invalid size"))
+			return
+		}
+		priv := x25519.NewKeyFromSeed(seed)
+		key.priv = priv
+		if x == nil {
+			x = priv[x25519.SeedSize:]
+		} else if !bytes.Equal(priv[x25519.SeedSize:], x) {
+			d.SaveError(errors.New("cose: invalid key pair"))
+			return
+		}
+	}
+
+	if len(x) != x25519.PublicKeySize {
+		d.SaveError(errors.New("cose: the parameter x has invalid size"))
+		return
+	}
+	pub := make(x25519.PublicKey, x25519.PublicKeySize)
+	copy(pub, x)
+	key.pub = pub
+}
+
+func parseX448Key(d *cborutils.Decoder, key *Key) {
+	x, seed := parseOKPParameters(d)
+	if d.Err() != nil {
+		return
+	}
+
+	if seed != nil {
+		if len(seed) != x448.SeedSize {
+			d.SaveError(errors.New("cose: the parameter d has invalid size"))
+			return
+		}
+		priv := x448.NewKeyFromSeed(seed)
+		key.priv = priv
+		if x == nil {
+			x = priv[x448.SeedSize:]
+		} else if !bytes.Equal(priv[x448.SeedSize:], x) {
+			d.SaveError(errors.New("cose: invalid key pair"))
+			return
+		}
+	}
+
+	if len(x) != x448.PublicKeySize {
+		d.SaveError(errors.New("cose: the parameter x has invalid size"))
+		return
+	}
+	pub := make(x448.PublicKey, x448.PublicKeySize)
+	copy(pub, x)
+	key.pub = pub
+}
+
+func encodeOKPKey(e *cborutils.Encoder, crv int64, x, d []byte) {
+	e.Set(keyLabelKeyType, cborutils.IntegerFromInt64(int64(KeyTypeOKP)))
+	e.Set(-1, cborutils.IntegerFromInt64(crv))
+	e.Set(-2, x)
+	if d != nil {
+		e.Set(-4, d)
+	}
+}
+
+func encodeEd25519Key(e *cborutils.Encoder, priv ed25519.PrivateKey, pub ed25519.PublicKey) {
+	if len(pub) != ed25519.PublicKeySize {
+		e.SaveError(errors.New("cose: invalid ed25519 public key size"))
+		return
+	}
+	var seed []byte
+	if priv != nil {
+		if len(priv) != ed25519.PrivateKeySize || !bytes.Equal(priv[ed25519.SeedSize:], pub) {
+			e.SaveError(errors.New("cose: invalid ed25519 key pair"))
+			return
+		}
+		seed = priv[:ed25519.SeedSize]
+	}
+	encodeOKPKey(e, curveEd25519, pub, seed)
+}
+
+func encodeEd448Key(e *cborutils.Encoder, priv ed448.PrivateKey, pub ed448.PublicKey) {
+	if len(pub) != ed448.PublicKeySize {
+		e.SaveError(errors.New("cose: invalid ed448 public key size"))
+		return
+	}
+	var seed []byte
+	if priv != nil {
+		if len(priv) != ed448.PrivateKeySize || !bytes.Equal(priv[ed448.SeedSize:], pub) {
+			e.SaveError(errors.New("cose: invalid ed448 key pair"))
+			return
+		}
+		seed = priv[:ed448.SeedSize]
+	}
+	encodeOKPKey(e, curveEd448, pub, seed)
+}
+
+func encodeX25519Key(e *cborutils.Encoder, priv x25519.PrivateKey, pub x25519.PublicKey) {
+	if len(pub) != x25519.PublicKeySize {
+		e.SaveError(errors.New("cose: invalid x25519 public key size"))
+		return
+	}
+	var seed []byte
+	if priv != nil {
+		if len(priv) != x25519.PrivateKeySize || !bytes.Equal(priv[x25519.SeedSize:], pub) {
+			e.SaveError(errors.New("cose: invalid x25519 key pair"))
+			return
+		}
+		seed = priv[:x25519.SeedSize]
+	}
+	encodeOKPKey(e, curveX25519, pub, seed)
+}
+
+func encodeX448Key(e *cborutils.Encoder, priv x448.PrivateKey, pub x448.PublicKey) {
+	if len(pub) != x448.PublicKeySize {
+		e.SaveError(errors.New("cose: invalid x448 public key size"))
+		return
+	}
+	var seed []byte
+	if priv != nil {
+		if len(priv) != x448.PrivateKeySize || !bytes.Equal(priv[x448.SeedSize:], pub) {
+			e.SaveError(errors.New("cose: invalid x448 key pair"))
+			return
+		}
+		seed = priv[:x448.SeedSize]
+	}
+	encodeOKPKey(e, curveX448, pub, seed)
+}
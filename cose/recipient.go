@@ -0,0 +1,254 @@
+package cose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/shogo82148/goat/keymanage"
+)
+
+// Recipient is a COSE_recipient defined in RFC 9052 Section 5.1.
+type Recipient struct {
+	// Protected is the protected header of the recipient.
+	Protected *Header
+
+	// Unprotected is the unprotected header of the recipient.
+	Unprotected *Header
+
+	// Ciphertext is the encrypted key.
+	// It is empty if the key is not transported, e.g. direct use of the shared secret.
+	Ciphertext []byte
+
+	// Recipients is the list of the recipients in the next layer.
+	Recipients []*Recipient
+
+	rawProtected []byte
+}
+
+// KeyWrapperFinder finds a key wrapper for the recipient.
+// protected and unprotected are the headers of the message body.
+type KeyWrapperFinder interface {
+	FindKeyWrapper(ctx context.Context, protected, unprotected *Header, recipient *Recipient) (wrapper keymanage.KeyWrapper, err error)
+}
+
+var _ KeyWrapperFinder = FindKeyWrapperFunc(nil)
+
+// FindKeyWrapperFunc is an adapter to allow the use of ordinary functions as KeyWrapperFinder.
+type FindKeyWrapperFunc func(ctx context.Context, protected, unprotected *Header, recipient *Recipient) (wrapper keymanage.KeyWrapper, err error)
+
+func (f FindKeyWrapperFunc) FindKeyWrapper(ctx context.Context, protected, unprotected *Header, recipient *Recipient) (wrapper keymanage.KeyWrapper, err error) {
+	return f(ctx, protected, unprotected, recipient)
+}
+
+// recipientOptions is passed to the key wrappers as opts.
+// The key wrappers of ECDH use it for building COSE_KDF_Context.
+type recipientOptions struct {
+	// alg is the algorithm of the layer that uses the key of the recipient.
+	alg Algorithm
+
+	// protected and unprotected are the headers of the recipient.
+	protected, unprotected *Header
+
+	// rawProtected is the serialized protected header of the recipient.
+	rawProtected []byte
+}
+
+// newRecipient returns a new recipient that has cek wrapped by kw.
+// alg is the algorithm that uses cek.
+func newRecipient(alg Algorithm, protected, unprotected *Header, kw keymanage.KeyWrapper, cek []byte) (*Recipient, error) {
+	if protected == nil {
+		protected = NewHeader()
+	}
+	if unprotected == nil {
+		unprotected = NewHeader()
+	}
+	if err := checkHeaders(protected, unprotected); err != nil {
+		return nil, err
+	}
+	if lookupAlgorithm(protected, unprotected) == AlgorithmReserved {
+		return nil, errors.New("cose: failed to wrap key: algorithm is not set")
+	}
+
+	rawProtected, err := encodeProtectedHeader(protected)
+	if err != nil {
+		return nil, err
+	}
+	opts := &recipientOptions{
+		alg:          alg,
+		protected:    protected,
+		unprotected:  unprotected,
+		rawProtected: rawProtected,
+	}
+	ciphertext, err := kw.WrapKey(cek, opts)
+	if err != nil {
+		return nil, fmt.Errorf("cose: failed to wrap key: %w", err)
+	}
+	return &Recipient{
+		Protected:    protected,
+		Unprotected:  unprotected,
+		Ciphertext:   ciphertext,
+		rawProtected: rawProtected,
+	}, nil
+}
+
+// findCEK finds the content encryption key from the recipients.
+func findCEK(ctx context.Context, finder KeyWrapperFinder, protected, unprotected *Header, recipients []*Recipient) ([]byte, error) {
+	for _, r := range recipients {
+		kw, err := finder.FindKeyWrapper(ctx, protected, unprotected, r)
+		if err != nil {
+			continue
+		}
+		rawProtected, err := r.serializedProtected()
+		if err != nil {
+			return nil, err
+		}
+		opts := &recipientOptions{
+			alg:          lookupAlgorithm(protected, unprotected),
+			protected:    r.Protected,
+			unprotected:  r.Unprotected,
+			rawProtected: rawProtected,
+		}
+		cek, err := kw.UnwrapKey(r.Ciphertext, opts)
+		if err != nil {
+			return nil, fmt.Errorf("cose: failed to unwrap key: %w", err)
+		}
+		return cek, nil
+	}
+	return nil, errors.New("cose: key wrapper not found")
+}
+
+// deriveRecipient returns a new recipient that agrees on the key with kd.
+// It is used for direct key agreement, and the derived key is returned.
+// alg is the algorithm that uses the derived key.
+func deriveRecipient(alg Algorithm, protected, unprotected *Header, kd keymanage.KeyDeriver) (*Recipient, []byte, error) {
+	if protected == nil {
+		protected = NewHeader()
+	}
+	if unprotected == nil {
+		unprotected = NewHeader()
+	}
+	if err := checkHeaders(protected, unprotected); err != nil {
+		return nil, nil, err
+	}
+	if lookupAlgorithm(protected, unprotected) == AlgorithmReserved {
+		return nil, nil, errors.New("cose: failed to derive key: algorithm is not set")
+	}
+
+	rawProtected, err := encodeProtectedHeader(protected)
+	if err != nil {
+		return nil, nil, err
+	}
+	opts := &recipientOptions{
+		alg:          alg,
+		protected:    protected,
+		unprotected:  unprotected,
+		rawProtected: rawProtected,
+	}
+	cek, ciphertext, err := kd.DeriveKey(opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cose: failed to derive key: %w", err)
+	}
+	return &Recipient{
+		Protected:    protected,
+		Unprotected:  unprotected,
+		Ciphertext:   ciphertext,
+		rawProtected: rawProtected,
+	}, cek, nil
+}
+
+func decodeRecipients(v any) ([]*Recipient, error) {
+	array, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("cose: invalid type of recipients: %T", v)
+	}
+	if len(array) == 0 {
+		return nil, errors.New("cose: no recipients")
+	}
+	recipients := make([]*Recipient, 0, len(array))
+	for _, v := range array {
+		r, err := decodeRecipient(v)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, r)
+	}
+	return recipients, nil
+}
+
+func decodeRecipient(v any) (*Recipient, error) {
+	array, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("cose: invalid type of recipient: %T", v)
+	}
+	if len(array) != 3 && len(array) != 4 {
+		return nil, fmt.Errorf("cose: invalid length of recipient: %d", len(array))
+	}
+
+	protected, unprotected, rawProtected, err := decodeHeaders(array[0], array[1])
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := decodePayload(array[2])
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Recipient{
+		Protected:    protected,
+		Unprotected:  unprotected,
+		Ciphertext:   ciphertext,
+		rawProtected: rawProtected,
+	}
+	if len(array) == 4 {
+		recipients, err := decodeRecipients(array[3])
+		if err != nil {
+			return nil, err
+		}
+		r.Recipients = recipients
+	}
+	return r, nil
+}
+
+func encodeRecipients(recipients []*Recipient) ([]any, error) {
+	array := make([]any, 0, len(recipients))
+	for _, r := range recipients {
+		v, err := r.encode()
+		if err != nil {
+			return nil, err
+		}
+		array = append(array, v)
+	}
+	return array, nil
+}
+
+func (r *Recipient) encode() ([]any, error) {
+	rawProtected, err := r.serializedProtected()
+	if err != nil {
+		return nil, err
+	}
+	unprotected, err := encodeHeader(r.Unprotected)
+	if err != nil {
+		return nil, err
+	}
+	array := []any{
+		rawProtected,
+		unprotected,
+		encodePayload(r.Ciphertext),
+	}
+	if len(r.Recipients) > 0 {
+		recipients, err := encodeRecipients(r.Recipients)
+		if err != nil {
+			return nil, err
+		}
+		array = append(array, recipients)
+	}
+	return array, nil
+}
+
+func (r *Recipient) serializedProtected() ([]byte, error) {
+	if r.rawProtected != nil {
+		return r.rawProtected, nil
+	}
+	return encodeProtectedHeader(r.Protected)
+}
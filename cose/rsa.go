@@ -0,0 +1,186 @@
+package cose
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/shogo82148/goat/internal/cborutils"
+)
+
+// RFC 8230 Section 4. COSE Key Type Parameters
+const (
+	keyLabelRSAN     = -1  // the RSA modulus n
+	keyLabelRSAE     = -2  // the RSA public exponent e
+	keyLabelRSAD     = -3  // the RSA private exponent d
+	keyLabelRSAP     = -4  // the prime factor p of n
+	keyLabelRSAQ     = -5  // the prime factor q of n
+	keyLabelRSADP    = -6  // dP is d mod (p - 1)
+	keyLabelRSADQ    = -7  // dQ is d mod (q - 1)
+	keyLabelRSAQInv  = -8  // qInv is the CRT coefficient q^(-1) mod p
+	keyLabelRSAOther = -9  // other prime infos, an array
+	keyLabelRSARi    = -10 // a prime factor r_i of n, where i >= 3
+	keyLabelRSADi    = -11 // d_i = d mod (r_i - 1)
+	keyLabelRSATi    = -12 // the CRT coefficient t_i = (r_1 * r_2 * ... * r_(i-1))^(-1) mod r_i
+)
+
+func parseRSAKey(d *cborutils.Decoder, key *Key) {
+	// parameters for public key
+	n := mustBigInt(d, keyLabelRSAN)
+	e := mustBigInt(d, keyLabelRSAE)
+	if err := d.Err(); err != nil {
+		return
+	}
+	if !e.IsInt64() || e.Int64() > math.MaxInt || e.Int64() <= 0 {
+		d.SaveError(errors.New("cose: invalid rsa parameter e"))
+		return
+	}
+	pub := rsa.PublicKey{
+		N: n,
+		E: int(e.Int64()),
+	}
+	if err := validateRSAPublicKey(&pub); err != nil {
+		d.SaveError(err)
+		return
+	}
+	key.pub = &pub
+
+	// parameters for private key
+	if !d.Has(keyLabelRSAD) {
+		return
+	}
+	priv := rsa.PrivateKey{
+		PublicKey: pub,
+		D:         mustBigInt(d, keyLabelRSAD),
+		Primes: []*big.Int{
+			mustBigInt(d, keyLabelRSAP),
+			mustBigInt(d, keyLabelRSAQ),
+		},
+	}
+
+	// other primes
+	if d.Has(keyLabelRSAOther) {
+		others, ok := d.GetArray(keyLabelRSAOther)
+		if !ok {
+			d.SaveError(errors.New("cose: invalid type for other prime infos"))
+			return
+		}
+		for _, other := range others {
+			m, ok := other.(map[any]any)
+			if !ok {
+				d.SaveError(errors.New("cose: invalid type for other prime info"))
+				return
+			}
+			od := cborutils.NewDecoder("cose", m)
+			r := mustBigInt(od, keyLabelRSARi)
+			if err := od.Err(); err != nil {
+				d.SaveError(err)
+				return
+			}
+			priv.Primes = append(priv.Primes, r)
+		}
+	}
+
+	// precomputed values for the Chinese Remainder Theorem
+	if d.Has(keyLabelRSADP) && d.Has(keyLabelRSADQ) && d.Has(keyLabelRSAQInv) {
+		priv.Precomputed = rsa.PrecomputedValues{
+			Dp:   mustBigInt(d, keyLabelRSADP),
+			Dq:   mustBigInt(d, keyLabelRSADQ),
+			Qinv: mustBigInt(d, keyLabelRSAQInv),
+		}
+	}
+	if err := d.Err(); err != nil {
+		return
+	}
+
+	if err := validateRSAPrivateKey(&priv); err != nil {
+		d.SaveError(err)
+		return
+	}
+	priv.Precompute()
+	key.priv = &priv
+}
+
+func encodeRSAKey(e *cborutils.Encoder, priv *rsa.PrivateKey, pub *rsa.PublicKey) {
+	if err := validateRSAPublicKey(pub); err != nil {
+		e.SaveError(err)
+		return
+	}
+	e.Set(keyLabelKeyType, cborutils.IntegerFromInt64(int64(KeyTypeRSA)))
+	e.Set(keyLabelRSAN, pub.N.Bytes())
+	e.Set(keyLabelRSAE, big.NewInt(int64(pub.E)).Bytes())
+
+	if priv == nil {
+		return
+	}
+	if !priv.PublicKey.Equal(pub) {
+		e.SaveError(errors.New("cose: invalid rsa key pair"))
+		return
+	}
+	if err := validateRSAPrivateKey(priv); err != nil {
+		e.SaveError(err)
+		return
+	}
+	e.Set(keyLabelRSAD, priv.D.Bytes())
+	e.Set(keyLabelRSAP, priv.Primes[0].Bytes())
+	e.Set(keyLabelRSAQ, priv.Primes[1].Bytes())
+
+	// precomputed values for the Chinese Remainder Theorem
+	if priv.Precomputed.Dp != nil {
+		e.Set(keyLabelRSADP, priv.Precomputed.Dp.Bytes())
+		e.Set(keyLabelRSADQ, priv.Precomputed.Dq.Bytes())
+		e.Set(keyLabelRSAQInv, priv.Precomputed.Qinv.Bytes())
+	}
+
+	// other primes
+	if len(priv.Primes) > 2 {
+		one := big.NewInt(1)
+		others := make([]any, 0, len(priv.Primes)-2)
+		r := new(big.Int).Mul(priv.Primes[0], priv.Primes[1])
+		for _, ri := range priv.Primes[2:] {
+			di := new(big.Int).Mod(priv.D, new(big.Int).Sub(ri, one))
+			ti := new(big.Int).ModInverse(r, ri)
+			if ti == nil {
+				e.SaveError(errors.New("cose: invalid rsa prime"))
+				return
+			}
+			other := cborutils.NewEncoder(nil)
+			other.Set(keyLabelRSARi, ri.Bytes())
+			other.Set(keyLabelRSADi, di.Bytes())
+			other.Set(keyLabelRSATi, ti.Bytes())
+			others = append(others, other.Data())
+			r.Mul(r, ri)
+		}
+		e.Set(keyLabelRSAOther, others)
+	}
+}
+
+// mustBigInt gets a big integer parameter encoded as a byte string.
+func mustBigInt(d *cborutils.Decoder, label int64) *big.Int {
+	data := d.MustBytes(label)
+	if data == nil {
+		return nil
+	}
+	return new(big.Int).SetBytes(data)
+}
+
+// sanity check of private key
+func validateRSAPrivateKey(key *rsa.PrivateKey) error {
+	if err := key.Validate(); err != nil {
+		return fmt.Errorf("cose: invalid rsa private key: %w", err)
+	}
+	return nil
+}
+
+// sanity check of public key
+func validateRSAPublicKey(key *rsa.PublicKey) error {
+	if key.N == nil || key.N.Sign() <= 0 {
+		return errors.New("cose: invalid rsa modulus")
+	}
+	if key.E < 2 || key.E > math.MaxInt32 {
+		return errors.New("cose: invalid rsa public exponent")
+	}
+	return nil
+}
@@ -0,0 +1,277 @@
+package cose
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/shogo82148/go-cbor"
+	"github.com/shogo82148/goat/sig"
+)
+
+// SignMessage is a COSE_Sign message defined in RFC 9052 Section 4.1.
+type SignMessage struct {
+	// Protected is the protected header of the body.
+	Protected *Header
+
+	// Unprotected is the unprotected header of the body.
+	Unprotected *Header
+
+	// Payload is the content of the message.
+	// nil means that the content is detached.
+	Payload []byte
+
+	// Signatures is the list of the signatures.
+	Signatures []*Signature
+
+	rawProtected []byte
+}
+
+// Signature is a COSE_Signature defined in RFC 9052 Section 4.1.
+type Signature struct {
+	// Protected is the protected header of the signer.
+	Protected *Header
+
+	// Unprotected is the unprotected header of the signer.
+	Unprotected *Header
+
+	// Signature is the signature.
+	Signature []byte
+
+	rawProtected []byte
+}
+
+// NewSignMessage returns a new COSE_Sign message that has no signature.
+func NewSignMessage(payload []byte) *SignMessage {
+	return &SignMessage{
+		Protected:   NewHeader(),
+		Unprotected: NewHeader(),
+		Payload:     payload,
+	}
+}
+
+// ParseSign parses a tagged or untagged COSE_Sign message.
+func ParseSign(data []byte) (*SignMessage, error) {
+	var msg SignMessage
+	if err := msg.UnmarshalCBOR(data); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// UnmarshalCBOR implements [cbor.Unmarshaler].
+// It accepts both tagged and untagged COSE_Sign messages.
+func (msg *SignMessage) UnmarshalCBOR(data []byte) error {
+	array, err := decodeMessage(data, TagNumberCOSESign, 4)
+	if err != nil {
+		return err
+	}
+
+	protected, unprotected, rawProtected, err := decodeHeaders(array[0], array[1])
+	if err != nil {
+		return err
+	}
+	payload, err := decodePayload(array[2])
+	if err != nil {
+		return err
+	}
+
+	rawSignatures, ok := array[3].([]any)
+	if !ok {
+		return fmt.Errorf("cose: invalid type of signatures: %T", array[3])
+	}
+	if len(rawSignatures) == 0 {
+		return errors.New("cose: no signatures")
+	}
+	signatures := make([]*Signature, 0, len(rawSignatures))
+	for _, v := range rawSignatures {
+		s, err := decodeSignature(v)
+		if err != nil {
+			return err
+		}
+		signatures = append(signatures, s)
+	}
+
+	*msg = SignMessage{
+		Protected:    protected,
+		Unprotected:  unprotected,
+		Payload:      payload,
+		Signatures:   signatures,
+		rawProtected: rawProtected,
+	}
+	return nil
+}
+
+func decodeSignature(v any) (*Signature, error) {
+	array, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("cose: invalid type of signature: %T", v)
+	}
+	if len(array) != 3 {
+		return nil, fmt.Errorf("cose: invalid length of signature: %d", len(array))
+	}
+
+	protected, unprotected, rawProtected, err := decodeHeaders(array[0], array[1])
+	if err != nil {
+		return nil, err
+	}
+	signature, ok := array[2].([]byte)
+	if !ok {
+		return nil, fmt.Errorf("cose: invalid type of signature: %T", array[2])
+	}
+	return &Signature{
+		Protected:    protected,
+		Unprotected:  unprotected,
+		Signature:    signature,
+		rawProtected: rawProtected,
+	}, nil
+}
+
+// MarshalCBOR implements [cbor.CBORMarshaler].
+// It encodes the message as a tagged COSE_Sign message.
+func (msg *SignMessage) MarshalCBOR() ([]byte, error) {
+	array, err := msg.encode()
+	if err != nil {
+		return nil, err
+	}
+	return cbor.Marshal(cbor.Tag{
+		Number:  TagNumberCOSESign,
+		Content: array,
+	})
+}
+
+// MarshalCBORUntagged encodes the message as an untagged COSE_Sign message.
+func (msg *SignMessage) MarshalCBORUntagged() ([]byte, error) {
+	array, err := msg.encode()
+	if err != nil {
+		return nil, err
+	}
+	return cbor.Marshal(array)
+}
+
+func (msg *SignMessage) encode() ([]any, error) {
+	if len(msg.Signatures) == 0 {
+		return nil, errors.New("cose: the message is not signed")
+	}
+	rawProtected, err := msg.serializedProtected()
+	if err != nil {
+		return nil, err
+	}
+	unprotected, err := encodeHeader(msg.Unprotected)
+	if err != nil {
+		return nil, err
+	}
+
+	signatures := make([]any, 0, len(msg.Signatures))
+	for _, s := range msg.Signatures {
+		v, err := s.encode()
+		if err != nil {
+			return nil, err
+		}
+		signatures = append(signatures, v)
+	}
+
+	return []any{
+		rawProtected,
+		unprotected,
+		encodePayload(msg.Payload),
+		signatures,
+	}, nil
+}
+
+func (msg *SignMessage) serializedProtected() ([]byte, error) {
+	if msg.rawProtected != nil {
+		return msg.rawProtected, nil
+	}
+	return encodeProtectedHeader(msg.Protected)
+}
+
+func (s *Signature) encode() ([]any, error) {
+	rawProtected, err := s.serializedProtected()
+	if err != nil {
+		return nil, err
+	}
+	unprotected, err := encodeHeader(s.Unprotected)
+	if err != nil {
+		return nil, err
+	}
+	return []any{
+		rawProtected,
+		unprotected,
+		s.Signature,
+	}, nil
+}
+
+func (s *Signature) serializedProtected() ([]byte, error) {
+	if s.rawProtected != nil {
+		return s.rawProtected, nil
+	}
+	return encodeProtectedHeader(s.Protected)
+}
+
+// AddSignature adds a new signature signed by key.
+// protected and unprotected are the headers of the signer.
+// external is the externally supplied data described in RFC 9052 Section 4.3.
+func (msg *SignMessage) AddSignature(protected, unprotected *Header, key sig.SigningKey, external []byte) error {
+	if protected == nil {
+		protected = NewHeader()
+	}
+	if unprotected == nil {
+		unprotected = NewHeader()
+	}
+	if err := checkHeaders(msg.Protected, msg.Unprotected); err != nil {
+		return err
+	}
+	if err := checkHeaders(protected, unprotected); err != nil {
+		return err
+	}
+	if lookupAlgorithm(protected, unprotected) == AlgorithmReserved {
+		return errors.New("cose: failed to sign: algorithm is not set")
+	}
+
+	bodyProtected, err := msg.serializedProtected()
+	if err != nil {
+		return err
+	}
+	signProtected, err := encodeProtectedHeader(protected)
+	if err != nil {
+		return err
+	}
+	toBeSigned, err := sigStructure(contextSignature, bodyProtected, signProtected, external, msg.Payload)
+	if err != nil {
+		return err
+	}
+	signature, err := key.Sign(toBeSigned)
+	if err != nil {
+		return fmt.Errorf("cose: failed to sign: %w", err)
+	}
+
+	// fix the body protected header, because the signatures depend on it.
+	msg.rawProtected = bodyProtected
+	msg.Signatures = append(msg.Signatures, &Signature{
+		Protected:    protected,
+		Unprotected:  unprotected,
+		Signature:    signature,
+		rawProtected: signProtected,
+	})
+	return nil
+}
+
+// verify verifies the signature s of msg with key.
+func (msg *SignMessage) verify(s *Signature, key sig.SigningKey, external []byte) error {
+	bodyProtected, err := msg.serializedProtected()
+	if err != nil {
+		return err
+	}
+	signProtected, err := s.serializedProtected()
+	if err != nil {
+		return err
+	}
+	toBeSigned, err := sigStructure(contextSignature, bodyProtected, signProtected, external, msg.Payload)
+	if err != nil {
+		return err
+	}
+	if err := key.Verify(toBeSigned, s.Signature); err != nil {
+		return fmt.Errorf("cose: failed to verify: %w", err)
+	}
+	return nil
+}
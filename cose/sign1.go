@@ -0,0 +1,177 @@
+package cose
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/shogo82148/go-cbor"
+	"github.com/shogo82148/goat/jwa"
+	"github.com/shogo82148/goat/sig"
+)
+
+// Sign1Message is a COSE_Sign1 message defined in RFC 9052 Section 4.2.
+type Sign1Message struct {
+	// Protected is the protected header.
+	Protected *Header
+
+	// Unprotected is the unprotected header.
+	Unprotected *Header
+
+	// Payload is the content of the message.
+	// nil means that the content is detached.
+	Payload []byte
+
+	// Signature is the signature of the message.
+	Signature []byte
+
+	rawProtected []byte
+}
+
+// NewSign1Message returns a new COSE_Sign1 message that has no signature.
+func NewSign1Message(payload []byte) *Sign1Message {
+	return &Sign1Message{
+		Protected:   NewHeader(),
+		Unprotected: NewHeader(),
+		Payload:     payload,
+	}
+}
+
+// ParseSign1 parses a tagged or untagged COSE_Sign1 message.
+func ParseSign1(data []byte) (*Sign1Message, error) {
+	var msg Sign1Message
+	if err := msg.UnmarshalCBOR(data); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// UnmarshalCBOR implements [cbor.Unmarshaler].
+// It accepts both tagged and untagged COSE_Sign1 messages.
+func (msg *Sign1Message) UnmarshalCBOR(data []byte) error {
+	array, err := decodeMessage(data, TagNumberCOSESign1, 4)
+	if err != nil {
+		return err
+	}
+
+	protected, unprotected, rawProtected, err := decodeHeaders(array[0], array[1])
+	if err != nil {
+		return err
+	}
+	payload, err := decodePayload(array[2])
+	if err != nil {
+		return err
+	}
+	signature, ok := array[3].([]byte)
+	if !ok {
+		return fmt.Errorf("cose: invalid type of signature: %T", array[3])
+	}
+
+	*msg = Sign1Message{
+		Protected:    protected,
+		Unprotected:  unprotected,
+		Payload:      payload,
+		Signature:    signature,
+		rawProtected: rawProtected,
+	}
+	return nil
+}
+
+// MarshalCBOR implements [cbor.CBORMarshaler].
+// It encodes the message as a tagged COSE_Sign1 message.
+func (msg *Sign1Message) MarshalCBOR() ([]byte, error) {
+	array, err := msg.encode()
+	if err != nil {
+		return nil, err
+	}
+	return cbor.Marshal(cbor.Tag{
+		Number:  TagNumberCOSESign1,
+		Content: array,
+	})
+}
+
+// MarshalCBORUntagged encodes the message as an untagged COSE_Sign1 message.
+func (msg *Sign1Message) MarshalCBORUntagged() ([]byte, error) {
+	array, err := msg.encode()
+	if err != nil {
+		return nil, err
+	}
+	return cbor.Marshal(array)
+}
+
+func (msg *Sign1Message) encode() ([]any, error) {
+	if msg.Signature == nil {
+		return nil, errors.New("cose: the message is not signed")
+	}
+	rawProtected, err := msg.serializedProtected()
+	if err != nil {
+		return nil, err
+	}
+	unprotected, err := encodeHeader(msg.Unprotected)
+	if err != nil {
+		return nil, err
+	}
+	return []any{
+		rawProtected,
+		unprotected,
+		encodePayload(msg.Payload),
+		msg.Signature,
+	}, nil
+}
+
+func (msg *Sign1Message) serializedProtected() ([]byte, error) {
+	if msg.rawProtected != nil {
+		return msg.rawProtected, nil
+	}
+	return encodeProtectedHeader(msg.Protected)
+}
+
+// Sign signs the message with key.
+// external is the externally supplied data described in RFC 9052 Section 4.3.
+func (msg *Sign1Message) Sign(key sig.SigningKey, external []byte) error {
+	if err := checkHeaders(msg.Protected, msg.Unprotected); err != nil {
+		return err
+	}
+	if lookupAlgorithm(msg.Protected, msg.Unprotected) == AlgorithmReserved {
+		return errors.New("cose: failed to sign: algorithm is not set")
+	}
+
+	rawProtected, err := encodeProtectedHeader(msg.Protected)
+	if err != nil {
+		return err
+	}
+	toBeSigned, err := sigStructure(contextSignature1, rawProtected, nil, external, msg.Payload)
+	if err != nil {
+		return err
+	}
+	signature, err := key.Sign(toBeSigned)
+	if err != nil {
+		return fmt.Errorf("cose: failed to sign: %w", err)
+	}
+
+	msg.rawProtected = rawProtected
+	msg.Signature = signature
+	return nil
+}
+
+// Verify verifies the signature of the message with key.
+// external is the externally supplied data described in RFC 9052 Section 4.3.
+// If the content is detached, set Payload before calling Verify.
+func (msg *Sign1Message) Verify(key sig.SigningKey, external []byte) error {
+	alg := lookupAlgorithm(msg.Protected, msg.Unprotected)
+	if alg.SignatureAlgorithm() == jwa.SignatureAlgorithmUnknown {
+		return fmt.Errorf("cose: unknown signature algorithm: %v", alg)
+	}
+
+	rawProtected, err := msg.serializedProtected()
+	if err != nil {
+		return err
+	}
+	toBeSigned, err := sigStructure(contextSignature1, rawProtected, nil, external, msg.Payload)
+	if err != nil {
+		return err
+	}
+	if err := key.Verify(toBeSigned, msg.Signature); err != nil {
+		return fmt.Errorf("cose: failed to verify: %w", err)
+	}
+	return nil
+}
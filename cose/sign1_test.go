@@ -0,0 +1,232 @@
+package cose
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	_ "github.com/shogo82148/goat/jwa/eddsa"
+	_ "github.com/shogo82148/goat/jwa/es"
+	_ "github.com/shogo82148/goat/jwa/ps"
+	"github.com/shogo82148/goat/sig"
+)
+
+func TestSign1Message_Verify(t *testing.T) {
+	dirs := []string{"sign1-tests", "ecdsa-examples", "eddsa-examples"}
+	for _, dir := range dirs {
+		for name, v := range readTestVectors(t, dir) {
+			if v.Input.Sign0 == nil {
+				continue
+			}
+			if name == "ecdsa-sig-04.json" {
+				// ES512 with P-256 key is not supported.
+				continue
+			}
+			t.Run(dir+"/"+name, func(t *testing.T) {
+				signer := v.Input.Sign0
+				alg := testAlgorithms[signer.Alg]
+				key := alg.SignatureAlgorithm().New().NewSigningKey(parseTestKey(t, signer.Key))
+				external := mustHex(t, signer.External)
+				data := mustHex(t, v.Output.CBOR)
+
+				msg, err := ParseSign1(data)
+				if err != nil {
+					if v.Fail {
+						return
+					}
+					t.Fatal(err)
+				}
+				err = msg.Verify(key, external)
+				if v.Fail {
+					if err == nil {
+						t.Error("want error, but not")
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !bytes.Equal(msg.Payload, []byte(v.Input.Plaintext)) {
+					t.Errorf("unexpected payload: got %q, want %q", msg.Payload, v.Input.Plaintext)
+				}
+
+				// check the intermediate value
+				toBeSigned, err := sigStructure(contextSignature1, msg.rawProtected, nil, external, msg.Payload)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := mustHex(t, v.Intermediates.ToBeSignHex); !bytes.Equal(toBeSigned, want) {
+					t.Errorf("unexpected ToBeSigned: got %x, want %x", toBeSigned, want)
+				}
+
+				// round trip
+				var got []byte
+				if data[0] == 0xd2 { // tag 18
+					got, err = msg.MarshalCBOR()
+				} else {
+					got, err = msg.MarshalCBORUntagged()
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, data) {
+					t.Errorf("unexpected encoding: got %x, want %x", got, data)
+				}
+			})
+		}
+	}
+}
+
+func TestSign1Message_Sign(t *testing.T) {
+	for name, v := range readTestVectors(t, "eddsa-examples") {
+		if v.Input.Sign0 == nil {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			// EdDSA is deterministic, so we can compare the result with the test vector.
+			signer := v.Input.Sign0
+			alg := testAlgorithms[signer.Alg]
+			key := alg.SignatureAlgorithm().New().NewSigningKey(parseTestKey(t, signer.Key))
+			want := mustHex(t, v.Output.CBOR)
+			parsed, err := ParseSign1(want)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			msg := NewSign1Message([]byte(v.Input.Plaintext))
+			msg.Protected.SetAlgorithm(alg)
+			msg.Protected.SetContentType(parsed.Protected.ContentType())
+			msg.Unprotected.SetKeyID(parsed.Unprotected.KeyID())
+			if err := msg.Sign(key, nil); err != nil {
+				t.Fatal(err)
+			}
+			got, err := msg.MarshalCBOR()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("unexpected result: got %x, want %x", got, want)
+			}
+		})
+	}
+}
+
+func TestSign1Message_SignVerify(t *testing.T) {
+	for name, v := range readTestVectors(t, "ecdsa-examples") {
+		if v.Input.Sign0 == nil {
+			continue
+		}
+		if name == "ecdsa-sig-04.json" {
+			// ES512 with P-256 key is not supported.
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			signer := v.Input.Sign0
+			alg := testAlgorithms[signer.Alg]
+			jwkKey := parseTestKey(t, signer.Key)
+			jwkKey.SetPublicKeyUse("")
+			key := alg.SignatureAlgorithm().New().NewSigningKey(jwkKey)
+			external := []byte("external data")
+
+			msg := NewSign1Message([]byte(v.Input.Plaintext))
+			msg.Protected.SetAlgorithm(alg)
+			if err := msg.Sign(key, external); err != nil {
+				t.Fatal(err)
+			}
+			data, err := msg.MarshalCBOR()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			parsed, err := ParseSign1(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := parsed.Verify(key, external); err != nil {
+				t.Fatal(err)
+			}
+			if err := parsed.Verify(key, nil); err == nil {
+				t.Error("want error, but not")
+			}
+
+			verifier := &Verifier{
+				AlgorithmVerifier: AllowedAlgorithms{alg},
+				KeyFinder: FindKeyFunc(func(ctx context.Context, protected, unprotected *Header) (sig.SigningKey, error) {
+					return key, nil
+				}),
+			}
+			if err := verifier.VerifySign1(t.Context(), parsed, external); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestSign1Message_Detached(t *testing.T) {
+	v := readTestVectors(t, "eddsa-examples")["eddsa-sig-01.json"]
+	signer := v.Input.Sign0
+	key := AlgorithmEdDSA.SignatureAlgorithm().New().NewSigningKey(parseTestKey(t, signer.Key))
+	content := []byte(v.Input.Plaintext)
+
+	msg := NewSign1Message(content)
+	msg.Protected.SetAlgorithm(AlgorithmEdDSA)
+	if err := msg.Sign(key, nil); err != nil {
+		t.Fatal(err)
+	}
+	msg.Payload = nil
+	data, err := msg.MarshalCBOR()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseSign1(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Payload != nil {
+		t.Errorf("want detached content, but got %x", parsed.Payload)
+	}
+	parsed.Payload = content
+	if err := parsed.Verify(key, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSign1Message_KeyOperations(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := NewPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := AlgorithmES256.SignatureAlgorithm().New().NewSigningKey(key)
+
+	msg := NewSign1Message([]byte("This is the content."))
+	msg.Protected.SetAlgorithm(AlgorithmES256)
+	if err := msg.Sign(signer, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// the key is restricted to verification.
+	key.SetKeyOperations([]KeyOp{KeyOpVerify})
+	verifier := AlgorithmES256.SignatureAlgorithm().New().NewSigningKey(key)
+	if err := msg.Verify(verifier, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := msg.Sign(verifier, nil); err == nil {
+		t.Error("want error, but not")
+	}
+
+	// the key is restricted to signing.
+	key.SetKeyOperations([]KeyOp{KeyOpSign})
+	signer = AlgorithmES256.SignatureAlgorithm().New().NewSigningKey(key)
+	if err := msg.Verify(signer, nil); err == nil {
+		t.Error("want error, but not")
+	}
+}
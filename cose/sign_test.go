@@ -0,0 +1,304 @@
+package cose
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	_ "github.com/shogo82148/goat/jwa/eddsa"
+	_ "github.com/shogo82148/goat/jwa/es"
+	_ "github.com/shogo82148/goat/jwa/ps"
+	"github.com/shogo82148/goat/sig"
+)
+
+func TestVerifier_VerifySign(t *testing.T) {
+	dirs := []string{"sign-tests", "ecdsa-examples", "eddsa-examples", "rsa-pss-examples"}
+	for _, dir := range dirs {
+		for name, v := range readTestVectors(t, dir) {
+			if v.Input.Sign == nil {
+				continue
+			}
+			if name == "ecdsa-04.json" {
+				// ES512 with P-256 key is not supported.
+				continue
+			}
+			t.Run(dir+"/"+name, func(t *testing.T) {
+				signer := v.Input.Sign.Signers[0]
+				alg := signer.algorithm()
+				key := alg.SignatureAlgorithm().New().NewSigningKey(parseTestKey(t, signer.Key))
+				external := mustHex(t, signer.External)
+				data := mustHex(t, v.Output.CBOR)
+
+				msg, err := ParseSign(data)
+				if err != nil {
+					if v.Fail {
+						return
+					}
+					t.Fatal(err)
+				}
+
+				verifier := &Verifier{
+					AlgorithmVerifier: AllowedAlgorithms{alg},
+					KeyFinder: FindKeyFunc(func(ctx context.Context, protected, unprotected *Header) (sig.SigningKey, error) {
+						return key, nil
+					}),
+				}
+				verified, err := verifier.VerifySign(t.Context(), msg, external)
+				if v.Fail {
+					if err == nil {
+						t.Error("want error, but not")
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(verified) != 1 || verified[0] != msg.Signatures[0] {
+					t.Errorf("unexpected verified signatures: %v", verified)
+				}
+
+				if !bytes.Equal(msg.Payload, []byte(v.Input.Plaintext)) {
+					t.Errorf("unexpected payload: got %q, want %q", msg.Payload, v.Input.Plaintext)
+				}
+
+				// check the intermediate value
+				s := msg.Signatures[0]
+				toBeSigned, err := sigStructure(contextSignature, msg.rawProtected, s.rawProtected, external, msg.Payload)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := mustHex(t, v.Intermediates.Signers[0].ToBeSignHex); !bytes.Equal(toBeSigned, want) {
+					t.Errorf("unexpected ToBeSigned: got %x, want %x", toBeSigned, want)
+				}
+
+				// round trip
+				var got []byte
+				if data[0] == 0xd8 { // tag 98
+					got, err = msg.MarshalCBOR()
+				} else {
+					got, err = msg.MarshalCBORUntagged()
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, data) {
+					t.Errorf("unexpected encoding: got %x, want %x", got, data)
+				}
+			})
+		}
+	}
+}
+
+func TestSignMessage_AddSignature(t *testing.T) {
+	for name, v := range readTestVectors(t, "eddsa-examples") {
+		if v.Input.Sign == nil {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			// EdDSA is deterministic, so we can compare the result with the test vector.
+			signer := v.Input.Sign.Signers[0]
+			alg := signer.algorithm()
+			key := alg.SignatureAlgorithm().New().NewSigningKey(parseTestKey(t, signer.Key))
+			want := mustHex(t, v.Output.CBOR)
+			parsed, err := ParseSign(want)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			msg := NewSignMessage([]byte(v.Input.Plaintext))
+			msg.Protected = parsed.Protected
+			msg.Unprotected = parsed.Unprotected
+			protected := NewHeader()
+			protected.SetAlgorithm(alg)
+			unprotected := NewHeader()
+			unprotected.SetKeyID(parsed.Signatures[0].Unprotected.KeyID())
+			if err := msg.AddSignature(protected, unprotected, key, nil); err != nil {
+				t.Fatal(err)
+			}
+			got, err := msg.MarshalCBOR()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("unexpected result: got %x, want %x", got, want)
+			}
+		})
+	}
+}
+
+func TestVerifier_SignerPolicy(t *testing.T) {
+	// generate keys
+	ecdsaPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed25519Pub, ed25519Priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := &KeySet{
+		Keys: []*Key{
+			{
+				kty:  KeyTypeEC2,
+				kid:  []byte("ecdsa"),
+				priv: ecdsaPriv,
+				pub:  &ecdsaPriv.PublicKey,
+			},
+			{
+				kty:  KeyTypeOKP,
+				kid:  []byte("ed25519"),
+				priv: ed25519Priv,
+				pub:  ed25519Pub,
+			},
+		},
+	}
+
+	// sign the message by two signers
+	msg := NewSignMessage([]byte("This is the content."))
+	msg.Protected.SetContentType(uint64(0))
+	for _, key := range set.Keys {
+		var alg Algorithm
+		switch key.kty {
+		case KeyTypeEC2:
+			alg = AlgorithmES256
+		case KeyTypeOKP:
+			alg = AlgorithmEdDSA
+		}
+		protected := NewHeader()
+		protected.SetAlgorithm(alg)
+		unprotected := NewHeader()
+		unprotected.SetKeyID(key.KeyID())
+		signingKey := alg.SignatureAlgorithm().New().NewSigningKey(key)
+		if err := msg.AddSignature(protected, unprotected, signingKey, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := msg.MarshalCBOR()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("all valid", func(t *testing.T) {
+		msg, err := ParseSign(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		verifier := &Verifier{
+			AlgorithmVerifier: AllowedAlgorithms{AlgorithmES256, AlgorithmEdDSA},
+			KeyFinder:         &KeySetKeyFinder{KeySet: set},
+			SignerPolicy:      SignerPolicyAll,
+		}
+		verified, err := verifier.VerifySign(t.Context(), msg, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(verified) != 2 {
+			t.Errorf("unexpected number of verified signatures: %d", len(verified))
+		}
+	})
+
+	t.Run("any", func(t *testing.T) {
+		msg, err := ParseSign(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		verifier := &Verifier{
+			AlgorithmVerifier: AllowedAlgorithms{AlgorithmEdDSA},
+			KeyFinder:         &KeySetKeyFinder{KeySet: set},
+			SignerPolicy:      SignerPolicyAny,
+		}
+		verified, err := verifier.VerifySign(t.Context(), msg, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(verified) != 1 || string(verified[0].Unprotected.KeyID()) != "ed25519" {
+			t.Errorf("unexpected verified signatures: %v", verified)
+		}
+	})
+
+	t.Run("all with a disallowed algorithm", func(t *testing.T) {
+		msg, err := ParseSign(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		verifier := &Verifier{
+			AlgorithmVerifier: AllowedAlgorithms{AlgorithmEdDSA},
+			KeyFinder:         &KeySetKeyFinder{KeySet: set},
+			SignerPolicy:      SignerPolicyAll,
+		}
+		if _, err := verifier.VerifySign(t.Context(), msg, nil); err == nil {
+			t.Error("want error, but not")
+		}
+	})
+
+	t.Run("all with a broken signature", func(t *testing.T) {
+		msg, err := ParseSign(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg.Signatures[0].Signature[0] ^= 0xff
+		verifier := &Verifier{
+			AlgorithmVerifier: UnsecureAnyAlgorithm,
+			KeyFinder:         &KeySetKeyFinder{KeySet: set},
+			SignerPolicy:      SignerPolicyAll,
+		}
+		if _, err := verifier.VerifySign(t.Context(), msg, nil); err == nil {
+			t.Error("want error, but not")
+		}
+
+		verifier.SignerPolicy = SignerPolicyAny
+		verified, err := verifier.VerifySign(t.Context(), msg, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(verified) != 1 || verified[0] != msg.Signatures[1] {
+			t.Errorf("unexpected verified signatures: %v", verified)
+		}
+	})
+
+	t.Run("key algorithm mismatch", func(t *testing.T) {
+		msg, err := ParseSign(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		restricted := &Key{
+			kty:  KeyTypeEC2,
+			kid:  []byte("ecdsa"),
+			priv: ecdsaPriv,
+			pub:  &ecdsaPriv.PublicKey,
+		}
+		restricted.SetAlgorithm(AlgorithmES384)
+		verifier := &Verifier{
+			AlgorithmVerifier: UnsecureAnyAlgorithm,
+			KeyFinder:         &KeySetKeyFinder{KeySet: &KeySet{Keys: []*Key{restricted}}},
+			SignerPolicy:      SignerPolicyAll,
+		}
+		if _, err := verifier.VerifySign(t.Context(), msg, nil); err == nil {
+			t.Error("want error, but not")
+		}
+
+		restricted.SetAlgorithm(AlgorithmES256)
+		verifier.SignerPolicy = SignerPolicyAny
+		if _, err := verifier.VerifySign(t.Context(), msg, nil); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("unknown kid", func(t *testing.T) {
+		msg, err := ParseSign(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		verifier := &Verifier{
+			AlgorithmVerifier: UnsecureAnyAlgorithm,
+			KeyFinder:         &KeySetKeyFinder{KeySet: &KeySet{}},
+		}
+		if _, err := verifier.VerifySign(t.Context(), msg, nil); err == nil {
+			t.Error("want error, but not")
+		}
+	})
+}
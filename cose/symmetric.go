@@ -0,0 +1,21 @@
+package cose
+
+import (
+	"github.com/shogo82148/goat/internal/cborutils"
+)
+
+// RFC 9053 Section 7.4. Symmetric Keys
+const keyLabelSymmetricK = -1 // the key value
+
+func parseSymmetricKey(d *cborutils.Decoder, key *Key) {
+	k := d.MustBytes(keyLabelSymmetricK)
+	if d.Err() != nil {
+		return
+	}
+	key.priv = k
+}
+
+func encodeSymmetricKey(e *cborutils.Encoder, k []byte) {
+	e.Set(keyLabelKeyType, cborutils.IntegerFromInt64(int64(KeyTypeSymmetric)))
+	e.Set(keyLabelSymmetricK, k)
+}
@@ -0,0 +1,122 @@
+package cose
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/shogo82148/goat/jwa"
+)
+
+var errVerifyFailed = errors.New("cose: failed to verify the message")
+
+// AlgorithmVerifier verifies the algorithm used for signing.
+type AlgorithmVerifier interface {
+	VerifyAlgorithm(ctx context.Context, alg Algorithm) error
+}
+
+type AllowedAlgorithms []Algorithm
+
+func (a AllowedAlgorithms) VerifyAlgorithm(ctx context.Context, alg Algorithm) error {
+	if slices.Contains(a, alg) {
+		return nil
+	}
+	return errors.New("cose: signing algorithm is not allowed")
+}
+
+// UnsecureAnyAlgorithm is an AlgorithmVerifier that accepts any algorithm.
+var UnsecureAnyAlgorithm = unsecureAnyAlgorithmVerifier{}
+
+type unsecureAnyAlgorithmVerifier struct{}
+
+func (unsecureAnyAlgorithmVerifier) VerifyAlgorithm(ctx context.Context, alg Algorithm) error {
+	return nil
+}
+
+// SignerPolicy is a policy for verifying COSE_Sign messages that have multiple signers.
+type SignerPolicy int
+
+const (
+	// SignerPolicyAny accepts the message if at least one of the signatures is valid.
+	SignerPolicyAny SignerPolicy = iota
+
+	// SignerPolicyAll accepts the message only if all of the signatures are valid.
+	SignerPolicyAll
+)
+
+// Verifier verifies COSE_Sign1 and COSE_Sign messages.
+type Verifier struct {
+	_NamedFieldsRequired struct{}
+
+	AlgorithmVerifier AlgorithmVerifier
+	KeyFinder         KeyFinder
+
+	// SignerPolicy is the policy for COSE_Sign messages.
+	// The default is SignerPolicyAny.
+	SignerPolicy SignerPolicy
+}
+
+// VerifySign1 verifies the COSE_Sign1 message.
+// external is the externally supplied data described in RFC 9052 Section 4.3.
+func (v *Verifier) VerifySign1(ctx context.Context, msg *Sign1Message, external []byte) error {
+	_ = v._NamedFieldsRequired
+	if v.AlgorithmVerifier == nil || v.KeyFinder == nil {
+		return errors.New("cose: verifier is not configured")
+	}
+
+	alg := lookupAlgorithm(msg.Protected, msg.Unprotected)
+	if alg.SignatureAlgorithm() == jwa.SignatureAlgorithmUnknown {
+		return errVerifyFailed
+	}
+	if err := v.AlgorithmVerifier.VerifyAlgorithm(ctx, alg); err != nil {
+		return errVerifyFailed
+	}
+	key, err := v.KeyFinder.FindKey(ctx, msg.Protected, msg.Unprotected)
+	if err != nil {
+		return errVerifyFailed
+	}
+	if err := msg.Verify(key, external); err != nil {
+		return errVerifyFailed
+	}
+	return nil
+}
+
+// VerifySign verifies the COSE_Sign message according to SignerPolicy.
+// external is the externally supplied data described in RFC 9052 Section 4.3.
+// It returns the signatures that are successfully verified.
+func (v *Verifier) VerifySign(ctx context.Context, msg *SignMessage, external []byte) ([]*Signature, error) {
+	_ = v._NamedFieldsRequired
+	if v.AlgorithmVerifier == nil || v.KeyFinder == nil {
+		return nil, errors.New("cose: verifier is not configured")
+	}
+
+	var verified []*Signature
+	for _, s := range msg.Signatures {
+		if err := v.verifySignature(ctx, msg, s, external); err != nil {
+			if v.SignerPolicy == SignerPolicyAll {
+				return nil, errVerifyFailed
+			}
+			continue
+		}
+		verified = append(verified, s)
+	}
+	if len(verified) == 0 {
+		return nil, errVerifyFailed
+	}
+	return verified, nil
+}
+
+func (v *Verifier) verifySignature(ctx context.Context, msg *SignMessage, s *Signature, external []byte) error {
+	alg := lookupAlgorithm(s.Protected, s.Unprotected)
+	if alg.SignatureAlgorithm() == jwa.SignatureAlgorithmUnknown {
+		return errVerifyFailed
+	}
+	if err := v.AlgorithmVerifier.VerifyAlgorithm(ctx, alg); err != nil {
+		return err
+	}
+	key, err := v.KeyFinder.FindKey(ctx, s.Protected, s.Unprotected)
+	if err != nil {
+		return err
+	}
+	return msg.verify(s, key, external)
+}
@@ -0,0 +1,138 @@
+package cose
+
+import (
+	"crypto"
+	"crypto/subtle"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	"github.com/shogo82148/go-cbor"
+	"github.com/shogo82148/goat/internal/cborutils"
+)
+
+// CertificateHash is COSE_CertHash defined in RFC 9360 Section 2.
+// It is the value of the "x5t" header parameter.
+type CertificateHash struct {
+	// Algorithm is the hash algorithm, such as [AlgorithmSHA256].
+	Algorithm Algorithm
+
+	// Value is the hash value of the DER-encoded certificate.
+	Value []byte
+}
+
+// NewCertificateHash returns the hash of cert computed with alg.
+func NewCertificateHash(alg Algorithm, cert *x509.Certificate) (*CertificateHash, error) {
+	value, err := hashCertificate(alg, cert)
+	if err != nil {
+		return nil, err
+	}
+	return &CertificateHash{
+		Algorithm: alg,
+		Value:     value,
+	}, nil
+}
+
+// Match reports whether h is the hash of cert.
+// It returns false if the hash algorithm is not supported.
+func (h *CertificateHash) Match(cert *x509.Certificate) bool {
+	value, err := hashCertificate(h.Algorithm, cert)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(value, h.Value) == 1
+}
+
+func hashCertificate(alg Algorithm, cert *x509.Certificate) ([]byte, error) {
+	var hash crypto.Hash
+	var size int
+	switch alg {
+	case AlgorithmSHA1:
+		hash, size = crypto.SHA1, 20
+	case AlgorithmSHA256_64:
+		hash, size = crypto.SHA256, 8
+	case AlgorithmSHA256:
+		hash, size = crypto.SHA256, 32
+	case AlgorithmSHA512_256:
+		hash, size = crypto.SHA512_256, 32
+	case AlgorithmSHA384:
+		hash, size = crypto.SHA384, 48
+	case AlgorithmSHA512:
+		hash, size = crypto.SHA512, 64
+	default:
+		return nil, fmt.Errorf("cose: unsupported hash algorithm: %s", alg)
+	}
+	if !hash.Available() {
+		return nil, fmt.Errorf("cose: hash algorithm is not available: %s", alg)
+	}
+	w := hash.New()
+	w.Write(cert.Raw)
+	return w.Sum(nil)[:size], nil
+}
+
+func decodeCertificateHash(v any) (*CertificateHash, error) {
+	array, ok := v.([]any)
+	if !ok || len(array) != 2 {
+		return nil, errors.New("cose: x5t must be an array of the algorithm and the hash value")
+	}
+	h := &CertificateHash{}
+	switch alg := array[0].(type) {
+	case cbor.Integer:
+		i, err := alg.Int64()
+		if err != nil {
+			return nil, fmt.Errorf("cose: invalid hash algorithm: %w", err)
+		}
+		h.Algorithm = Algorithm(i)
+	case string:
+		// text string algorithms are not supported.
+		// the raw value is still available in Header.Raw.
+	default:
+		return nil, fmt.Errorf("cose: invalid type of hash algorithm: %T", alg)
+	}
+	value, ok := array[1].([]byte)
+	if !ok {
+		return nil, fmt.Errorf("cose: invalid type of hash value: %T", array[1])
+	}
+	h.Value = value
+	return h, nil
+}
+
+func (h *CertificateHash) encode() []any {
+	return []any{
+		cborutils.IntegerFromInt64(int64(h.Algorithm)),
+		h.Value,
+	}
+}
+
+// lookupX509Bag returns the x5bag from the protected header or the unprotected header.
+func lookupX509Bag(protected, unprotected *Header) []*x509.Certificate {
+	if protected != nil && protected.x5bag != nil {
+		return protected.x5bag
+	}
+	if unprotected != nil {
+		return unprotected.x5bag
+	}
+	return nil
+}
+
+// lookupX509CertificateChain returns the x5chain from the protected header or the unprotected header.
+func lookupX509CertificateChain(protected, unprotected *Header) []*x509.Certificate {
+	if protected != nil && protected.x5chain != nil {
+		return protected.x5chain
+	}
+	if unprotected != nil {
+		return unprotected.x5chain
+	}
+	return nil
+}
+
+// lookupX509CertificateHash returns the x5t from the protected header or the unprotected header.
+func lookupX509CertificateHash(protected, unprotected *Header) *CertificateHash {
+	if protected != nil && protected.x5t != nil {
+		return protected.x5t
+	}
+	if unprotected != nil {
+		return unprotected.x5t
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package cose
+
+import (
+	"bytes"
+	"crypto/x509"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shogo82148/goat"
+	_ "github.com/shogo82148/goat/jwa/es"
+)
+
+func readTestCertificate(t *testing.T, name string) *x509.Certificate {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "cose-wg-examples", "x509-examples", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestVerifier_X509KeyFinder(t *testing.T) {
+	alice := readTestCertificate(t, "alice.der")
+	ca := readTestCertificate(t, "ca.der")
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	tests := map[string]struct {
+		check  func(t *testing.T, h *Header)
+		finder *X509KeyFinder
+	}{
+		"signed-01.json": {
+			check: func(t *testing.T, h *Header) {
+				if bag := h.X509Bag(); len(bag) != 1 || !bag[0].Equal(alice) {
+					t.Errorf("unexpected x5bag: %v", bag)
+				}
+			},
+		},
+		"signed-02.json": {
+			check: func(t *testing.T, h *Header) {
+				if bag := h.X509Bag(); len(bag) != 2 || !bag[0].Equal(alice) || !bag[1].Equal(ca) {
+					t.Errorf("unexpected x5bag: %v", bag)
+				}
+			},
+		},
+		"signed-03.json": {
+			check: func(t *testing.T, h *Header) {
+				if chain := h.X509CertificateChain(); len(chain) != 1 || !chain[0].Equal(alice) {
+					t.Errorf("unexpected x5chain: %v", chain)
+				}
+			},
+			finder: &X509KeyFinder{
+				Roots: roots,
+			},
+		},
+		"signed-04.json": {
+			check: func(t *testing.T, h *Header) {
+				if chain := h.X509CertificateChain(); len(chain) != 2 || !chain[0].Equal(alice) || !chain[1].Equal(ca) {
+					t.Errorf("unexpected x5chain: %v", chain)
+				}
+			},
+			finder: &X509KeyFinder{
+				Roots: roots,
+			},
+		},
+		"signed-05.json": {
+			check: func(t *testing.T, h *Header) {
+				x5t := h.X509CertificateHash()
+				if x5t == nil || x5t.Algorithm != AlgorithmSHA256 {
+					t.Fatalf("unexpected x5t: %v", x5t)
+				}
+				if !x5t.Match(alice) {
+					t.Error("x5t does not match alice's certificate")
+				}
+				if x5t.Match(ca) {
+					t.Error("x5t matches ca's certificate")
+				}
+			},
+			finder: &X509KeyFinder{
+				Roots:        roots,
+				Certificates: []*x509.Certificate{alice},
+			},
+		},
+	}
+
+	for name, v := range readTestVectors(t, "x509-examples") {
+		tt, ok := tests[name]
+		if !ok {
+			t.Errorf("unknown test vector: %s", name)
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			data := mustHex(t, v.Output.CBOR)
+			if tt.finder == nil {
+				// signed-01 and signed-02 encode "kid" as a text string,
+				// but RFC 9052 requires a byte string.
+				// Fix the major type to test x5bag.
+				i := bytes.Index(data, []byte("\x6eAlice Lovelace"))
+				if i < 0 {
+					t.Fatal("kid not found")
+				}
+				data[i] = 0x4e
+			}
+			msg, err := ParseSign(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := msg.Signatures[0]
+			tt.check(t, s.Unprotected)
+
+			// round trip
+			got, err := msg.MarshalCBOR()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("unexpected encoding: got %x, want %x", got, data)
+			}
+
+			if tt.finder == nil {
+				// the signer can't be identified only by x5bag.
+				finder := &X509KeyFinder{Roots: roots}
+				if _, err := finder.FindKey(t.Context(), s.Protected, s.Unprotected); err == nil {
+					t.Error("want error, but not")
+				}
+				return
+			}
+			verifier := &Verifier{
+				AlgorithmVerifier: AllowedAlgorithms{AlgorithmES256},
+				KeyFinder:         tt.finder,
+			}
+			if _, err := verifier.VerifySign(t.Context(), msg, nil); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestX509KeyFinder_Error(t *testing.T) {
+	alice := readTestCertificate(t, "alice.der")
+	ca := readTestCertificate(t, "ca.der")
+
+	t.Run("untrusted root", func(t *testing.T) {
+		protected := NewHeader()
+		protected.SetAlgorithm(AlgorithmES256)
+		unprotected := NewHeader()
+		unprotected.SetX509CertificateChain([]*x509.Certificate{alice, ca})
+		finder := &X509KeyFinder{
+			Roots: x509.NewCertPool(),
+		}
+		if _, err := finder.FindKey(t.Context(), protected, unprotected); err == nil {
+			t.Error("want error, but not")
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		protected := NewHeader()
+		protected.SetAlgorithm(AlgorithmES256)
+		unprotected := NewHeader()
+		unprotected.SetX509CertificateChain([]*x509.Certificate{alice, ca})
+		roots := x509.NewCertPool()
+		roots.AddCert(ca)
+		finder := &X509KeyFinder{
+			Roots: roots,
+			Clock: goat.ClockFunc(func() time.Time {
+				return alice.NotAfter.Add(time.Second)
+			}),
+		}
+		if _, err := finder.FindKey(t.Context(), protected, unprotected); err == nil {
+			t.Error("want error, but not")
+		}
+	})
+
+	t.Run("x5t mismatch", func(t *testing.T) {
+		x5t, err := NewCertificateHash(AlgorithmSHA256, ca)
+		if err != nil {
+			t.Fatal(err)
+		}
+		protected := NewHeader()
+		protected.SetAlgorithm(AlgorithmES256)
+		unprotected := NewHeader()
+		unprotected.SetX509CertificateChain([]*x509.Certificate{alice})
+		unprotected.SetX509CertificateHash(x5t)
+		finder := &X509KeyFinder{}
+		if _, err := finder.FindKey(t.Context(), protected, unprotected); err == nil {
+			t.Error("want error, but not")
+		}
+	})
+
+	t.Run("x5t not found", func(t *testing.T) {
+		x5t, err := NewCertificateHash(AlgorithmSHA256_64, alice)
+		if err != nil {
+			t.Fatal(err)
+		}
+		protected := NewHeader()
+		protected.SetAlgorithm(AlgorithmES256)
+		unprotected := NewHeader()
+		unprotected.SetX509CertificateHash(x5t)
+		finder := &X509KeyFinder{
+			Certificates: []*x509.Certificate{ca},
+		}
+		if _, err := finder.FindKey(t.Context(), protected, unprotected); err == nil {
+			t.Error("want error, but not")
+		}
+	})
+}
+
+func TestHeader_X509(t *testing.T) {
+	alice := readTestCertificate(t, "alice.der")
+	ca := readTestCertificate(t, "ca.der")
+	x5t, err := NewCertificateHash(AlgorithmSHA256_64, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(x5t.Value) != 8 {
+		t.Errorf("unexpected length of SHA-256/64: %d", len(x5t.Value))
+	}
+	u, err := url.Parse("https://example.com/alice.der")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewHeader()
+	h.SetX509Bag([]*x509.Certificate{ca})
+	h.SetX509CertificateChain([]*x509.Certificate{alice, ca})
+	h.SetX509CertificateHash(x5t)
+	h.SetX509URL(u)
+	data, err := encodeProtectedHeader(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := decodeProtectedHeader(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bag := got.X509Bag(); len(bag) != 1 || !bag[0].Equal(ca) {
+		t.Errorf("unexpected x5bag: %v", bag)
+	}
+	if chain := got.X509CertificateChain(); len(chain) != 2 || !chain[0].Equal(alice) || !chain[1].Equal(ca) {
+		t.Errorf("unexpected x5chain: %v", chain)
+	}
+	if x5t := got.X509CertificateHash(); x5t == nil || x5t.Algorithm != AlgorithmSHA256_64 || !x5t.Match(alice) {
+		t.Errorf("unexpected x5t: %v", x5t)
+	}
+	if x5u := got.X509URL(); x5u == nil || x5u.String() != u.String() {
+		t.Errorf("unexpected x5u: %v", x5u)
+	}
+}
@@ -0,0 +1,182 @@
+// Package cwt handles CBOR Web Token defined in RFC 8392.
+package cwt
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/shogo82148/go-cbor"
+	"github.com/shogo82148/goat/cose"
+	"github.com/shogo82148/goat/internal/cborutils"
+	"github.com/shogo82148/goat/sig"
+)
+
+// TagNumberCWT is the CBOR tag number for CWT defined in RFC 8392 Section 6.
+const TagNumberCWT cbor.TagNumber = 61
+
+// RFC 8392 Section 4. Summary of the Claim Names, Keys, and Value Types
+const (
+	claimLabelIssuer         = 1
+	claimLabelSubject        = 2
+	claimLabelAudience       = 3
+	claimLabelExpirationTime = 4
+	claimLabelNotBefore      = 5
+	claimLabelIssuedAt       = 6
+	claimLabelCWTID          = 7
+)
+
+// Claims is a CWT Claims Set defined in RFC 8392.
+type Claims struct {
+	// RFC 8392 Section 3.1.1. iss (Issuer) Claim
+	Issuer string
+
+	// RFC 8392 Section 3.1.2. sub (Subject) Claim
+	Subject string
+
+	// RFC 8392 Section 3.1.3. aud (Audience) Claim
+	Audience []string
+
+	// RFC 8392 Section 3.1.4. exp (Expiration Time) Claim
+	ExpirationTime time.Time
+
+	// RFC 8392 Section 3.1.5. nbf (Not Before) Claim
+	NotBefore time.Time
+
+	// RFC 8392 Section 3.1.6. iat (Issued At) Claim
+	IssuedAt time.Time
+
+	// RFC 8392 Section 3.1.7. cti (CWT ID) Claim
+	CWTID []byte
+
+	// Raw is the raw data of CBOR-decoded claims.
+	// The keys are cbor.Integer for integer labels, and string for text labels.
+	Raw map[any]any
+}
+
+// Token is a decoded CWT.
+type Token struct {
+	// Protected and Unprotected are the headers of
+	// the COSE message that contains the claims.
+	Protected   *cose.Header
+	Unprotected *cose.Header
+
+	Claims *Claims
+}
+
+// Sign returns a CWT signed with key using COSE_Sign1.
+// The algorithm must be set in protected or unprotected.
+// Nil headers are treated as empty headers.
+func Sign(protected, unprotected *cose.Header, claims *Claims, key sig.SigningKey) ([]byte, error) {
+	payload, err := encodeClaims(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := cose.NewSign1Message(payload)
+	if protected != nil {
+		msg.Protected = protected
+	}
+	if unprotected != nil {
+		msg.Unprotected = unprotected
+	}
+	if err := msg.Sign(key, nil); err != nil {
+		return nil, err
+	}
+	return msg.MarshalCBOR()
+}
+
+// MAC returns a CWT authenticated with key using COSE_Mac0.
+// key is typically created by [cose.Algorithm.NewMACKey].
+// The algorithm must be set in protected or unprotected.
+// Nil headers are treated as empty headers.
+func MAC(protected, unprotected *cose.Header, claims *Claims, key sig.SigningKey) ([]byte, error) {
+	payload, err := encodeClaims(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := cose.NewMac0Message(payload)
+	if protected != nil {
+		msg.Protected = protected
+	}
+	if unprotected != nil {
+		msg.Unprotected = unprotected
+	}
+	if err := msg.Sign(key, nil); err != nil {
+		return nil, err
+	}
+	return msg.MarshalCBOR()
+}
+
+// Encrypt returns a CWT encrypted with the content encryption key cek using COSE_Encrypt0.
+// The content encryption algorithm must be set in protected or unprotected.
+// If the IV is not set, a new IV is generated and set to the unprotected header.
+// Nil headers are treated as empty headers.
+func Encrypt(protected, unprotected *cose.Header, claims *Claims, cek []byte) ([]byte, error) {
+	payload, err := encodeClaims(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := cose.NewEncrypt0Message()
+	if protected != nil {
+		msg.Protected = protected
+	}
+	if unprotected != nil {
+		msg.Unprotected = unprotected
+	}
+	if err := msg.Encrypt(cek, payload, nil); err != nil {
+		return nil, err
+	}
+	return msg.MarshalCBOR()
+}
+
+func encodeClaims(c *Claims) ([]byte, error) {
+	if c == nil {
+		return nil, errors.New("cwt: claims are nil")
+	}
+	raw := make(map[any]any, len(c.Raw))
+	maps.Copy(raw, c.Raw)
+	e := cborutils.NewEncoder(raw)
+
+	if iss := c.Issuer; iss != "" {
+		e.Set(claimLabelIssuer, iss)
+	}
+	if sub := c.Subject; sub != "" {
+		e.Set(claimLabelSubject, sub)
+	}
+	if aud := c.Audience; aud != nil {
+		if len(aud) == 1 {
+			e.Set(claimLabelAudience, aud[0])
+		} else {
+			a := make([]any, 0, len(aud))
+			for _, v := range aud {
+				a = append(a, v)
+			}
+			e.Set(claimLabelAudience, a)
+		}
+	}
+	if exp := c.ExpirationTime; !exp.IsZero() {
+		e.SetTime(claimLabelExpirationTime, exp)
+	}
+	if nbf := c.NotBefore; !nbf.IsZero() {
+		e.SetTime(claimLabelNotBefore, nbf)
+	}
+	if iat := c.IssuedAt; !iat.IsZero() {
+		e.SetTime(claimLabelIssuedAt, iat)
+	}
+	if cti := c.CWTID; cti != nil {
+		e.Set(claimLabelCWTID, cti)
+	}
+
+	if err := e.Err(); err != nil {
+		return nil, err
+	}
+	data, err := cbor.Marshal(e.Data())
+	if err != nil {
+		return nil, fmt.Errorf("cwt: failed to encode claims: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,523 @@
+package cwt
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/shogo82148/goat"
+	"github.com/shogo82148/goat/cose"
+	_ "github.com/shogo82148/goat/jwa/agcm"
+	_ "github.com/shogo82148/goat/jwa/es"
+	"github.com/shogo82148/goat/sig"
+)
+
+// testClock is the clock for testing.
+var testClock = goat.ClockFunc(func() time.Time {
+	return time.Unix(1443944944, 0)
+})
+
+func mustHex(t testing.TB, s string) []byte {
+	t.Helper()
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// RFC 8392 Appendix A.2.1. 256-Bit Symmetric Key
+func testSymmetricKey(t testing.TB) *cose.Key {
+	t.Helper()
+	key, err := cose.NewPrivateKey(mustHex(t, "403697de87af64611c1d32a05dab0fe1fcb715a86ab435f1ec99192d79569388"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// RFC 8392 Appendix A.2.3. ECDSA P-256 256-Bit COSE Key
+func testECDSAKey(t testing.TB) *cose.Key {
+	t.Helper()
+	priv, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), mustHex(t, "6c1382765aec5358f117733d281c1c7bdc39884d04a45a1e6c67c858bc206c19"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := cose.NewPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func testKeyFinder(key *cose.Key) cose.KeyFinder {
+	return cose.FindKeyFunc(func(ctx context.Context, protected, unprotected *cose.Header) (sig.SigningKey, error) {
+		alg := protected.Algorithm()
+		if alg == cose.AlgorithmHMAC256_64 {
+			return alg.NewMACKey(key), nil
+		}
+		return alg.SignatureAlgorithm().New().NewSigningKey(key), nil
+	})
+}
+
+func TestParse(t *testing.T) {
+	t.Run("RFC 8392 Appendix A.3. Example Signed CWT", func(t *testing.T) {
+		raw := mustHex(t, "d28443a10126a05850a70175636f61703a2f2f61732e6578616d706c652e636f"+
+			"6d02656572696b77037818636f61703a2f2f6c696768742e6578616d706c652e"+
+			"636f6d041a5612aeb0051a5610d9f0061a5610d9f007420b7158405427c1ff28"+
+			"d23fbad1f29c4c7c6a555e601d6fa29f9179bc3d7438bacaca5acd08c8d4d4f9"+
+			"6131680c429a01f85951ecee743a52b9b63632c57209120e1c9e30")
+		p := &Parser{
+			KeyFinder:             testKeyFinder(testECDSAKey(t)),
+			AlgorithmVerifier:     AllowedAlgorithms{cose.AlgorithmES256},
+			IssuerSubjectVerifier: Issuer("coap://as.example.com"),
+			AudienceVerifier:      Audience("coap://light.example.com"),
+			Clock:                 testClock,
+		}
+		token, err := p.Parse(t.Context(), raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token.Protected.Algorithm() != cose.AlgorithmES256 {
+			t.Errorf("unexpected algorithm: %v", token.Protected.Algorithm())
+		}
+		assertRFC8392Claims(t, token.Claims)
+	})
+
+	t.Run("RFC 8392 Appendix A.4. Example MACed CWT", func(t *testing.T) {
+		raw := mustHex(t, "d83dd18443a10104a1044c53796d6d65747269633235365850a70175636f6170"+
+			"3a2f2f61732e6578616d706c652e636f6d02656572696b77037818636f61703a"+
+			"2f2f6c696768742e6578616d706c652e636f6d041a5612aeb0051a5610d9f006"+
+			"1a5610d9f007420b7148093101ef6d789200")
+		p := &Parser{
+			KeyFinder:             testKeyFinder(testSymmetricKey(t)),
+			AlgorithmVerifier:     AllowedAlgorithms{cose.AlgorithmHMAC256_64},
+			IssuerSubjectVerifier: Issuer("coap://as.example.com"),
+			AudienceVerifier:      Audience("coap://light.example.com"),
+			Clock:                 testClock,
+		}
+		token, err := p.Parse(t.Context(), raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kid := token.Unprotected.KeyID(); string(kid) != "Symmetric256" {
+			t.Errorf("unexpected key id: %q", kid)
+		}
+		assertRFC8392Claims(t, token.Claims)
+	})
+
+	t.Run("RFC 8392 Appendix A.7. Example MACed CWT with a Floating-Point Value", func(t *testing.T) {
+		raw := mustHex(t, "d18443a10104a1044c53796d6d65747269633235364ba106fb41d584367c2000"+
+			"0048b8816f34c0542892")
+		p := &Parser{
+			KeyFinder:             testKeyFinder(testSymmetricKey(t)),
+			AlgorithmVerifier:     AllowedAlgorithms{cose.AlgorithmHMAC256_64},
+			IssuerSubjectVerifier: UnsecureAnyIssuerSubject,
+			AudienceVerifier:      UnsecureAnyAudience,
+
+			// the token is issued at 1443944944.5.
+			Clock: goat.ClockFunc(func() time.Time {
+				return time.Unix(1443944945, 0)
+			}),
+		}
+		token, err := p.Parse(t.Context(), raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := time.Unix(1443944944, 500000000); !token.Claims.IssuedAt.Equal(want) {
+			t.Errorf("unexpected iat: got %v, want %v", token.Claims.IssuedAt, want)
+		}
+	})
+}
+
+func assertRFC8392Claims(t *testing.T, c *Claims) {
+	t.Helper()
+	if c.Issuer != "coap://as.example.com" {
+		t.Errorf("unexpected issuer: %q", c.Issuer)
+	}
+	if c.Subject != "erikw" {
+		t.Errorf("unexpected subject: %q", c.Subject)
+	}
+	if !slices.Equal(c.Audience, []string{"coap://light.example.com"}) {
+		t.Errorf("unexpected audience: %v", c.Audience)
+	}
+	if !c.ExpirationTime.Equal(time.Unix(1444064944, 0)) {
+		t.Errorf("unexpected exp: %v", c.ExpirationTime)
+	}
+	if !c.NotBefore.Equal(time.Unix(1443944944, 0)) {
+		t.Errorf("unexpected nbf: %v", c.NotBefore)
+	}
+	if !c.IssuedAt.Equal(time.Unix(1443944944, 0)) {
+		t.Errorf("unexpected iat: %v", c.IssuedAt)
+	}
+	if !bytes.Equal(c.CWTID, []byte{0x0b, 0x71}) {
+		t.Errorf("unexpected cti: %x", c.CWTID)
+	}
+}
+
+func TestParse_Error(t *testing.T) {
+	now := time.Unix(1443944944, 0)
+	clock := goat.ClockFunc(func() time.Time {
+		return now
+	})
+	key := testECDSAKey(t)
+	signer := cose.AlgorithmES256.SignatureAlgorithm().New().NewSigningKey(key)
+	protected := cose.NewHeader()
+	protected.SetAlgorithm(cose.AlgorithmES256)
+
+	p := &Parser{
+		KeyFinder:             testKeyFinder(key),
+		AlgorithmVerifier:     AllowedAlgorithms{cose.AlgorithmES256},
+		IssuerSubjectVerifier: Issuer("issuer"),
+		AudienceVerifier:      Audience("audience"),
+		Clock:                 clock,
+	}
+	tests := []struct {
+		name   string
+		claims *Claims
+	}{
+		{
+			name: "expired",
+			claims: &Claims{
+				Issuer:         "issuer",
+				Audience:       []string{"audience"},
+				ExpirationTime: now,
+			},
+		},
+		{
+			name: "not valid yet",
+			claims: &Claims{
+				Issuer:    "issuer",
+				Audience:  []string{"audience"},
+				NotBefore: now.Add(time.Second),
+			},
+		},
+		{
+			name: "issued in the future",
+			claims: &Claims{
+				Issuer:   "issuer",
+				Audience: []string{"audience"},
+				IssuedAt: now.Add(time.Second),
+			},
+		},
+		{
+			name: "issuer",
+			claims: &Claims{
+				Issuer:   "evil",
+				Audience: []string{"audience"},
+			},
+		},
+		{
+			name: "audience",
+			claims: &Claims{
+				Issuer:   "issuer",
+				Audience: []string{"evil"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Sign(protected, nil, tt.claims, signer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := p.Parse(t.Context(), data); err == nil {
+				t.Error("want error, but not")
+			}
+		})
+	}
+
+	t.Run("algorithm", func(t *testing.T) {
+		p := &Parser{
+			KeyFinder:             testKeyFinder(key),
+			AlgorithmVerifier:     AllowedAlgorithms{cose.AlgorithmES384},
+			IssuerSubjectVerifier: UnsecureAnyIssuerSubject,
+			AudienceVerifier:      UnsecureAnyAudience,
+			Clock:                 clock,
+		}
+		data, err := Sign(protected, nil, &Claims{}, signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := p.Parse(t.Context(), data); err == nil {
+			t.Error("want error, but not")
+		}
+	})
+
+	t.Run("untagged", func(t *testing.T) {
+		msg := cose.NewSign1Message([]byte{0xa0})
+		msg.Protected.SetAlgorithm(cose.AlgorithmES256)
+		if err := msg.Sign(signer, nil); err != nil {
+			t.Fatal(err)
+		}
+		data, err := msg.MarshalCBORUntagged()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := p.Parse(t.Context(), data); err == nil {
+			t.Error("want error, but not")
+		}
+	})
+}
+
+func TestParse_Leeway(t *testing.T) {
+	now := time.Unix(1443944944, 0)
+	key := testECDSAKey(t)
+	signer := cose.AlgorithmES256.SignatureAlgorithm().New().NewSigningKey(key)
+	protected := cose.NewHeader()
+	protected.SetAlgorithm(cose.AlgorithmES256)
+
+	p := &Parser{
+		KeyFinder:             testKeyFinder(key),
+		AlgorithmVerifier:     AllowedAlgorithms{cose.AlgorithmES256},
+		IssuerSubjectVerifier: UnsecureAnyIssuerSubject,
+		AudienceVerifier:      UnsecureAnyAudience,
+		Clock: goat.ClockFunc(func() time.Time {
+			return now
+		}),
+		Leeway: 5 * time.Second,
+		MaxAge: time.Minute,
+	}
+	tests := []struct {
+		name   string
+		claims *Claims
+		ok     bool
+	}{
+		{
+			name: "expired within leeway",
+			claims: &Claims{
+				ExpirationTime: now.Add(-4 * time.Second),
+				IssuedAt:       now.Add(-time.Minute),
+			},
+			ok: true,
+		},
+		{
+			name: "expired",
+			claims: &Claims{
+				ExpirationTime: now.Add(-5 * time.Second),
+				IssuedAt:       now.Add(-time.Minute),
+			},
+		},
+		{
+			name: "not valid yet within leeway",
+			claims: &Claims{
+				NotBefore: now.Add(5 * time.Second),
+				IssuedAt:  now,
+			},
+			ok: true,
+		},
+		{
+			name: "not valid yet",
+			claims: &Claims{
+				NotBefore: now.Add(6 * time.Second),
+				IssuedAt:  now,
+			},
+		},
+		{
+			name: "issued in the future within leeway",
+			claims: &Claims{
+				IssuedAt: now.Add(5 * time.Second),
+			},
+			ok: true,
+		},
+		{
+			name: "issued in the future",
+			claims: &Claims{
+				IssuedAt: now.Add(6 * time.Second),
+			},
+		},
+		{
+			name: "max age within leeway",
+			claims: &Claims{
+				IssuedAt: now.Add(-time.Minute - 5*time.Second),
+			},
+			ok: true,
+		},
+		{
+			name: "too old",
+			claims: &Claims{
+				IssuedAt: now.Add(-time.Minute - 6*time.Second),
+			},
+		},
+		{
+			name:   "iat is missing",
+			claims: &Claims{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Sign(protected, nil, tt.claims, signer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = p.Parse(t.Context(), data)
+			if tt.ok && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Error("want error, but not")
+			}
+		})
+	}
+}
+
+func TestSign(t *testing.T) {
+	key := testECDSAKey(t)
+	protected := cose.NewHeader()
+	protected.SetAlgorithm(cose.AlgorithmES256)
+	claims := &Claims{
+		Issuer:         "coap://as.example.com",
+		Subject:        "erikw",
+		Audience:       []string{"coap://light.example.com"},
+		ExpirationTime: time.Unix(1444064944, 0),
+		NotBefore:      time.Unix(1443944944, 0),
+		IssuedAt:       time.Unix(1443944944, 0),
+		CWTID:          []byte{0x0b, 0x71},
+	}
+	data, err := Sign(protected, nil, claims, cose.AlgorithmES256.SignatureAlgorithm().New().NewSigningKey(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the claims are encoded as same as RFC 8392 Appendix A.1.
+	msg, err := cose.ParseSign1(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := mustHex(t, "a70175636f61703a2f2f61732e6578616d706c652e636f6d02656572696b7703"+
+		"7818636f61703a2f2f6c696768742e6578616d706c652e636f6d041a5612aeb0"+
+		"051a5610d9f0061a5610d9f007420b71")
+	if !bytes.Equal(msg.Payload, want) {
+		t.Errorf("unexpected claims: got %x, want %x", msg.Payload, want)
+	}
+
+	p := &Parser{
+		KeyFinder:             testKeyFinder(key),
+		AlgorithmVerifier:     AllowedAlgorithms{cose.AlgorithmES256},
+		IssuerSubjectVerifier: Issuer("coap://as.example.com"),
+		AudienceVerifier:      Audience("coap://light.example.com"),
+		Clock:                 testClock,
+	}
+	token, err := p.Parse(t.Context(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertRFC8392Claims(t, token.Claims)
+}
+
+func TestMAC(t *testing.T) {
+	key := testSymmetricKey(t)
+	protected := cose.NewHeader()
+	protected.SetAlgorithm(cose.AlgorithmHMAC256_64)
+	claims := &Claims{
+		Issuer:   "issuer",
+		Audience: []string{"audience1", "audience2"},
+		IssuedAt: time.Unix(1443944944, 500000000),
+	}
+	data, err := MAC(protected, nil, claims, cose.AlgorithmHMAC256_64.NewMACKey(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Parser{
+		KeyFinder:             testKeyFinder(key),
+		AlgorithmVerifier:     AllowedAlgorithms{cose.AlgorithmHMAC256_64},
+		IssuerSubjectVerifier: Issuer("issuer"),
+		AudienceVerifier:      Audience("audience2"),
+	}
+	token, err := p.Parse(t.Context(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(token.Claims.Audience, claims.Audience) {
+		t.Errorf("unexpected audience: %v", token.Claims.Audience)
+	}
+	if !token.Claims.IssuedAt.Equal(claims.IssuedAt) {
+		t.Errorf("unexpected iat: %v", token.Claims.IssuedAt)
+	}
+}
+
+func TestEncrypt(t *testing.T) {
+	cek := make([]byte, 16)
+	if _, err := rand.Read(cek); err != nil {
+		t.Fatal(err)
+	}
+	protected := cose.NewHeader()
+	protected.SetAlgorithm(cose.AlgorithmA128GCM)
+	data, err := Encrypt(protected, nil, &Claims{Issuer: "issuer"}, cek)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	finder := FindContentKeyFunc(func(ctx context.Context, protected, unprotected *cose.Header) ([]byte, error) {
+		return cek, nil
+	})
+	p := &Parser{
+		KeyFinder:             testKeyFinder(testECDSAKey(t)),
+		ContentKeyFinder:      finder,
+		AlgorithmVerifier:     AllowedAlgorithms{cose.AlgorithmA128GCM},
+		IssuerSubjectVerifier: Issuer("issuer"),
+		AudienceVerifier:      UnsecureAnyAudience,
+	}
+	token, err := p.Parse(t.Context(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Claims.Issuer != "issuer" {
+		t.Errorf("unexpected issuer: %q", token.Claims.Issuer)
+	}
+
+	// the content key finder is required.
+	p.ContentKeyFinder = nil
+	if _, err := p.Parse(t.Context(), data); err == nil {
+		t.Error("want error, but not")
+	}
+}
+
+func TestParse_Nested(t *testing.T) {
+	// RFC 8392 Section 7: a MACed CWT that is nested in an encrypted CWT.
+	key := testSymmetricKey(t)
+	cek := make([]byte, 16)
+	if _, err := rand.Read(cek); err != nil {
+		t.Fatal(err)
+	}
+
+	h := cose.NewHeader()
+	h.SetAlgorithm(cose.AlgorithmHMAC256_64)
+	inner, err := MAC(h, nil, &Claims{Issuer: "issuer"}, cose.AlgorithmHMAC256_64.NewMACKey(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	outer := cose.NewEncrypt0Message()
+	outer.Protected.SetAlgorithm(cose.AlgorithmA128GCM)
+	if err := outer.Encrypt(cek, inner, nil); err != nil {
+		t.Fatal(err)
+	}
+	data, err := outer.MarshalCBOR()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Parser{
+		KeyFinder: testKeyFinder(key),
+		ContentKeyFinder: FindContentKeyFunc(func(ctx context.Context, protected, unprotected *cose.Header) ([]byte, error) {
+			return cek, nil
+		}),
+		AlgorithmVerifier:     AllowedAlgorithms{cose.AlgorithmHMAC256_64, cose.AlgorithmA128GCM},
+		IssuerSubjectVerifier: Issuer("issuer"),
+		AudienceVerifier:      UnsecureAnyAudience,
+	}
+	token, err := p.Parse(t.Context(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Protected.Algorithm() != cose.AlgorithmHMAC256_64 {
+		t.Errorf("unexpected algorithm: %v", token.Protected.Algorithm())
+	}
+}
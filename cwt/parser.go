@@ -0,0 +1,353 @@
+package cwt
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/shogo82148/go-cbor"
+	"github.com/shogo82148/goat"
+	"github.com/shogo82148/goat/cose"
+	"github.com/shogo82148/goat/internal/cborutils"
+	"github.com/shogo82148/goat/internal/clockutils"
+)
+
+// maxNestingDepth is the maximum depth of nested CWTs described in RFC 8392 Section 7.
+const maxNestingDepth = 4
+
+// ContentKeyFinder finds the content encryption key for COSE_Encrypt0 messages.
+type ContentKeyFinder interface {
+	FindContentKey(ctx context.Context, protected, unprotected *cose.Header) (cek []byte, err error)
+}
+
+// FindContentKeyFunc is an adapter to allow the use of ordinary functions as ContentKeyFinder.
+type FindContentKeyFunc func(ctx context.Context, protected, unprotected *cose.Header) (cek []byte, err error)
+
+func (f FindContentKeyFunc) FindContentKey(ctx context.Context, protected, unprotected *cose.Header) (cek []byte, err error) {
+	return f(ctx, protected, unprotected)
+}
+
+// AlgorithmVerifier verifies the algorithm used for signing, MAC or encryption.
+type AlgorithmVerifier interface {
+	VerifyAlgorithm(ctx context.Context, alg cose.Algorithm) error
+}
+
+// UnsecureAnyAlgorithm is an AlgorithmVerifier that accepts any algorithm.
+var UnsecureAnyAlgorithm = unsecureAnyAlgorithmVerifier{}
+
+type unsecureAnyAlgorithmVerifier struct{}
+
+func (unsecureAnyAlgorithmVerifier) VerifyAlgorithm(ctx context.Context, alg cose.Algorithm) error {
+	return nil
+}
+
+// AllowedAlgorithms is an AlgorithmVerifier that accepts only the specified algorithms.
+type AllowedAlgorithms []cose.Algorithm
+
+func (a AllowedAlgorithms) VerifyAlgorithm(ctx context.Context, alg cose.Algorithm) error {
+	if slices.Contains(a, alg) {
+		return nil
+	}
+	return errors.New("cwt: algorithm is not allowed")
+}
+
+// IssuerSubjectVerifier verifies the issuer and the subject.
+type IssuerSubjectVerifier interface {
+	VerifyIssuer(ctx context.Context, iss, sub string) error
+}
+
+// Issuer is a verifier that accepts only the specified issuer.
+type Issuer string
+
+func (i Issuer) VerifyIssuer(ctx context.Context, iss, sub string) error {
+	if iss != string(i) {
+		return fmt.Errorf("cwt: invalid issuer: %s", iss)
+	}
+	return nil
+}
+
+// UnsecureAnyIssuerSubject is an IssuerSubjectVerifier that accepts any issuer and subject.
+// This is not recommended.
+var UnsecureAnyIssuerSubject = unsecureAnyIssuerSubjectVerifier{}
+
+type unsecureAnyIssuerSubjectVerifier struct{}
+
+func (unsecureAnyIssuerSubjectVerifier) VerifyIssuer(ctx context.Context, iss, sub string) error {
+	return nil
+}
+
+// AudienceVerifier verifies the audience.
+type AudienceVerifier interface {
+	VerifyAudience(ctx context.Context, aud []string) error
+}
+
+// UnsecureAnyAudience is an AudienceVerifier that accepts any audience.
+// This is not recommended.
+var UnsecureAnyAudience = unsecureAnyAudienceVerifier{}
+
+type unsecureAnyAudienceVerifier struct{}
+
+func (unsecureAnyAudienceVerifier) VerifyAudience(ctx context.Context, aud []string) error {
+	return nil
+}
+
+// Audience is a verifier that accepts only the tokens for the specified audience.
+type Audience string
+
+func (a Audience) VerifyAudience(ctx context.Context, aud []string) error {
+	if slices.Contains(aud, string(a)) {
+		return nil
+	}
+	return fmt.Errorf("cwt: invalid audience: %s", aud)
+}
+
+// Parser is a CWT parser.
+type Parser struct {
+	_NamedFieldsRequired struct{}
+
+	// KeyFinder finds the key for COSE_Sign1 and COSE_Mac0 messages.
+	// For COSE_Mac0 messages, the key is typically created by [cose.Algorithm.NewMACKey].
+	KeyFinder cose.KeyFinder
+
+	// ContentKeyFinder finds the content encryption key for COSE_Encrypt0 messages.
+	// If it is nil, the parser rejects encrypted CWTs.
+	ContentKeyFinder ContentKeyFinder
+
+	AlgorithmVerifier     AlgorithmVerifier
+	IssuerSubjectVerifier IssuerSubjectVerifier
+	AudienceVerifier      AudienceVerifier
+
+	// Clock provides the current time to validate the exp, nbf and iat claims.
+	// If it is nil, the system clock is used.
+	Clock goat.Clock
+
+	// Leeway is the allowed clock skew between the issuer and the parser.
+	// It is applied to the exp, nbf and iat claims.
+	Leeway time.Duration
+
+	// MaxAge is the maximum age of the token calculated from the iat claim.
+	// If it is zero, the age is not checked.
+	// Otherwise, the iat claim is required.
+	MaxAge time.Duration
+}
+
+// Parse parses and verifies the CWT.
+// The COSE message must be tagged, and it may be wrapped by the CWT tag.
+// Nested CWTs described in RFC 8392 Section 7 are also supported.
+func (p *Parser) Parse(ctx context.Context, data []byte) (*Token, error) {
+	// verify the parser options
+	_ = p._NamedFieldsRequired
+	if p.KeyFinder == nil || p.AlgorithmVerifier == nil || p.IssuerSubjectVerifier == nil || p.AudienceVerifier == nil {
+		return nil, errors.New("cwt: parser is not configured")
+	}
+	if p.Leeway < 0 || p.MaxAge < 0 {
+		return nil, errors.New("cwt: negative leeway or max age")
+	}
+
+	for range maxNestingDepth {
+		tag, content, ok := splitTag(data)
+		if ok && tag == TagNumberCWT {
+			tag, content, ok = splitTag(content)
+		}
+		if !ok {
+			return nil, errors.New("cwt: failed to parse: untagged COSE message")
+		}
+
+		protected, unprotected, payload, err := p.verify(ctx, tag, content)
+		if err != nil {
+			return nil, err
+		}
+
+		// if the payload is also tagged, it is a nested CWT.
+		if _, _, ok := splitTag(payload); ok {
+			data = payload
+			continue
+		}
+
+		// parse claims
+		c, err := p.parseClaims(ctx, payload)
+		if err != nil {
+			return nil, err
+		}
+		token := &Token{
+			Protected:   protected,
+			Unprotected: unprotected,
+			Claims:      c,
+		}
+		return token, nil
+	}
+	return nil, errors.New("cwt: failed to parse: too deeply nested")
+}
+
+// verify verifies the COSE message and returns its headers and payload.
+func (p *Parser) verify(ctx context.Context, tag cbor.TagNumber, data []byte) (protected, unprotected *cose.Header, payload []byte, err error) {
+	switch tag {
+	case cose.TagNumberCOSESign1:
+		msg, err := cose.ParseSign1(data)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("cwt: failed to parse: %w", err)
+		}
+		if err := p.verifyAlgorithm(ctx, msg.Protected, msg.Unprotected); err != nil {
+			return nil, nil, nil, err
+		}
+		key, err := p.KeyFinder.FindKey(ctx, msg.Protected, msg.Unprotected)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("cwt: failed to find key: %w", err)
+		}
+		if err := msg.Verify(key, nil); err != nil {
+			return nil, nil, nil, fmt.Errorf("cwt: failed to verify signature: %w", err)
+		}
+		return msg.Protected, msg.Unprotected, msg.Payload, nil
+
+	case cose.TagNumberCOSEMac0:
+		msg, err := cose.ParseMac0(data)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("cwt: failed to parse: %w", err)
+		}
+		if err := p.verifyAlgorithm(ctx, msg.Protected, msg.Unprotected); err != nil {
+			return nil, nil, nil, err
+		}
+		key, err := p.KeyFinder.FindKey(ctx, msg.Protected, msg.Unprotected)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("cwt: failed to find key: %w", err)
+		}
+		if err := msg.Verify(key, nil); err != nil {
+			return nil, nil, nil, fmt.Errorf("cwt: failed to verify tag: %w", err)
+		}
+		return msg.Protected, msg.Unprotected, msg.Payload, nil
+
+	case cose.TagNumberCOSEEncrypt0:
+		if p.ContentKeyFinder == nil {
+			return nil, nil, nil, errors.New("cwt: content key finder is not configured")
+		}
+		msg, err := cose.ParseEncrypt0(data)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("cwt: failed to parse: %w", err)
+		}
+		if err := p.verifyAlgorithm(ctx, msg.Protected, msg.Unprotected); err != nil {
+			return nil, nil, nil, err
+		}
+		cek, err := p.ContentKeyFinder.FindContentKey(ctx, msg.Protected, msg.Unprotected)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("cwt: failed to find key: %w", err)
+		}
+		plaintext, err := msg.Decrypt(cek, nil)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("cwt: failed to decrypt: %w", err)
+		}
+		return msg.Protected, msg.Unprotected, plaintext, nil
+	}
+	return nil, nil, nil, fmt.Errorf("cwt: unsupported tag number: %d", tag)
+}
+
+func (p *Parser) verifyAlgorithm(ctx context.Context, protected, unprotected *cose.Header) error {
+	alg := protected.Algorithm()
+	if alg == cose.AlgorithmReserved {
+		alg = unprotected.Algorithm()
+	}
+	if err := p.AlgorithmVerifier.VerifyAlgorithm(ctx, alg); err != nil {
+		return fmt.Errorf("cwt: failed to verify algorithm: %w", err)
+	}
+	return nil
+}
+
+// splitTag splits the head of a tagged CBOR data item into the tag number and the content.
+// ok is false if data is not tagged.
+func splitTag(data []byte) (tag cbor.TagNumber, content []byte, ok bool) {
+	if len(data) == 0 || data[0]>>5 != 6 { // major type 6: tagged data item
+		return 0, nil, false
+	}
+	info := data[0] & 0x1f
+	switch {
+	case info < 24:
+		return cbor.TagNumber(info), data[1:], true
+	case info == 24 && len(data) >= 2:
+		return cbor.TagNumber(data[1]), data[2:], true
+	case info == 25 && len(data) >= 3:
+		return cbor.TagNumber(binary.BigEndian.Uint16(data[1:])), data[3:], true
+	case info == 26 && len(data) >= 5:
+		return cbor.TagNumber(binary.BigEndian.Uint32(data[1:])), data[5:], true
+	case info == 27 && len(data) >= 9:
+		return cbor.TagNumber(binary.BigEndian.Uint64(data[1:])), data[9:], true
+	}
+	return 0, nil, false
+}
+
+func (p *Parser) parseClaims(ctx context.Context, data []byte) (*Claims, error) {
+	now := clockutils.Now(p.Clock)
+
+	var raw map[any]any
+	dec := cbor.NewDecoder(bytes.NewReader(data))
+	dec.UseAnyKey()
+	dec.UseInteger()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("cwt: failed to parse claims: %w", err)
+	}
+	c := &Claims{
+		Raw: raw,
+	}
+	d := cborutils.NewDecoder("cwt", raw)
+
+	c.Issuer, _ = d.GetString(claimLabelIssuer)
+	c.Subject, _ = d.GetString(claimLabelSubject)
+	if err := p.IssuerSubjectVerifier.VerifyIssuer(ctx, c.Issuer, c.Subject); err != nil {
+		return nil, fmt.Errorf("cwt: failed to verify issuer and subject: %w", err)
+	}
+
+	// In RFC 8392, the "aud" claim is defined as a string or an array of strings.
+	if aud, ok := d.Get(claimLabelAudience); ok {
+		switch aud := aud.(type) {
+		case []any:
+			for _, v := range aud {
+				s, ok := v.(string)
+				if !ok {
+					d.SaveError(fmt.Errorf("cwt: invalid type of aud claim: %T", v))
+				}
+				c.Audience = append(c.Audience, s)
+			}
+		case string:
+			c.Audience = []string{aud}
+		default:
+			d.SaveError(fmt.Errorf("cwt: invalid type of aud claim: %T", aud))
+		}
+	}
+	if err := p.AudienceVerifier.VerifyAudience(ctx, c.Audience); err != nil {
+		return nil, fmt.Errorf("cwt: failed to verify audience: %w", err)
+	}
+
+	if t, ok := d.GetTime(claimLabelExpirationTime); ok {
+		c.ExpirationTime = t
+		if !now.Before(t.Add(p.Leeway)) {
+			d.SaveError(fmt.Errorf("cwt: token is expired"))
+		}
+	}
+
+	if t, ok := d.GetTime(claimLabelNotBefore); ok {
+		c.NotBefore = t
+		if now.Add(p.Leeway).Before(t) {
+			d.SaveError(fmt.Errorf("cwt: token is not valid yet"))
+		}
+	}
+
+	if t, ok := d.GetTime(claimLabelIssuedAt); ok {
+		c.IssuedAt = t
+		if now.Add(p.Leeway).Before(t) {
+			d.SaveError(fmt.Errorf("cwt: token is issued in the future"))
+		}
+		if p.MaxAge > 0 && now.Sub(t) > p.MaxAge+p.Leeway {
+			d.SaveError(fmt.Errorf("cwt: token is too old"))
+		}
+	} else if p.MaxAge > 0 {
+		d.SaveError(fmt.Errorf("cwt: iat claim is required"))
+	}
+	c.CWTID, _ = d.GetBytes(claimLabelCWTID)
+
+	if err := d.Err(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,187 @@
+package httpdigest
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Handler is an [http.Handler] that verifies the digest fields of requests
+// and adds the Content-Digest field to responses.
+//
+// The request content is verified while the wrapped handler reads it, without buffering.
+// If the digest doesn't match, reading the request body fails with an error wrapping [ErrDigestMismatch]
+// when it reaches the end of the content, so the handler must check the error before using the content.
+// The content is not verified until it is read to the end.
+// If the handler stops reading early, e.g. it uses [encoding/json.Decoder],
+// it must close the request body and check the error of Close before using the content.
+//
+// The response content is hashed while the wrapped handler writes it,
+// and the digest is sent in the trailer section.
+// If the wrapped handler sets the Content-Digest field by itself, the field is sent as is.
+type Handler struct {
+	_NamedFieldsRequired struct{}
+
+	// Handler is the wrapped handler.
+	Handler http.Handler
+
+	// Algorithms are the algorithms for the response digests, ordered by preference.
+	// The Want-Content-Digest field of the request selects one of them.
+	// If it is empty, sha-256 is used.
+	// If any of them is not available, the handler responds with 500 Internal Server Error.
+	Algorithms []Algorithm
+
+	// RequireDigest rejects the requests that have content without the Content-Digest field
+	// with 400 Bad Request.
+	// If it is false, such requests are passed to the wrapped handler without verification.
+	RequireDigest bool
+}
+
+var _ http.Handler = (*Handler)(nil)
+
+// ServeHTTP implements [http.Handler].
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_ = h._NamedFieldsRequired
+	algs := h.Algorithms
+	if len(algs) == 0 {
+		algs = []Algorithm{AlgorithmSHA256}
+	}
+	for _, alg := range algs {
+		if !alg.Available() {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// verify the request content.
+	verified := false
+	for _, name := range []string{FieldContentDigest, FieldReprDigest} {
+		if field := strings.Join(r.Header.Values(name), ", "); field != "" {
+			digests, err := ParseDigests(field)
+			if err != nil || len(supportedDigests(digests)) == 0 {
+				h.badRequest(w, algs)
+				return
+			}
+			r.Body = newVerifyingReader(r.Body, func() string { return field })
+			verified = true
+		} else if _, ok := r.Trailer[name]; ok {
+			r.Body = newVerifyingReader(r.Body, func() string { return strings.Join(r.Trailer.Values(name), ", ") })
+			verified = true
+		}
+	}
+	if !verified && h.RequireDigest && r.ContentLength != 0 {
+		h.badRequest(w, algs)
+		return
+	}
+
+	// add the digest of the response content.
+	alg, ok := SelectAlgorithm(r.Header.Get(FieldWantContentDigest), algs)
+	if !ok || r.Method == http.MethodHead {
+		h.Handler.ServeHTTP(w, r)
+		return
+	}
+	dw := &digestWriter{
+		ResponseWriter: w,
+		h:              newHasher([]Algorithm{alg}),
+	}
+	if r.Header.Get(FieldWantReprDigest) != "" {
+		if alg, ok := SelectAlgorithm(r.Header.Get(FieldWantReprDigest), algs); ok {
+			dw.reprHash = newHasher([]Algorithm{alg})
+		}
+	}
+	h.Handler.ServeHTTP(dw, r)
+	dw.finish()
+}
+
+func (h *Handler) badRequest(w http.ResponseWriter, algs []Algorithm) {
+	// RFC 9530 Section 4:
+	// the server can advertise the acceptable algorithms by the Want-Content-Digest field.
+	prefs := make([]Preference, 0, len(algs))
+	for i, alg := range algs {
+		prefs = append(prefs, Preference{Algorithm: alg, Weight: max(10-i, 1)})
+	}
+	w.Header().Set(FieldWantContentDigest, FormatPreferences(prefs...))
+	http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+}
+
+// digestWriter computes the digest of the response content,
+// and sends it in the trailer section.
+type digestWriter struct {
+	http.ResponseWriter
+	h        *hasher
+	reprHash *hasher // nil if Repr-Digest is not requested
+
+	wroteHeader bool
+	skip        bool
+	skipRepr    bool
+}
+
+func (w *digestWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if code >= 100 && code <= 199 && code != http.StatusSwitchingProtocols {
+		// informational responses don't have content.
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.wroteHeader = true
+
+	header := w.Header()
+	switch {
+	case code == http.StatusNoContent, code == http.StatusNotModified, code == http.StatusSwitchingProtocols:
+		w.skip = true
+	case header.Get(FieldContentDigest) != "":
+		// the wrapped handler computes the digest by itself.
+		w.skip = true
+	default:
+		header.Add("Trailer", FieldContentDigest)
+	}
+
+	// the representation data and the content are the same, unless the response is a partial content.
+	if w.reprHash == nil || w.skip || code == http.StatusPartialContent || header.Get(FieldReprDigest) != "" {
+		w.skipRepr = true
+	} else {
+		header.Add("Trailer", FieldReprDigest)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *digestWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(p)
+	if !w.skip {
+		w.h.Write(p[:n])
+	}
+	if !w.skipRepr {
+		w.reprHash.Write(p[:n])
+	}
+	return n, err
+}
+
+func (w *digestWriter) finish() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.skip {
+		w.Header().Set(FieldContentDigest, FormatDigests(w.h.digests()...))
+	}
+	if !w.skipRepr {
+		w.Header().Set(FieldReprDigest, FormatDigests(w.reprHash.digests()...))
+	}
+}
+
+// Flush implements [http.Flusher].
+func (w *digestWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap returns the underlying [http.ResponseWriter] for [http.ResponseController].
+func (w *digestWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,227 @@
+package httpdigest
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// echoHandler echoes the request body, or responds 400 if the digest doesn't match.
+var echoHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if errors.Is(err, ErrDigestMismatch) {
+		http.Error(w, "digest mismatch", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+})
+
+func newTestClient(ts *httptest.Server, want ...Preference) *http.Client {
+	return &http.Client{
+		Transport: &Transport{
+			Transport:     ts.Client().Transport,
+			Want:          want,
+			RequireDigest: true,
+		},
+	}
+}
+
+func TestHandler(t *testing.T) {
+	ts := httptest.NewServer(&Handler{
+		Handler:       echoHandler,
+		Algorithms:    []Algorithm{AlgorithmSHA256, AlgorithmSHA512},
+		RequireDigest: true,
+	})
+	defer ts.Close()
+
+	t.Run("digest in header", func(t *testing.T) {
+		client := newTestClient(ts)
+		resp, err := client.Post(ts.URL, "application/json", bytes.NewReader([]byte(`{"hello": "world"}`)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected status: %d, %s", resp.StatusCode, body)
+		}
+		if string(body) != `{"hello": "world"}` {
+			t.Errorf("unexpected body: %s", body)
+		}
+		want := "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:"
+		if got := resp.Trailer.Get(FieldContentDigest); got != want {
+			t.Errorf("unexpected Content-Digest: got %s, want %s", got, want)
+		}
+	})
+
+	t.Run("digest in trailer", func(t *testing.T) {
+		client := newTestClient(ts, Preference{Algorithm: AlgorithmSHA512, Weight: 10})
+		// io.MultiReader hides the length, so the request can't be replayed.
+		body := io.MultiReader(strings.NewReader(`{"hello": `), strings.NewReader(`"world"}`))
+		resp, err := client.Post(ts.URL, "application/json", body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		got, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected status: %d, %s", resp.StatusCode, got)
+		}
+		want := "sha-512=:WZDPaVn/7XgHaAy8pmojAkGWoRx2UFChF41A2svX+TaPm+AbwAgBWnrIiYllu7BNNyealdVLvRwEmTHWXvJwew==:"
+		if got := resp.Trailer.Get(FieldContentDigest); got != want {
+			t.Errorf("unexpected Content-Digest: got %s, want %s", got, want)
+		}
+	})
+
+	t.Run("digest mismatch", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader(`{"hello": "world"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set(FieldContentDigest, "sha-256=:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=:")
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("unexpected status: %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("digest missing", func(t *testing.T) {
+		resp, err := ts.Client().Post(ts.URL, "application/json", strings.NewReader(`{"hello": "world"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("unexpected status: %d", resp.StatusCode)
+		}
+		if got := resp.Header.Get(FieldWantContentDigest); got != "sha-256=10, sha-512=9" {
+			t.Errorf("unexpected Want-Content-Digest: %s", got)
+		}
+	})
+}
+
+func TestTransport_Mismatch(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(FieldContentDigest, "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:")
+		io.WriteString(w, `{"hello": "tampered"}`)
+	}))
+	defer ts.Close()
+
+	client := newTestClient(ts)
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if _, err := io.ReadAll(resp.Body); !errors.Is(err, ErrDigestMismatch) {
+		t.Errorf("want ErrDigestMismatch, got %v", err)
+	}
+}
+
+func TestTransport_Missing(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"hello": "world"}`)
+	}))
+	defer ts.Close()
+
+	client := newTestClient(ts)
+	if _, err := client.Get(ts.URL); err == nil {
+		t.Error("want error, but not")
+	}
+}
+
+func TestHandler_ReprDigest(t *testing.T) {
+	h := &Handler{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, `{"hello": "world"}`)
+		}),
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(FieldWantReprDigest, "sha-256=1")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	resp := rec.Result()
+	if got := resp.Header.Values("Trailer"); len(got) != 2 {
+		t.Errorf("unexpected Trailer: %v", got)
+	}
+	want := "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:"
+	if got := resp.Trailer.Get(FieldReprDigest); got != want {
+		t.Errorf("unexpected Repr-Digest: got %s, want %s", got, want)
+	}
+	if got := resp.Trailer.Get(FieldContentDigest); got != want {
+		t.Errorf("unexpected Content-Digest: got %s, want %s", got, want)
+	}
+}
+
+func TestHandler_NotReadToEnd(t *testing.T) {
+	tests := []struct {
+		name   string
+		digest string
+		n      int64 // the number of bytes read by the handler, or -1 to read to the end
+		ok     bool
+	}{
+		{"read to end", "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:", -1, true},
+		{"read partially", "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:", 5, false},
+		{"mismatch", "sha-256=:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=:", -1, false},
+		{"mismatch read partially", "sha-256=:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=:", 5, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var closeErr error
+			h := &Handler{
+				Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					var body io.Reader = r.Body
+					if tt.n >= 0 {
+						body = io.LimitReader(r.Body, tt.n)
+					}
+					io.Copy(io.Discard, body)
+					closeErr = r.Body.Close()
+				}),
+			}
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"hello": "world"}`))
+			req.Header.Set(FieldContentDigest, tt.digest)
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if tt.ok && closeErr != nil {
+				t.Errorf("unexpected error: %v", closeErr)
+			}
+			if !tt.ok && closeErr == nil {
+				t.Error("want error, but not")
+			}
+		})
+	}
+}
+
+func TestHandler_UnavailableAlgorithm(t *testing.T) {
+	h := &Handler{
+		Handler:    echoHandler,
+		Algorithms: []Algorithm{"sha3-256"},
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(FieldWantContentDigest, "sha3-256=1")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("unexpected status: %d", rec.Code)
+	}
+}
@@ -0,0 +1,346 @@
+// Package httpdigest handles the Digest Fields defined in RFC 9530,
+// such as Content-Digest, Repr-Digest and Want-Content-Digest.
+package httpdigest
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/shogo82148/goat/internal/sfv"
+)
+
+// The field names defined in RFC 9530.
+const (
+	// FieldContentDigest is the Content-Digest field defined in RFC 9530 Section 2.
+	FieldContentDigest = "Content-Digest"
+
+	// FieldReprDigest is the Repr-Digest field defined in RFC 9530 Section 3.
+	FieldReprDigest = "Repr-Digest"
+
+	// FieldWantContentDigest is the Want-Content-Digest field defined in RFC 9530 Section 4.
+	FieldWantContentDigest = "Want-Content-Digest"
+
+	// FieldWantReprDigest is the Want-Repr-Digest field defined in RFC 9530 Section 4.
+	FieldWantReprDigest = "Want-Repr-Digest"
+)
+
+// ErrDigestMismatch means the digest of the content doesn't match the digest field.
+var ErrDigestMismatch = errors.New("httpdigest: digest mismatch")
+
+// Algorithm is a hash algorithm registered in the Hash Algorithms for HTTP Digest Fields registry
+// defined in RFC 9530 Section 7.2.
+type Algorithm string
+
+const (
+	// AlgorithmSHA256 is SHA-256.
+	AlgorithmSHA256 Algorithm = "sha-256"
+
+	// AlgorithmSHA512 is SHA-512.
+	AlgorithmSHA512 Algorithm = "sha-512"
+)
+
+// supportedAlgorithms are the algorithms with the "Active" status, ordered by preference.
+var supportedAlgorithms = []Algorithm{AlgorithmSHA512, AlgorithmSHA256}
+
+// Available reports whether the algorithm is supported.
+// The algorithms with the "Deprecated" status such as "md5" are not supported.
+func (alg Algorithm) Available() bool {
+	return slices.Contains(supportedAlgorithms, alg)
+}
+
+// New returns a new hash.Hash for the algorithm.
+// It panics if the algorithm is not available.
+func (alg Algorithm) New() hash.Hash {
+	switch alg {
+	case AlgorithmSHA256:
+		return sha256.New()
+	case AlgorithmSHA512:
+		return sha512.New()
+	}
+	panic("httpdigest: requested hash algorithm " + string(alg) + " is unavailable")
+}
+
+// String implements [fmt.Stringer].
+func (alg Algorithm) String() string {
+	return string(alg)
+}
+
+// Digest is a member of the Content-Digest and Repr-Digest fields.
+type Digest struct {
+	Algorithm Algorithm
+	Value     []byte
+}
+
+// ParseDigests parses the value of the Content-Digest or Repr-Digest field.
+// The digests of unknown algorithms are also returned.
+func ParseDigests(s string) ([]Digest, error) {
+	members, err := sfv.ParseDictionary(s)
+	if err != nil {
+		return nil, err
+	}
+	digests := make([]Digest, 0, len(members))
+	for _, m := range members {
+		item, ok := m.Value.(sfv.Item)
+		if !ok {
+			return nil, fmt.Errorf("httpdigest: digest of %q must be a byte sequence", m.Key)
+		}
+		value, ok := item.Value.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("httpdigest: digest of %q must be a byte sequence", m.Key)
+		}
+		digests = append(digests, Digest{
+			Algorithm: Algorithm(m.Key),
+			Value:     value,
+		})
+	}
+	return digests, nil
+}
+
+// FormatDigests returns the value of the Content-Digest or Repr-Digest field.
+func FormatDigests(digests ...Digest) string {
+	var buf strings.Builder
+	for i, d := range digests {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(string(d.Algorithm))
+		buf.WriteByte('=')
+		_ = sfv.SerializeBareItem(&buf, d.Value) // a byte sequence is always serializable.
+	}
+	return buf.String()
+}
+
+// Preference is a member of the Want-Content-Digest and Want-Repr-Digest fields.
+type Preference struct {
+	Algorithm Algorithm
+
+	// Weight is the preference between 0 and 10.
+	// 0 means "not acceptable", 1 is the least preferred, and 10 is the most preferred.
+	Weight int
+}
+
+// ParsePreferences parses the value of the Want-Content-Digest or Want-Repr-Digest field.
+func ParsePreferences(s string) ([]Preference, error) {
+	members, err := sfv.ParseDictionary(s)
+	if err != nil {
+		return nil, err
+	}
+	prefs := make([]Preference, 0, len(members))
+	for _, m := range members {
+		item, ok := m.Value.(sfv.Item)
+		if !ok {
+			return nil, fmt.Errorf("httpdigest: preference of %q must be an integer", m.Key)
+		}
+		weight, ok := item.Value.(int64)
+		if !ok || weight < 0 || weight > 10 {
+			return nil, fmt.Errorf("httpdigest: preference of %q must be an integer between 0 and 10", m.Key)
+		}
+		prefs = append(prefs, Preference{
+			Algorithm: Algorithm(m.Key),
+			Weight:    int(weight),
+		})
+	}
+	return prefs, nil
+}
+
+// FormatPreferences returns the value of the Want-Content-Digest or Want-Repr-Digest field.
+func FormatPreferences(prefs ...Preference) string {
+	var buf strings.Builder
+	for i, p := range prefs {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(string(p.Algorithm))
+		buf.WriteByte('=')
+		_ = sfv.SerializeBareItem(&buf, int64(p.Weight))
+	}
+	return buf.String()
+}
+
+// SelectAlgorithm selects the most preferred algorithm in want among algs.
+// want is the value of the Want-Content-Digest or Want-Repr-Digest field.
+// If want is empty or invalid, it returns algs[0].
+// It returns false if no algorithm in algs is acceptable.
+func SelectAlgorithm(want string, algs []Algorithm) (Algorithm, bool) {
+	if len(algs) == 0 {
+		return "", false
+	}
+	if want == "" {
+		return algs[0], true
+	}
+	prefs, err := ParsePreferences(want)
+	if err != nil {
+		return algs[0], true
+	}
+	var selected Algorithm
+	weight := 0
+	for _, p := range prefs {
+		if p.Weight > weight && slices.Contains(algs, p.Algorithm) {
+			selected, weight = p.Algorithm, p.Weight
+		}
+	}
+	return selected, weight > 0
+}
+
+// Compute returns the digest of the content read from r.
+func Compute(alg Algorithm, r io.Reader) (Digest, error) {
+	if !alg.Available() {
+		return Digest{}, fmt.Errorf("httpdigest: unsupported algorithm: %q", alg)
+	}
+	h := alg.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return Digest{}, err
+	}
+	return Digest{Algorithm: alg, Value: h.Sum(nil)}, nil
+}
+
+// Verify reads the content from r and verifies it with field,
+// which is the value of the Content-Digest or Repr-Digest field.
+// All the digests of the supported algorithms in field must match.
+func Verify(field string, r io.Reader) error {
+	digests, err := ParseDigests(field)
+	if err != nil {
+		return err
+	}
+	h := newHasher(supportedDigests(digests))
+	if len(h.hashes) == 0 {
+		return errors.New("httpdigest: no supported algorithm")
+	}
+	if _, err := io.Copy(h, r); err != nil {
+		return err
+	}
+	return h.verify(digests)
+}
+
+// supportedDigests returns the supported algorithms in digests.
+func supportedDigests(digests []Digest) []Algorithm {
+	var algs []Algorithm
+	for _, d := range digests {
+		if d.Algorithm.Available() && !slices.Contains(algs, d.Algorithm) {
+			algs = append(algs, d.Algorithm)
+		}
+	}
+	return algs
+}
+
+// hasher computes the digests of several algorithms at once.
+type hasher struct {
+	algs   []Algorithm
+	hashes []hash.Hash
+}
+
+func newHasher(algs []Algorithm) *hasher {
+	hashes := make([]hash.Hash, 0, len(algs))
+	for _, alg := range algs {
+		hashes = append(hashes, alg.New())
+	}
+	return &hasher{algs: algs, hashes: hashes}
+}
+
+func (h *hasher) Write(p []byte) (int, error) {
+	for _, hh := range h.hashes {
+		hh.Write(p)
+	}
+	return len(p), nil
+}
+
+func (h *hasher) digests() []Digest {
+	digests := make([]Digest, 0, len(h.hashes))
+	for i, hh := range h.hashes {
+		digests = append(digests, Digest{Algorithm: h.algs[i], Value: hh.Sum(nil)})
+	}
+	return digests
+}
+
+// verify checks all the digests of the computed algorithms.
+func (h *hasher) verify(want []Digest) error {
+	verified := false
+	for i, hh := range h.hashes {
+		got := hh.Sum(nil)
+		for _, d := range want {
+			if d.Algorithm != h.algs[i] {
+				continue
+			}
+			if subtle.ConstantTimeCompare(got, d.Value) != 1 {
+				return fmt.Errorf("%w: %s", ErrDigestMismatch, d.Algorithm)
+			}
+			verified = true
+		}
+	}
+	if !verified {
+		return errors.New("httpdigest: no supported algorithm")
+	}
+	return nil
+}
+
+// verifyingReader verifies the digest when it reaches EOF.
+type verifyingReader struct {
+	r io.ReadCloser
+	h *hasher
+
+	// field returns the value of the digest field.
+	// It is called at EOF, so the field may be in the trailers.
+	field func() string
+	err   error
+	eof   bool // true if the content is read to the end and verified
+}
+
+func newVerifyingReader(r io.ReadCloser, field func() string) *verifyingReader {
+	return &verifyingReader{
+		r: r,
+		// the algorithm may be unknown until the trailers are received.
+		h:     newHasher(supportedAlgorithms),
+		field: field,
+	}
+}
+
+func (r *verifyingReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, err := r.r.Read(p)
+	r.h.Write(p[:n])
+	if err == io.EOF {
+		r.eof = true
+		if verr := r.verify(); verr != nil {
+			err = verr
+		}
+	}
+	if err != nil {
+		r.err = err
+	}
+	return n, err
+}
+
+func (r *verifyingReader) verify() error {
+	field := r.field()
+	if field == "" {
+		return errors.New("httpdigest: digest field is missing")
+	}
+	digests, err := ParseDigests(field)
+	if err != nil {
+		return err
+	}
+	return r.h.verify(digests)
+}
+
+// Close closes the underlying reader.
+// It returns an error if the content is not verified,
+// i.e. it is not read to the end or the digest doesn't match.
+func (r *verifyingReader) Close() error {
+	err := r.r.Close()
+	if !r.eof {
+		return errors.New("httpdigest: the content is closed before it is verified")
+	}
+	if r.err != io.EOF {
+		return r.err
+	}
+	return err
+}
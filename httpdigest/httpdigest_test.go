@@ -0,0 +1,100 @@
+package httpdigest
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestCompute(t *testing.T) {
+	// RFC 9530 Appendix B. Examples of Unsolicited Digest
+	tests := []struct {
+		alg  Algorithm
+		want string
+	}{
+		{AlgorithmSHA256, "X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE="},
+		{AlgorithmSHA512, "WZDPaVn/7XgHaAy8pmojAkGWoRx2UFChF41A2svX+TaPm+AbwAgBWnrIiYllu7BNNyealdVLvRwEmTHWXvJwew=="},
+	}
+	for _, tt := range tests {
+		d, err := Compute(tt.alg, strings.NewReader(`{"hello": "world"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := base64.StdEncoding.EncodeToString(d.Value); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.alg, got, tt.want)
+		}
+	}
+
+	if _, err := Compute("md5", strings.NewReader("")); err == nil {
+		t.Error("want error, but not")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	const content = `{"hello": "world"}`
+	tests := []struct {
+		field string
+		ok    bool
+	}{
+		{"sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:", true},
+		{"sha-512=:WZDPaVn/7XgHaAy8pmojAkGWoRx2UFChF41A2svX+TaPm+AbwAgBWnrIiYllu7BNNyealdVLvRwEmTHWXvJwew==:, md5=:Sd/dVLAcvNLSq16eXua5uQ==:", true},
+		{"sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:, sha-512=:AAAA:", false},
+		{"md5=:Sd/dVLAcvNLSq16eXua5uQ==:", false},
+		{"sha-256=X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=", false},
+	}
+	for _, tt := range tests {
+		err := Verify(tt.field, strings.NewReader(content))
+		if tt.ok && err != nil {
+			t.Errorf("%q: %v", tt.field, err)
+		}
+		if !tt.ok && err == nil {
+			t.Errorf("%q: want error, but not", tt.field)
+		}
+	}
+}
+
+func TestFormatDigests(t *testing.T) {
+	field := "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:, md5=:Sd/dVLAcvNLSq16eXua5uQ==:"
+	digests, err := ParseDigests(field)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(digests) != 2 || digests[0].Algorithm != AlgorithmSHA256 || digests[1].Algorithm != "md5" {
+		t.Fatalf("unexpected digests: %v", digests)
+	}
+	if got := FormatDigests(digests...); got != field {
+		t.Errorf("got %s, want %s", got, field)
+	}
+}
+
+func TestSelectAlgorithm(t *testing.T) {
+	algs := []Algorithm{AlgorithmSHA256, AlgorithmSHA512}
+	tests := []struct {
+		want string
+		alg  Algorithm
+		ok   bool
+	}{
+		{"", AlgorithmSHA256, true},
+		{"sha-512=3, sha-256=10, unixsum=0", AlgorithmSHA256, true},
+		{"sha-512=10, sha-256=1", AlgorithmSHA512, true},
+		{"sha-256=0, md5=10", "", false},
+		{"invalid!", AlgorithmSHA256, true},
+	}
+	for _, tt := range tests {
+		alg, ok := SelectAlgorithm(tt.want, algs)
+		if alg != tt.alg || ok != tt.ok {
+			t.Errorf("%q: got (%q, %t), want (%q, %t)", tt.want, alg, ok, tt.alg, tt.ok)
+		}
+	}
+
+	prefs, err := ParsePreferences("sha-512=3, sha-256=10, unixsum=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := FormatPreferences(prefs...); got != "sha-512=3, sha-256=10, unixsum=0" {
+		t.Errorf("unexpected preferences: %s", got)
+	}
+	if _, err := ParsePreferences("sha-256=11"); err == nil {
+		t.Error("want error, but not")
+	}
+}
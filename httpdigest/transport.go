@@ -0,0 +1,193 @@
+package httpdigest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Transport is an [http.RoundTripper] that adds the Content-Digest field to requests
+// and verifies the digest fields of responses.
+//
+// If the request body can be read again by [http.Request.GetBody], e.g. the request is created by
+// [http.NewRequest] with [bytes.Reader], the digest is sent in the header section,
+// so it can be covered by signatures such as HTTP Message Signatures and detached JWS.
+// Otherwise, the content is hashed while it is sent, and the digest is sent in the trailer section.
+//
+// The response content is verified while it is read, without buffering.
+// If the digest doesn't match, reading the response body fails with an error wrapping [ErrDigestMismatch]
+// when it reaches the end of the content.
+// If the caller stops reading early, closing the response body returns an error,
+// because the content read so far is not verified.
+// The response decompressed by the underlying transport (see [http.Response.Uncompressed]) can't be verified.
+type Transport struct {
+	_NamedFieldsRequired struct{}
+
+	// Transport is the underlying RoundTripper.
+	// If it is nil, [http.DefaultTransport] is used.
+	Transport http.RoundTripper
+
+	// Algorithm is the algorithm for the request digests.
+	// If it is empty, sha-256 is used.
+	Algorithm Algorithm
+
+	// Want is sent as the Want-Content-Digest field if it is not empty.
+	Want []Preference
+
+	// RequireDigest makes the responses that have content without the Content-Digest field an error.
+	RequireDigest bool
+}
+
+var _ http.RoundTripper = (*Transport)(nil)
+
+// RoundTrip implements [http.RoundTripper].
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	_ = t._NamedFieldsRequired
+	alg := t.Algorithm
+	if alg == "" {
+		alg = AlgorithmSHA256
+	}
+	if !alg.Available() {
+		closeBody(req)
+		return nil, fmt.Errorf("httpdigest: unsupported algorithm: %q", alg)
+	}
+
+	// RoundTrip must not modify the request.
+	req2 := req.Clone(req.Context())
+	if len(t.Want) > 0 && req2.Header.Get(FieldWantContentDigest) == "" {
+		req2.Header.Set(FieldWantContentDigest, FormatPreferences(t.Want...))
+	}
+	if hasContent(req) && req.Header.Get(FieldContentDigest) == "" {
+		if err := addContentDigest(req2, alg); err != nil {
+			closeBody(req)
+			return nil, err
+		}
+	}
+
+	resp, err := t.transport().RoundTrip(req2)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.verifyResponse(req, resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (t *Transport) transport() http.RoundTripper {
+	if t.Transport == nil {
+		return http.DefaultTransport
+	}
+	return t.Transport
+}
+
+func hasContent(req *http.Request) bool {
+	return req.Body != nil && req.Body != http.NoBody
+}
+
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+func addContentDigest(req *http.Request, alg Algorithm) error {
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return err
+		}
+		d, err := Compute(alg, body)
+		body.Close()
+		if err != nil {
+			return err
+		}
+		req.Header.Set(FieldContentDigest, FormatDigests(d))
+		return nil
+	}
+
+	// send the digest in the trailer section.
+	trailer := req.Trailer.Clone()
+	if trailer == nil {
+		trailer = make(http.Header)
+	}
+	trailer[FieldContentDigest] = nil
+	req.Trailer = trailer
+	if req.ContentLength > 0 {
+		// the trailer section is available only in the chunked transfer coding.
+		req.ContentLength = -1
+	}
+	req.Body = &trailerReader{
+		r:       req.Body,
+		h:       newHasher([]Algorithm{alg}),
+		trailer: trailer,
+	}
+	return nil
+}
+
+// trailerReader computes the digest of the request content,
+// and sets it to the trailer when it reaches EOF.
+type trailerReader struct {
+	r       io.ReadCloser
+	h       *hasher
+	trailer http.Header
+}
+
+func (r *trailerReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.h.Write(p[:n])
+	if err == io.EOF {
+		r.trailer.Set(FieldContentDigest, FormatDigests(r.h.digests()...))
+	}
+	return n, err
+}
+
+func (r *trailerReader) Close() error {
+	return r.r.Close()
+}
+
+func (t *Transport) verifyResponse(req *http.Request, resp *http.Response) error {
+	if req.Method == http.MethodHead || resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return nil
+	}
+	if resp.Uncompressed {
+		// the underlying transport has decoded the content, so the digest can't be verified.
+		if t.RequireDigest {
+			return errors.New("httpdigest: the response content is decompressed by the transport")
+		}
+		return nil
+	}
+
+	verified := false
+	for _, name := range []string{FieldContentDigest, FieldReprDigest} {
+		if name == FieldReprDigest && resp.StatusCode == http.StatusPartialContent {
+			// the representation data is not the same as the content.
+			continue
+		}
+		field := strings.Join(resp.Header.Values(name), ", ")
+		_, inTrailer := resp.Trailer[name]
+		if field == "" && !inTrailer {
+			continue
+		}
+		if field != "" {
+			digests, err := ParseDigests(field)
+			if err != nil {
+				return err
+			}
+			if len(supportedDigests(digests)) == 0 {
+				return errors.New("httpdigest: no supported algorithm")
+			}
+			resp.Body = newVerifyingReader(resp.Body, func() string { return field })
+		} else {
+			resp.Body = newVerifyingReader(resp.Body, func() string { return strings.Join(resp.Trailer.Values(name), ", ") })
+		}
+		verified = true
+	}
+	if !verified && t.RequireDigest && resp.ContentLength != 0 {
+		return errors.New("httpdigest: Content-Digest is missing in the response")
+	}
+	return nil
+}
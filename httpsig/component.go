@@ -0,0 +1,330 @@
+package httpsig
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/shogo82148/goat/internal/sfv"
+)
+
+// Component is a component identifier defined in RFC 9421 Section 2.
+// It is either an HTTP field name or a derived component name starting with "@",
+// with its parameters.
+type Component struct {
+	name   string
+	params []sfv.Param
+}
+
+// The derived components defined in RFC 9421 Section 2.2.
+var (
+	// ComponentMethod is "@method" defined in RFC 9421 Section 2.2.1.
+	ComponentMethod = Component{name: "@method"}
+
+	// ComponentTargetURI is "@target-uri" defined in RFC 9421 Section 2.2.2.
+	ComponentTargetURI = Component{name: "@target-uri"}
+
+	// ComponentAuthority is "@authority" defined in RFC 9421 Section 2.2.3.
+	ComponentAuthority = Component{name: "@authority"}
+
+	// ComponentScheme is "@scheme" defined in RFC 9421 Section 2.2.4.
+	ComponentScheme = Component{name: "@scheme"}
+
+	// ComponentRequestTarget is "@request-target" defined in RFC 9421 Section 2.2.5.
+	ComponentRequestTarget = Component{name: "@request-target"}
+
+	// ComponentPath is "@path" defined in RFC 9421 Section 2.2.6.
+	ComponentPath = Component{name: "@path"}
+
+	// ComponentQuery is "@query" defined in RFC 9421 Section 2.2.7.
+	ComponentQuery = Component{name: "@query"}
+
+	// ComponentStatus is "@status" defined in RFC 9421 Section 2.2.9.
+	ComponentStatus = Component{name: "@status"}
+)
+
+// Field returns the component identifier of the HTTP field defined in RFC 9421 Section 2.1.
+// name is converted to lower case.
+func Field(name string) Component {
+	return Component{name: strings.ToLower(name)}
+}
+
+// QueryParam returns the component identifier of the query parameter
+// "@query-param" defined in RFC 9421 Section 2.2.8.
+func QueryParam(name string) Component {
+	return Component{
+		name:   "@query-param",
+		params: []sfv.Param{{Key: "name", Value: name}},
+	}
+}
+
+// DictionaryMember returns the component identifier of the member key
+// of the Dictionary Structured Field name defined in RFC 9421 Section 2.1.2.
+func DictionaryMember(name, key string) Component {
+	return Component{
+		name:   strings.ToLower(name),
+		params: []sfv.Param{{Key: "key", Value: key}},
+	}
+}
+
+// ParseComponent parses the serialized component identifier such as `"@query-param";name="Pet"`.
+func ParseComponent(s string) (Component, error) {
+	item, err := sfv.ParseItem(s)
+	if err != nil {
+		return Component{}, err
+	}
+	return componentFromItem(item)
+}
+
+func componentFromItem(item sfv.Item) (Component, error) {
+	name, ok := item.Value.(string)
+	if !ok {
+		return Component{}, fmt.Errorf("httpsig: component identifier must be a string, got %T", item.Value)
+	}
+	if name != strings.ToLower(name) {
+		return Component{}, fmt.Errorf("httpsig: component identifier must be lower case: %q", name)
+	}
+	return Component{name: name, params: item.Params}, nil
+}
+
+// Name returns the name of the component.
+func (c Component) Name() string {
+	return c.name
+}
+
+// Request returns the component identifier with the "req" parameter defined in RFC 9421 Section 2.4,
+// which refers to the component of the request in a signed response.
+func (c Component) Request() Component {
+	if c.hasParam("req") {
+		return c
+	}
+	params := append(c.params[:len(c.params):len(c.params)], sfv.Param{Key: "req", Value: true})
+	return Component{name: c.name, params: params}
+}
+
+// BinaryWrapped returns the component identifier with the "bs" parameter defined in RFC 9421 Section 2.1.3.
+func (c Component) BinaryWrapped() Component {
+	if c.hasParam("bs") {
+		return c
+	}
+	params := append(c.params[:len(c.params):len(c.params)], sfv.Param{Key: "bs", Value: true})
+	return Component{name: c.name, params: params}
+}
+
+func (c Component) hasParam(key string) bool {
+	v, ok := sfv.LookupParam(c.params, key)
+	if !ok {
+		return false
+	}
+	b, isBool := v.(bool)
+	return !isBool || b
+}
+
+func (c Component) item() sfv.Item {
+	return sfv.Item{Value: c.name, Params: c.params}
+}
+
+// String returns the serialized component identifier.
+func (c Component) String() string {
+	var buf strings.Builder
+	if err := sfv.SerializeItem(&buf, c.item()); err != nil {
+		return fmt.Sprintf("%q", c.name)
+	}
+	return buf.String()
+}
+
+// message is the HTTP message to be signed or verified.
+type message struct {
+	req  *http.Request
+	resp *http.Response // nil if the message is a request
+}
+
+// values returns the component values of c in msg.
+// Most components have a single value, but "@query-param" may have several values.
+func (msg *message) values(c Component) ([]string, error) {
+	for _, p := range c.params {
+		switch p.Key {
+		case "req", "bs", "name", "key":
+		default:
+			return nil, fmt.Errorf("httpsig: unsupported parameter %q of %s", p.Key, c)
+		}
+	}
+
+	req := msg.req
+	isRequest := msg.resp == nil
+	if c.hasParam("req") {
+		if isRequest {
+			return nil, fmt.Errorf("httpsig: req parameter is used in the request: %s", c)
+		}
+		isRequest = true
+	}
+	if req == nil && isRequest {
+		return nil, fmt.Errorf("httpsig: the request is required for %s", c)
+	}
+
+	if strings.HasPrefix(c.name, "@") {
+		if c.hasParam("bs") || c.hasParam("key") {
+			return nil, fmt.Errorf("httpsig: invalid parameter for the derived component: %s", c)
+		}
+		if c.name == "@status" {
+			if isRequest {
+				return nil, errors.New("httpsig: @status is not available in the request")
+			}
+			return []string{strconv.Itoa(msg.resp.StatusCode)}, nil
+		}
+		if !isRequest {
+			return nil, fmt.Errorf("httpsig: %s is not available in the response", c)
+		}
+		return derivedValues(c, req)
+	}
+
+	var header http.Header
+	if isRequest {
+		header = req.Header
+	} else {
+		header = msg.resp.Header
+	}
+	return fieldValues(c, header)
+}
+
+func derivedValues(c Component, req *http.Request) ([]string, error) {
+	u := targetURI(req)
+	switch c.name {
+	case "@method":
+		// RFC 9421 Section 2.2.1:
+		// > The method name is case sensitive, and the value MUST NOT be transformed.
+		return []string{req.Method}, nil
+	case "@target-uri":
+		return []string{u.String()}, nil
+	case "@authority":
+		return []string{authority(u)}, nil
+	case "@scheme":
+		return []string{strings.ToLower(u.Scheme)}, nil
+	case "@request-target":
+		if req.Method == http.MethodConnect {
+			return []string{authority(u)}, nil
+		}
+		return []string{u.RequestURI()}, nil
+	case "@path":
+		path := u.EscapedPath()
+		if path == "" {
+			path = "/"
+		}
+		return []string{path}, nil
+	case "@query":
+		return []string{"?" + u.RawQuery}, nil
+	case "@query-param":
+		v, ok := sfv.LookupParam(c.params, "name")
+		name, isString := v.(string)
+		if !ok || !isString {
+			return nil, errors.New("httpsig: @query-param requires the name parameter")
+		}
+		query, err := url.ParseQuery(u.RawQuery)
+		if err != nil {
+			return nil, fmt.Errorf("httpsig: failed to parse the query: %w", err)
+		}
+		values, ok := query[name]
+		if !ok {
+			return nil, fmt.Errorf("httpsig: query parameter %q is missing", name)
+		}
+		ret := make([]string, 0, len(values))
+		for _, v := range values {
+			ret = append(ret, percentEncode(v))
+		}
+		return ret, nil
+	}
+	return nil, fmt.Errorf("httpsig: unknown derived component: %s", c)
+}
+
+// percentEncode encodes s as described in RFC 9421 Section 2.2.8.
+func percentEncode(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// targetURI returns the absolute target URI of req.
+func targetURI(req *http.Request) *url.URL {
+	u := *req.URL
+	if u.Host == "" {
+		// the server-side request has only the request target.
+		u.Host = req.Host
+	}
+	if u.Scheme == "" {
+		u.Scheme = "http"
+		if req.TLS != nil {
+			u.Scheme = "https"
+		}
+	}
+	return &u
+}
+
+// authority returns the normalized authority of u defined in RFC 9421 Section 2.2.3.
+func authority(u *url.URL) string {
+	host := strings.ToLower(u.Host)
+	h, port, err := net.SplitHostPort(host)
+	if err != nil {
+		return host
+	}
+	switch {
+	case port == "80" && strings.EqualFold(u.Scheme, "http"),
+		port == "443" && strings.EqualFold(u.Scheme, "https"):
+		if strings.Contains(h, ":") {
+			return "[" + h + "]"
+		}
+		return h
+	}
+	return host
+}
+
+// fieldValues returns the value of the HTTP field defined in RFC 9421 Section 2.1.
+func fieldValues(c Component, header http.Header) ([]string, error) {
+	lines, ok := header[http.CanonicalHeaderKey(c.name)]
+	if !ok {
+		return nil, fmt.Errorf("httpsig: %s is missing", c)
+	}
+	trimmed := make([]string, 0, len(lines))
+	for _, line := range lines {
+		trimmed = append(trimmed, strings.Trim(line, " \t"))
+	}
+	lines = trimmed
+
+	if c.hasParam("bs") {
+		// RFC 9421 Section 2.1.3. Binary-Wrapped HTTP Fields
+		var buf strings.Builder
+		for i, line := range lines {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			if err := sfv.SerializeBareItem(&buf, []byte(line)); err != nil {
+				return nil, err
+			}
+		}
+		return []string{buf.String()}, nil
+	}
+
+	value := strings.Join(lines, ", ")
+	if v, ok := sfv.LookupParam(c.params, "key"); ok {
+		// RFC 9421 Section 2.1.2. Dictionary Structured Field Members
+		key, isString := v.(string)
+		if !isString {
+			return nil, fmt.Errorf("httpsig: invalid key parameter: %s", c)
+		}
+		dict, err := sfv.ParseDictionary(value)
+		if err != nil {
+			return nil, err
+		}
+		member, ok := sfv.LookupMember(dict, key)
+		if !ok {
+			return nil, fmt.Errorf("httpsig: member %q of %s is missing", key, c.name)
+		}
+		var buf strings.Builder
+		if err := sfv.SerializeMember(&buf, member); err != nil {
+			return nil, err
+		}
+		return []string{buf.String()}, nil
+	}
+	return []string{value}, nil
+}
@@ -0,0 +1,316 @@
+// Package httpsig handles HTTP Message Signatures defined in RFC 9421.
+package httpsig
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shogo82148/goat"
+	"github.com/shogo82148/goat/internal/clockutils"
+	"github.com/shogo82148/goat/internal/sfv"
+	"github.com/shogo82148/goat/jwa"
+	"github.com/shogo82148/goat/sig"
+)
+
+// Algorithm is an HTTP signature algorithm registered in RFC 9421 Section 6.2.2.
+type Algorithm string
+
+const (
+	// AlgorithmRSAPSSSHA512 is RSASSA-PSS using SHA-512 defined in RFC 9421 Section 3.3.1.
+	AlgorithmRSAPSSSHA512 Algorithm = "rsa-pss-sha512"
+
+	// AlgorithmRSAv15SHA256 is RSASSA-PKCS1-v1_5 using SHA-256 defined in RFC 9421 Section 3.3.2.
+	AlgorithmRSAv15SHA256 Algorithm = "rsa-v1_5-sha256"
+
+	// AlgorithmHMACSHA256 is HMAC using SHA-256 defined in RFC 9421 Section 3.3.3.
+	AlgorithmHMACSHA256 Algorithm = "hmac-sha256"
+
+	// AlgorithmECDSAP256SHA256 is ECDSA using curve P-256 DSS and SHA-256 defined in RFC 9421 Section 3.3.4.
+	AlgorithmECDSAP256SHA256 Algorithm = "ecdsa-p256-sha256"
+
+	// AlgorithmECDSAP384SHA384 is ECDSA using curve P-384 DSS and SHA-384 defined in RFC 9421 Section 3.3.5.
+	AlgorithmECDSAP384SHA384 Algorithm = "ecdsa-p384-sha384"
+
+	// AlgorithmEd25519 is EdDSA using curve edwards25519 defined in RFC 9421 Section 3.3.6.
+	AlgorithmEd25519 Algorithm = "ed25519"
+)
+
+// SignatureAlgorithm returns the JWS algorithm that has the same signature format as alg.
+// It returns [jwa.SignatureAlgorithmUnknown] if alg is unknown.
+func (alg Algorithm) SignatureAlgorithm() jwa.SignatureAlgorithm {
+	switch alg {
+	case AlgorithmRSAPSSSHA512:
+		return jwa.SignatureAlgorithmPS512
+	case AlgorithmRSAv15SHA256:
+		return jwa.SignatureAlgorithmRS256
+	case AlgorithmHMACSHA256:
+		return jwa.SignatureAlgorithmHS256
+	case AlgorithmECDSAP256SHA256:
+		return jwa.SignatureAlgorithmES256
+	case AlgorithmECDSAP384SHA384:
+		return jwa.SignatureAlgorithmES384
+	case AlgorithmEd25519:
+		return jwa.SignatureAlgorithmEd25519
+	}
+	return jwa.SignatureAlgorithmUnknown
+}
+
+// String implements [fmt.Stringer].
+func (alg Algorithm) String() string {
+	return string(alg)
+}
+
+// SignatureParams is the signature parameters defined in RFC 9421 Section 2.3.
+type SignatureParams struct {
+	// Components are the covered components.
+	Components []Component
+
+	// Created is the "created" parameter.
+	Created time.Time
+
+	// Expires is the "expires" parameter.
+	Expires time.Time
+
+	// Nonce is the "nonce" parameter.
+	Nonce string
+
+	// Algorithm is the "alg" parameter.
+	Algorithm Algorithm
+
+	// KeyID is the "keyid" parameter.
+	KeyID string
+
+	// Tag is the "tag" parameter.
+	Tag string
+
+	// list is the parsed inner list.
+	// It is used for reconstructing "@signature-params" as is received.
+	list *sfv.InnerList
+}
+
+func (p *SignatureParams) innerList() sfv.InnerList {
+	if p.list != nil {
+		return *p.list
+	}
+
+	items := make([]sfv.Item, 0, len(p.Components))
+	for _, c := range p.Components {
+		items = append(items, c.item())
+	}
+	var params []sfv.Param
+	if !p.Created.IsZero() {
+		params = append(params, sfv.Param{Key: "created", Value: p.Created.Unix()})
+	}
+	if !p.Expires.IsZero() {
+		params = append(params, sfv.Param{Key: "expires", Value: p.Expires.Unix()})
+	}
+	if p.Nonce != "" {
+		params = append(params, sfv.Param{Key: "nonce", Value: p.Nonce})
+	}
+	if p.Algorithm != "" {
+		params = append(params, sfv.Param{Key: "alg", Value: string(p.Algorithm)})
+	}
+	if p.KeyID != "" {
+		params = append(params, sfv.Param{Key: "keyid", Value: p.KeyID})
+	}
+	if p.Tag != "" {
+		params = append(params, sfv.Param{Key: "tag", Value: p.Tag})
+	}
+	return sfv.InnerList{Items: items, Params: params}
+}
+
+// String returns the serialized signature parameters,
+// which is the value of the Signature-Input field.
+func (p *SignatureParams) String() string {
+	var buf strings.Builder
+	if err := sfv.SerializeInnerList(&buf, p.innerList()); err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+func parseSignatureParams(list sfv.InnerList) (*SignatureParams, error) {
+	p := &SignatureParams{
+		list: &list,
+	}
+	for _, item := range list.Items {
+		c, err := componentFromItem(item)
+		if err != nil {
+			return nil, err
+		}
+		p.Components = append(p.Components, c)
+	}
+	for _, param := range list.Params {
+		var ok bool
+		switch param.Key {
+		case "created":
+			var v int64
+			v, ok = param.Value.(int64)
+			p.Created = time.Unix(v, 0)
+		case "expires":
+			var v int64
+			v, ok = param.Value.(int64)
+			p.Expires = time.Unix(v, 0)
+		case "nonce":
+			p.Nonce, ok = param.Value.(string)
+		case "alg":
+			var v string
+			v, ok = param.Value.(string)
+			p.Algorithm = Algorithm(v)
+		case "keyid":
+			p.KeyID, ok = param.Value.(string)
+		case "tag":
+			p.Tag, ok = param.Value.(string)
+		default:
+			// ignore unknown parameters, but they are still covered by the signature.
+			ok = true
+		}
+		if !ok {
+			return nil, fmt.Errorf("httpsig: invalid type of signature parameter %q: %T", param.Key, param.Value)
+		}
+	}
+	return p, nil
+}
+
+// signatureBase creates the signature base defined in RFC 9421 Section 2.5.
+func signatureBase(msg *message, params *SignatureParams) ([]byte, error) {
+	var buf strings.Builder
+	seen := make(map[string]struct{}, len(params.Components))
+	for _, c := range params.Components {
+		id := c.String()
+		if _, ok := seen[id]; ok {
+			return nil, fmt.Errorf("httpsig: duplicated component: %s", id)
+		}
+		seen[id] = struct{}{}
+
+		values, err := msg.values(c)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range values {
+			if strings.ContainsAny(v, "\r\n") {
+				return nil, fmt.Errorf("httpsig: the value of %s contains a newline", id)
+			}
+			buf.WriteString(id)
+			buf.WriteString(": ")
+			buf.WriteString(v)
+			buf.WriteByte('\n')
+		}
+	}
+
+	buf.WriteString(`"@signature-params": `)
+	if err := sfv.SerializeInnerList(&buf, params.innerList()); err != nil {
+		return nil, err
+	}
+	return []byte(buf.String()), nil
+}
+
+// Signer signs HTTP messages.
+type Signer struct {
+	_NamedFieldsRequired struct{}
+
+	// Label is the label of the signature in the Signature and Signature-Input fields.
+	// If it is empty, "sig1" is used.
+	Label string
+
+	// Key is the signing key, typically created by [jwa.SignatureAlgorithm.New]
+	// and [sig.Algorithm.NewSigningKey].
+	Key sig.SigningKey
+
+	// Algorithm is set to the "alg" parameter if it is not empty.
+	Algorithm Algorithm
+
+	// KeyID is set to the "keyid" parameter if it is not empty.
+	KeyID string
+
+	// Tag is set to the "tag" parameter if it is not empty.
+	Tag string
+
+	// Components are the covered components.
+	Components []Component
+
+	// Expires is the lifetime of the signature.
+	// If it is not zero, the "expires" parameter is set.
+	Expires time.Duration
+
+	// Nonce adds a random "nonce" parameter if it is true.
+	Nonce bool
+
+	// Clock is used for the "created" and "expires" parameters.
+	// If it is nil, the system clock is used.
+	Clock goat.Clock
+}
+
+// SignRequest signs req, and adds the Signature and Signature-Input fields to req.
+func (s *Signer) SignRequest(ctx context.Context, req *http.Request) error {
+	return s.sign(ctx, &message{req: req}, req.Header)
+}
+
+// SignResponse signs resp, and adds the Signature and Signature-Input fields to resp.
+// req is the request for resp, which is used by the components with the "req" parameter.
+// It may be nil if no such component is covered.
+func (s *Signer) SignResponse(ctx context.Context, resp *http.Response, req *http.Request) error {
+	return s.sign(ctx, &message{req: req, resp: resp}, resp.Header)
+}
+
+func (s *Signer) sign(ctx context.Context, msg *message, header http.Header) error {
+	_ = s._NamedFieldsRequired
+	if s.Key == nil {
+		return errors.New("httpsig: key is missing")
+	}
+	label := s.label()
+	if !sfv.IsKey(label) {
+		return fmt.Errorf("httpsig: invalid label: %q", label)
+	}
+
+	now := clockutils.Now(s.Clock)
+	params := &SignatureParams{
+		Components: s.Components,
+		Created:    now,
+		Algorithm:  s.Algorithm,
+		KeyID:      s.KeyID,
+		Tag:        s.Tag,
+	}
+	if s.Expires != 0 {
+		params.Expires = now.Add(s.Expires)
+	}
+	if s.Nonce {
+		params.Nonce = rand.Text()
+	}
+
+	base, err := signatureBase(msg, params)
+	if err != nil {
+		return err
+	}
+	signature, err := sig.SignContext(ctx, s.Key, base)
+	if err != nil {
+		return err
+	}
+
+	var input, value strings.Builder
+	input.WriteString(label)
+	input.WriteByte('=')
+	if err := sfv.SerializeInnerList(&input, params.innerList()); err != nil {
+		return err
+	}
+	value.WriteString(label)
+	value.WriteByte('=')
+	if err := sfv.SerializeBareItem(&value, signature); err != nil {
+		return err
+	}
+	header.Add("Signature-Input", input.String())
+	header.Add("Signature", value.String())
+	return nil
+}
+
+func (s *Signer) label() string {
+	if s.Label == "" {
+		return "sig1"
+	}
+	return s.Label
+}
@@ -0,0 +1,448 @@
+package httpsig
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shogo82148/goat"
+	"github.com/shogo82148/goat/internal/sfv"
+	"github.com/shogo82148/goat/jwa"
+	_ "github.com/shogo82148/goat/jwa/ed25519" // for Ed25519
+	_ "github.com/shogo82148/goat/jwa/es"      // for ECDSA
+	_ "github.com/shogo82148/goat/jwa/hs"      // for HMAC SHA-256
+	_ "github.com/shogo82148/goat/jwa/ps"      // for RSASSA-PSS
+	_ "github.com/shogo82148/goat/jwa/rs"      // for RSASSA-PKCS1-v1_5
+	"github.com/shogo82148/goat/jwk"
+	"github.com/shogo82148/goat/sig"
+)
+
+// RFC 9421 Appendix B.2. Example Message
+const testRequest = "POST /foo?param=Value&Pet=dog HTTP/1.1\r\n" +
+	"Host: example.com\r\n" +
+	"Date: Tue, 20 Apr 2021 02:07:55 GMT\r\n" +
+	"Content-Type: application/json\r\n" +
+	"Content-Digest: sha-512=:WZDPaVn/7XgHaAy8pmojAkGWoRx2UFChF41A2svX+TaPm+AbwAgBWnrIiYllu7BNNyealdVLvRwEmTHWXvJwew==:\r\n" +
+	"Content-Length: 18\r\n" +
+	"\r\n" +
+	`{"hello": "world"}`
+
+const testResponse = "HTTP/1.1 200 OK\r\n" +
+	"Date: Tue, 20 Apr 2021 02:07:56 GMT\r\n" +
+	"Content-Type: application/json\r\n" +
+	"Content-Digest: sha-512=:mEWXIS7MaLRuGgxOBdODa3xqM1XdEvxoYhvlCFJ41QJgJc4GTsPp29l5oGX69wWdXymyU0rjJuahq4l5aGgfLQ==:\r\n" +
+	"Content-Length: 23\r\n" +
+	"\r\n" +
+	`{"message": "good dog"}`
+
+func newTestRequest(t *testing.T) *http.Request {
+	t.Helper()
+	req, err := http.ReadRequest(bufio.NewReader(strings.NewReader(testRequest)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func newTestResponse(t *testing.T, req *http.Request) *http.Response {
+	t.Helper()
+	resp, err := http.ReadResponse(bufio.NewReader(strings.NewReader(testResponse)), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestSignatureBase(t *testing.T) {
+	req := newTestRequest(t)
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			// RFC 9421 Appendix B.2.1. Minimal Signature Using rsa-pss-sha512
+			name:  "minimal",
+			input: `();created=1618884473;keyid="test-key-rsa-pss";nonce="b3k2pp5k7z-50gnwp.yemd"`,
+			want:  `"@signature-params": ();created=1618884473;keyid="test-key-rsa-pss";nonce="b3k2pp5k7z-50gnwp.yemd"`,
+		},
+		{
+			// RFC 9421 Appendix B.2.2. Selective Covered Components Using rsa-pss-sha512
+			name:  "selective",
+			input: `("@authority" "content-digest" "@query-param";name="Pet");created=1618884473;keyid="test-key-rsa-pss";tag="header-example"`,
+			want: `"@authority": example.com` + "\n" +
+				`"content-digest": sha-512=:WZDPaVn/7XgHaAy8pmojAkGWoRx2UFChF41A2svX+TaPm+AbwAgBWnrIiYllu7BNNyealdVLvRwEmTHWXvJwew==:` + "\n" +
+				`"@query-param";name="Pet": dog` + "\n" +
+				`"@signature-params": ("@authority" "content-digest" "@query-param";name="Pet");created=1618884473;keyid="test-key-rsa-pss";tag="header-example"`,
+		},
+		{
+			// RFC 9421 Appendix B.2.3. Full Coverage Using rsa-pss-sha512
+			name:  "full",
+			input: `("date" "@method" "@path" "@query" "@authority" "content-type" "content-digest" "content-length");created=1618884473;keyid="test-key-rsa-pss"`,
+			want: `"date": Tue, 20 Apr 2021 02:07:55 GMT` + "\n" +
+				`"@method": POST` + "\n" +
+				`"@path": /foo` + "\n" +
+				`"@query": ?param=Value&Pet=dog` + "\n" +
+				`"@authority": example.com` + "\n" +
+				`"content-type": application/json` + "\n" +
+				`"content-digest": sha-512=:WZDPaVn/7XgHaAy8pmojAkGWoRx2UFChF41A2svX+TaPm+AbwAgBWnrIiYllu7BNNyealdVLvRwEmTHWXvJwew==:` + "\n" +
+				`"content-length": 18` + "\n" +
+				`"@signature-params": ("date" "@method" "@path" "@query" "@authority" "content-type" "content-digest" "content-length");created=1618884473;keyid="test-key-rsa-pss"`,
+		},
+		{
+			name:  "derived components",
+			input: `("@target-uri" "@scheme" "@request-target" "content-type";bs);alg="hmac-sha256"`,
+			want: `"@target-uri": http://example.com/foo?param=Value&Pet=dog` + "\n" +
+				`"@scheme": http` + "\n" +
+				`"@request-target": /foo?param=Value&Pet=dog` + "\n" +
+				`"content-type";bs: :YXBwbGljYXRpb24vanNvbg==:` + "\n" +
+				`"@signature-params": ("@target-uri" "@scheme" "@request-target" "content-type";bs);alg="hmac-sha256"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := sfv.ParseInnerList(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			params, err := parseSignatureParams(list)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := signatureBase(&message{req: req}, params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("unexpected signature base:\ngot:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignatureBase_Response(t *testing.T) {
+	req := newTestRequest(t)
+	resp := newTestResponse(t, req)
+	params := &SignatureParams{
+		Components: []Component{
+			ComponentStatus,
+			Field("Content-Digest"),
+			ComponentMethod.Request(),
+			QueryParam("Pet").Request(),
+		},
+		Created: time.Unix(1618884479, 0),
+		KeyID:   "test-key-ecc-p256",
+	}
+	got, err := signatureBase(&message{req: req, resp: resp}, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `"@status": 200` + "\n" +
+		`"content-digest": sha-512=:mEWXIS7MaLRuGgxOBdODa3xqM1XdEvxoYhvlCFJ41QJgJc4GTsPp29l5oGX69wWdXymyU0rjJuahq4l5aGgfLQ==:` + "\n" +
+		`"@method";req: POST` + "\n" +
+		`"@query-param";name="Pet";req: dog` + "\n" +
+		`"@signature-params": ("@status" "content-digest" "@method";req "@query-param";name="Pet";req);created=1618884479;keyid="test-key-ecc-p256"`
+	if string(got) != want {
+		t.Errorf("unexpected signature base:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	// the request components are not available in the response without req.
+	params.Components = []Component{ComponentMethod}
+	if _, err := signatureBase(&message{req: req, resp: resp}, params); err == nil {
+		t.Error("want error, but not")
+	}
+}
+
+func TestVerify_RFC9421_HMAC(t *testing.T) {
+	// RFC 9421 Appendix B.2.5. Signing a Request Using hmac-sha256
+	secret, err := base64.StdEncoding.DecodeString("uzvJfB4u3N0Jy4T7NZ75MDVcr8zSTInedJtkgcu46YW4XByzNJjxBdtjUkdJPBtbmHhIDi6pcl8jsasjlTMtDQ==")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := jwk.NewPrivateKey(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key.SetKeyID("test-shared-secret")
+	key.SetAlgorithm(jwa.KeyAlgorithm(jwa.SignatureAlgorithmHS256))
+	set := &jwk.Set{Keys: []*jwk.Key{key}}
+
+	req := newTestRequest(t)
+	req.Header.Set("Signature-Input", `sig-b25=("date" "@authority" "content-type");created=1618884473;keyid="test-shared-secret"`)
+	req.Header.Set("Signature", `sig-b25=:pxcQw6G3AjtMBQjwo8XzkZf/bws5LelbaMk5rGIGtE8=:`)
+
+	v := &Verifier{
+		KeyFinder: &JWKSetKeyFinder{JWKSet: set},
+	}
+	params, err := v.VerifyRequest(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.KeyID != "test-shared-secret" {
+		t.Errorf("unexpected keyid: %q", params.KeyID)
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		alg  Algorithm
+		priv any
+	}{
+		{AlgorithmRSAPSSSHA512, rsaKey},
+		{AlgorithmRSAv15SHA256, rsaKey},
+		{AlgorithmHMACSHA256, []byte("a shared secret that is long enough for HMAC")},
+		{AlgorithmECDSAP256SHA256, p256Key},
+		{AlgorithmECDSAP384SHA384, p384Key},
+		{AlgorithmEd25519, ed25519Key},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alg.String(), func(t *testing.T) {
+			ctx := context.Background()
+			priv, err := jwk.NewPrivateKey(tt.priv)
+			if err != nil {
+				t.Fatal(err)
+			}
+			priv.SetKeyID("test-key")
+			var verifyKey *jwk.Key
+			if tt.alg == AlgorithmHMACSHA256 {
+				verifyKey = priv
+			} else {
+				verifyKey, err = jwk.NewPublicKey(priv.PublicKey())
+				if err != nil {
+					t.Fatal(err)
+				}
+				verifyKey.SetKeyID("test-key")
+			}
+
+			s := &Signer{
+				Key:       tt.alg.SignatureAlgorithm().New().NewSigningKey(priv),
+				Algorithm: tt.alg,
+				KeyID:     "test-key",
+				Tag:       "webhook",
+				Components: []Component{
+					ComponentMethod,
+					ComponentTargetURI,
+					Field("Content-Digest"),
+				},
+				Expires: time.Minute,
+				Nonce:   true,
+			}
+			req := newTestRequest(t)
+			if err := s.SignRequest(ctx, req); err != nil {
+				t.Fatal(err)
+			}
+
+			v := &Verifier{
+				KeyFinder:          &JWKSetKeyFinder{JWKSet: &jwk.Set{Keys: []*jwk.Key{verifyKey}}},
+				Label:              "sig1",
+				RequiredComponents: []Component{Field("content-digest")},
+				Tag:                "webhook",
+				MaxAge:             time.Minute,
+			}
+			params, err := v.VerifyRequest(ctx, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if params.Algorithm != tt.alg {
+				t.Errorf("unexpected alg: %q", params.Algorithm)
+			}
+			if params.Nonce == "" {
+				t.Error("want nonce")
+			}
+
+			// tampered request
+			req.Header.Set("Content-Digest", "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:")
+			if _, err := v.VerifyRequest(ctx, req); err == nil {
+				t.Error("want error, but not")
+			}
+		})
+	}
+}
+
+func TestSignAndVerify_Response(t *testing.T) {
+	ctx := context.Background()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := jwk.NewPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := newTestRequest(t)
+	resp := newTestResponse(t, req)
+	s := &Signer{
+		Label: "reqres",
+		Key:   jwa.SignatureAlgorithmES256.New().NewSigningKey(key),
+		Components: []Component{
+			ComponentStatus,
+			Field("content-digest"),
+			ComponentAuthority.Request(),
+			DictionaryMember("Content-Digest", "sha-512").Request(),
+		},
+	}
+	if err := s.SignResponse(ctx, resp, req); err != nil {
+		t.Fatal(err)
+	}
+
+	v := &Verifier{
+		KeyFinder: FindKeyFunc(func(ctx context.Context, params *SignatureParams) (sig.SigningKey, error) {
+			return jwa.SignatureAlgorithmES256.New().NewSigningKey(key), nil
+		}),
+		RequiredComponents: []Component{ComponentStatus},
+	}
+	if _, err := v.VerifyResponse(ctx, resp, req); err != nil {
+		t.Fatal(err)
+	}
+
+	// the signature covers the request.
+	req.Host = "example.org"
+	if _, err := v.VerifyResponse(ctx, resp, req); err == nil {
+		t.Error("want error, but not")
+	}
+}
+
+func TestVerify_Expired(t *testing.T) {
+	ctx := context.Background()
+	key, err := jwk.NewPrivateKey([]byte("a shared secret that is long enough for HMAC"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingKey := jwa.SignatureAlgorithmHS256.New().NewSigningKey(key)
+	now := time.Unix(1618884473, 0)
+	clock := goat.ClockFunc(func() time.Time { return now })
+
+	s := &Signer{
+		Key:        signingKey,
+		Components: []Component{ComponentMethod},
+		Expires:    time.Minute,
+		Clock:      clock,
+	}
+	req := newTestRequest(t)
+	if err := s.SignRequest(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	v := &Verifier{
+		KeyFinder: FindKeyFunc(func(ctx context.Context, params *SignatureParams) (sig.SigningKey, error) {
+			return signingKey, nil
+		}),
+		Clock: clock,
+	}
+	if _, err := v.VerifyRequest(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := v.VerifyRequest(ctx, req); err == nil {
+		t.Error("want error, but not")
+	}
+}
+
+func TestVerify_Leeway(t *testing.T) {
+	ctx := context.Background()
+	key, err := jwk.NewPrivateKey([]byte("a shared secret that is long enough for HMAC"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingKey := jwa.SignatureAlgorithmHS256.New().NewSigningKey(key)
+	now := time.Unix(1618884473, 0)
+	s := &Signer{
+		Key:        signingKey,
+		Components: []Component{ComponentMethod},
+		Expires:    time.Minute,
+		Clock:      goat.ClockFunc(func() time.Time { return now }),
+	}
+	req := newTestRequest(t)
+	if err := s.SignRequest(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	var verifierNow time.Time
+	v := &Verifier{
+		KeyFinder: FindKeyFunc(func(ctx context.Context, params *SignatureParams) (sig.SigningKey, error) {
+			return signingKey, nil
+		}),
+		Clock: goat.ClockFunc(func() time.Time { return verifierNow }),
+	}
+	tests := []struct {
+		name   string
+		now    time.Time
+		leeway time.Duration
+		maxAge time.Duration
+		ok     bool
+	}{
+		{"expired", now.Add(time.Minute + 3*time.Second), 0, 0, false},
+		{"expired within leeway", now.Add(time.Minute + 3*time.Second), 5 * time.Second, 0, true},
+		{"created in the future", now.Add(-3 * time.Second), 0, 0, false},
+		{"created in the future within leeway", now.Add(-3 * time.Second), 5 * time.Second, 0, true},
+		{"too old", now.Add(33 * time.Second), 0, 30 * time.Second, false},
+		{"too old within leeway", now.Add(33 * time.Second), 5 * time.Second, 30 * time.Second, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifierNow = tt.now
+			v.Leeway = tt.leeway
+			v.MaxAge = tt.maxAge
+			_, err := v.VerifyRequest(ctx, req)
+			if tt.ok && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Error("want error, but not")
+			}
+		})
+	}
+}
+
+func TestComponent(t *testing.T) {
+	tests := []struct {
+		c    Component
+		want string
+	}{
+		{ComponentMethod, `"@method"`},
+		{Field("Content-Type"), `"content-type"`},
+		{QueryParam("Pet"), `"@query-param";name="Pet"`},
+		{ComponentStatus.Request(), `"@status";req`},
+		{DictionaryMember("Example-Dict", "a"), `"example-dict";key="a"`},
+		{Field("Example-Header").BinaryWrapped(), `"example-header";bs`},
+	}
+	for _, tt := range tests {
+		if got := tt.c.String(); got != tt.want {
+			t.Errorf("unexpected component: got %s, want %s", got, tt.want)
+		}
+		c, err := ParseComponent(tt.want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.String() != tt.want {
+			t.Errorf("unexpected component: got %s, want %s", c, tt.want)
+		}
+	}
+}
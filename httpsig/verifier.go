@@ -0,0 +1,215 @@
+package httpsig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shogo82148/goat"
+	"github.com/shogo82148/goat/internal/clockutils"
+	"github.com/shogo82148/goat/internal/sfv"
+	"github.com/shogo82148/goat/jwa"
+	"github.com/shogo82148/goat/jwk"
+	"github.com/shogo82148/goat/sig"
+)
+
+// KeyFinder finds the key for verifying the signature.
+type KeyFinder interface {
+	FindKey(ctx context.Context, params *SignatureParams) (key sig.SigningKey, err error)
+}
+
+// FindKeyFunc is an adapter to allow the use of ordinary functions as KeyFinder.
+type FindKeyFunc func(ctx context.Context, params *SignatureParams) (key sig.SigningKey, err error)
+
+// FindKey calls f(ctx, params).
+func (f FindKeyFunc) FindKey(ctx context.Context, params *SignatureParams) (key sig.SigningKey, err error) {
+	return f(ctx, params)
+}
+
+var _ KeyFinder = (*JWKSetKeyFinder)(nil)
+
+// JWKSetKeyFinder finds the key identified by the "keyid" parameter from the JWK Set.
+//
+// The algorithm is determined by the "alg" signature parameter, or the "alg" member of the key.
+// If both are present, they must be the same.
+type JWKSetKeyFinder struct {
+	JWKSet *jwk.Set
+}
+
+// FindKey implements [KeyFinder].
+func (f *JWKSetKeyFinder) FindKey(ctx context.Context, params *SignatureParams) (key sig.SigningKey, err error) {
+	if f.JWKSet == nil {
+		return nil, errors.New("httpsig: jwk set is not configured")
+	}
+	if params.KeyID == "" {
+		return nil, errors.New("httpsig: keyid is missing")
+	}
+	k, ok := f.JWKSet.Find(params.KeyID)
+	if !ok {
+		return nil, fmt.Errorf("httpsig: key %q is not found", params.KeyID)
+	}
+
+	alg := jwa.SignatureAlgorithm(k.Algorithm())
+	if params.Algorithm != "" {
+		paramAlg := params.Algorithm.SignatureAlgorithm()
+		if paramAlg == jwa.SignatureAlgorithmUnknown {
+			return nil, fmt.Errorf("httpsig: unknown algorithm: %q", params.Algorithm)
+		}
+		if alg != "" && alg != paramAlg {
+			return nil, fmt.Errorf("httpsig: requested alg %q is not supported by the key", params.Algorithm)
+		}
+		alg = paramAlg
+	}
+	if alg == "" {
+		return nil, errors.New("httpsig: algorithm is unknown")
+	}
+	if !alg.Available() {
+		return nil, errors.New("httpsig: algorithm not available")
+	}
+	return alg.New().NewSigningKey(k), nil
+}
+
+// Verifier verifies HTTP message signatures.
+type Verifier struct {
+	_NamedFieldsRequired struct{}
+
+	// KeyFinder finds the key for verifying the signature.
+	KeyFinder KeyFinder
+
+	// Label is the label of the signature to be verified.
+	// If it is empty, the message must have exactly one signature.
+	Label string
+
+	// RequiredComponents are the components that must be covered by the signature.
+	RequiredComponents []Component
+
+	// Tag is the expected "tag" parameter.
+	// If it is empty, any tag is accepted.
+	Tag string
+
+	// MaxAge is the maximum age of the signature calculated from the "created" parameter.
+	// If it is zero, the age is not checked and the "created" parameter is optional.
+	// The "expires" parameter is always checked.
+	MaxAge time.Duration
+
+	// Leeway is the allowed clock skew between the signer and the verifier.
+	// It is applied to the "created" and "expires" parameters.
+	Leeway time.Duration
+
+	// Clock is used to check the "created" and "expires" parameters.
+	// If it is nil, the system clock is used.
+	Clock goat.Clock
+}
+
+// VerifyRequest verifies the signature of req.
+func (v *Verifier) VerifyRequest(ctx context.Context, req *http.Request) (*SignatureParams, error) {
+	return v.verify(ctx, &message{req: req}, req.Header)
+}
+
+// VerifyResponse verifies the signature of resp.
+// req is the request for resp, which is used by the components with the "req" parameter.
+// It may be nil if no such component is covered.
+func (v *Verifier) VerifyResponse(ctx context.Context, resp *http.Response, req *http.Request) (*SignatureParams, error) {
+	return v.verify(ctx, &message{req: req, resp: resp}, resp.Header)
+}
+
+func (v *Verifier) verify(ctx context.Context, msg *message, header http.Header) (*SignatureParams, error) {
+	_ = v._NamedFieldsRequired
+	if v.KeyFinder == nil {
+		return nil, errors.New("httpsig: verifier is not configured")
+	}
+
+	inputs, err := sfv.ParseDictionary(strings.Join(header.Values("Signature-Input"), ", "))
+	if err != nil {
+		return nil, err
+	}
+	signatures, err := sfv.ParseDictionary(strings.Join(header.Values("Signature"), ", "))
+	if err != nil {
+		return nil, err
+	}
+
+	label := v.Label
+	if label == "" {
+		if len(inputs) != 1 {
+			return nil, fmt.Errorf("httpsig: exactly one signature is expected, but got %d", len(inputs))
+		}
+		label = inputs[0].Key
+	}
+	inputAny, ok := sfv.LookupMember(inputs, label)
+	if !ok {
+		return nil, fmt.Errorf("httpsig: signature %q is not found", label)
+	}
+	input, ok := inputAny.(sfv.InnerList)
+	if !ok {
+		return nil, fmt.Errorf("httpsig: Signature-Input of %q must be an inner list", label)
+	}
+	signatureAny, ok := sfv.LookupMember(signatures, label)
+	if !ok {
+		return nil, fmt.Errorf("httpsig: signature %q is not found", label)
+	}
+	signatureItem, ok := signatureAny.(sfv.Item)
+	if !ok {
+		return nil, fmt.Errorf("httpsig: Signature of %q must be a byte sequence", label)
+	}
+	signature, ok := signatureItem.Value.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("httpsig: Signature of %q must be a byte sequence", label)
+	}
+
+	params, err := parseSignatureParams(input)
+	if err != nil {
+		return nil, err
+	}
+	if err := v.verifyParams(params); err != nil {
+		return nil, err
+	}
+
+	key, err := v.KeyFinder.FindKey(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("httpsig: failed to find key: %w", err)
+	}
+	base, err := signatureBase(msg, params)
+	if err != nil {
+		return nil, err
+	}
+	if err := key.Verify(base, signature); err != nil {
+		return nil, fmt.Errorf("httpsig: failed to verify signature: %w", err)
+	}
+	return params, nil
+}
+
+func (v *Verifier) verifyParams(params *SignatureParams) error {
+	covered := make(map[string]struct{}, len(params.Components))
+	for _, c := range params.Components {
+		covered[c.String()] = struct{}{}
+	}
+	for _, c := range v.RequiredComponents {
+		if _, ok := covered[c.String()]; !ok {
+			return fmt.Errorf("httpsig: %s is not covered by the signature", c)
+		}
+	}
+
+	if v.Tag != "" && params.Tag != v.Tag {
+		return fmt.Errorf("httpsig: unexpected tag: %q", params.Tag)
+	}
+
+	now := clockutils.Now(v.Clock)
+	if !params.Expires.IsZero() && !now.Before(params.Expires.Add(v.Leeway)) {
+		return errors.New("httpsig: signature is expired")
+	}
+	if !params.Created.IsZero() && now.Add(v.Leeway).Before(params.Created) {
+		return errors.New("httpsig: signature is created in the future")
+	}
+	if v.MaxAge != 0 {
+		if params.Created.IsZero() {
+			return errors.New("httpsig: created is missing")
+		}
+		if now.Sub(params.Created) > v.MaxAge+v.Leeway {
+			return errors.New("httpsig: signature is too old")
+		}
+	}
+	return nil
+}
@@ -2,6 +2,8 @@ package cborutils
 
 import (
 	"fmt"
+	"math"
+	"time"
 
 	"github.com/shogo82148/go-cbor"
 )
@@ -90,3 +92,48 @@ func (d *Decoder) MustBytes(label int64) []byte {
 	}
 	return b
 }
+
+// Get gets a raw parameter.
+func (d *Decoder) Get(label int64) (any, bool) {
+	v, ok := d.raw[IntegerFromInt64(label)]
+	return v, ok
+}
+
+// GetArray gets an array parameter.
+func (d *Decoder) GetArray(label int64) ([]any, bool) {
+	v, ok := d.raw[IntegerFromInt64(label)]
+	if !ok {
+		return nil, false
+	}
+
+	a, ok := v.([]any)
+	return a, ok
+}
+
+// GetTime gets a NumericDate parameter.
+// It accepts both integer and floating-point values.
+func (d *Decoder) GetTime(label int64) (time.Time, bool) {
+	v, ok := d.raw[IntegerFromInt64(label)]
+	if !ok {
+		return time.Time{}, false
+	}
+
+	switch v := v.(type) {
+	case cbor.Integer:
+		i, err := v.Int64()
+		if err != nil {
+			d.SaveError(fmt.Errorf("%s: failed to parse parameter %d: %w", d.pkg, label, err))
+			return time.Time{}, false
+		}
+		return time.Unix(i, 0), true
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			d.SaveError(fmt.Errorf("%s: failed to parse parameter %d", d.pkg, label))
+			return time.Time{}, false
+		}
+		i, f := math.Modf(v)
+		return time.Unix(int64(i), int64(f*1e9)), true
+	}
+	d.SaveError(fmt.Errorf("%s: invalid type for %d: %T", d.pkg, label, v))
+	return time.Time{}, false
+}
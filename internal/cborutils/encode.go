@@ -0,0 +1,57 @@
+package cborutils
+
+import "time"
+
+// Encoder encodes parameters into a CBOR map with integer labels,
+// e.g. COSE_Key and CWT Claims Set.
+// It records the first error, and the error is reported by Err.
+type Encoder struct {
+	raw map[any]any
+	err error
+}
+
+// NewEncoder returns a new Encoder that sets the parameters into raw.
+// If raw is nil, a new map is allocated.
+func NewEncoder(raw map[any]any) *Encoder {
+	if raw == nil {
+		raw = make(map[any]any)
+	}
+	return &Encoder{
+		raw: raw,
+	}
+}
+
+// Data returns the encoded map.
+func (e *Encoder) Data() map[any]any {
+	return e.raw
+}
+
+// Set sets the parameter.
+func (e *Encoder) Set(label int64, v any) {
+	e.raw[IntegerFromInt64(label)] = v
+}
+
+// SetTime sets a NumericDate parameter.
+// The time is encoded as an integer if it has no fractional seconds,
+// otherwise as a floating-point value.
+func (e *Encoder) SetTime(label int64, t time.Time) {
+	if nsec := t.Nanosecond(); nsec != 0 {
+		e.Set(label, float64(t.Unix())+float64(nsec)/1e9)
+		return
+	}
+	e.Set(label, IntegerFromInt64(t.Unix()))
+}
+
+// SaveError asserts the operation must not fail.
+// If err is nil, SaveError does nothing.
+// Otherwise, SaveError records the first error.
+func (e *Encoder) SaveError(err error) {
+	if err != nil && e.err == nil {
+		e.err = err
+	}
+}
+
+// Err returns the first error during encoding.
+func (e *Encoder) Err() error {
+	return e.err
+}
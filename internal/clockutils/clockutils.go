@@ -0,0 +1,16 @@
+package clockutils
+
+import (
+	"time"
+
+	"github.com/shogo82148/goat"
+)
+
+// Now returns the current time from c.
+// If c is nil, the system clock is used.
+func Now(c goat.Clock) time.Time {
+	if c == nil {
+		return time.Now()
+	}
+	return c.Now()
+}
@@ -31,6 +31,25 @@ func Unmarshal(data []byte, v any) error {
 	return nil
 }
 
+// Convert converts v decoded by the json package to T.
+// If v is not T, it is converted through JSON encoding.
+// It returns false if v can't be converted.
+func Convert[T any](v any) (T, bool) {
+	if t, ok := v.(T); ok {
+		return t, true
+	}
+
+	var zero, t T
+	data, err := json.Marshal(v)
+	if err != nil {
+		return zero, false
+	}
+	if err := Unmarshal(data, &t); err != nil {
+		return zero, false
+	}
+	return t, true
+}
+
 var b64 = base64.RawURLEncoding
 
 type Decoder struct {
@@ -33,6 +33,49 @@ func TestUnmarshal(t *testing.T) {
 	})
 }
 
+func TestConvert(t *testing.T) {
+	t.Run("same type", func(t *testing.T) {
+		got, ok := Convert[string]("shaken")
+		if !ok || got != "shaken" {
+			t.Errorf("unexpected result: %q, %t", got, ok)
+		}
+	})
+
+	t.Run("number", func(t *testing.T) {
+		got, ok := Convert[int64](json.Number("1300819380"))
+		if !ok || got != 1300819380 {
+			t.Errorf("unexpected result: %d, %t", got, ok)
+		}
+	})
+
+	t.Run("struct", func(t *testing.T) {
+		type tenant struct {
+			ID   string `json:"id"`
+			Plan int    `json:"plan"`
+		}
+		v := map[string]any{
+			"id":   "example",
+			"plan": json.Number("3"),
+		}
+		got, ok := Convert[tenant](v)
+		if !ok {
+			t.Fatal("failed to convert")
+		}
+		if diff := cmp.Diff(tenant{ID: "example", Plan: 3}, got); diff != "" {
+			t.Error("mismatch (-want +got):", diff)
+		}
+	})
+
+	t.Run("type mismatch", func(t *testing.T) {
+		if _, ok := Convert[int64]("shaken"); ok {
+			t.Error("want false, got true")
+		}
+		if _, ok := Convert[int64](json.Number("1.5")); ok {
+			t.Error("want false, got true")
+		}
+	})
+}
+
 func TestDecoder_Decode(t *testing.T) {
 	v := "AyM1SysPpbyDfgZld3umj1qzKObwVMkoqQ-EstJQLr_T-1qS0gZH75" +
 		"aKtMN3Yj0iPS4hcgUuTwjAzZr1Z9CAow"
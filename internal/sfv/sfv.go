@@ -0,0 +1,527 @@
+// Package sfv implements the subset of Structured Field Values for HTTP defined in RFC 8941
+// that is necessary for HTTP Message Signatures (RFC 9421) and Digest Fields (RFC 9530).
+package sfv
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Token is a Token defined in RFC 8941 Section 3.3.4.
+type Token string
+
+// Decimal is a Decimal defined in RFC 8941 Section 3.3.2.
+type Decimal float64
+
+// Param is a parameter defined in RFC 8941 Section 3.1.2.
+// Value is one of int64, Decimal, string, Token, []byte and bool.
+type Param struct {
+	Key   string
+	Value any
+}
+
+// Item is an Item defined in RFC 8941 Section 3.3.
+type Item struct {
+	Value  any
+	Params []Param
+}
+
+// InnerList is an Inner List defined in RFC 8941 Section 3.1.1.
+type InnerList struct {
+	Items  []Item
+	Params []Param
+}
+
+// Member is a member of a Dictionary defined in RFC 8941 Section 3.2.
+// Value is either Item or InnerList.
+type Member struct {
+	Key   string
+	Value any
+}
+
+// LookupParam returns the value of the parameter key.
+func LookupParam(params []Param, key string) (any, bool) {
+	for _, p := range params {
+		if p.Key == key {
+			return p.Value, true
+		}
+	}
+	return nil, false
+}
+
+// LookupMember returns the value of the Dictionary member key.
+func LookupMember(members []Member, key string) (any, bool) {
+	// RFC 8941 Section 4.2.2:
+	// > If dictionary already contains a key this_key, overwrite its value.
+	var ret any
+	var found bool
+	for _, m := range members {
+		if m.Key == key {
+			ret, found = m.Value, true
+		}
+	}
+	return ret, found
+}
+
+type parser struct {
+	s   string
+	pos int
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *parser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *parser) skipSP() {
+	for !p.eof() && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *parser) skipOWS() {
+	for !p.eof() && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("sfv: invalid structured field at %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// ParseDictionary parses a Dictionary defined in RFC 8941 Section 4.2.2.
+func ParseDictionary(s string) ([]Member, error) {
+	p := &parser{s: s}
+	p.skipSP()
+	var members []Member
+	for !p.eof() {
+		key, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		var value any
+		if p.peek() == '=' {
+			p.pos++
+			value, err = p.parseItemOrInnerList()
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			params, err := p.parseParameters()
+			if err != nil {
+				return nil, err
+			}
+			value = Item{Value: true, Params: params}
+		}
+		members = append(members, Member{Key: key, Value: value})
+
+		p.skipOWS()
+		if p.eof() {
+			return members, nil
+		}
+		if p.peek() != ',' {
+			return nil, p.errorf("expected ','")
+		}
+		p.pos++
+		p.skipOWS()
+		if p.eof() {
+			return nil, p.errorf("trailing ','")
+		}
+	}
+	return members, nil
+}
+
+// ParseInnerList parses s as an Inner List with parameters.
+func ParseInnerList(s string) (InnerList, error) {
+	p := &parser{s: s}
+	p.skipSP()
+	list, err := p.parseInnerList()
+	if err != nil {
+		return InnerList{}, err
+	}
+	p.skipSP()
+	if !p.eof() {
+		return InnerList{}, p.errorf("unexpected trailing characters")
+	}
+	return list, nil
+}
+
+// ParseItem parses s as an Item with parameters.
+func ParseItem(s string) (Item, error) {
+	p := &parser{s: s}
+	p.skipSP()
+	item, err := p.parseItem()
+	if err != nil {
+		return Item{}, err
+	}
+	p.skipSP()
+	if !p.eof() {
+		return Item{}, p.errorf("unexpected trailing characters")
+	}
+	return item, nil
+}
+
+func (p *parser) parseItemOrInnerList() (any, error) {
+	if p.peek() == '(' {
+		return p.parseInnerList()
+	}
+	return p.parseItem()
+}
+
+// parseInnerList parses an Inner List defined in RFC 8941 Section 4.2.1.2.
+func (p *parser) parseInnerList() (InnerList, error) {
+	if p.peek() != '(' {
+		return InnerList{}, p.errorf("expected '('")
+	}
+	p.pos++
+	var items []Item
+	for !p.eof() {
+		p.skipSP()
+		if p.peek() == ')' {
+			p.pos++
+			params, err := p.parseParameters()
+			if err != nil {
+				return InnerList{}, err
+			}
+			return InnerList{Items: items, Params: params}, nil
+		}
+		item, err := p.parseItem()
+		if err != nil {
+			return InnerList{}, err
+		}
+		items = append(items, item)
+		if c := p.peek(); c != ' ' && c != ')' {
+			return InnerList{}, p.errorf("expected ' ' or ')'")
+		}
+	}
+	return InnerList{}, p.errorf("unterminated inner list")
+}
+
+// parseItem parses an Item defined in RFC 8941 Section 4.2.3.
+func (p *parser) parseItem() (Item, error) {
+	value, err := p.parseBareItem()
+	if err != nil {
+		return Item{}, err
+	}
+	params, err := p.parseParameters()
+	if err != nil {
+		return Item{}, err
+	}
+	return Item{Value: value, Params: params}, nil
+}
+
+// parseBareItem parses a Bare Item defined in RFC 8941 Section 4.2.3.1.
+func (p *parser) parseBareItem() (any, error) {
+	c := p.peek()
+	switch {
+	case c == '-' || isDigit(c):
+		return p.parseNumber()
+	case c == '"':
+		return p.parseString()
+	case c == '*' || isAlpha(c):
+		return p.parseToken(), nil
+	case c == ':':
+		return p.parseByteSequence()
+	case c == '?':
+		return p.parseBoolean()
+	}
+	return nil, p.errorf("unexpected character %q", c)
+}
+
+// parseParameters parses Parameters defined in RFC 8941 Section 4.2.3.2.
+func (p *parser) parseParameters() ([]Param, error) {
+	var params []Param
+	for p.peek() == ';' {
+		p.pos++
+		p.skipSP()
+		key, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		var value any = true
+		if p.peek() == '=' {
+			p.pos++
+			value, err = p.parseBareItem()
+			if err != nil {
+				return nil, err
+			}
+		}
+		// RFC 8941 Section 4.2.3.2:
+		// > If parameters already contains a key param_key, overwrite its value.
+		replaced := false
+		for i := range params {
+			if params[i].Key == key {
+				params[i].Value = value
+				replaced = true
+			}
+		}
+		if !replaced {
+			params = append(params, Param{Key: key, Value: value})
+		}
+	}
+	return params, nil
+}
+
+// parseKey parses a Key defined in RFC 8941 Section 4.2.3.3.
+func (p *parser) parseKey() (string, error) {
+	c := p.peek()
+	if c != '*' && !isLower(c) {
+		return "", p.errorf("invalid key")
+	}
+	start := p.pos
+	for !p.eof() {
+		c := p.s[p.pos]
+		if !isLower(c) && !isDigit(c) && c != '_' && c != '-' && c != '.' && c != '*' {
+			break
+		}
+		p.pos++
+	}
+	return p.s[start:p.pos], nil
+}
+
+// parseNumber parses an Integer or a Decimal defined in RFC 8941 Section 4.2.4.
+func (p *parser) parseNumber() (any, error) {
+	start := p.pos
+	if p.peek() == '-' {
+		p.pos++
+	}
+	if !isDigit(p.peek()) {
+		return nil, p.errorf("invalid number")
+	}
+	decimal := false
+	for !p.eof() {
+		c := p.s[p.pos]
+		if c == '.' && !decimal {
+			if p.pos-start > 12 {
+				return nil, p.errorf("too long decimal")
+			}
+			decimal = true
+			p.pos++
+			continue
+		}
+		if !isDigit(c) {
+			break
+		}
+		p.pos++
+	}
+	s := p.s[start:p.pos]
+	if decimal {
+		if strings.HasSuffix(s, ".") || len(s)-strings.IndexByte(s, '.')-1 > 3 {
+			return nil, p.errorf("invalid decimal")
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, p.errorf("invalid decimal")
+		}
+		return Decimal(f), nil
+	}
+	if len(strings.TrimPrefix(s, "-")) > 15 {
+		return nil, p.errorf("too long integer")
+	}
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return nil, p.errorf("invalid integer")
+	}
+	return i, nil
+}
+
+// parseString parses a String defined in RFC 8941 Section 4.2.5.
+func (p *parser) parseString() (string, error) {
+	p.pos++ // skip '"'
+	var buf strings.Builder
+	for !p.eof() {
+		c := p.s[p.pos]
+		p.pos++
+		switch {
+		case c == '\\':
+			if p.eof() {
+				return "", p.errorf("unterminated string")
+			}
+			c = p.s[p.pos]
+			p.pos++
+			if c != '"' && c != '\\' {
+				return "", p.errorf("invalid escape")
+			}
+			buf.WriteByte(c)
+		case c == '"':
+			return buf.String(), nil
+		case c < 0x20 || c > 0x7e:
+			return "", p.errorf("invalid character in string")
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+// parseToken parses a Token defined in RFC 8941 Section 4.2.6.
+func (p *parser) parseToken() Token {
+	start := p.pos
+	p.pos++
+	for !p.eof() && (isTChar(p.s[p.pos]) || p.s[p.pos] == ':' || p.s[p.pos] == '/') {
+		p.pos++
+	}
+	return Token(p.s[start:p.pos])
+}
+
+// parseByteSequence parses a Byte Sequence defined in RFC 8941 Section 4.2.7.
+func (p *parser) parseByteSequence() ([]byte, error) {
+	p.pos++ // skip ':'
+	end := strings.IndexByte(p.s[p.pos:], ':')
+	if end < 0 {
+		return nil, p.errorf("unterminated byte sequence")
+	}
+	s := p.s[p.pos : p.pos+end]
+	p.pos += end + 1
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, p.errorf("invalid byte sequence: %v", err)
+	}
+	return b, nil
+}
+
+// parseBoolean parses a Boolean defined in RFC 8941 Section 4.2.8.
+func (p *parser) parseBoolean() (bool, error) {
+	p.pos++ // skip '?'
+	switch p.peek() {
+	case '1':
+		p.pos++
+		return true, nil
+	case '0':
+		p.pos++
+		return false, nil
+	}
+	return false, p.errorf("invalid boolean")
+}
+
+// IsKey reports whether s is a valid Key defined in RFC 8941 Section 3.1.2.
+func IsKey(s string) bool {
+	p := &parser{s: s}
+	key, err := p.parseKey()
+	return err == nil && key == s
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLower(c byte) bool {
+	return c >= 'a' && c <= 'z'
+}
+
+func isAlpha(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// isTChar reports whether c is a tchar defined in RFC 9110 Section 5.6.2.
+func isTChar(c byte) bool {
+	if isAlpha(c) || isDigit(c) {
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
+
+// SerializeMember serializes the value of a Dictionary member.
+func SerializeMember(buf *strings.Builder, value any) error {
+	switch v := value.(type) {
+	case InnerList:
+		return SerializeInnerList(buf, v)
+	case Item:
+		return SerializeItem(buf, v)
+	}
+	return fmt.Errorf("sfv: unexpected member type: %T", value)
+}
+
+// SerializeInnerList serializes an Inner List defined in RFC 8941 Section 4.1.1.1.
+func SerializeInnerList(buf *strings.Builder, list InnerList) error {
+	buf.WriteByte('(')
+	for i, item := range list.Items {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		if err := SerializeItem(buf, item); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte(')')
+	return SerializeParams(buf, list.Params)
+}
+
+// SerializeItem serializes an Item defined in RFC 8941 Section 4.1.3.
+func SerializeItem(buf *strings.Builder, item Item) error {
+	if err := SerializeBareItem(buf, item.Value); err != nil {
+		return err
+	}
+	return SerializeParams(buf, item.Params)
+}
+
+// SerializeParams serializes Parameters defined in RFC 8941 Section 4.1.1.2.
+func SerializeParams(buf *strings.Builder, params []Param) error {
+	for _, p := range params {
+		buf.WriteByte(';')
+		buf.WriteString(p.Key)
+		if v, ok := p.Value.(bool); ok && v {
+			continue
+		}
+		buf.WriteByte('=')
+		if err := SerializeBareItem(buf, p.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SerializeBareItem serializes a Bare Item defined in RFC 8941 Section 4.1.3.1.
+func SerializeBareItem(buf *strings.Builder, value any) error {
+	switch v := value.(type) {
+	case int64:
+		if v > 999_999_999_999_999 || v < -999_999_999_999_999 {
+			return errors.New("sfv: integer out of range")
+		}
+		buf.WriteString(strconv.FormatInt(v, 10))
+	case Decimal:
+		s := strconv.FormatFloat(float64(v), 'f', 3, 64)
+		s = strings.TrimRight(s, "0")
+		if strings.HasSuffix(s, ".") {
+			s += "0"
+		}
+		buf.WriteString(s)
+	case string:
+		buf.WriteByte('"')
+		for i := 0; i < len(v); i++ {
+			c := v[i]
+			if c < 0x20 || c > 0x7e {
+				return errors.New("sfv: invalid character in string")
+			}
+			if c == '"' || c == '\\' {
+				buf.WriteByte('\\')
+			}
+			buf.WriteByte(c)
+		}
+		buf.WriteByte('"')
+	case Token:
+		buf.WriteString(string(v))
+	case []byte:
+		buf.WriteByte(':')
+		buf.WriteString(base64.StdEncoding.EncodeToString(v))
+		buf.WriteByte(':')
+	case bool:
+		if v {
+			buf.WriteString("?1")
+		} else {
+			buf.WriteString("?0")
+		}
+	default:
+		return fmt.Errorf("sfv: unexpected bare item type: %T", value)
+	}
+	return nil
+}
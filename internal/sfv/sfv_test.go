@@ -0,0 +1,79 @@
+package sfv
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseDictionary(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{
+			in:   `sig1=("@method" "@authority");created=1618884473;keyid="test-key", sig2=:dGVzdA==:`,
+			want: `sig1=("@method" "@authority");created=1618884473;keyid="test-key", sig2=:dGVzdA==:`,
+		},
+		{
+			in:   `a=?0, b, c;foo=bar`,
+			want: `a=?0, b=?1, c=?1;foo=bar`,
+		},
+		{
+			in:   `a=1.5,   b=-42;x="esc\"aped"`,
+			want: `a=1.5, b=-42;x="esc\"aped"`,
+		},
+		{
+			in:   `a=(1 2), a=3`,
+			want: `a=(1 2), a=3`,
+		},
+	}
+	for _, tt := range tests {
+		members, err := ParseDictionary(tt.in)
+		if err != nil {
+			t.Errorf("%q: %v", tt.in, err)
+			continue
+		}
+		var buf strings.Builder
+		for i, m := range members {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(m.Key)
+			buf.WriteByte('=')
+			if err := SerializeMember(&buf, m.Value); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if buf.String() != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.in, buf.String(), tt.want)
+		}
+	}
+
+	// the last member wins.
+	members, err := ParseDictionary(`a=(1 2), a=3`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, ok := LookupMember(members, "a")
+	if !ok || v.(Item).Value != int64(3) {
+		t.Errorf("unexpected member: %v", v)
+	}
+}
+
+func TestParseDictionary_Invalid(t *testing.T) {
+	tests := []string{
+		`A=1`,
+		`a=1,`,
+		`a=1 b=2`,
+		`a="unterminated`,
+		`a=:invalid base64:`,
+		`a=(1 2`,
+		`a=?2`,
+		`a=1234567890123456`,
+	}
+	for _, in := range tests {
+		if _, err := ParseDictionary(in); err == nil {
+			t.Errorf("%q: want error, but not", in)
+		}
+	}
+}
@@ -2,8 +2,11 @@
 package ed25519
 
 import (
+	"context"
+	"crypto"
 	"crypto/ed25519"
 
+	"github.com/shogo82148/goat"
 	"github.com/shogo82148/goat/jwa"
 	"github.com/shogo82148/goat/jwk/jwktypes"
 	"github.com/shogo82148/goat/sig"
@@ -30,6 +33,15 @@ func (alg *algorithm) NewSigningKey(key sig.Key) sig.SigningKey {
 	}
 	if key, ok := priv.(ed25519.PrivateKey); ok {
 		k.priv = key
+	} else if signer, ok := priv.(crypto.Signer); ok {
+		key, ok := signer.Public().(ed25519.PublicKey)
+		if !ok {
+			return sig.NewInvalidKey("ed25519", priv, pub)
+		}
+		k.signer = signer
+		if pub == nil {
+			k.pub = key
+		}
 	} else if priv != nil {
 		return sig.NewInvalidKey("ed25519", priv, pub)
 	}
@@ -41,19 +53,34 @@ func (alg *algorithm) NewSigningKey(key sig.Key) sig.SigningKey {
 	return k
 }
 
+var _ sig.ContextSigningKey = (*signingKey)(nil)
+var _ sig.IdentifiableSigningKey = (*signingKey)(nil)
+
 type signingKey struct {
 	priv      ed25519.PrivateKey
+	signer    crypto.Signer
 	pub       ed25519.PublicKey
 	canSign   bool
 	canVerify bool
 }
 
 func (key *signingKey) Sign(payload []byte) (signature []byte, err error) {
-	if key.priv == nil || !key.canSign {
+	return key.SignContext(context.Background(), payload)
+}
+
+// SignContext implements [github.com/shogo82148/goat/sig.ContextSigningKey].
+func (key *signingKey) SignContext(ctx context.Context, payload []byte) (signature []byte, err error) {
+	if !key.canSign {
 		return nil, sig.ErrSignUnavailable
 	}
-	signature = ed25519.Sign(key.priv, payload)
-	return
+	switch {
+	case key.priv != nil:
+		return ed25519.Sign(key.priv, payload), nil
+	case key.signer != nil:
+		// ed25519 signs the message itself, not its digest.
+		return sig.SignDigest(ctx, key.signer, payload, crypto.Hash(0))
+	}
+	return nil, sig.ErrSignUnavailable
 }
 
 func (key *signingKey) Verify(payload, signature []byte) error {
@@ -65,3 +92,11 @@ func (key *signingKey) Verify(payload, signature []byte) error {
 	}
 	return nil
 }
+
+// PublicKey implements [github.com/shogo82148/goat/sig.IdentifiableSigningKey].
+func (key *signingKey) PublicKey() goat.PublicKey {
+	if key.pub == nil {
+		return nil
+	}
+	return key.pub
+}
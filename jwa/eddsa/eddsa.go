@@ -4,8 +4,11 @@
 package eddsa
 
 import (
+	"context"
+	"crypto"
 	"crypto/ed25519"
 
+	"github.com/shogo82148/goat"
 	"github.com/shogo82148/goat/ed448"
 	"github.com/shogo82148/goat/jwa"
 	"github.com/shogo82148/goat/jwk/jwktypes"
@@ -54,6 +57,25 @@ func (alg *algorithm) NewSigningKey(key sig.Key) sig.SigningKey {
 			canSign:   canSign,
 			canVerify: canVerify,
 		}
+	case crypto.Signer:
+		switch pubkey := priv.Public().(type) {
+		case ed25519.PublicKey:
+			return &ed25519Key{
+				signer:    priv,
+				pub:       pubkey,
+				canSign:   canSign,
+				canVerify: canVerify,
+			}
+		case ed448.PublicKey:
+			return &ed448Key{
+				signer:    priv,
+				pub:       pubkey,
+				canSign:   canSign,
+				canVerify: canVerify,
+			}
+		default:
+			return sig.NewInvalidKey("eddsa", priv, pub)
+		}
 	case nil:
 		switch pub := pub.(type) {
 		case ed25519.PublicKey:
@@ -76,19 +98,34 @@ func (alg *algorithm) NewSigningKey(key sig.Key) sig.SigningKey {
 	}
 }
 
+var _ sig.ContextSigningKey = (*ed25519Key)(nil)
+var _ sig.IdentifiableSigningKey = (*ed25519Key)(nil)
+
 type ed25519Key struct {
 	priv      ed25519.PrivateKey
+	signer    crypto.Signer
 	pub       ed25519.PublicKey
 	canSign   bool
 	canVerify bool
 }
 
 func (key *ed25519Key) Sign(payload []byte) (signature []byte, err error) {
+	return key.SignContext(context.Background(), payload)
+}
+
+// SignContext implements [github.com/shogo82148/goat/sig.ContextSigningKey].
+func (key *ed25519Key) SignContext(ctx context.Context, payload []byte) (signature []byte, err error) {
 	if !key.canSign {
 		return nil, sig.ErrSignUnavailable
 	}
-	signature = ed25519.Sign(key.priv, payload)
-	return
+	switch {
+	case key.priv != nil:
+		return ed25519.Sign(key.priv, payload), nil
+	case key.signer != nil:
+		// ed25519 signs the message itself, not its digest.
+		return sig.SignDigest(ctx, key.signer, payload, crypto.Hash(0))
+	}
+	return nil, sig.ErrSignUnavailable
 }
 
 func (key *ed25519Key) Verify(payload, signature []byte) error {
@@ -101,19 +138,42 @@ func (key *ed25519Key) Verify(payload, signature []byte) error {
 	return nil
 }
 
+// PublicKey implements [github.com/shogo82148/goat/sig.IdentifiableSigningKey].
+func (key *ed25519Key) PublicKey() goat.PublicKey {
+	if key.pub == nil {
+		return nil
+	}
+	return key.pub
+}
+
+var _ sig.ContextSigningKey = (*ed448Key)(nil)
+var _ sig.IdentifiableSigningKey = (*ed448Key)(nil)
+
 type ed448Key struct {
 	priv      ed448.PrivateKey
+	signer    crypto.Signer
 	pub       ed448.PublicKey
 	canSign   bool
 	canVerify bool
 }
 
 func (key *ed448Key) Sign(payload []byte) (signature []byte, err error) {
+	return key.SignContext(context.Background(), payload)
+}
+
+// SignContext implements [github.com/shogo82148/goat/sig.ContextSigningKey].
+func (key *ed448Key) SignContext(ctx context.Context, payload []byte) (signature []byte, err error) {
 	if !key.canSign {
 		return nil, sig.ErrSignUnavailable
 	}
-	signature = ed448.Sign(key.priv, payload)
-	return
+	switch {
+	case key.priv != nil:
+		return ed448.Sign(key.priv, payload), nil
+	case key.signer != nil:
+		// ed448 signs the message itself, not its digest.
+		return sig.SignDigest(ctx, key.signer, payload, crypto.Hash(0))
+	}
+	return nil, sig.ErrSignUnavailable
 }
 
 func (key *ed448Key) Verify(payload, signature []byte) error {
@@ -125,3 +185,11 @@ func (key *ed448Key) Verify(payload, signature []byte) error {
 	}
 	return nil
 }
+
+// PublicKey implements [github.com/shogo82148/goat/sig.IdentifiableSigningKey].
+func (key *ed448Key) PublicKey() goat.PublicKey {
+	if key.pub == nil {
+		return nil
+	}
+	return key.pub
+}
@@ -1,11 +1,15 @@
 package eddsa
 
 import (
+	"crypto"
 	"crypto/ed25519"
+	"crypto/rand"
 	"crypto/subtle"
 	"testing"
 
 	"github.com/shogo82148/goat"
+	"github.com/shogo82148/goat/ed448"
+	"github.com/shogo82148/goat/sig"
 )
 
 type rawKey struct {
@@ -69,3 +73,46 @@ func TestVerify(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// opaqueSigner hides the private key, so the signing key signs through crypto.Signer.
+type opaqueSigner struct {
+	crypto.Signer
+}
+
+// TestSigner checks that the message itself is passed to crypto.Signer with crypto.Hash(0),
+// because EdDSA doesn't sign the digest of the message.
+func TestSigner(t *testing.T) {
+	_, priv25519, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, priv448, err := ed448.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := []byte("payload")
+
+	tests := []struct {
+		name string
+		priv crypto.Signer
+		want []byte
+	}{
+		{"Ed25519", priv25519, ed25519.Sign(priv25519, payload)},
+		{"Ed448", priv448, ed448.Sign(priv448, payload)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := New().NewSigningKey(sig.NewSignerKey(opaqueSigner{tt.priv}))
+			got, err := key.Sign(payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if subtle.ConstantTimeCompare(got, tt.want) == 0 {
+				t.Errorf("signature mismatch: want %#v, got %#v", tt.want, got)
+			}
+			if err := key.Verify(payload, got); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@
 package es
 
 import (
+	"context"
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/elliptic"
@@ -9,8 +10,11 @@ import (
 	"crypto/sha256"
 	_ "crypto/sha512" // for crypto.SHA512
 	"encoding/asn1"
+	"errors"
+	"hash"
 	"math/big"
 
+	"github.com/shogo82148/goat"
 	"github.com/shogo82148/goat/jwa"
 	"github.com/shogo82148/goat/jwk/jwktypes"
 	"github.com/shogo82148/goat/secp256k1"
@@ -76,11 +80,14 @@ type algorithm struct {
 	crv  elliptic.Curve
 }
 
-var _ sig.SigningKey = (*signingKey)(nil)
+var _ sig.StreamingSigningKey = (*signingKey)(nil)
+var _ sig.ContextSigningKey = (*signingKey)(nil)
+var _ sig.IdentifiableSigningKey = (*signingKey)(nil)
 
 type signingKey struct {
 	hash      crypto.Hash
 	priv      *ecdsa.PrivateKey
+	signer    crypto.Signer
 	pub       *ecdsa.PublicKey
 	canSign   bool
 	canVerify bool
@@ -96,8 +103,16 @@ func (alg *algorithm) NewSigningKey(key sig.Key) sig.SigningKey {
 
 	priv := key.PrivateKey()
 	pub := key.PublicKey()
+	var signerPub *ecdsa.PublicKey
 	if key, ok := priv.(*ecdsa.PrivateKey); ok {
 		k.priv = key
+	} else if signer, ok := priv.(crypto.Signer); ok {
+		key, ok := signer.Public().(*ecdsa.PublicKey)
+		if !ok {
+			return sig.NewInvalidKey(alg.alg.String(), priv, pub)
+		}
+		k.signer = signer
+		signerPub = key
 	} else if priv != nil {
 		return sig.NewInvalidKey(alg.alg.String(), priv, pub)
 	}
@@ -106,6 +121,9 @@ func (alg *algorithm) NewSigningKey(key sig.Key) sig.SigningKey {
 	} else if priv != nil {
 		return sig.NewInvalidKey(alg.alg.String(), priv, pub)
 	}
+	if k.signer != nil && k.pub == nil {
+		k.pub = signerPub
+	}
 
 	if k.priv != nil {
 		if k.priv.Curve != alg.crv {
@@ -122,20 +140,88 @@ func (alg *algorithm) NewSigningKey(key sig.Key) sig.SigningKey {
 
 // Sign implements [github.com/shogo82148/goat/sig.Key].
 func (key *signingKey) Sign(payload []byte) (signature []byte, err error) {
+	return key.SignContext(context.Background(), payload)
+}
+
+// SignContext implements [github.com/shogo82148/goat/sig.ContextSigningKey].
+func (key *signingKey) SignContext(ctx context.Context, payload []byte) (signature []byte, err error) {
 	if !key.hash.Available() {
 		return nil, sig.ErrHashUnavailable
 	}
-	if key.priv == nil || !key.canSign {
-		return nil, sig.ErrSignUnavailable
+	s := &stream{
+		key:  key,
+		hash: key.hash.New(),
 	}
-
-	hash := key.hash.New()
-	if _, err := hash.Write(payload); err != nil {
+	if _, err := s.Write(payload); err != nil {
 		return nil, err
 	}
-	sum := hash.Sum(nil)
+	return s.signContext(ctx)
+}
+
+// Verify implements [github.com/shogo82148/goat/sig.Key].
+func (key *signingKey) Verify(payload, signature []byte) error {
+	s, err := key.NewStream()
+	if err != nil {
+		return err
+	}
+	if _, err := s.Write(payload); err != nil {
+		return err
+	}
+	return s.Verify(signature)
+}
+
+// PublicKey implements [github.com/shogo82148/goat/sig.IdentifiableSigningKey].
+func (key *signingKey) PublicKey() goat.PublicKey {
+	if key.pub == nil {
+		return nil
+	}
+	return key.pub
+}
+
+// NewStream implements [github.com/shogo82148/goat/sig.StreamingSigningKey].
+func (key *signingKey) NewStream() (sig.Stream, error) {
+	if !key.hash.Available() {
+		return nil, sig.ErrHashUnavailable
+	}
+	return &stream{
+		key:  key,
+		hash: key.hash.New(),
+	}, nil
+}
+
+type stream struct {
+	key  *signingKey
+	hash hash.Hash
+}
+
+// Write implements [io.Writer].
+func (s *stream) Write(p []byte) (int, error) {
+	return s.hash.Write(p)
+}
+
+// Sign implements [github.com/shogo82148/goat/sig.Stream].
+func (s *stream) Sign() (signature []byte, err error) {
+	return s.signContext(context.Background())
+}
+
+func (s *stream) signContext(ctx context.Context) (signature []byte, err error) {
+	key := s.key
+	if !key.canSign {
+		return nil, sig.ErrSignUnavailable
+	}
+	sum := s.hash.Sum(nil)
+	if key.priv == nil {
+		if key.signer == nil {
+			return nil, sig.ErrSignUnavailable
+		}
+		der, err := sig.SignDigest(ctx, key.signer, sum, key.hash)
+		if err != nil {
+			return nil, err
+		}
+		return asn1ToRaw(der, key.pub.Curve)
+	}
 
-	r, s, err := ecdsa.Sign(rand.Reader, key.priv, sum)
+	r, ss, err := ecdsa.Sign(rand.Reader, key.priv, sum)
 	if err != nil {
 		return nil, err
 	}
@@ -144,15 +230,39 @@ func (key *signingKey) Sign(payload []byte) (signature []byte, err error) {
 
 	ret := make([]byte, 2*size)
 	r.FillBytes(ret[:size])
-	s.FillBytes(ret[size:])
+	ss.FillBytes(ret[size:])
 	return ret, nil
 }
 
-// Verify implements [github.com/shogo82148/goat/sig.Key].
-func (key *signingKey) Verify(payload, signature []byte) error {
-	if !key.hash.Available() {
-		return sig.ErrHashUnavailable
+// asn1ToRaw converts the ASN.1 DER encoded ECDSA signature returned by [crypto.Signer]
+// into the JWS signature format: the concatenation of R and S defined in RFC 7518 Section 3.4.
+func asn1ToRaw(der []byte, crv elliptic.Curve) ([]byte, error) {
+	var s struct {
+		R *big.Int
+		S *big.Int
+	}
+	rest, err := asn1.Unmarshal(der, &s)
+	if err != nil {
+		return nil, err
 	}
+	if len(rest) != 0 {
+		return nil, errors.New("es: trailing data after the signature")
+	}
+
+	bits := crv.Params().BitSize
+	size := (bits + 7) / 8
+	if s.R.Sign() <= 0 || s.S.Sign() <= 0 || s.R.BitLen() > bits || s.S.BitLen() > bits {
+		return nil, errors.New("es: invalid signature")
+	}
+	ret := make([]byte, 2*size)
+	s.R.FillBytes(ret[:size])
+	s.S.FillBytes(ret[size:])
+	return ret, nil
+}
+
+// Verify implements [github.com/shogo82148/goat/sig.Stream].
+func (s *stream) Verify(signature []byte) error {
+	key := s.key
 	if key.pub == nil || !key.canVerify {
 		return sig.ErrSignUnavailable
 	}
@@ -162,16 +272,11 @@ func (key *signingKey) Verify(payload, signature []byte) error {
 	if len(signature) != 2*size {
 		return sig.ErrSignatureMismatch
 	}
-
-	hash := key.hash.New()
-	if _, err := hash.Write(payload); err != nil {
-		return err
-	}
-	sum := hash.Sum(nil)
+	sum := s.hash.Sum(nil)
 
 	r := new(big.Int).SetBytes(signature[:size])
-	s := new(big.Int).SetBytes(signature[size:])
-	if !ecdsa.Verify(key.pub, sum, r, s) {
+	ss := new(big.Int).SetBytes(signature[size:])
+	if !ecdsa.Verify(key.pub, sum, r, ss) {
 		return sig.ErrSignatureMismatch
 	}
 	return nil
@@ -208,6 +313,8 @@ func (*algorithmES256K) NewSigningKey(sigKey sig.Key) sig.SigningKey {
 	return k
 }
 
+var _ sig.IdentifiableSigningKey = (*signingKeyES256K)(nil)
+
 type signingKeyES256K struct {
 	priv      *secp256k1.PrivateKey
 	pub       *secp256k1.PublicKey
@@ -268,3 +375,11 @@ func (key *signingKeyES256K) Verify(payload, signature []byte) error {
 	}
 	return nil
 }
+
+// PublicKey implements [github.com/shogo82148/goat/sig.IdentifiableSigningKey].
+func (key *signingKeyES256K) PublicKey() goat.PublicKey {
+	if key.pub == nil {
+		return nil
+	}
+	return key.pub
+}
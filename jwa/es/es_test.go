@@ -1,6 +1,7 @@
 package es
 
 import (
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
@@ -193,3 +194,36 @@ func Test_InvalidCurve(t *testing.T) {
 		}
 	}
 }
+
+// opaqueSigner hides *ecdsa.PrivateKey, so the signing key signs through crypto.Signer.
+type opaqueSigner struct {
+	crypto.Signer
+}
+
+// TestSigner checks that the ASN.1 signatures of crypto.Signer are converted into R || S.
+func TestSigner(t *testing.T) {
+	for i, test := range tests {
+		data, err := hex.DecodeString(test.priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		priv, err := ecdsa.ParseRawPrivateKey(test.curve, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		alg := test.alg()
+		key := alg.NewSigningKey(sig.NewSignerKey(opaqueSigner{priv}))
+		got, err := key.Sign(test.in)
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+			continue
+		}
+
+		// verify with the public key
+		key = alg.NewSigningKey(&rawKey{nil, &priv.PublicKey})
+		if err := key.Verify(test.in, got); err != nil {
+			t.Errorf("test %d: %v", i, err)
+		}
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"crypto"
 	"crypto/hmac"
 	"fmt"
+	"hash"
 
+	"github.com/shogo82148/goat"
 	"github.com/shogo82148/goat/jwa"
 	"github.com/shogo82148/goat/jwk/jwktypes"
 	"github.com/shogo82148/goat/sig"
@@ -108,7 +110,8 @@ type algorithm struct {
 	weak bool
 }
 
-var _ sig.SigningKey = (*signingKey)(nil)
+var _ sig.StreamingSigningKey = (*signingKey)(nil)
+var _ sig.IdentifiableSigningKey = (*signingKey)(nil)
 
 // signingKey is a key for signing.
 type signingKey struct {
@@ -145,29 +148,71 @@ func (alg *algorithm) NewSigningKey(key sig.Key) sig.SigningKey {
 
 // Sign implements [github.com/shogo82148/goat/sig.Key].
 func (key *signingKey) Sign(payload []byte) (signature []byte, err error) {
-	if !key.hash.Available() {
-		return nil, sig.ErrHashUnavailable
-	}
-	if !key.canSign {
-		return nil, sig.ErrSignUnavailable
+	s, err := key.NewStream()
+	if err != nil {
+		return nil, err
 	}
-	mac := hmac.New(key.hash.New, key.key)
-	if _, err := mac.Write(payload); err != nil {
+	if _, err := s.Write(payload); err != nil {
 		return nil, err
 	}
-	return mac.Sum(nil), nil
+	return s.Sign()
 }
 
 // Verify implements [github.com/shogo82148/goat/sig.Key].
 func (key *signingKey) Verify(payload, signature []byte) error {
-	mac := hmac.New(key.hash.New, key.key)
-	if _, err := mac.Write(payload); err != nil {
+	s, err := key.NewStream()
+	if err != nil {
+		return err
+	}
+	if _, err := s.Write(payload); err != nil {
 		return err
 	}
-	if !key.canVerify {
+	return s.Verify(signature)
+}
+
+// PublicKey implements [github.com/shogo82148/goat/sig.IdentifiableSigningKey].
+func (key *signingKey) PublicKey() goat.PublicKey {
+	if key.key == nil {
+		return nil
+	}
+	return key.key
+}
+
+// NewStream implements [github.com/shogo82148/goat/sig.StreamingSigningKey].
+func (key *signingKey) NewStream() (sig.Stream, error) {
+	if !key.hash.Available() {
+		return nil, sig.ErrHashUnavailable
+	}
+	return &stream{
+		key: key,
+		mac: hmac.New(key.hash.New, key.key),
+	}, nil
+}
+
+type stream struct {
+	key *signingKey
+	mac hash.Hash
+}
+
+// Write implements [io.Writer].
+func (s *stream) Write(p []byte) (int, error) {
+	return s.mac.Write(p)
+}
+
+// Sign implements [github.com/shogo82148/goat/sig.Stream].
+func (s *stream) Sign() (signature []byte, err error) {
+	if !s.key.canSign {
+		return nil, sig.ErrSignUnavailable
+	}
+	return s.mac.Sum(nil), nil
+}
+
+// Verify implements [github.com/shogo82148/goat/sig.Stream].
+func (s *stream) Verify(signature []byte) error {
+	if !s.key.canVerify {
 		return sig.ErrSignUnavailable
 	}
-	sum := mac.Sum(nil)
+	sum := s.mac.Sum(nil)
 	if !hmac.Equal(signature, sum) {
 		return sig.ErrSignatureMismatch
 	}
@@ -2,11 +2,14 @@
 package ps
 
 import (
+	"context"
 	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
 	"fmt"
+	"hash"
 
+	"github.com/shogo82148/goat"
 	"github.com/shogo82148/goat/jwa"
 	"github.com/shogo82148/goat/jwk/jwktypes"
 	"github.com/shogo82148/goat/sig"
@@ -104,11 +107,14 @@ type algorithm struct {
 	weak bool
 }
 
-var _ sig.SigningKey = (*signingKey)(nil)
+var _ sig.StreamingSigningKey = (*signingKey)(nil)
+var _ sig.IdentifiableSigningKey = (*signingKey)(nil)
+var _ sig.ContextSigningKey = (*signingKey)(nil)
 
 type signingKey struct {
 	hash       crypto.Hash
 	privateKey *rsa.PrivateKey
+	signer     crypto.Signer
 	publicKey  *rsa.PublicKey
 	canSign    bool
 	canVerify  bool
@@ -124,8 +130,16 @@ func (alg *algorithm) NewSigningKey(key sig.Key) sig.SigningKey {
 		canSign:   jwktypes.CanUseFor(key, jwktypes.KeyOpSign),
 		canVerify: jwktypes.CanUseFor(key, jwktypes.KeyOpVerify),
 	}
+	var signerPub *rsa.PublicKey
 	if key, ok := priv.(*rsa.PrivateKey); ok {
 		k.privateKey = key
+	} else if signer, ok := priv.(crypto.Signer); ok {
+		key, ok := signer.Public().(*rsa.PublicKey)
+		if !ok {
+			return sig.NewInvalidKey(alg.alg.String(), priv, pub)
+		}
+		k.signer = signer
+		signerPub = key
 	} else if priv != nil {
 		return sig.NewInvalidKey(alg.alg.String(), priv, pub)
 	}
@@ -137,6 +151,9 @@ func (alg *algorithm) NewSigningKey(key sig.Key) sig.SigningKey {
 	if k.privateKey != nil && k.publicKey == nil {
 		k.publicKey = &k.privateKey.PublicKey
 	}
+	if k.signer != nil && k.publicKey == nil {
+		k.publicKey = signerPub
+	}
 	if k.publicKey == nil {
 		return sig.NewInvalidKey(alg.alg.String(), priv, pub)
 	}
@@ -150,30 +167,96 @@ func (alg *algorithm) NewSigningKey(key sig.Key) sig.SigningKey {
 
 // Sign implements [github.com/shogo82148/goat/sig.Key].
 func (key *signingKey) Sign(payload []byte) (signature []byte, err error) {
+	return key.SignContext(context.Background(), payload)
+}
+
+// SignContext implements [github.com/shogo82148/goat/sig.ContextSigningKey].
+func (key *signingKey) SignContext(ctx context.Context, payload []byte) (signature []byte, err error) {
 	if !key.hash.Available() {
 		return nil, sig.ErrHashUnavailable
 	}
-	if key.privateKey == nil || !key.canSign {
-		return nil, sig.ErrSignUnavailable
+	s := &stream{
+		key:  key,
+		hash: key.hash.New(),
 	}
-	hash := key.hash.New()
-	if _, err := hash.Write(payload); err != nil {
+	if _, err := s.Write(payload); err != nil {
 		return nil, err
 	}
-	return rsa.SignPSS(rand.Reader, key.privateKey, key.hash, hash.Sum(nil), nil)
+	return s.signContext(ctx)
 }
 
 // Verify implements [github.com/shogo82148/goat/sig.Key].
 func (key *signingKey) Verify(payload, signature []byte) error {
+	s, err := key.NewStream()
+	if err != nil {
+		return err
+	}
+	if _, err := s.Write(payload); err != nil {
+		return err
+	}
+	return s.Verify(signature)
+}
+
+// PublicKey implements [github.com/shogo82148/goat/sig.IdentifiableSigningKey].
+func (key *signingKey) PublicKey() goat.PublicKey {
+	if key.publicKey == nil {
+		return nil
+	}
+	return key.publicKey
+}
+
+// NewStream implements [github.com/shogo82148/goat/sig.StreamingSigningKey].
+func (key *signingKey) NewStream() (sig.Stream, error) {
 	if !key.hash.Available() {
-		return sig.ErrHashUnavailable
+		return nil, sig.ErrHashUnavailable
 	}
-	if !key.canVerify {
-		return sig.ErrSignUnavailable
+	return &stream{
+		key:  key,
+		hash: key.hash.New(),
+	}, nil
+}
+
+type stream struct {
+	key  *signingKey
+	hash hash.Hash
+}
+
+// Write implements [io.Writer].
+func (s *stream) Write(p []byte) (int, error) {
+	return s.hash.Write(p)
+}
+
+// Sign implements [github.com/shogo82148/goat/sig.Stream].
+func (s *stream) Sign() (signature []byte, err error) {
+	return s.signContext(context.Background())
+}
+
+func (s *stream) signContext(ctx context.Context) (signature []byte, err error) {
+	key := s.key
+	if !key.canSign {
+		return nil, sig.ErrSignUnavailable
 	}
-	hash := key.hash.New()
-	if _, err := hash.Write(payload); err != nil {
-		return err
+	digest := s.hash.Sum(nil)
+
+	// RFC 7518 Section 3.5:
+	// > The size of the salt value is the same size as the hash function output.
+	opts := &rsa.PSSOptions{
+		SaltLength: rsa.PSSSaltLengthEqualsHash,
+		Hash:       key.hash,
+	}
+	switch {
+	case key.privateKey != nil:
+		return rsa.SignPSS(rand.Reader, key.privateKey, key.hash, digest, opts)
+	case key.signer != nil:
+		return sig.SignDigest(ctx, key.signer, digest, opts)
+	}
+	return nil, sig.ErrSignUnavailable
+}
+
+// Verify implements [github.com/shogo82148/goat/sig.Stream].
+func (s *stream) Verify(signature []byte) error {
+	if !s.key.canVerify {
+		return sig.ErrSignUnavailable
 	}
-	return rsa.VerifyPSS(key.publicKey, key.hash, hash.Sum(nil), signature, nil)
+	return rsa.VerifyPSS(s.key.publicKey, s.key.hash, s.hash.Sum(nil), signature, nil)
 }
@@ -1,6 +1,7 @@
 package ps
 
 import (
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
 	"math/big"
@@ -137,6 +138,25 @@ func TestSignAndVerify(t *testing.T) {
 	}
 }
 
+func TestSaltLength(t *testing.T) {
+	test := tests[0]
+	key := New256().NewSigningKey(&rawKey{test.priv, test.pub})
+	got, err := key.Sign(test.in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// RFC 7518 Section 3.5: the salt length is the same as the hash length.
+	digest := crypto.SHA256.New()
+	digest.Write(test.in)
+	opts := &rsa.PSSOptions{
+		SaltLength: rsa.PSSSaltLengthEqualsHash,
+	}
+	if err := rsa.VerifyPSS(test.pub, crypto.SHA256, digest.Sum(nil), got, opts); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestWeakKeys(t *testing.T) {
 	rsakey, err := rsa.GenerateKey(rand.Reader, 2047)
 	if err != nil {
@@ -311,6 +311,35 @@ func (h *Header) SetPBES2Count(p2c int) {
 	h.p2c = p2c
 }
 
+// Get returns the header parameter name as T.
+// It is useful for the parameters that have no dedicated accessors.
+// If the value is not T, it is converted through JSON encoding,
+// so T can be a numeric type or a struct.
+// It returns false if the parameter is missing or can't be converted to T.
+func Get[T any](h *Header, name string) (T, bool) {
+	var zero T
+	if h == nil {
+		return zero, false
+	}
+	v, ok := h.Raw[name]
+	if !ok {
+		return zero, false
+	}
+	return jsonutils.Convert[T](v)
+}
+
+// Set sets the header parameter name to value.
+// value must be able to be encoded by the json package.
+// It is encoded into the JSON header, and it is integrity protected if h is the protected header.
+// The registered parameters such as "alg" should be set by their dedicated setters,
+// which take precedence over the value set by Set.
+func (h *Header) Set(name string, value any) {
+	if h.Raw == nil {
+		h.Raw = map[string]any{}
+	}
+	h.Raw[name] = value
+}
+
 func (h *Header) MarshalJSON() ([]byte, error) {
 	raw, err := encodeHeader(h)
 	if err != nil {
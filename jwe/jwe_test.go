@@ -648,6 +648,54 @@ func TestNewMessage(t *testing.T) {
 	})
 }
 
+func TestHeader_Custom(t *testing.T) {
+	header := &Header{}
+	header.SetAlgorithm(jwa.KeyManagementAlgorithmA128KW)
+	header.Set("tenant", "example")
+	header.Set("nonce", 42)
+	msg1, err := NewMessage(jwa.EncryptionAlgorithmA128GCM, header, []byte("Hello JWE!"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rawKey := `{` +
+		`"k": "5zDzOzDfceBkTJHEec_s0g",` +
+		`"kty": "oct"` +
+		`}`
+	k, err := jwk.ParseKey([]byte(rawKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	alg := header.Algorithm().New()
+	if err = msg1.Encrypt(alg.NewKeyWrapper(k), nil); err != nil {
+		t.Fatal(err)
+	}
+	data, err := msg1.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg2, err := ParseJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = msg2.Decrypt(FindKeyWrapperFunc(func(protected, unprotected, recipient *Header) (wrapper keymanage.KeyWrapper, err error) {
+		if tenant, ok := Get[string](protected, "tenant"); !ok || tenant != "example" {
+			t.Errorf("unexpected tenant: %q", tenant)
+		}
+		if nonce, ok := Get[int](protected, "nonce"); !ok || nonce != 42 {
+			t.Errorf("unexpected nonce: %d", nonce)
+		}
+		if _, ok := Get[string](unprotected, "tenant"); ok {
+			t.Error("want false, got true")
+		}
+		return alg.NewKeyWrapper(k), nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestParseJSON(t *testing.T) {
 	raw := `{` +
 		`"protected":` +
@@ -61,11 +61,21 @@ type keyOps interface {
 	KeyOperations() []KeyOp
 }
 
+// keyOpsChecker is implemented by the keys that represent the key operations
+// in another format, e.g. the "key_ops" parameter of COSE_Key.
+type keyOpsChecker interface {
+	CanUseFor(op KeyOp) bool
+}
+
 func CanUseFor(key any, op KeyOp) bool {
 	return checkKeyOps(key, op) && checkKeyUse(key, op)
 }
 
 func checkKeyOps(key any, op KeyOp) bool {
+	if checker, ok := key.(keyOpsChecker); ok {
+		return checker.CanUseFor(op)
+	}
+
 	getter, ok := key.(keyOps)
 	if !ok {
 		return true
@@ -3,6 +3,7 @@ package jws
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha1"
 	"crypto/sha256"
 	"crypto/subtle"
@@ -25,6 +26,8 @@ import (
 // shorthand for base64.RawURLEncoding
 var b64 = base64.RawURLEncoding
 
+// knownParams is the list of header parameters defined in RFC 7515 and RFC 7797.
+// The parameters defined in RFC 7515 must not be appeared in the "crit" header parameter.
 var knownParams = [...]string{
 	jwa.AlgorithmKey,
 	jwa.JWKSetURLKey,
@@ -35,6 +38,7 @@ var knownParams = [...]string{
 	jwa.X509CertificateSHA1Thumbprint,
 	jwa.X509CertificateSHA256Thumbprint,
 	jwa.TypeKey,
+	jwa.ContentTypeKey,
 	jwa.CriticalKey,
 	jwa.Base64URLEncodePayloadKey,
 }
@@ -203,6 +207,36 @@ func (h *Header) SetBase64(b64 bool) {
 	}
 }
 
+// Get returns the header parameter name as T.
+// It is useful for the parameters that have no dedicated accessors,
+// such as "ppt" defined in RFC 8225 and "nonce" defined in RFC 8555.
+// If the value is not T, it is converted through JSON encoding,
+// so T can be a numeric type or a struct.
+// It returns false if the parameter is missing or can't be converted to T.
+func Get[T any](h *Header, name string) (T, bool) {
+	var zero T
+	if h == nil {
+		return zero, false
+	}
+	v, ok := h.Raw[name]
+	if !ok {
+		return zero, false
+	}
+	return jsonutils.Convert[T](v)
+}
+
+// Set sets the header parameter name to value.
+// value must be able to be encoded by the json package.
+// It is encoded into the JSON header and signed if h is the protected header.
+// The registered parameters such as "alg" should be set by their dedicated setters,
+// which take precedence over the value set by Set.
+func (h *Header) Set(name string, value any) {
+	if h.Raw == nil {
+		h.Raw = map[string]any{}
+	}
+	h.Raw[name] = value
+}
+
 func (h *Header) UnmarshalJSON(data []byte) error {
 	var raw map[string]any
 	dec := json.NewDecoder(bytes.NewReader(data))
@@ -248,6 +282,42 @@ type Message struct {
 
 	payload []byte
 	nb64    bool // nb64 is !b64
+	format  Format
+}
+
+// Format is a serialization format of JWS.
+type Format int
+
+const (
+	// FormatUnknown means the message is not parsed, e.g. it is created by [NewMessage].
+	FormatUnknown Format = iota
+
+	// FormatCompact is JWS Compact Serialization defined in RFC 7515 Section 7.1.
+	FormatCompact
+
+	// FormatGeneralJSON is General JWS JSON Serialization Syntax defined in RFC 7515 Section 7.2.1.
+	FormatGeneralJSON
+
+	// FormatFlattenedJSON is Flattened JWS JSON Serialization Syntax defined in RFC 7515 Section 7.2.2.
+	FormatFlattenedJSON
+)
+
+// String implements [fmt.Stringer].
+func (f Format) String() string {
+	switch f {
+	case FormatCompact:
+		return "compact"
+	case FormatGeneralJSON:
+		return "general JSON"
+	case FormatFlattenedJSON:
+		return "flattened JSON"
+	}
+	return "unknown"
+}
+
+// Format returns the serialization format that msg is parsed from.
+func (msg *Message) Format() Format {
+	return msg.format
 }
 
 // Signature is a signature of Message.
@@ -259,6 +329,16 @@ type Signature struct {
 	signature    []byte
 }
 
+// Protected returns the protected header of the signature.
+func (sig *Signature) Protected() *Header {
+	return sig.protected
+}
+
+// Unprotected returns the unprotected header of the signature.
+func (sig *Signature) Unprotected() *Header {
+	return sig.header
+}
+
 // ParseCompact parses a Compact Serialized JWS Signature.
 func ParseCompact(data []byte) (*Message, error) {
 	// copy data
@@ -297,6 +377,7 @@ func ParseCompact(data []byte) (*Message, error) {
 	return &Message{
 		payload: payload,
 		nb64:    h.nb64,
+		format:  FormatCompact,
 		Signatures: []*Signature{
 			{
 				protected:    &h,
@@ -308,6 +389,45 @@ func ParseCompact(data []byte) (*Message, error) {
 	}, nil
 }
 
+// ParseCompactDetached parses a Compact Serialized JWS Signature with detached content
+// described in RFC 7515 Appendix F.
+// The payload part of data must be empty, and payload is the detached content.
+func ParseCompactDetached(data, payload []byte) (*Message, error) {
+	msg, err := ParseCompact(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(msg.payload) != 0 {
+		return nil, errors.New("jws: failed to parse JWS: the payload is not detached")
+	}
+	msg.attach(payload)
+	return msg, nil
+}
+
+// ParseDetached parses a JSON Serialized JWS Signature with detached content
+// described in RFC 7515 Appendix F.
+// data must not have the "payload" member, and payload is the detached content.
+func ParseDetached(data, payload []byte) (*Message, error) {
+	msg, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if msg.payload != nil {
+		return nil, errors.New("jws: failed to parse JWS: the payload is not detached")
+	}
+	msg.attach(payload)
+	return msg, nil
+}
+
+// attach sets the detached content to msg.
+func (msg *Message) attach(payload []byte) {
+	if msg.nb64 {
+		msg.payload = append([]byte(nil), payload...)
+	} else {
+		msg.payload = b64Encode(payload)
+	}
+}
+
 func Parse(data []byte) (*Message, error) {
 	var msg Message
 	if err := msg.UnmarshalJSON(data); err != nil {
@@ -347,7 +467,9 @@ func (msg *Message) UnmarshalJSON(data []byte) error {
 		return errors.New("jws: failed to parse JWS: neither signatures nor signature are set")
 	}
 
+	m.format = FormatGeneralJSON
 	if flattened {
+		m.format = FormatFlattenedJSON
 		sigs := map[string]any{
 			"signature": sigAny,
 		}
@@ -409,6 +531,14 @@ func (msg *Message) UnmarshalJSON(data []byte) error {
 				return fmt.Errorf("jws: failed to parse header: %w", err)
 			}
 			sig.header = header
+
+			// RFC 7515 Section 7.2.1:
+			// The Header Parameter names in the two locations MUST be disjoint.
+			if sig.protected != nil {
+				if err := checkDisjoint(sig.protected.Raw, header.Raw); err != nil {
+					return err
+				}
+			}
 		}
 
 		// decode signature
@@ -435,33 +565,85 @@ func (msg *Message) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// MarshalJSON implements [encoding/json.Marshaler].
+// It encodes msg into Flattened JWS JSON Serialization if msg has only one signature,
+// unless msg is parsed from General JWS JSON Serialization.
+// Otherwise, it encodes msg into General JWS JSON Serialization.
 func (msg *Message) MarshalJSON() ([]byte, error) {
-	raw := map[string]any{
-		"payload": string(msg.payload),
+	return msg.marshalJSON(msg.jsonFormat(), false)
+}
+
+// MarshalJSONDetached encodes msg into JSON Serialization with detached content
+// described in RFC 7515 Appendix F.
+// The "payload" member is omitted, so the content must be transferred by other means.
+func (msg *Message) MarshalJSONDetached() ([]byte, error) {
+	return msg.marshalJSON(msg.jsonFormat(), true)
+}
+
+// MarshalGeneralJSON encodes msg into General JWS JSON Serialization defined in RFC 7515 Section 7.2.1.
+func (msg *Message) MarshalGeneralJSON() ([]byte, error) {
+	return msg.marshalJSON(FormatGeneralJSON, false)
+}
+
+// MarshalFlattenedJSON encodes msg into Flattened JWS JSON Serialization defined in RFC 7515 Section 7.2.2.
+// msg must have exactly one signature.
+func (msg *Message) MarshalFlattenedJSON() ([]byte, error) {
+	return msg.marshalJSON(FormatFlattenedJSON, false)
+}
+
+func (msg *Message) jsonFormat() Format {
+	if len(msg.Signatures) == 1 && msg.format != FormatGeneralJSON {
+		return FormatFlattenedJSON
 	}
-	if len(msg.Signatures) == 1 {
-		// Flattened JWS JSON Serialization
-		sig := msg.Signatures[0]
-		raw["protected"] = string(sig.rawProtected)
-		raw["signature"] = string(sig.b64signature)
-	} else {
-		// Complete JWS JSON Serialization Representation
+	return FormatGeneralJSON
+}
+
+func (msg *Message) marshalJSON(format Format, detached bool) ([]byte, error) {
+	raw := map[string]any{}
+	if !detached {
+		raw["payload"] = string(msg.payload)
+	}
+	switch format {
+	case FormatFlattenedJSON:
+		if len(msg.Signatures) != 1 {
+			return nil, fmt.Errorf("jws: invalid number of signatures: %d", len(msg.Signatures))
+		}
+		encodeSignature(raw, msg.Signatures[0])
+	case FormatGeneralJSON:
 		signatures := make([]any, 0, len(msg.Signatures))
 		for _, sig := range msg.Signatures {
-			raw := map[string]any{
-				"protected": string(sig.rawProtected),
-				"signature": string(sig.b64signature),
-			}
-			if sig.header != nil {
-				raw["header"] = sig.header
-			}
+			raw := map[string]any{}
+			encodeSignature(raw, sig)
 			signatures = append(signatures, raw)
 		}
 		raw["signatures"] = signatures
+	default:
+		return nil, fmt.Errorf("jws: unsupported format: %s", format)
 	}
 	return json.Marshal(raw)
 }
 
+// encodeSignature sets the members of sig to raw.
+func encodeSignature(raw map[string]any, sig *Signature) {
+	if len(sig.rawProtected) > 0 {
+		raw["protected"] = string(sig.rawProtected)
+	}
+	if sig.header != nil {
+		raw["header"] = sig.header
+	}
+	raw["signature"] = string(sig.b64signature)
+}
+
+// checkDisjoint checks that the Header Parameter names in the protected header and the unprotected header are disjoint.
+func checkDisjoint(protected, unprotected map[string]any) error {
+	for name := range unprotected {
+		if _, ok := protected[name]; ok {
+			return fmt.Errorf("jws: header parameter %q is in both the protected header and the unprotected header", name)
+		}
+	}
+	return nil
+}
+
 func decodeHeader(raw map[string]any) (*Header, error) {
 	d := jsonutils.NewDecoder("jws", raw)
 	h := &Header{
@@ -536,16 +718,8 @@ func decodeHeader(raw map[string]any) (*Header, error) {
 		h.nb64 = !b64
 	}
 
-	// verify critical parameter
-CRIT_LOOP:
-	for _, param1 := range h.crit {
-		for _, param2 := range knownParams {
-			if param1 == param2 {
-				continue CRIT_LOOP
-			}
-		}
-		d.SaveError(fmt.Errorf("jws: unknown parameter is in crit: %q", param1))
-	}
+	// the critical parameters are verified by Verifier,
+	// because the understood extensions depend on the application.
 
 	if err := d.Err(); err != nil {
 		return nil, err
@@ -553,6 +727,61 @@ CRIT_LOOP:
 	return h, nil
 }
 
+// lookupAlgorithm returns the algorithm from the protected header or the unprotected header.
+func lookupAlgorithm(protected, unprotected *Header) jwa.SignatureAlgorithm {
+	if protected != nil && protected.alg != jwa.SignatureAlgorithmUnknown {
+		return protected.alg
+	}
+	if unprotected != nil {
+		return unprotected.alg
+	}
+	return jwa.SignatureAlgorithmUnknown
+}
+
+// lookupKeyID returns the key ID from the protected header or the unprotected header.
+func lookupKeyID(protected, unprotected *Header) string {
+	if protected != nil && protected.kid != "" {
+		return protected.kid
+	}
+	if unprotected != nil {
+		return unprotected.kid
+	}
+	return ""
+}
+
+// lookupX509CertificateChain returns the X.509 certificate chain from the protected header or the unprotected header.
+func lookupX509CertificateChain(protected, unprotected *Header) []*x509.Certificate {
+	if protected != nil && protected.x5c != nil {
+		return protected.x5c
+	}
+	if unprotected != nil {
+		return unprotected.x5c
+	}
+	return nil
+}
+
+// lookupX509CertificateSHA1 returns the X.509 certificate SHA-1 thumbprint from the protected header or the unprotected header.
+func lookupX509CertificateSHA1(protected, unprotected *Header) []byte {
+	if protected != nil && protected.x5t != nil {
+		return protected.x5t
+	}
+	if unprotected != nil {
+		return unprotected.x5t
+	}
+	return nil
+}
+
+// lookupX509CertificateSHA256 returns the X.509 certificate SHA-256 thumbprint from the protected header or the unprotected header.
+func lookupX509CertificateSHA256(protected, unprotected *Header) []byte {
+	if protected != nil && protected.x5tS256 != nil {
+		return protected.x5tS256
+	}
+	if unprotected != nil {
+		return unprotected.x5tS256
+	}
+	return nil
+}
+
 func encodeHeader(h *Header) (map[string]any, error) {
 	if h == nil {
 		return nil, nil
@@ -631,6 +860,12 @@ func encodeHeader(h *Header) (map[string]any, error) {
 
 // Sign adds a new signature signed by key.
 func (msg *Message) Sign(protected, header *Header, key sig.SigningKey) error {
+	return msg.SignContext(context.Background(), protected, header, key)
+}
+
+// SignContext is same as Sign, but ctx is passed to key if it implements [sig.ContextSigningKey],
+// so signing by a remote key can be cancelled and time-limited.
+func (msg *Message) SignContext(ctx context.Context, protected, header *Header, key sig.SigningKey) error {
 	if msg.nb64 != protected.nb64 {
 		return errors.New("jws: failed to sign: b64 is mismatch")
 	}
@@ -646,12 +881,24 @@ func (msg *Message) Sign(protected, header *Header, key sig.SigningKey) error {
 	}
 	raw = b64Encode(raw)
 
+	// RFC 7515 Section 7.2.1:
+	// The Header Parameter names in the two locations MUST be disjoint.
+	if header != nil {
+		h2, err := encodeHeader(header)
+		if err != nil {
+			return err
+		}
+		if err := checkDisjoint(h1, h2); err != nil {
+			return err
+		}
+	}
+
 	// sign
 	buf := make([]byte, 0, len(msg.payload)+len(raw)+1)
 	buf = append(buf, raw...)
 	buf = append(buf, '.')
 	buf = append(buf, msg.payload...)
-	signature, err := key.Sign(buf)
+	signature, err := sig.SignContext(ctx, key, buf)
 	if err != nil {
 		return fmt.Errorf("jws: failed to sign: %w", err)
 	}
@@ -690,6 +937,23 @@ func (msg *Message) Compact() ([]byte, error) {
 	return buf, nil
 }
 
+// CompactDetached encodes JWS Signature into Compact Serialization with detached content
+// described in RFC 7515 Appendix F.
+// The payload is omitted, so the content must be transferred by other means.
+func (msg *Message) CompactDetached() ([]byte, error) {
+	if len(msg.Signatures) != 1 {
+		return nil, fmt.Errorf("jws: invalid number of signatures: %d", len(msg.Signatures))
+	}
+	sig := msg.Signatures[0]
+
+	buf := make([]byte, 0, len(sig.rawProtected)+len(sig.b64signature)+2)
+	buf = append(buf, sig.rawProtected...)
+	buf = append(buf, '.')
+	buf = append(buf, '.')
+	buf = append(buf, sig.b64signature...)
+	return buf, nil
+}
+
 func b64Decode(src []byte) ([]byte, error) {
 	dst := make([]byte, b64.DecodedLen(len(src)))
 	n, err := b64.Decode(dst, src)
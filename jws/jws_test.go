@@ -3,9 +3,16 @@ package jws
 import (
 	"bytes"
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"encoding/json"
 	"errors"
+	"io"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/shogo82148/goat/jwa"
 	_ "github.com/shogo82148/goat/jwa/eddsa" //nolint:staticcheck // for Ed25519
@@ -837,6 +844,226 @@ func TestSign(t *testing.T) {
 	})
 }
 
+func TestHeader_Custom(t *testing.T) {
+	type tenant struct {
+		ID   string `json:"id"`
+		Plan int    `json:"plan"`
+	}
+
+	rawKey := `{"kty":"oct",` +
+		`"k":"AyM1SysPpbyDfgZld3umj1qzKObwVMkoqQ-EstJQLr_T-1qS0gZH75` +
+		`aKtMN3Yj0iPS4hcgUuTwjAzZr1Z9CAow"` +
+		`}`
+	key, err := jwk.ParseKey([]byte(rawKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := jwa.SignatureAlgorithmHS256.New().NewSigningKey(key)
+
+	h := NewHeader()
+	h.SetAlgorithm(jwa.SignatureAlgorithmHS256)
+	h.Set("ppt", "shaken")
+	h.Set("http://openbanking.org.uk/iat", 1300819380)
+	h.Set("tenant", tenant{ID: "example", Plan: 3})
+	msg := NewMessage([]byte("hello"))
+	if err := msg.Sign(h, nil, k); err != nil {
+		t.Fatal(err)
+	}
+	data, err := msg.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseCompact(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &Verifier{
+		AlgorithmVerifier: AllowedAlgorithms{jwa.SignatureAlgorithmHS256},
+		KeyFinder:         &JWKKeyFinder{JWK: key},
+	}
+	protected, _, _, err := v.Verify(t.Context(), parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ppt, ok := Get[string](protected, "ppt"); !ok || ppt != "shaken" {
+		t.Errorf("unexpected ppt: %q", ppt)
+	}
+	if iat, ok := Get[int64](protected, "http://openbanking.org.uk/iat"); !ok || iat != 1300819380 {
+		t.Errorf("unexpected iat: %d", iat)
+	}
+	if got, ok := Get[tenant](protected, "tenant"); !ok || got != (tenant{ID: "example", Plan: 3}) {
+		t.Errorf("unexpected tenant: %v", got)
+	}
+	if _, ok := Get[string](protected, "missing"); ok {
+		t.Error("want false, got true")
+	}
+	if _, ok := Get[int64](protected, "ppt"); ok {
+		t.Error("want false, got true")
+	}
+	if _, ok := Get[string](nil, "ppt"); ok {
+		t.Error("want false, got true")
+	}
+
+	// the custom parameters are signed.
+	tampered := NewHeader()
+	tampered.SetAlgorithm(jwa.SignatureAlgorithmHS256)
+	tampered.Set("ppt", "div")
+	raw, err := tampered.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed.Signatures[0].rawProtected = b64Encode(raw)
+	if _, _, _, err := v.Verify(t.Context(), parsed); err == nil {
+		t.Error("want error, got nil")
+	}
+}
+
+func TestDetached(t *testing.T) {
+	// RFC 7520 Section 4.5. Signature with Detached Content
+	payload := []byte("It\u2019s a dangerous business, Frodo, going out your door. " +
+		"You step onto the road, and if you don't keep your feet, " +
+		"there\u2019s no knowing where you might be swept off to.")
+	rawKey := `{"kty":"oct",` +
+		`"kid":"018c0ae5-4d9b-471b-bfd6-eef314bc7037",` +
+		`"alg":"HS256",` +
+		`"k":"hJtXIZ2uSN5kbQfbtTNWbpdmhkV8FJG-Onbc6mxCcYg"}`
+	key, err := jwk.ParseKey([]byte(rawKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	compact := "eyJhbGciOiJIUzI1NiIsImtpZCI6IjAxOGMwYWU1LTRkOWItNDcxYi1iZmQ2LWVlZjMxNGJjNzAzNyJ9" +
+		"." +
+		"." +
+		"s0h6KThzkfBBBkLspW1h84VsJZFTsPPqMDA7g1Md7p0"
+	flattened := `{"protected":"eyJhbGciOiJIUzI1NiIsImtpZCI6IjAxOGMwYWU1LTRkOWItNDcxYi1iZmQ2LWVlZjMxNGJjNzAzNyJ9",` +
+		`"signature":"s0h6KThzkfBBBkLspW1h84VsJZFTsPPqMDA7g1Md7p0"}`
+	v := &Verifier{
+		AlgorithmVerifier: AllowedAlgorithms{jwa.SignatureAlgorithmHS256},
+		KeyFinder:         &JWKKeyFinder{JWK: key},
+	}
+
+	t.Run("sign", func(t *testing.T) {
+		h := NewHeader()
+		h.SetAlgorithm(jwa.SignatureAlgorithmHS256)
+		h.SetKeyID("018c0ae5-4d9b-471b-bfd6-eef314bc7037")
+		msg := NewMessage(payload)
+		if err := msg.Sign(h, nil, jwa.SignatureAlgorithmHS256.New().NewSigningKey(key)); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := msg.CompactDetached()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != compact {
+			t.Errorf("want %s, got %s", compact, got)
+		}
+
+		got, err = msg.MarshalJSONDetached()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != flattened {
+			t.Errorf("want %s, got %s", flattened, got)
+		}
+	})
+
+	t.Run("compact", func(t *testing.T) {
+		msg, err := ParseCompactDetached([]byte(compact), payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _, got, err := v.Verify(t.Context(), msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, payload) {
+			t.Errorf("unexpected payload: want %q, got %q", payload, got)
+		}
+
+		// the content is tampered.
+		msg, err = ParseCompactDetached([]byte(compact), []byte("tampered"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, _, err := v.Verify(t.Context(), msg); err == nil {
+			t.Error("want error, got nil")
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		msg, err := ParseDetached([]byte(flattened), payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _, got, err := v.Verify(t.Context(), msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, payload) {
+			t.Errorf("unexpected payload: want %q, got %q", payload, got)
+		}
+	})
+
+	t.Run("not detached", func(t *testing.T) {
+		msg := NewMessage(payload)
+		if err := msg.Sign(NewHeader(), nil, jwa.SignatureAlgorithmHS256.New().NewSigningKey(key)); err != nil {
+			t.Fatal(err)
+		}
+		data, err := msg.Compact()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ParseCompactDetached(data, payload); err == nil {
+			t.Error("want error, got nil")
+		}
+		data, err = msg.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ParseDetached(data, payload); err == nil {
+			t.Error("want error, got nil")
+		}
+	})
+
+	t.Run("unencoded payload", func(t *testing.T) {
+		h := NewHeader()
+		h.SetAlgorithm(jwa.SignatureAlgorithmHS256)
+		h.SetBase64(false)
+		msg := NewRawMessage([]byte("$02"))
+		if err := msg.Sign(h, nil, jwa.SignatureAlgorithmHS256.New().NewSigningKey(key)); err != nil {
+			t.Fatal(err)
+		}
+		data, err := msg.CompactDetached()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parsed, err := ParseCompactDetached(data, []byte("$02"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _, got, err := v.Verify(t.Context(), parsed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != "$02" {
+			t.Errorf("unexpected payload: %q", got)
+		}
+
+		// VerifyContent also supports unencoded payloads.
+		parsed, err = ParseCompact(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, _, err := v.VerifyContent(t.Context(), parsed, []byte("$02")); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
 func TestKeyTypeMissmatch(t *testing.T) {
 	ctx := t.Context()
 
@@ -879,3 +1106,205 @@ func TestKeyTypeMissmatch(t *testing.T) {
 		t.Error("want error, got nil")
 	}
 }
+
+func TestMessage_Format(t *testing.T) {
+	rawKey := `{"kty":"oct",` +
+		`"k":"hJtXIZ2uSN5kbQfbtTNWbpdmhkV8FJG-Onbc6mxCcYg"}`
+	key, err := jwk.ParseKey([]byte(rawKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingKey := jwa.SignatureAlgorithmHS256.New().NewSigningKey(key)
+
+	protected := NewHeader()
+	protected.SetAlgorithm(jwa.SignatureAlgorithmHS256)
+	unprotected := NewHeader()
+	unprotected.SetKeyID("hmac")
+	msg := NewMessage([]byte("hello"))
+	if msg.Format() != FormatUnknown {
+		t.Errorf("unexpected format: %s", msg.Format())
+	}
+	if err := msg.Sign(protected, unprotected, signingKey); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("flattened", func(t *testing.T) {
+		data, err := msg.MarshalFlattenedJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Parse(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Format() != FormatFlattenedJSON {
+			t.Errorf("unexpected format: %s", got.Format())
+		}
+		if kid := got.Signatures[0].Unprotected().KeyID(); kid != "hmac" {
+			t.Errorf("unexpected kid: %q", kid)
+		}
+
+		// MarshalJSON keeps the format.
+		data2, err := got.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != string(data2) {
+			t.Errorf("want %s, got %s", data, data2)
+		}
+	})
+
+	t.Run("general", func(t *testing.T) {
+		data, err := msg.MarshalGeneralJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Parse(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Format() != FormatGeneralJSON {
+			t.Errorf("unexpected format: %s", got.Format())
+		}
+		if kid := got.Signatures[0].Unprotected().KeyID(); kid != "hmac" {
+			t.Errorf("unexpected kid: %q", kid)
+		}
+
+		// MarshalJSON keeps the format.
+		data2, err := got.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != string(data2) {
+			t.Errorf("want %s, got %s", data, data2)
+		}
+	})
+
+	t.Run("compact", func(t *testing.T) {
+		msg := NewMessage([]byte("hello"))
+		if err := msg.Sign(protected, nil, signingKey); err != nil {
+			t.Fatal(err)
+		}
+		data, err := msg.Compact()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ParseCompact(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Format() != FormatCompact {
+			t.Errorf("unexpected format: %s", got.Format())
+		}
+	})
+
+	t.Run("flattened with multiple signatures", func(t *testing.T) {
+		msg := NewMessage([]byte("hello"))
+		for range 2 {
+			if err := msg.Sign(protected, nil, signingKey); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := msg.MarshalFlattenedJSON(); err == nil {
+			t.Error("want error, got nil")
+		}
+	})
+}
+
+func TestDisjoint(t *testing.T) {
+	rawKey := `{"kty":"oct",` +
+		`"k":"hJtXIZ2uSN5kbQfbtTNWbpdmhkV8FJG-Onbc6mxCcYg"}`
+	key, err := jwk.ParseKey([]byte(rawKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingKey := jwa.SignatureAlgorithmHS256.New().NewSigningKey(key)
+
+	t.Run("sign", func(t *testing.T) {
+		protected := NewHeader()
+		protected.SetAlgorithm(jwa.SignatureAlgorithmHS256)
+		protected.SetKeyID("protected")
+		unprotected := NewHeader()
+		unprotected.SetKeyID("unprotected")
+
+		msg := NewMessage([]byte("hello"))
+		if err := msg.Sign(protected, unprotected, signingKey); err == nil {
+			t.Error("want error, got nil")
+		}
+		if _, err := SignReader(protected, unprotected, strings.NewReader("hello"), signingKey); err == nil {
+			t.Error("want error, got nil")
+		}
+	})
+
+	t.Run("parse", func(t *testing.T) {
+		// {"alg":"HS256","kid":"protected"}
+		data := `{"payload":"aGVsbG8",` +
+			`"protected":"eyJhbGciOiJIUzI1NiIsImtpZCI6InByb3RlY3RlZCJ9",` +
+			`"header":{"kid":"unprotected"},` +
+			`"signature":"AAAA"}`
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Error("want error, got nil")
+		}
+	})
+}
+
+// contextSigner is a [sig.ContextSigner] that waits for the response from the remote signer.
+type contextSigner struct {
+	crypto.Signer
+	response <-chan struct{}
+}
+
+func (s *contextSigner) SignContext(ctx context.Context, rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-s.response:
+		return s.Sign(rand, digest, opts)
+	}
+}
+
+func TestSignContext(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := jwk.NewPublicKey(priv.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHeader()
+	h.SetAlgorithm(jwa.SignatureAlgorithmES256)
+
+	t.Run("success", func(t *testing.T) {
+		response := make(chan struct{})
+		close(response)
+		signer := &contextSigner{Signer: priv, response: response}
+		key := jwa.SignatureAlgorithmES256.New().NewSigningKey(sig.NewSignerKey(signer))
+
+		msg := NewMessage([]byte("hello"))
+		if err := msg.SignContext(t.Context(), h, nil, key); err != nil {
+			t.Fatal(err)
+		}
+
+		v := &Verifier{
+			AlgorithmVerifier: AllowedAlgorithms{jwa.SignatureAlgorithmES256},
+			KeyFinder:         &JWKKeyFinder{JWK: pub},
+		}
+		if _, _, _, err := v.Verify(t.Context(), msg); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		signer := &contextSigner{Signer: priv, response: make(chan struct{})}
+		key := jwa.SignatureAlgorithmES256.New().NewSigningKey(sig.NewSignerKey(signer))
+
+		ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+		defer cancel()
+		msg := NewMessage([]byte("hello"))
+		err := msg.SignContext(ctx, h, nil, key)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("want context.DeadlineExceeded, got %v", err)
+		}
+	})
+}
@@ -2,8 +2,15 @@ package jws
 
 import (
 	"context"
+	"crypto"
+	"crypto/subtle"
+	"crypto/x509"
 	"errors"
+	"fmt"
 
+	"github.com/shogo82148/goat"
+	"github.com/shogo82148/goat/internal/clockutils"
+	"github.com/shogo82148/goat/jwa"
 	"github.com/shogo82148/goat/jwk"
 	"github.com/shogo82148/goat/sig"
 )
@@ -32,3 +39,203 @@ func (f *JWKKeyFinder) FindKey(ctx context.Context, protected, unprotected *Head
 	}
 	return alg.New().NewSigningKey(f.JWK), nil
 }
+
+var _ KeyFinder = (*X509KeyFinder)(nil)
+
+// X509KeyFinder finds a signing key from the "x5c" (X.509 Certificate Chain) header parameter
+// defined in RFC 7515 Section 4.1.6.
+// The certificate chain is validated against Roots,
+// and the public key is extracted from the first certificate of the chain.
+// If the "x5t" or "x5t#S256" header parameters are present, they must match the certificate.
+type X509KeyFinder struct {
+	_NamedFieldsRequired struct{}
+
+	// Roots is the set of trusted root certificates.
+	// It must not be nil.
+	Roots *x509.CertPool
+
+	// Intermediates are the intermediate certificates known by the verifier.
+	// They are used in addition to the certificates in the "x5c" header parameter.
+	Intermediates []*x509.Certificate
+
+	// KeyUsages specifies which Extended Key Usage values are acceptable.
+	// If it is empty, any usage is acceptable.
+	KeyUsages []x509.ExtKeyUsage
+
+	// Clock is used to check the validity of the certificates.
+	// If it is nil, the system clock is used.
+	Clock goat.Clock
+
+	// DNSName is the name that must be in the Subject Alternative Name extension of the certificate.
+	// If it is empty, the name is not checked.
+	DNSName string
+
+	// CommonName is the common name that must be in the subject of the certificate.
+	// If it is empty, the common name is not checked.
+	CommonName string
+}
+
+// FindKey implements [KeyFinder].
+func (f *X509KeyFinder) FindKey(ctx context.Context, protected, unprotected *Header) (key sig.SigningKey, err error) {
+	_ = f._NamedFieldsRequired
+	if f.Roots == nil {
+		return nil, errors.New("jws: roots are not configured")
+	}
+
+	chain := lookupX509CertificateChain(protected, unprotected)
+	if len(chain) == 0 {
+		return nil, errors.New("jws: x5c is missing")
+	}
+	leaf := chain[0]
+	for _, h := range [...]*Header{protected, unprotected} {
+		if h != nil && !matchCertificate(leaf, h.x5t, h.x5tS256) {
+			return nil, errors.New("jws: the certificate thumbprint is mismatch")
+		}
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	for _, cert := range f.Intermediates {
+		intermediates.AddCert(cert)
+	}
+	usages := f.KeyUsages
+	if len(usages) == 0 {
+		usages = []x509.ExtKeyUsage{x509.ExtKeyUsageAny}
+	}
+	opts := x509.VerifyOptions{
+		Roots:         f.Roots,
+		Intermediates: intermediates,
+		KeyUsages:     usages,
+		CurrentTime:   clockutils.Now(f.Clock),
+		DNSName:       f.DNSName,
+	}
+	if _, err := leaf.Verify(opts); err != nil {
+		return nil, fmt.Errorf("jws: failed to verify the certificate chain: %w", err)
+	}
+
+	// RFC 5280 Section 4.2.1.3: the digitalSignature bit is asserted
+	// when the subject public key is used for verifying digital signatures.
+	if leaf.KeyUsage != 0 && leaf.KeyUsage&x509.KeyUsageDigitalSignature == 0 {
+		return nil, errors.New("jws: the certificate is not for digital signatures")
+	}
+	if f.CommonName != "" && leaf.Subject.CommonName != f.CommonName {
+		return nil, fmt.Errorf("jws: unexpected common name: %q", leaf.Subject.CommonName)
+	}
+
+	k, err := jwk.NewPublicKey(leaf.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	alg := lookupAlgorithm(protected, unprotected)
+	if !alg.Available() {
+		return nil, errors.New("jws: algorithm not available")
+	}
+	return alg.New().NewSigningKey(k), nil
+}
+
+// JWKTrustVerifier verifies that the JSON Web Key embedded in the JWS header is trusted.
+type JWKTrustVerifier interface {
+	// VerifyJWK returns nil if key is trusted.
+	// thumbprint is the JWK Thumbprint of key defined in RFC 7638.
+	VerifyJWK(ctx context.Context, key *jwk.Key, thumbprint []byte) error
+}
+
+// VerifyJWKFunc is an adapter to allow the use of ordinary functions as JWKTrustVerifier.
+type VerifyJWKFunc func(ctx context.Context, key *jwk.Key, thumbprint []byte) error
+
+// VerifyJWK calls f(ctx, key, thumbprint).
+func (f VerifyJWKFunc) VerifyJWK(ctx context.Context, key *jwk.Key, thumbprint []byte) error {
+	return f(ctx, key, thumbprint)
+}
+
+var _ JWKTrustVerifier = TrustedThumbprints(nil)
+
+// TrustedThumbprints is an allow-list of the base64url-encoded JWK Thumbprints defined in RFC 7638.
+type TrustedThumbprints []string
+
+// VerifyJWK implements [JWKTrustVerifier].
+func (t TrustedThumbprints) VerifyJWK(ctx context.Context, key *jwk.Key, thumbprint []byte) error {
+	encoded := b64Encode(thumbprint)
+	for _, s := range t {
+		if subtle.ConstantTimeCompare([]byte(s), encoded) == 1 {
+			return nil
+		}
+	}
+	return errors.New("jws: the key is not trusted")
+}
+
+var _ KeyFinder = (*EmbeddedJWKKeyFinder)(nil)
+
+// EmbeddedJWKKeyFinder finds a signing key from the "jwk" (JSON Web Key) header parameter
+// defined in RFC 7515 Section 4.1.3, such as ACME requests (RFC 8555) and DPoP proofs (RFC 9449).
+//
+// Anyone can embed their key in the header, so the key is used only if Trust accepts it.
+// The "jwk" header parameter must be in the protected header,
+// and it must not contain any private key.
+type EmbeddedJWKKeyFinder struct {
+	_NamedFieldsRequired struct{}
+
+	// Trust verifies that the embedded key is trusted.
+	// It must not be nil.
+	Trust JWKTrustVerifier
+
+	// Hash is the hash function for the JWK Thumbprint.
+	// If it is zero, crypto.SHA256 is used.
+	Hash crypto.Hash
+}
+
+// FindKey implements [KeyFinder].
+func (f *EmbeddedJWKKeyFinder) FindKey(ctx context.Context, protected, unprotected *Header) (key sig.SigningKey, err error) {
+	if f.Trust == nil {
+		return nil, errors.New("jws: trust verifier is not configured")
+	}
+	if unprotected != nil && unprotected.jwk != nil {
+		return nil, errors.New("jws: jwk must be in the protected header")
+	}
+	if protected == nil || protected.jwk == nil {
+		return nil, errors.New("jws: jwk is missing")
+	}
+	k := protected.jwk
+	if k.PrivateKey() != nil {
+		return nil, errors.New("jws: jwk must not contain any private key")
+	}
+	if k.PublicKey() == nil {
+		return nil, errors.New("jws: jwk doesn't contain any public key")
+	}
+
+	thumbprint, err := f.Thumbprint(protected)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Trust.VerifyJWK(ctx, k, thumbprint); err != nil {
+		return nil, fmt.Errorf("jws: failed to verify jwk: %w", err)
+	}
+
+	alg := protected.alg
+	if !alg.Available() {
+		return nil, errors.New("jws: algorithm not available")
+	}
+	if keyAlg := k.Algorithm(); keyAlg != "" && keyAlg != jwa.KeyAlgorithm(alg) {
+		return nil, fmt.Errorf("jws: requested alg %q is not supported by the key", alg)
+	}
+	return alg.New().NewSigningKey(k), nil
+}
+
+// Thumbprint returns the JWK Thumbprint defined in RFC 7638 of the "jwk" header parameter in protected.
+// Call it with the protected header returned by the [Verifier] to get the verified thumbprint.
+func (f *EmbeddedJWKKeyFinder) Thumbprint(protected *Header) ([]byte, error) {
+	_ = f._NamedFieldsRequired
+	if protected == nil || protected.jwk == nil {
+		return nil, errors.New("jws: jwk is missing")
+	}
+	h := f.Hash
+	if h == 0 {
+		h = crypto.SHA256
+	}
+	if !h.Available() {
+		return nil, errors.New("jws: hash function not available")
+	}
+	return protected.jwk.Thumbprint(h.New())
+}
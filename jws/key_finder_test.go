@@ -0,0 +1,424 @@
+package jws
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/shogo82148/goat"
+	"github.com/shogo82148/goat/jwa"
+	"github.com/shogo82148/goat/jwk"
+)
+
+// testCertificate is a certificate and its private key for testing.
+type testCertificate struct {
+	cert *x509.Certificate
+	priv *ecdsa.PrivateKey
+}
+
+// newTestCertificate creates a certificate from template.
+// If parent is nil, it creates a self-signed certificate.
+func newTestCertificate(t *testing.T, template *x509.Certificate, parent *testCertificate) *testCertificate {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parentCert, parentKey := template, crypto.Signer(priv)
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.priv
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, priv.Public(), parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCertificate{cert: cert, priv: priv}
+}
+
+func TestX509KeyFinder(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := goat.ClockFunc(func() time.Time { return now })
+	root := newTestCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Root CA"},
+		NotBefore:             now.Add(-24 * time.Hour),
+		NotAfter:              now.Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil)
+	intermediate := newTestCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "Intermediate CA"},
+		NotBefore:             now.Add(-24 * time.Hour),
+		NotAfter:              now.Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, root)
+	leaf := newTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "signer"},
+		DNSNames:     []string{"signer.example.com"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}, intermediate)
+	encipher := newTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(4),
+		Subject:      pkix.Name{CommonName: "encipher"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		KeyUsage:     x509.KeyUsageKeyEncipherment,
+	}, intermediate)
+	untrusted := newTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(5),
+		Subject:      pkix.Name{CommonName: "signer"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}, nil)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root.cert)
+
+	// sign signs the payload with the certificate and parses it as a compact serialized JWS.
+	sign := func(t *testing.T, c *testCertificate, chain []*x509.Certificate, x5t []byte) *Message {
+		t.Helper()
+		key, err := jwk.NewPrivateKey(c.priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := NewHeader()
+		h.SetAlgorithm(jwa.SignatureAlgorithmES256)
+		h.SetX509CertificateChain(chain)
+		h.SetX509CertificateSHA1(x5t)
+		msg := NewMessage([]byte("hello"))
+		if err := msg.Sign(h, nil, jwa.SignatureAlgorithmES256.New().NewSigningKey(key)); err != nil {
+			t.Fatal(err)
+		}
+		data, err := msg.Compact()
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, err = ParseCompact(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+	x5t := sha1.Sum(leaf.cert.Raw)
+
+	tests := []struct {
+		name    string
+		finder  *X509KeyFinder
+		msg     *Message
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			finder: &X509KeyFinder{
+				Roots: roots,
+				Clock: clock,
+			},
+			msg: sign(t, leaf, []*x509.Certificate{leaf.cert, intermediate.cert}, x5t[:]),
+		},
+		{
+			name: "known intermediates",
+			finder: &X509KeyFinder{
+				Roots:         roots,
+				Intermediates: []*x509.Certificate{intermediate.cert},
+				Clock:         clock,
+			},
+			msg: sign(t, leaf, []*x509.Certificate{leaf.cert}, nil),
+		},
+		{
+			name: "missing intermediates",
+			finder: &X509KeyFinder{
+				Roots: roots,
+				Clock: clock,
+			},
+			msg:     sign(t, leaf, []*x509.Certificate{leaf.cert}, nil),
+			wantErr: true,
+		},
+		{
+			name: "no roots",
+			finder: &X509KeyFinder{
+				Clock: clock,
+			},
+			msg:     sign(t, leaf, []*x509.Certificate{leaf.cert, intermediate.cert}, nil),
+			wantErr: true,
+		},
+		{
+			name: "no x5c",
+			finder: &X509KeyFinder{
+				Roots: roots,
+				Clock: clock,
+			},
+			msg:     sign(t, leaf, nil, nil),
+			wantErr: true,
+		},
+		{
+			name: "untrusted",
+			finder: &X509KeyFinder{
+				Roots: roots,
+				Clock: clock,
+			},
+			msg:     sign(t, untrusted, []*x509.Certificate{untrusted.cert}, nil),
+			wantErr: true,
+		},
+		{
+			name: "x5t mismatch",
+			finder: &X509KeyFinder{
+				Roots: roots,
+				Clock: clock,
+			},
+			msg: func() *Message {
+				// the thumbprint in the unprotected header doesn't match the certificate in the protected header.
+				msg := sign(t, leaf, []*x509.Certificate{leaf.cert, intermediate.cert}, nil)
+				msg.Signatures[0].header = NewHeader()
+				msg.Signatures[0].header.SetX509CertificateSHA1(make([]byte, sha1.Size))
+				return msg
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "expired",
+			finder: &X509KeyFinder{
+				Roots: roots,
+				Clock: goat.ClockFunc(func() time.Time { return now.Add(2 * time.Hour) }),
+			},
+			msg:     sign(t, leaf, []*x509.Certificate{leaf.cert, intermediate.cert}, nil),
+			wantErr: true,
+		},
+		{
+			name: "extended key usage",
+			finder: &X509KeyFinder{
+				Roots:     roots,
+				KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+				Clock:     clock,
+			},
+			msg: sign(t, leaf, []*x509.Certificate{leaf.cert, intermediate.cert}, nil),
+		},
+		{
+			name: "extended key usage mismatch",
+			finder: &X509KeyFinder{
+				Roots:     roots,
+				KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+				Clock:     clock,
+			},
+			msg:     sign(t, leaf, []*x509.Certificate{leaf.cert, intermediate.cert}, nil),
+			wantErr: true,
+		},
+		{
+			name: "key usage mismatch",
+			finder: &X509KeyFinder{
+				Roots: roots,
+				Clock: clock,
+			},
+			msg:     sign(t, encipher, []*x509.Certificate{encipher.cert, intermediate.cert}, nil),
+			wantErr: true,
+		},
+		{
+			name: "dns name",
+			finder: &X509KeyFinder{
+				Roots:      roots,
+				Clock:      clock,
+				DNSName:    "signer.example.com",
+				CommonName: "signer",
+			},
+			msg: sign(t, leaf, []*x509.Certificate{leaf.cert, intermediate.cert}, nil),
+		},
+		{
+			name: "dns name mismatch",
+			finder: &X509KeyFinder{
+				Roots:   roots,
+				Clock:   clock,
+				DNSName: "evil.example.com",
+			},
+			msg:     sign(t, leaf, []*x509.Certificate{leaf.cert, intermediate.cert}, nil),
+			wantErr: true,
+		},
+		{
+			name: "common name mismatch",
+			finder: &X509KeyFinder{
+				Roots:      roots,
+				Clock:      clock,
+				CommonName: "evil",
+			},
+			msg:     sign(t, leaf, []*x509.Certificate{leaf.cert, intermediate.cert}, nil),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Verifier{
+				AlgorithmVerifier: AllowedAlgorithms{jwa.SignatureAlgorithmES256},
+				KeyFinder:         tt.finder,
+			}
+			_, _, payload, err := v.Verify(t.Context(), tt.msg)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("want error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(payload) != "hello" {
+				t.Errorf("unexpected payload: %q", payload)
+			}
+		})
+	}
+}
+
+func TestEmbeddedJWKKeyFinder(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := jwk.NewPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := jwk.NewPublicKey(priv.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	thumbprint, err := pub.Thumbprint(sha256.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingKey := jwa.SignatureAlgorithmES256.New().NewSigningKey(key)
+
+	// sign signs the payload and parses it as a compact serialized JWS.
+	sign := func(t *testing.T, embedded *jwk.Key) *Message {
+		t.Helper()
+		h := NewHeader()
+		h.SetAlgorithm(jwa.SignatureAlgorithmES256)
+		h.SetJWK(embedded)
+		msg := NewMessage([]byte("hello"))
+		if err := msg.Sign(h, nil, signingKey); err != nil {
+			t.Fatal(err)
+		}
+		data, err := msg.Compact()
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, err = ParseCompact(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+
+	t.Run("trusted", func(t *testing.T) {
+		f := &EmbeddedJWKKeyFinder{
+			Trust: TrustedThumbprints{b64.EncodeToString(thumbprint)},
+		}
+		v := &Verifier{
+			AlgorithmVerifier: AllowedAlgorithms{jwa.SignatureAlgorithmES256},
+			KeyFinder:         f,
+		}
+		protected, _, _, err := v.Verify(t.Context(), sign(t, pub))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := f.Thumbprint(protected)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, thumbprint) {
+			t.Errorf("unexpected thumbprint: want %x, got %x", thumbprint, got)
+		}
+	})
+
+	t.Run("bound to an account", func(t *testing.T) {
+		accounts := map[string]string{
+			b64.EncodeToString(thumbprint): "account-1",
+		}
+		var account string
+		f := &EmbeddedJWKKeyFinder{
+			Trust: VerifyJWKFunc(func(ctx context.Context, key *jwk.Key, thumbprint []byte) error {
+				a, ok := accounts[b64.EncodeToString(thumbprint)]
+				if !ok {
+					return errors.New("account not found")
+				}
+				account = a
+				return nil
+			}),
+		}
+		v := &Verifier{
+			AlgorithmVerifier: AllowedAlgorithms{jwa.SignatureAlgorithmES256},
+			KeyFinder:         f,
+		}
+		if _, _, _, err := v.Verify(t.Context(), sign(t, pub)); err != nil {
+			t.Fatal(err)
+		}
+		if account != "account-1" {
+			t.Errorf("unexpected account: %q", account)
+		}
+	})
+
+	t.Run("untrusted", func(t *testing.T) {
+		f := &EmbeddedJWKKeyFinder{
+			Trust: TrustedThumbprints{"NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"},
+		}
+		msg := sign(t, pub)
+		if _, err := f.FindKey(t.Context(), msg.Signatures[0].protected, nil); err == nil {
+			t.Error("want error, got nil")
+		}
+	})
+
+	t.Run("private key", func(t *testing.T) {
+		f := &EmbeddedJWKKeyFinder{
+			Trust: VerifyJWKFunc(func(ctx context.Context, key *jwk.Key, thumbprint []byte) error {
+				return nil
+			}),
+		}
+		msg := sign(t, key)
+		if _, err := f.FindKey(t.Context(), msg.Signatures[0].protected, nil); err == nil {
+			t.Error("want error, got nil")
+		}
+	})
+
+	t.Run("unprotected", func(t *testing.T) {
+		f := &EmbeddedJWKKeyFinder{
+			Trust: TrustedThumbprints{b64.EncodeToString(thumbprint)},
+		}
+		protected := NewHeader()
+		protected.SetAlgorithm(jwa.SignatureAlgorithmES256)
+		unprotected := NewHeader()
+		unprotected.SetJWK(pub)
+		if _, err := f.FindKey(t.Context(), protected, unprotected); err == nil {
+			t.Error("want error, got nil")
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		f := &EmbeddedJWKKeyFinder{
+			Trust: TrustedThumbprints{b64.EncodeToString(thumbprint)},
+		}
+		msg := sign(t, nil)
+		if _, err := f.FindKey(t.Context(), msg.Signatures[0].protected, nil); err == nil {
+			t.Error("want error, got nil")
+		}
+	})
+}
@@ -0,0 +1,449 @@
+package jws
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shogo82148/goat"
+	"github.com/shogo82148/goat/internal/clockutils"
+	"github.com/shogo82148/goat/jwa"
+	"github.com/shogo82148/goat/jwk"
+	"github.com/shogo82148/goat/sig"
+	"github.com/shogo82148/memoize"
+)
+
+const (
+	// The default value of User-Agent header
+	defaultUserAgent = "https://github.com/shogo82148/goat"
+
+	// The default duration for caching the remote keys.
+	defaultCacheDuration = time.Hour
+
+	// The default maximum number of the cached URLs.
+	defaultMaxCacheEntries = 64
+
+	// The maximum size of the response body.
+	maxResponseSize = 1 << 20
+)
+
+// Doer is a interface for doing an http request, such as http.Client.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+var _ KeyFinder = (*RemoteKeyFinder)(nil)
+
+// RemoteKeyFinder finds the key referenced by the "jku" (JWK Set URL) header parameter
+// defined in RFC 7515 Section 4.1.2, or the "x5u" (X.509 URL) header parameter
+// defined in RFC 7515 Section 4.1.5.
+//
+// The URLs in the header are controlled by the sender of the message,
+// so RemoteKeyFinder fetches only the URLs that match AllowedURLs,
+// and the "jku" and "x5u" header parameters must be in the protected header.
+// The key is selected by the "kid", "x5t", and "x5t#S256" header parameters.
+//
+// The certificate chain fetched from "x5u" is validated only if Roots is set.
+// Otherwise, AllowedURLs is the only source of trust,
+// i.e. any certificate served from the allowed URLs is trusted.
+type RemoteKeyFinder struct {
+	_NamedFieldsRequired struct{}
+
+	// Doer is used for http requests.
+	// If it nil, an http.Client that doesn't follow redirects is used.
+	// If Doer follows redirects, the redirected URL must also match AllowedURLs.
+	Doer Doer
+
+	// UserAgent is the value of User-Agent header in http requests.
+	// If it is empty string, "https://github.com/shogo82148/goat" is used.
+	UserAgent string
+
+	// AllowedURLs is the allow-list of the URLs.
+	// A URL matches an entry if the origin (the scheme, the host and the port),
+	// the path and the query are equal.
+	// If the path of the entry ends with "/", the URL also matches if it has the same origin and no query,
+	// and its path is under the path of the entry.
+	// If AllowedURLs is empty, no URL is fetched.
+	AllowedURLs []string
+
+	// Roots is the set of trusted root certificates for the certificate chains fetched from "x5u".
+	// If it is nil, the chains are not validated.
+	Roots *x509.CertPool
+
+	// Clock is used to check the validity of the certificates fetched from "x5u".
+	// If it is nil, the system clock is used.
+	Clock goat.Clock
+
+	// CacheDuration is the duration for caching the fetched keys.
+	// If it is zero, the keys are cached for an hour.
+	CacheDuration time.Duration
+
+	// MaxCacheEntries is the maximum number of the URLs whose keys are cached.
+	// The least recently used URL is evicted when the limit is exceeded.
+	// If it is zero, 64 is used.
+	MaxCacheEntries int
+
+	jwks memoize.Group[string, *jwk.Set]
+	x5u  memoize.Group[string, []*x509.Certificate]
+
+	mu    sync.Mutex
+	lru   list.List // of cacheKey, the most recently used first
+	cache map[cacheKey]*list.Element
+}
+
+// noRedirectClient is the default Doer of RemoteKeyFinder.
+// It doesn't follow redirects, because the redirected URLs may not be allowed.
+var noRedirectClient = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// cacheKey is a key of the cache of RemoteKeyFinder.
+type cacheKey struct {
+	x5u bool // true for x5u, false for jku
+	url string
+}
+
+// FindKey implements [KeyFinder].
+func (f *RemoteKeyFinder) FindKey(ctx context.Context, protected, unprotected *Header) (key sig.SigningKey, err error) {
+	_ = f._NamedFieldsRequired
+
+	alg := lookupAlgorithm(protected, unprotected)
+	if unprotected != nil && (unprotected.jku != nil || unprotected.x5u != nil) {
+		return nil, errors.New("jws: jku and x5u must be in the protected header")
+	}
+	var jku, x5u *url.URL
+	if protected != nil {
+		jku, x5u = protected.jku, protected.x5u
+	}
+	kid := lookupKeyID(protected, unprotected)
+	x5t := lookupX509CertificateSHA1(protected, unprotected)
+	x5tS256 := lookupX509CertificateSHA256(protected, unprotected)
+	if !alg.Available() {
+		return nil, errors.New("jws: algorithm not available")
+	}
+
+	switch {
+	case jku != nil:
+		if !f.isAllowed(jku) {
+			return nil, fmt.Errorf("jws: jku %q is not allowed", jku)
+		}
+		u := jku.String()
+		f.touch(cacheKey{url: u})
+		set, _, err := f.jwks.Do(ctx, u, f.getJWKS)
+		if err != nil {
+			return nil, fmt.Errorf("jws: failed to fetch jku %q: %w", u, err)
+		}
+		key, err := selectKey(set, alg, kid, x5t, x5tS256)
+		if err != nil {
+			return nil, err
+		}
+		return alg.New().NewSigningKey(key), nil
+
+	case x5u != nil:
+		if !f.isAllowed(x5u) {
+			return nil, fmt.Errorf("jws: x5u %q is not allowed", x5u)
+		}
+		u := x5u.String()
+		f.touch(cacheKey{x5u: true, url: u})
+		certs, _, err := f.x5u.Do(ctx, u, f.getX5U)
+		if err != nil {
+			return nil, fmt.Errorf("jws: failed to fetch x5u %q: %w", u, err)
+		}
+		if !matchCertificate(certs[0], x5t, x5tS256) {
+			return nil, errors.New("jws: the certificate thumbprint is mismatch")
+		}
+		if err := f.verifyChain(certs); err != nil {
+			return nil, err
+		}
+		key, err := jwk.NewPublicKey(certs[0].PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		return alg.New().NewSigningKey(key), nil
+	}
+	return nil, errors.New("jws: neither jku nor x5u is set")
+}
+
+// verifyChain verifies the certificate chain fetched from x5u with Roots.
+func (f *RemoteKeyFinder) verifyChain(certs []*x509.Certificate) error {
+	if f.Roots == nil {
+		return nil
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	opts := x509.VerifyOptions{
+		Roots:         f.Roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		CurrentTime:   clockutils.Now(f.Clock),
+	}
+	if _, err := certs[0].Verify(opts); err != nil {
+		return fmt.Errorf("jws: failed to verify the certificate chain: %w", err)
+	}
+	return nil
+}
+
+// touch marks key as the most recently used,
+// and evicts the least recently used keys if the cache is full.
+func (f *RemoteKeyFinder) touch(key cacheKey) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.cache == nil {
+		f.cache = make(map[cacheKey]*list.Element)
+	}
+	if e, ok := f.cache[key]; ok {
+		f.lru.MoveToFront(e)
+		return
+	}
+	f.cache[key] = f.lru.PushFront(key)
+
+	limit := f.MaxCacheEntries
+	if limit <= 0 {
+		limit = defaultMaxCacheEntries
+	}
+	for f.lru.Len() > limit {
+		e := f.lru.Back()
+		old := f.lru.Remove(e).(cacheKey)
+		delete(f.cache, old)
+		if old.x5u {
+			f.x5u.Forget(old.url)
+		} else {
+			f.jwks.Forget(old.url)
+		}
+	}
+}
+
+// isAllowed reports whether u matches AllowedURLs.
+func (f *RemoteKeyFinder) isAllowed(u *url.URL) bool {
+	if u.Opaque != "" || u.User != nil || u.Fragment != "" || u.Host == "" {
+		return false
+	}
+	path := u.EscapedPath()
+	for _, seg := range strings.Split(path, "/") {
+		if seg == "." || seg == ".." {
+			return false
+		}
+		// reject the encoded dot segments and slashes, which may be normalized by the server.
+		if strings.Contains(seg, "%") {
+			if unescaped, err := url.PathUnescape(seg); err != nil || unescaped == "." || unescaped == ".." || strings.Contains(unescaped, "/") {
+				return false
+			}
+		}
+	}
+
+	for _, s := range f.AllowedURLs {
+		allowed, err := url.Parse(s)
+		if err != nil {
+			continue
+		}
+		if !sameOrigin(allowed, u) {
+			continue
+		}
+		allowedPath := allowed.EscapedPath()
+		if path == allowedPath && u.RawQuery == allowed.RawQuery {
+			return true
+		}
+		if strings.HasSuffix(allowedPath, "/") && u.RawQuery == "" && !u.ForceQuery && strings.HasPrefix(path, allowedPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameOrigin reports whether a and b have the same origin defined in RFC 6454,
+// i.e. the scheme, the host and the port are equal.
+func sameOrigin(a, b *url.URL) bool {
+	return strings.EqualFold(a.Scheme, b.Scheme) &&
+		strings.EqualFold(a.Hostname(), b.Hostname()) &&
+		effectivePort(a) == effectivePort(b)
+}
+
+// effectivePort returns the port of u.
+// If u has no port, the default port of the scheme is returned.
+func effectivePort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http":
+		return "80"
+	case "https":
+		return "443"
+	}
+	return ""
+}
+
+func (f *RemoteKeyFinder) getJWKS(ctx context.Context, url string) (*jwk.Set, time.Time, error) {
+	data, expiresAt, err := f.get(ctx, url, "application/jwk-set+json")
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	set, err := jwk.ParseSet(data)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return set, expiresAt, nil
+}
+
+func (f *RemoteKeyFinder) getX5U(ctx context.Context, url string) ([]*x509.Certificate, time.Time, error) {
+	data, expiresAt, err := f.get(ctx, url, "application/pem-certificate-chain")
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	// RFC 7515 Section 4.1.5:
+	// The certificate or certificate chain MUST be in PEM-encoded form.
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, time.Time{}, errors.New("jws: no certificate found")
+	}
+	return certs, expiresAt, nil
+}
+
+func (f *RemoteKeyFinder) get(ctx context.Context, url, accept string) ([]byte, time.Time, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	d := f.CacheDuration
+	if d == 0 {
+		d = defaultCacheDuration
+	}
+
+	// The monotonic clock reading can be incorrect in cases where the host system is hibernated
+	// (for example using EC2 Hibernate, AWS Lambda, etc).
+	// So convert it to wall-clock.
+	expiresAt := time.Now().Add(d).Round(0)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	userAgent := f.UserAgent
+	if userAgent == "" {
+		userAgent = defaultUserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", accept)
+
+	doer := f.Doer
+	if doer == nil {
+		doer = noRedirectClient
+	}
+	resp, err := doer.Do(req)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer resp.Body.Close() //nolint:errcheck // ignore error because we can't do anything about it.
+
+	// the Doer may follow redirects.
+	if resp.Request != nil && !f.isAllowed(resp.Request.URL) {
+		return nil, time.Time{}, fmt.Errorf("jws: redirected to %q, but it is not allowed", resp.Request.URL)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, time.Time{}, fmt.Errorf("jws: unexpected response code: %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize+1))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if len(data) > maxResponseSize {
+		return nil, time.Time{}, errors.New("jws: response body is too large")
+	}
+	return data, expiresAt, nil
+}
+
+// selectKey selects the key for alg from set by kid, x5t, and x5t#S256.
+func selectKey(set *jwk.Set, alg jwa.SignatureAlgorithm, kid string, x5t, x5tS256 []byte) (*jwk.Key, error) {
+	var candidates []*jwk.Key
+	for _, key := range set.Keys {
+		if kid != "" && key.KeyID() != kid {
+			continue
+		}
+		if keyAlg := key.Algorithm(); keyAlg != "" && keyAlg != jwa.KeyAlgorithm(alg) {
+			continue
+		}
+		if x5t != nil && !bytes.Equal(keyThumbprint(key, key.X509CertificateSHA1(), sha1Sum), x5t) {
+			continue
+		}
+		if x5tS256 != nil && !bytes.Equal(keyThumbprint(key, key.X509CertificateSHA256(), sha256Sum), x5tS256) {
+			continue
+		}
+		candidates = append(candidates, key)
+	}
+
+	if len(candidates) == 0 {
+		return nil, errors.New("jws: key not found")
+	}
+	if kid == "" && x5t == nil && x5tS256 == nil && len(candidates) > 1 {
+		return nil, errors.New("jws: failed to select the key: kid is not set")
+	}
+	return candidates[0], nil
+}
+
+// keyThumbprint returns the thumbprint of the certificate of key.
+// If the thumbprint is not set, it is calculated from the certificate chain.
+func keyThumbprint(key *jwk.Key, thumbprint []byte, sum func(data []byte) []byte) []byte {
+	if thumbprint != nil {
+		return thumbprint
+	}
+	if chain := key.X509CertificateChain(); len(chain) > 0 {
+		return sum(chain[0].Raw)
+	}
+	return nil
+}
+
+// matchCertificate reports whether cert matches the thumbprints x5t and x5t#S256.
+// Nil thumbprints are ignored.
+func matchCertificate(cert *x509.Certificate, x5t, x5tS256 []byte) bool {
+	if x5t != nil && !bytes.Equal(sha1Sum(cert.Raw), x5t) {
+		return false
+	}
+	if x5tS256 != nil && !bytes.Equal(sha256Sum(cert.Raw), x5tS256) {
+		return false
+	}
+	return true
+}
+
+func sha1Sum(data []byte) []byte {
+	sum := sha1.Sum(data)
+	return sum[:]
+}
+
+func sha256Sum(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
@@ -0,0 +1,342 @@
+package jws
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shogo82148/goat/jwa"
+	"github.com/shogo82148/goat/jwk"
+)
+
+func TestRemoteKeyFinder_isAllowed(t *testing.T) {
+	f := &RemoteKeyFinder{
+		AllowedURLs: []string{
+			"https://example.com/jwks.json",
+			"https://keys.example.com/certs/",
+		},
+	}
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://example.com/jwks.json", true},
+		{"https://EXAMPLE.COM/jwks.json", true},
+		{"https://example.com:443/jwks.json", true},
+		{"https://example.com:8443/jwks.json", false},
+		{"http://example.com/jwks.json", false},
+		{"https://example.com/jwks.json?foo=bar", false},
+		{"https://example.com/jwks.json#foo", false},
+		{"https://example.com/other.json", false},
+		{"https://example.com.evil.example/jwks.json", false},
+		{"https://user@example.com/jwks.json", false},
+		{"https://keys.example.com/certs/", true},
+		{"https://keys.example.com/certs/key.pem", true},
+		{"https://keys.example.com/certs/sub/key.pem", true},
+		{"https://keys.example.com/certs/key.pem?v=1", false},
+		{"https://keys.example.com/certs/sub%2F..%2Fkey.pem", false},
+		{"https://keys.example.com:8443/certs/key.pem", false},
+		{"https://keys.example.com/certs/../evil.pem", false},
+		{"https://keys.example.com/certs/%2e%2e/evil.pem", false},
+		{"https://keys.example.com/evil.pem", false},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := f.isAllowed(u); got != tt.want {
+			t.Errorf("isAllowed(%q) = %t, want %t", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestRemoteKeyFinder(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := jwk.NewPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := jwk.NewPublicKey(priv.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub.SetKeyID("key-1")
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, err := jwk.NewPublicKey(other.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub.SetKeyID("key-2")
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, priv.Public(), priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var jwksCount, x5uCount atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		jwksCount.Add(1)
+		data0, _ := pub.MarshalJSON()
+		data1, _ := otherPub.MarshalJSON()
+		w.Header().Set("Content-Type", "application/jwk-set+json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"keys": []json.RawMessage{data1, data0},
+		})
+	})
+	mux.HandleFunc("/cert.pem", func(w http.ResponseWriter, r *http.Request) {
+		x5uCount.Add(1)
+		w.Header().Set("Content-Type", "application/pem-certificate-chain")
+		_ = pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/jwks.json?evil", http.StatusFound)
+	})
+	ts := httptest.NewTLSServer(mux)
+	defer ts.Close()
+
+	f := &RemoteKeyFinder{
+		Doer:        ts.Client(),
+		AllowedURLs: []string{ts.URL + "/jwks.json", ts.URL + "/cert.pem"},
+	}
+	v := &Verifier{
+		AlgorithmVerifier: AllowedAlgorithms{jwa.SignatureAlgorithmES256},
+		KeyFinder:         f,
+	}
+	signingKey := jwa.SignatureAlgorithmES256.New().NewSigningKey(key)
+
+	// sign signs the payload and parses it as a compact serialized JWS.
+	sign := func(t *testing.T, h *Header) *Message {
+		t.Helper()
+		msg := NewMessage([]byte("hello"))
+		if err := msg.Sign(h, nil, signingKey); err != nil {
+			t.Fatal(err)
+		}
+		data, err := msg.Compact()
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, err = ParseCompact(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+	mustParse := func(t *testing.T, s string) *url.URL {
+		t.Helper()
+		u, err := url.Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+
+	t.Run("jku", func(t *testing.T) {
+		h := NewHeader()
+		h.SetAlgorithm(jwa.SignatureAlgorithmES256)
+		h.SetJWKSetURL(mustParse(t, ts.URL+"/jwks.json"))
+		h.SetKeyID("key-1")
+		for range 3 {
+			if _, _, _, err := v.Verify(t.Context(), sign(t, h)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := jwksCount.Load(); got != 1 {
+			t.Errorf("want 1 request, got %d", got)
+		}
+	})
+
+	t.Run("jku with wrong kid", func(t *testing.T) {
+		h := NewHeader()
+		h.SetAlgorithm(jwa.SignatureAlgorithmES256)
+		h.SetJWKSetURL(mustParse(t, ts.URL+"/jwks.json"))
+		h.SetKeyID("key-2")
+		if _, _, _, err := v.Verify(t.Context(), sign(t, h)); err == nil {
+			t.Error("want error, got nil")
+		}
+	})
+
+	t.Run("jku without kid", func(t *testing.T) {
+		h := NewHeader()
+		h.SetAlgorithm(jwa.SignatureAlgorithmES256)
+		h.SetJWKSetURL(mustParse(t, ts.URL+"/jwks.json"))
+		if _, err := f.FindKey(t.Context(), h, nil); err == nil {
+			t.Error("want error, got nil")
+		}
+	})
+
+	t.Run("x5u", func(t *testing.T) {
+		sum := sha256.Sum256(der)
+		h := NewHeader()
+		h.SetAlgorithm(jwa.SignatureAlgorithmES256)
+		h.SetX509URL(mustParse(t, ts.URL+"/cert.pem"))
+		h.SetX509CertificateSHA256(sum[:])
+		for range 3 {
+			if _, _, _, err := v.Verify(t.Context(), sign(t, h)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := x5uCount.Load(); got != 1 {
+			t.Errorf("want 1 request, got %d", got)
+		}
+	})
+
+	t.Run("x5u with wrong thumbprint", func(t *testing.T) {
+		h := NewHeader()
+		h.SetAlgorithm(jwa.SignatureAlgorithmES256)
+		h.SetX509URL(mustParse(t, ts.URL+"/cert.pem"))
+		h.SetX509CertificateSHA256(make([]byte, sha256.Size))
+		if _, err := f.FindKey(t.Context(), h, nil); err == nil {
+			t.Error("want error, got nil")
+		}
+	})
+
+	t.Run("x5u with roots", func(t *testing.T) {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := NewHeader()
+		h.SetAlgorithm(jwa.SignatureAlgorithmES256)
+		h.SetX509URL(mustParse(t, ts.URL+"/cert.pem"))
+
+		roots := x509.NewCertPool()
+		roots.AddCert(cert)
+		trusted := &RemoteKeyFinder{
+			Doer:        ts.Client(),
+			AllowedURLs: []string{ts.URL + "/cert.pem"},
+			Roots:       roots,
+		}
+		if _, err := trusted.FindKey(t.Context(), h, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		untrusted := &RemoteKeyFinder{
+			Doer:        ts.Client(),
+			AllowedURLs: []string{ts.URL + "/cert.pem"},
+			Roots:       x509.NewCertPool(),
+		}
+		if _, err := untrusted.FindKey(t.Context(), h, nil); err == nil {
+			t.Error("want error, got nil")
+		}
+	})
+
+	t.Run("unprotected jku", func(t *testing.T) {
+		jwksCount.Store(0)
+		protected := NewHeader()
+		protected.SetAlgorithm(jwa.SignatureAlgorithmES256)
+		unprotected := NewHeader()
+		unprotected.SetJWKSetURL(mustParse(t, ts.URL+"/jwks.json"))
+		unprotected.SetKeyID("key-1")
+		if _, err := f.FindKey(t.Context(), protected, unprotected); err == nil {
+			t.Error("want error, got nil")
+		}
+		if got := jwksCount.Load(); got != 0 {
+			t.Errorf("want no request, got %d", got)
+		}
+	})
+
+	t.Run("redirect", func(t *testing.T) {
+		h := NewHeader()
+		h.SetAlgorithm(jwa.SignatureAlgorithmES256)
+		h.SetJWKSetURL(mustParse(t, ts.URL+"/redirect"))
+		h.SetKeyID("key-1")
+
+		// the Doer follows the redirect, but the redirected URL is not allowed.
+		f := &RemoteKeyFinder{
+			Doer:        ts.Client(),
+			AllowedURLs: []string{ts.URL + "/redirect"},
+		}
+		if _, err := f.FindKey(t.Context(), h, nil); err == nil {
+			t.Error("want error, got nil")
+		}
+
+		// the default Doer doesn't follow the redirect.
+		plain := httptest.NewServer(mux)
+		defer plain.Close()
+		h.SetJWKSetURL(mustParse(t, plain.URL+"/redirect"))
+		jwksCount.Store(0)
+		f = &RemoteKeyFinder{
+			AllowedURLs: []string{plain.URL + "/"},
+		}
+		if _, err := f.FindKey(t.Context(), h, nil); err == nil {
+			t.Error("want error, got nil")
+		}
+		if got := jwksCount.Load(); got != 0 {
+			t.Errorf("want no request, got %d", got)
+		}
+	})
+
+	t.Run("cache eviction", func(t *testing.T) {
+		jwksCount.Store(0)
+		x5uCount.Store(0)
+		f := &RemoteKeyFinder{
+			Doer:            ts.Client(),
+			AllowedURLs:     []string{ts.URL + "/jwks.json", ts.URL + "/cert.pem"},
+			MaxCacheEntries: 1,
+		}
+		jku := NewHeader()
+		jku.SetAlgorithm(jwa.SignatureAlgorithmES256)
+		jku.SetJWKSetURL(mustParse(t, ts.URL+"/jwks.json"))
+		jku.SetKeyID("key-1")
+		x5u := NewHeader()
+		x5u.SetAlgorithm(jwa.SignatureAlgorithmES256)
+		x5u.SetX509URL(mustParse(t, ts.URL+"/cert.pem"))
+		for range 2 {
+			if _, err := f.FindKey(t.Context(), jku, nil); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := f.FindKey(t.Context(), x5u, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := jwksCount.Load(); got != 2 {
+			t.Errorf("want 2 requests, got %d", got)
+		}
+		if got := x5uCount.Load(); got != 2 {
+			t.Errorf("want 2 requests, got %d", got)
+		}
+		if got := f.lru.Len(); got != 1 {
+			t.Errorf("want 1 cached URL, got %d", got)
+		}
+	})
+
+	t.Run("not allowed", func(t *testing.T) {
+		jwksCount.Store(0)
+		h := NewHeader()
+		h.SetAlgorithm(jwa.SignatureAlgorithmES256)
+		h.SetJWKSetURL(mustParse(t, ts.URL+"/jwks.json?evil"))
+		h.SetKeyID("key-1")
+		if _, err := f.FindKey(t.Context(), h, nil); err == nil {
+			t.Error("want error, got nil")
+		}
+		if got := jwksCount.Load(); got != 0 {
+			t.Errorf("want no request, got %d", got)
+		}
+	})
+}
@@ -85,7 +85,7 @@ func TestRFC7520(t *testing.T) {
 				// general JSON serialization
 				if tv.Output.JSON != nil {
 					t.Log("verifying general JSON serialization")
-					data, err := json.Marshal(tv.Output.JSONFlat)
+					data, err := json.Marshal(tv.Output.JSON)
 					if err != nil {
 						t.Fatal(err)
 					}
@@ -96,10 +96,51 @@ func TestRFC7520(t *testing.T) {
 					testRFC7520VerifySigngleSignature(t, &tv, msg)
 				}
 			}
+
+			// encode into JSON serialization
+			if tv.Output.JSONFlat != nil {
+				t.Log("encoding flattened JSON serialization")
+				testRFC7520Marshal(t, tv.Output.JSONFlat, FormatFlattenedJSON, (*Message).MarshalFlattenedJSON)
+			}
+			if tv.Output.JSON != nil {
+				t.Log("encoding general JSON serialization")
+				testRFC7520Marshal(t, tv.Output.JSON, FormatGeneralJSON, (*Message).MarshalGeneralJSON)
+			}
 		})
 	}
 }
 
+func testRFC7520Marshal(t *testing.T, want map[string]any, format Format, marshal func(msg *Message) ([]byte, error)) {
+	t.Helper()
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Format() != format {
+		t.Errorf("unexpected format: want %s, got %s", format, msg.Format())
+	}
+
+	if _, ok := want["payload"]; ok {
+		data, err = marshal(msg)
+	} else {
+		data, err = msg.MarshalJSONDetached()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := jsonutils.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("JSON serialization mismatch (-want +got):\n%s", diff)
+	}
+}
+
 func testRFC7520VerifySigngleSignature(t *testing.T, tv *testVector, msg *Message) {
 	ctx := t.Context()
 
@@ -0,0 +1,165 @@
+package jws
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/shogo82148/goat/sig"
+)
+
+// SignReader signs the content read from r and returns a JWS message with detached content
+// described in RFC 7515 Appendix F.
+// The content is hashed incrementally, so it is not buffered in memory
+// if the key implements [sig.StreamingSigningKey].
+// Encode the returned message with [Message.CompactDetached] or [Message.MarshalJSONDetached],
+// and transfer the content by other means.
+func SignReader(protected, header *Header, r io.Reader, key sig.SigningKey) (*Message, error) {
+	// encode the header
+	h1, err := encodeHeader(protected)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(h1)
+	if err != nil {
+		return nil, err
+	}
+	raw = b64Encode(raw)
+
+	// RFC 7515 Section 7.2.1:
+	// The Header Parameter names in the two locations MUST be disjoint.
+	if header != nil {
+		h2, err := encodeHeader(header)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkDisjoint(h1, h2); err != nil {
+			return nil, err
+		}
+	}
+
+	// sign
+	s, err := sig.NewStream(key)
+	if err != nil {
+		return nil, fmt.Errorf("jws: failed to sign: %w", err)
+	}
+	if err := writeSigningInput(s, raw, r, protected.nb64); err != nil {
+		return nil, fmt.Errorf("jws: failed to sign: %w", err)
+	}
+	signature, err := s.Sign()
+	if err != nil {
+		return nil, fmt.Errorf("jws: failed to sign: %w", err)
+	}
+
+	return &Message{
+		Signatures: []*Signature{
+			{
+				protected:    protected,
+				header:       header,
+				rawProtected: raw,
+				b64signature: b64Encode(signature),
+				signature:    signature,
+			},
+		},
+		nb64: protected.nb64,
+	}, nil
+}
+
+// VerifyReader verifies the JWS message with the detached content read from r.
+// msg must not have the payload, e.g. it is parsed by [ParseCompact] or [Parse] from
+// the output of [Message.CompactDetached] or [Message.MarshalJSONDetached].
+// The content is hashed incrementally, so it is not buffered in memory
+// if the keys implement [sig.StreamingSigningKey].
+// The content is not returned; it is trusted only if err is nil.
+func (v *Verifier) VerifyReader(ctx context.Context, msg *Message, r io.Reader) (protected, unprotected *Header, err error) {
+	if v.AlgorithmVerifier == nil || v.KeyFinder == nil {
+		return nil, nil, errors.New("jws: verifier is not configured")
+	}
+	if len(msg.payload) != 0 {
+		return nil, nil, errors.New("jws: the payload is not detached")
+	}
+
+	// prepare the streams for the signatures.
+	results := make([]*SignatureResult, len(msg.Signatures))
+	streams := make([]sig.Stream, len(msg.Signatures))
+	writers := make([]io.Writer, 0, len(msg.Signatures))
+	for i, s := range msg.Signatures {
+		results[i] = &SignatureResult{Signature: s}
+		key, err := v.findKey(ctx, s)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		results[i].Key = key
+		stream, err := sig.NewStream(key)
+		if err != nil {
+			results[i].Err = fmt.Errorf("jws: failed to verify signature: %w", err)
+			continue
+		}
+		if _, err := stream.Write(s.rawProtected); err != nil {
+			results[i].Err = fmt.Errorf("jws: failed to verify signature: %w", err)
+			continue
+		}
+		if _, err := stream.Write([]byte{'.'}); err != nil {
+			results[i].Err = fmt.Errorf("jws: failed to verify signature: %w", err)
+			continue
+		}
+		streams[i] = stream
+		writers = append(writers, stream)
+	}
+
+	// read the content.
+	if len(writers) > 0 {
+		if err := writeContent(io.MultiWriter(writers...), r, msg.nb64); err != nil {
+			return nil, nil, fmt.Errorf("jws: failed to read the content: %w", err)
+		}
+	}
+
+	// verify the signatures.
+	for i, s := range msg.Signatures {
+		if streams[i] == nil {
+			continue
+		}
+		if err := streams[i].Verify(s.signature); err != nil {
+			results[i].Err = fmt.Errorf("jws: failed to verify signature: %w", err)
+		}
+	}
+
+	if err := v.signaturePolicy().AcceptSignatures(ctx, results); err != nil {
+		return nil, nil, errVerifyFailed
+	}
+	for _, r := range results {
+		if r.Err == nil {
+			return r.Signature.protected, r.Signature.header, nil
+		}
+	}
+	return nil, nil, errVerifyFailed
+}
+
+// writeSigningInput writes the JWS Signing Input into w.
+func writeSigningInput(w io.Writer, rawProtected []byte, r io.Reader, nb64 bool) error {
+	if _, err := w.Write(rawProtected); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte{'.'}); err != nil {
+		return err
+	}
+	return writeContent(w, r, nb64)
+}
+
+// writeContent writes the content read from r into w.
+// The content is base64url-encoded unless nb64 is true.
+func writeContent(w io.Writer, r io.Reader, nb64 bool) error {
+	if nb64 {
+		_, err := io.Copy(w, r)
+		return err
+	}
+	enc := base64.NewEncoder(b64, w)
+	if _, err := io.Copy(enc, r); err != nil {
+		return err
+	}
+	return enc.Close()
+}
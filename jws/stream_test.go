@@ -0,0 +1,256 @@
+package jws
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/shogo82148/goat/jwa"
+	_ "github.com/shogo82148/goat/jwa/ps" // for RSASSA-PSS
+	"github.com/shogo82148/goat/jwk"
+	"github.com/shogo82148/goat/sig"
+)
+
+func TestSignReader(t *testing.T) {
+	payload := []byte(strings.Repeat("It’s a dangerous business, Frodo, going out your door. ", 1000))
+
+	hsKey, err := jwk.ParseKey([]byte(`{"kty":"oct","k":"hJtXIZ2uSN5kbQfbtTNWbpdmhkV8FJG-Onbc6mxCcYg"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsKey, err := jwk.NewPrivateKey(rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	esKey, err := jwk.NewPrivateKey(ecdsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edKey, err := jwk.NewPrivateKey(ed25519Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		alg jwa.SignatureAlgorithm
+		key *jwk.Key
+	}{
+		{jwa.SignatureAlgorithmHS256, hsKey},
+		{jwa.SignatureAlgorithmRS256, rsKey},
+		{jwa.SignatureAlgorithmPS256, rsKey},
+		{jwa.SignatureAlgorithmES256, esKey},
+		{jwa.SignatureAlgorithmEdDSA, edKey}, // fall back to buffering
+	}
+	for _, tt := range tests {
+		for _, b64 := range []bool{true, false} {
+			name := tt.alg.String()
+			if !b64 {
+				name += "/b64=false"
+			}
+			t.Run(name, func(t *testing.T) {
+				h := NewHeader()
+				h.SetAlgorithm(tt.alg)
+				if !b64 {
+					h.SetBase64(false)
+					h.SetCritical([]string{jwa.Base64URLEncodePayloadKey})
+				}
+				key := tt.alg.New().NewSigningKey(tt.key)
+				msg, err := SignReader(h, nil, bytes.NewReader(payload), key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				data, err := msg.CompactDetached()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				v := &Verifier{
+					AlgorithmVerifier: AllowedAlgorithms{tt.alg},
+					KeyFinder:         &JWKKeyFinder{JWK: tt.key},
+				}
+
+				// verify with the streaming verifier.
+				parsed, err := ParseCompact(data)
+				if err != nil {
+					t.Fatal(err)
+				}
+				protected, _, err := v.VerifyReader(t.Context(), parsed, bytes.NewReader(payload))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if protected.Algorithm() != tt.alg {
+					t.Errorf("unexpected algorithm: want %s, got %s", tt.alg, protected.Algorithm())
+				}
+
+				// verify with the non-streaming verifier.
+				parsed, err = ParseCompactDetached(data, payload)
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, _, got, err := v.Verify(t.Context(), parsed)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, payload) {
+					t.Error("unexpected payload")
+				}
+
+				// tampered content.
+				parsed, err = ParseCompact(data)
+				if err != nil {
+					t.Fatal(err)
+				}
+				tampered := bytes.Clone(payload)
+				tampered[len(tampered)-1]++
+				if _, _, err := v.VerifyReader(t.Context(), parsed, bytes.NewReader(tampered)); err == nil {
+					t.Error("want error, got nil")
+				}
+			})
+		}
+	}
+}
+
+func TestVerifyReader(t *testing.T) {
+	// RFC 7520 Section 4.5. Signature with Detached Content
+	payload := "It’s a dangerous business, Frodo, going out your door. " +
+		"You step onto the road, and if you don't keep your feet, " +
+		"there’s no knowing where you might be swept off to."
+	rawKey := `{"kty":"oct",` +
+		`"kid":"018c0ae5-4d9b-471b-bfd6-eef314bc7037",` +
+		`"alg":"HS256",` +
+		`"k":"hJtXIZ2uSN5kbQfbtTNWbpdmhkV8FJG-Onbc6mxCcYg"}`
+	key, err := jwk.ParseKey([]byte(rawKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	flattened := `{"protected":"eyJhbGciOiJIUzI1NiIsImtpZCI6IjAxOGMwYWU1LTRkOWItNDcxYi1iZmQ2LWVlZjMxNGJjNzAzNyJ9",` +
+		`"signature":"s0h6KThzkfBBBkLspW1h84VsJZFTsPPqMDA7g1Md7p0"}`
+	v := &Verifier{
+		AlgorithmVerifier: AllowedAlgorithms{jwa.SignatureAlgorithmHS256},
+		KeyFinder:         &JWKKeyFinder{JWK: key},
+	}
+
+	t.Run("detached", func(t *testing.T) {
+		msg, err := Parse([]byte(flattened))
+		if err != nil {
+			t.Fatal(err)
+		}
+		protected, _, err := v.VerifyReader(t.Context(), msg, strings.NewReader(payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if protected.KeyID() != "018c0ae5-4d9b-471b-bfd6-eef314bc7037" {
+			t.Errorf("unexpected kid: %q", protected.KeyID())
+		}
+	})
+
+	t.Run("not detached", func(t *testing.T) {
+		msg, err := ParseDetached([]byte(flattened), []byte(payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := v.VerifyReader(t.Context(), msg, strings.NewReader(payload)); err == nil {
+			t.Error("want error, got nil")
+		}
+	})
+}
+
+func TestVerifyReader_SignaturePolicy(t *testing.T) {
+	newKey := func(t *testing.T, k string) *jwk.Key {
+		t.Helper()
+		key, err := jwk.ParseKey([]byte(`{"kty":"oct","k":"` + k + `"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	keys := map[string]*jwk.Key{
+		"alice": newKey(t, "AyM1SysPpbyDfgZld3umj1qzKObwVMkoqQ-EstJQLr_T-1qS0gZH75aKtMN3Yj0iPS4hcgUuTwjAzZr1Z9CAow"),
+		"bob":   newKey(t, "ajRkFwBAV3wmihgYdgU6HJFRH_0kbOsxSdGJJWCBgQhkD2RJZ1R1Qh6D6ug-XO1l0MQaf27meJbBL_J4NNExiA"),
+	}
+	finder := FindKeyFunc(func(_ context.Context, protected, unprotected *Header) (sig.SigningKey, error) {
+		key, ok := keys[protected.KeyID()]
+		if !ok {
+			return nil, errors.New("key not found")
+		}
+		return protected.Algorithm().New().NewSigningKey(key), nil
+	})
+	v := &Verifier{
+		AlgorithmVerifier: AllowedAlgorithms{jwa.SignatureAlgorithmHS256, jwa.SignatureAlgorithmHS512},
+		KeyFinder:         finder,
+		SignaturePolicy:   SignatureThreshold(2),
+	}
+
+	type signer struct {
+		kid string
+		alg jwa.SignatureAlgorithm
+	}
+	tests := []struct {
+		name       string
+		signers    []signer
+		wantFailed bool
+	}{
+		{
+			name:    "distinct keys",
+			signers: []signer{{"alice", jwa.SignatureAlgorithmHS256}, {"bob", jwa.SignatureAlgorithmHS256}},
+		},
+		{
+			name:       "same key with different algorithms",
+			signers:    []signer{{"alice", jwa.SignatureAlgorithmHS256}, {"alice", jwa.SignatureAlgorithmHS512}},
+			wantFailed: true,
+		},
+	}
+
+	payload := []byte("release v1.0.0")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := NewMessage(payload)
+			for _, s := range tt.signers {
+				protected := NewHeader()
+				protected.SetAlgorithm(s.alg)
+				protected.SetKeyID(s.kid)
+				if err := msg.Sign(protected, nil, s.alg.New().NewSigningKey(keys[s.kid])); err != nil {
+					t.Fatal(err)
+				}
+			}
+			data, err := msg.MarshalJSONDetached()
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed, err := Parse(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, _, err = v.VerifyReader(t.Context(), parsed, bytes.NewReader(payload))
+			if tt.wantFailed {
+				if err == nil {
+					t.Error("want error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -3,9 +3,11 @@ package jws
 import (
 	"context"
 	"errors"
+	"fmt"
 	"slices"
 
 	"github.com/shogo82148/goat/jwa"
+	"github.com/shogo82148/goat/sig"
 )
 
 var errVerifyFailed = errors.New("jws: failed to verify the message")
@@ -33,12 +35,153 @@ func (unsecureAnyAlgorithmVerifier) VerifyAlgorithm(ctx context.Context, alg jwa
 	return nil
 }
 
+// CriticalParameterVerifier verifies the value of an extension header parameter
+// that is listed in the "crit" header parameter.
+type CriticalParameterVerifier interface {
+	VerifyCriticalParameter(ctx context.Context, name string, value any, protected *Header) error
+}
+
+// VerifyCriticalParameterFunc is an adapter to allow the use of ordinary functions as CriticalParameterVerifier.
+type VerifyCriticalParameterFunc func(ctx context.Context, name string, value any, protected *Header) error
+
+func (f VerifyCriticalParameterFunc) VerifyCriticalParameter(ctx context.Context, name string, value any, protected *Header) error {
+	return f(ctx, name, value, protected)
+}
+
+// SignatureResult is the result of verifying a signature of the JWS message.
+type SignatureResult struct {
+	// Signature is the verified signature.
+	Signature *Signature
+
+	// Err is nil if the signature is valid.
+	// Otherwise, it describes why the verification failed.
+	Err error
+
+	// Key is the key found by the KeyFinder.
+	// It is nil if the key is not found.
+	Key sig.SigningKey
+}
+
+// KeyID returns the "kid" header parameter in the protected header of the signature.
+// The "kid" in the unprotected header is ignored, because it is not integrity protected.
+func (r *SignatureResult) KeyID() string {
+	if r.Signature.protected == nil {
+		return ""
+	}
+	return r.Signature.protected.KeyID()
+}
+
+// SameKey reports whether r and other are valid signatures made by the same key.
+// The keys are compared by their public keys or their shared secrets (see [sig.SameKey]),
+// so a key used with different algorithms, e.g. RS256 and PS256, is the same key.
+func (r *SignatureResult) SameKey(other *SignatureResult) bool {
+	if r.Err != nil || other.Err != nil {
+		return false
+	}
+	return sig.SameKey(r.Key, other.Key)
+}
+
+// SignaturePolicy decides whether the JWS message is accepted
+// from the results of verifying its signatures.
+type SignaturePolicy interface {
+	AcceptSignatures(ctx context.Context, results []*SignatureResult) error
+}
+
+// SignaturePolicyAny accepts the message if at least one of the signatures is valid.
+// It is the default policy.
+var SignaturePolicyAny = signaturePolicyAny{}
+
+type signaturePolicyAny struct{}
+
+func (signaturePolicyAny) AcceptSignatures(ctx context.Context, results []*SignatureResult) error {
+	for _, r := range results {
+		if r.Err == nil {
+			return nil
+		}
+	}
+	return errors.New("jws: no valid signature")
+}
+
+// SignaturePolicyAll accepts the message only if all of the signatures are valid.
+var SignaturePolicyAll = signaturePolicyAll{}
+
+type signaturePolicyAll struct{}
+
+func (signaturePolicyAll) AcceptSignatures(ctx context.Context, results []*SignatureResult) error {
+	if len(results) == 0 {
+		return errors.New("jws: no valid signature")
+	}
+	for i, r := range results {
+		if r.Err != nil {
+			return fmt.Errorf("jws: signature %d is invalid: %w", i, r.Err)
+		}
+	}
+	return nil
+}
+
+// SignatureThreshold accepts the message if it has valid signatures made by
+// at least the specified number of distinct keys.
+// The keys are distinguished by the keys that verified the signatures (see [SignatureResult.SameKey]),
+// not by the "kid" header parameter.
+// The keys returned by the KeyFinder must implement [sig.IdentifiableSigningKey] to be distinguished.
+type SignatureThreshold int
+
+func (n SignatureThreshold) AcceptSignatures(ctx context.Context, results []*SignatureResult) error {
+	if n <= 0 {
+		return errors.New("jws: invalid signature threshold")
+	}
+	var distinct []*SignatureResult
+	for _, r := range results {
+		if r.Err != nil || slices.ContainsFunc(distinct, r.SameKey) {
+			continue
+		}
+		distinct = append(distinct, r)
+	}
+	if len(distinct) < int(n) {
+		return fmt.Errorf("jws: %d valid signatures from distinct keys, but %d are required", len(distinct), n)
+	}
+	return nil
+}
+
+// RequiredKeyIDs accepts the message only if all the specified keys have valid signatures.
+// The keys are identified by the "kid" parameter in the protected header,
+// and each of them must be a distinct key (see [SignatureResult.SameKey]).
+type RequiredKeyIDs []string
+
+func (kids RequiredKeyIDs) AcceptSignatures(ctx context.Context, results []*SignatureResult) error {
+	if len(kids) == 0 {
+		return errors.New("jws: no required key ID")
+	}
+	used := make([]*SignatureResult, 0, len(kids))
+KID_LOOP:
+	for _, kid := range kids {
+		for _, r := range results {
+			if r.Err == nil && r.KeyID() == kid && !slices.ContainsFunc(used, r.SameKey) {
+				used = append(used, r)
+				continue KID_LOOP
+			}
+		}
+		return fmt.Errorf("jws: no valid signature for kid %q", kid)
+	}
+	return nil
+}
+
 // Verifier verifies the JWS message.
 type Verifier struct {
 	_NamedFieldsRequired struct{}
 
 	AlgorithmVerifier AlgorithmVerifier
 	KeyFinder         KeyFinder
+
+	// CriticalParameters is the registry of the extension header parameters that the verifier understands.
+	// The signatures that list a parameter not in the registry in the "crit" header parameter are rejected.
+	// The "b64" header parameter defined in RFC 7797 is always understood.
+	// If the CriticalParameterVerifier is nil, any value of the parameter is accepted.
+	CriticalParameters map[string]CriticalParameterVerifier
+
+	// SignaturePolicy is the policy for the messages that have multiple signatures.
+	// If it is nil, SignaturePolicyAny is used.
+	SignaturePolicy SignaturePolicy
 }
 
 // Verify verifies the JWS message.
@@ -62,17 +205,64 @@ func (v *Verifier) Verify(ctx context.Context, msg *Message) (protected, unprote
 	return v.verify(ctx, msg, content, sigContent)
 }
 
+// VerifyContent verifies the JWS message with the detached content described in RFC 7515 Appendix F.
 func (v *Verifier) VerifyContent(ctx context.Context, msg *Message, content []byte) (protected, unprotected *Header, payload []byte, err error) {
 	if v.AlgorithmVerifier == nil || v.KeyFinder == nil {
 		return nil, nil, nil, errors.New("jws: verifier is not configured")
 	}
 
+	if msg.nb64 {
+		return v.verify(ctx, msg, content, content)
+	}
 	b64Content := b64Encode(content)
 	return v.verify(ctx, msg, content, b64Content)
 }
 
+// VerifySignatures verifies all the signatures of the JWS message and applies SignaturePolicy.
+// It returns the results of each signature, even if the message is rejected by the policy.
+func (v *Verifier) VerifySignatures(ctx context.Context, msg *Message) (results []*SignatureResult, payload []byte, err error) {
+	if v.AlgorithmVerifier == nil || v.KeyFinder == nil {
+		return nil, nil, errors.New("jws: verifier is not configured")
+	}
+
+	content := msg.payload
+	if !msg.nb64 {
+		content, err = b64Decode(msg.payload)
+		if err != nil {
+			return nil, nil, fmt.Errorf("jws: failed to parse payload: %w", err)
+		}
+	}
+	results = v.verifySignatures(ctx, msg, msg.payload)
+	if err := v.signaturePolicy().AcceptSignatures(ctx, results); err != nil {
+		return results, nil, err
+	}
+	return results, content, nil
+}
+
+func (v *Verifier) signaturePolicy() SignaturePolicy {
+	if v.SignaturePolicy == nil {
+		return SignaturePolicyAny
+	}
+	return v.SignaturePolicy
+}
+
 func (v *Verifier) verify(ctx context.Context, msg *Message, rawContent, sigContent []byte) (protected, unprotected *Header, payload []byte, err error) {
+	results := v.verifySignatures(ctx, msg, sigContent)
+	if err := v.signaturePolicy().AcceptSignatures(ctx, results); err != nil {
+		return nil, nil, nil, errVerifyFailed
+	}
+	for _, r := range results {
+		if r.Err == nil {
+			return r.Signature.protected, r.Signature.header, rawContent, nil
+		}
+	}
+	return nil, nil, nil, errVerifyFailed
+}
+
+// verifySignatures verifies each signature of msg.
+func (v *Verifier) verifySignatures(ctx context.Context, msg *Message, sigContent []byte) []*SignatureResult {
 	_ = v._NamedFieldsRequired
+
 	// pre-allocate buffer
 	size := 0
 	for _, sig := range msg.Signatures {
@@ -83,31 +273,95 @@ func (v *Verifier) verify(ctx context.Context, msg *Message, rawContent, sigCont
 	size += len(sigContent) + 1 // +1 for '.'
 	buf := make([]byte, size)
 
+	results := make([]*SignatureResult, 0, len(msg.Signatures))
 	for _, sig := range msg.Signatures {
-		var alg jwa.SignatureAlgorithm
-		if sig.protected != nil {
-			alg = sig.protected.alg
-		} else if sig.header != nil {
-			alg = sig.header.alg
+		buf = buf[:0]
+		buf = append(buf, sig.rawProtected...)
+		buf = append(buf, '.')
+		buf = append(buf, sigContent...)
+		results = append(results, v.verifySignature(ctx, sig, buf))
+	}
+	return results
+}
+
+// verifySignature verifies sig over the signing input.
+func (v *Verifier) verifySignature(ctx context.Context, sig *Signature, input []byte) *SignatureResult {
+	r := &SignatureResult{
+		Signature: sig,
+	}
+	key, err := v.findKey(ctx, sig)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	r.Key = key
+	if err := key.Verify(input, sig.signature); err != nil {
+		r.Err = fmt.Errorf("jws: failed to verify signature: %w", err)
+	}
+	return r
+}
+
+// findKey verifies the headers of s and finds the key for verifying s.
+func (v *Verifier) findKey(ctx context.Context, s *Signature) (sig.SigningKey, error) {
+	alg := lookupAlgorithm(s.protected, s.header)
+	if alg == jwa.SignatureAlgorithmUnknown {
+		return nil, errors.New("jws: algorithm is missing")
+	}
+	if err := v.AlgorithmVerifier.VerifyAlgorithm(ctx, alg); err != nil {
+		return nil, fmt.Errorf("jws: failed to verify algorithm: %w", err)
+	}
+	if err := v.verifyCritical(ctx, s.protected, s.header); err != nil {
+		return nil, err
+	}
+	key, err := v.KeyFinder.FindKey(ctx, s.protected, s.header)
+	if err != nil {
+		return nil, fmt.Errorf("jws: failed to find key: %w", err)
+	}
+	return key, nil
+}
+
+// verifyCritical verifies the "crit" header parameter defined in RFC 7515 Section 4.1.11.
+func (v *Verifier) verifyCritical(ctx context.Context, protected, unprotected *Header) error {
+	// the "crit" header parameter must be integrity protected.
+	if unprotected != nil {
+		if _, ok := unprotected.Raw[jwa.CriticalKey]; ok || unprotected.crit != nil {
+			return errors.New("jws: crit must be in the protected header")
 		}
-		if alg == jwa.SignatureAlgorithmUnknown {
+	}
+	if protected == nil {
+		return nil
+	}
+	if _, ok := protected.Raw[jwa.CriticalKey]; ok && len(protected.crit) == 0 {
+		return errors.New("jws: crit must not be empty")
+	}
+
+	for _, name := range protected.crit {
+		if name == jwa.Base64URLEncodePayloadKey {
+			// RFC 7797 is built-in.
 			continue
 		}
-		if err := v.AlgorithmVerifier.VerifyAlgorithm(ctx, alg); err != nil {
-			continue
+		if slices.Contains(knownParams[:], name) {
+			return fmt.Errorf("jws: %q must not be in crit", name)
 		}
-		key, err := v.KeyFinder.FindKey(ctx, sig.protected, sig.header)
-		if err != nil {
+		value, ok := protected.Raw[name]
+		if !ok {
+			return fmt.Errorf("jws: the critical parameter %q is missing", name)
+		}
+		pv, ok := v.CriticalParameters[name]
+		if !ok {
+			return fmt.Errorf("jws: unknown parameter is in crit: %q", name)
+		}
+		if pv == nil {
 			continue
 		}
-		buf = buf[:0]
-		buf = append(buf, sig.rawProtected...)
-		buf = append(buf, '.')
-		buf = append(buf, sigContent...)
-		err = key.Verify(buf, sig.signature)
-		if err == nil {
-			return sig.protected, sig.header, rawContent, nil
+		if err := pv.VerifyCriticalParameter(ctx, name, value, protected); err != nil {
+			return err
 		}
 	}
-	return nil, nil, nil, errVerifyFailed
+
+	// RFC 7797 Section 6: "b64" must be listed in "crit" when it is used.
+	if protected.nb64 && !slices.Contains(protected.crit, jwa.Base64URLEncodePayloadKey) {
+		return errors.New("jws: b64 must be in crit")
+	}
+	return nil
 }
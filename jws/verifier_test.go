@@ -0,0 +1,448 @@
+package jws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shogo82148/goat/jwa"
+	"github.com/shogo82148/goat/jwk"
+	"github.com/shogo82148/goat/sig"
+)
+
+func TestVerifier_Critical(t *testing.T) {
+	rawKey := `{"kty":"oct",` +
+		`"k":"AyM1SysPpbyDfgZld3umj1qzKObwVMkoqQ-EstJQLr_T-1qS0gZH75` +
+		`aKtMN3Yj0iPS4hcgUuTwjAzZr1Z9CAow"` +
+		`}`
+	key, err := jwk.ParseKey([]byte(rawKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := jwa.SignatureAlgorithmHS256.New().NewSigningKey(key)
+	finder := FindKeyFunc(func(_ context.Context, protected, unprotected *Header) (sig.SigningKey, error) {
+		return k, nil
+	})
+
+	// sign signs payload and parses it as a compact serialized JWS.
+	sign := func(t *testing.T, protected *Header, payload []byte) *Message {
+		t.Helper()
+		var msg *Message
+		if protected.Base64() {
+			msg = NewMessage(payload)
+		} else {
+			msg = NewRawMessage(payload)
+		}
+		if err := msg.Sign(protected, nil, k); err != nil {
+			t.Fatal(err)
+		}
+		data, err := msg.Compact()
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, err = ParseCompact(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+
+	// header returns a new protected header from JSON.
+	header := func(t *testing.T, s string) *Header {
+		t.Helper()
+		h := NewHeader()
+		if err := h.UnmarshalJSON([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+
+	// RFC 8225 Section 8.1. "ppt" (PASSporT) Header Parameter
+	ppt := VerifyCriticalParameterFunc(func(ctx context.Context, name string, value any, protected *Header) error {
+		if value != "shaken" {
+			return errors.New("unsupported ppt")
+		}
+		return nil
+	})
+
+	// Open Banking UK "http://openbanking.org.uk/iat" Header Parameter
+	now := time.Unix(1300819380, 0)
+	obiat := VerifyCriticalParameterFunc(func(ctx context.Context, name string, value any, protected *Header) error {
+		n, ok := value.(json.Number)
+		if !ok {
+			return errors.New("invalid type of iat")
+		}
+		iat, err := n.Int64()
+		if err != nil {
+			return err
+		}
+		if time.Unix(iat, 0).After(now) {
+			return errors.New("iat is in the future")
+		}
+		return nil
+	})
+
+	tests := []struct {
+		name       string
+		protected  string
+		registry   map[string]CriticalParameterVerifier
+		wantFailed bool
+	}{
+		{
+			name:      "no crit",
+			protected: `{"alg":"HS256"}`,
+		},
+		{
+			name:       "unknown parameter",
+			protected:  `{"alg":"HS256","ppt":"shaken","crit":["ppt"]}`,
+			wantFailed: true,
+		},
+		{
+			name:      "registered parameter without verifier",
+			protected: `{"alg":"HS256","ppt":"shaken","crit":["ppt"]}`,
+			registry: map[string]CriticalParameterVerifier{
+				"ppt": nil,
+			},
+		},
+		{
+			name:      "registered parameter",
+			protected: `{"alg":"HS256","ppt":"shaken","crit":["ppt"]}`,
+			registry: map[string]CriticalParameterVerifier{
+				"ppt": ppt,
+			},
+		},
+		{
+			name:      "invalid value",
+			protected: `{"alg":"HS256","ppt":"div","crit":["ppt"]}`,
+			registry: map[string]CriticalParameterVerifier{
+				"ppt": ppt,
+			},
+			wantFailed: true,
+		},
+		{
+			name:      "openbanking iat",
+			protected: `{"alg":"HS256","http://openbanking.org.uk/iat":1300819380,"crit":["http://openbanking.org.uk/iat"]}`,
+			registry: map[string]CriticalParameterVerifier{
+				"http://openbanking.org.uk/iat": obiat,
+			},
+		},
+		{
+			name:      "openbanking iat in the future",
+			protected: `{"alg":"HS256","http://openbanking.org.uk/iat":1300819381,"crit":["http://openbanking.org.uk/iat"]}`,
+			registry: map[string]CriticalParameterVerifier{
+				"http://openbanking.org.uk/iat": obiat,
+			},
+			wantFailed: true,
+		},
+		{
+			name:      "one of the parameters is unknown",
+			protected: `{"alg":"HS256","ppt":"shaken","exp":1300819380,"crit":["ppt","exp"]}`,
+			registry: map[string]CriticalParameterVerifier{
+				"ppt": ppt,
+			},
+			wantFailed: true,
+		},
+		{
+			name:      "missing parameter",
+			protected: `{"alg":"HS256","crit":["ppt"]}`,
+			registry: map[string]CriticalParameterVerifier{
+				"ppt": nil,
+			},
+			wantFailed: true,
+		},
+		{
+			name:       "parameter defined in RFC 7515",
+			protected:  `{"alg":"HS256","kid":"foo","crit":["kid"]}`,
+			wantFailed: true,
+		},
+		{
+			name:       "empty crit",
+			protected:  `{"alg":"HS256","crit":[]}`,
+			wantFailed: true,
+		},
+		{
+			name:      "b64",
+			protected: `{"alg":"HS256","b64":false,"crit":["b64"]}`,
+		},
+		{
+			name:       "b64 without crit",
+			protected:  `{"alg":"HS256","b64":false}`,
+			wantFailed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := sign(t, header(t, tt.protected), []byte("hello"))
+			v := &Verifier{
+				AlgorithmVerifier:  AllowedAlgorithms{jwa.SignatureAlgorithmHS256},
+				KeyFinder:          finder,
+				CriticalParameters: tt.registry,
+			}
+			_, _, _, err := v.Verify(t.Context(), msg)
+			if tt.wantFailed {
+				if err == nil {
+					t.Error("want error, but not")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	t.Run("crit in the unprotected header", func(t *testing.T) {
+		protected := NewHeader()
+		protected.SetAlgorithm(jwa.SignatureAlgorithmHS256)
+		unprotected := NewHeader()
+		unprotected.Raw["ppt"] = "shaken"
+		unprotected.SetCritical([]string{"ppt"})
+		msg := NewMessage([]byte("$.02"))
+		if err := msg.Sign(protected, unprotected, k); err != nil {
+			t.Fatal(err)
+		}
+		v := &Verifier{
+			AlgorithmVerifier: AllowedAlgorithms{jwa.SignatureAlgorithmHS256},
+			KeyFinder:         finder,
+			CriticalParameters: map[string]CriticalParameterVerifier{
+				"ppt": nil,
+			},
+		}
+		if _, _, _, err := v.Verify(t.Context(), msg); err == nil {
+			t.Error("want error, but not")
+		}
+	})
+}
+
+func TestVerifier_SignaturePolicy(t *testing.T) {
+	newKey := func(t *testing.T, k string) *jwk.Key {
+		t.Helper()
+		key, err := jwk.ParseKey([]byte(`{"kty":"oct","k":"` + k + `"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	keys := map[string]*jwk.Key{
+		"alice": newKey(t, "AyM1SysPpbyDfgZld3umj1qzKObwVMkoqQ-EstJQLr_T-1qS0gZH75aKtMN3Yj0iPS4hcgUuTwjAzZr1Z9CAow"),
+		"bob":   newKey(t, "hJtXIZ2uSN5kbQfbtTNWbpdmhkV8FJG-Onbc6mxCcYg"),
+		"carol": newKey(t, "GawgguFyGrWKav7AX4VKUg"),
+	}
+	finder := FindKeyFunc(func(_ context.Context, protected, unprotected *Header) (sig.SigningKey, error) {
+		key, ok := keys[protected.KeyID()]
+		if !ok {
+			return nil, errors.New("key not found")
+		}
+		return protected.Algorithm().New().NewSigningKey(key), nil
+	})
+
+	// sign signs the payload by the signers.
+	// the signing key of "mallory" is unknown to the verifier.
+	sign := func(t *testing.T, signers ...string) *Message {
+		t.Helper()
+		msg := NewMessage([]byte("release v1.0.0"))
+		for _, kid := range signers {
+			key, ok := keys[kid]
+			if !ok {
+				key = newKey(t, "AyM1SysPpbyDfgZld3umj1qzKObwVMkoqQ-EstJQLr_T-1qS0gZH75aKtMN3Yj0iPS4hcgUuTwjAzZr1Z9CAow")
+				kid = "carol" // pretend to be carol
+			}
+			protected := NewHeader()
+			protected.SetAlgorithm(jwa.SignatureAlgorithmHS256)
+			protected.SetKeyID(kid)
+			if err := msg.Sign(protected, nil, jwa.SignatureAlgorithmHS256.New().NewSigningKey(key)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return msg
+	}
+
+	tests := []struct {
+		name       string
+		signers    []string
+		policy     SignaturePolicy
+		wantValid  []bool
+		wantFailed bool
+	}{
+		{
+			name:      "any",
+			signers:   []string{"mallory", "alice"},
+			policy:    nil,
+			wantValid: []bool{false, true},
+		},
+		{
+			name:       "any: no valid signature",
+			signers:    []string{"mallory"},
+			policy:     SignaturePolicyAny,
+			wantValid:  []bool{false},
+			wantFailed: true,
+		},
+		{
+			name:      "all",
+			signers:   []string{"alice", "bob"},
+			policy:    SignaturePolicyAll,
+			wantValid: []bool{true, true},
+		},
+		{
+			name:       "all: one of the signatures is invalid",
+			signers:    []string{"alice", "mallory"},
+			policy:     SignaturePolicyAll,
+			wantValid:  []bool{true, false},
+			wantFailed: true,
+		},
+		{
+			name:      "2 of 3",
+			signers:   []string{"alice", "mallory", "bob"},
+			policy:    SignatureThreshold(2),
+			wantValid: []bool{true, false, true},
+		},
+		{
+			name:       "2 of 3: only one valid signature",
+			signers:    []string{"alice", "mallory"},
+			policy:     SignatureThreshold(2),
+			wantValid:  []bool{true, false},
+			wantFailed: true,
+		},
+		{
+			name:       "2 of 3: same key",
+			signers:    []string{"alice", "alice"},
+			policy:     SignatureThreshold(2),
+			wantValid:  []bool{true, true},
+			wantFailed: true,
+		},
+		{
+			name:      "required key IDs",
+			signers:   []string{"alice", "bob", "mallory"},
+			policy:    RequiredKeyIDs{"alice", "bob"},
+			wantValid: []bool{true, true, false},
+		},
+		{
+			name:       "required key IDs: missing",
+			signers:    []string{"alice", "mallory"},
+			policy:     RequiredKeyIDs{"alice", "carol"},
+			wantValid:  []bool{true, false},
+			wantFailed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := sign(t, tt.signers...)
+			v := &Verifier{
+				AlgorithmVerifier: AllowedAlgorithms{jwa.SignatureAlgorithmHS256},
+				KeyFinder:         finder,
+				SignaturePolicy:   tt.policy,
+			}
+
+			results, payload, err := v.VerifySignatures(t.Context(), msg)
+			if len(results) != len(tt.wantValid) {
+				t.Fatalf("unexpected number of results: %d", len(results))
+			}
+			for i, r := range results {
+				if r.Signature != msg.Signatures[i] {
+					t.Errorf("results[%d]: unexpected signature", i)
+				}
+				if valid := r.Err == nil; valid != tt.wantValid[i] {
+					t.Errorf("results[%d]: want valid %t, got error %v", i, tt.wantValid[i], r.Err)
+				}
+			}
+
+			_, _, payload2, err2 := v.Verify(t.Context(), msg)
+			if tt.wantFailed {
+				if err == nil {
+					t.Error("want error, but not")
+				}
+				if err2 == nil {
+					t.Error("want error, but not")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err2 != nil {
+				t.Fatal(err2)
+			}
+			if string(payload) != "release v1.0.0" || string(payload2) != "release v1.0.0" {
+				t.Errorf("unexpected payload: %q, %q", payload, payload2)
+			}
+		})
+	}
+}
+
+func TestVerifier_SignaturePolicy_KeyFinderIgnoresKeyID(t *testing.T) {
+	key, err := jwk.ParseKey([]byte(`{"kty":"oct","k":"ajRkFwBAV3wmihgYdgU6HJFRH_0kbOsxSdGJJWCBgQhkD2RJZ1R1Qh6D6ug-XO1l0MQaf27meJbBL_J4NNExiA"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the finder returns the same key regardless of "kid",
+	// like X509KeyFinder and EmbeddedJWKKeyFinder.
+	finder := FindKeyFunc(func(_ context.Context, protected, unprotected *Header) (sig.SigningKey, error) {
+		return protected.Algorithm().New().NewSigningKey(key), nil
+	})
+
+	// copy one valid signature under different unprotected "kid"s.
+	copied := NewMessage([]byte("release v1.0.0"))
+	protected := NewHeader()
+	protected.SetAlgorithm(jwa.SignatureAlgorithmHS256)
+	if err := copied.Sign(protected, nil, jwa.SignatureAlgorithmHS256.New().NewSigningKey(key)); err != nil {
+		t.Fatal(err)
+	}
+	orig := copied.Signatures[0]
+	copied.Signatures = nil
+	for _, kid := range []string{"alice", "bob", "carol"} {
+		unprotected := NewHeader()
+		unprotected.SetKeyID(kid)
+		s := *orig
+		s.header = unprotected
+		copied.Signatures = append(copied.Signatures, &s)
+	}
+
+	// sign by one key under different protected "kid"s.
+	resigned := NewMessage([]byte("release v1.0.0"))
+	for _, kid := range []string{"alice", "bob", "carol"} {
+		protected := NewHeader()
+		protected.SetAlgorithm(jwa.SignatureAlgorithmHS256)
+		protected.SetKeyID(kid)
+		if err := resigned.Sign(protected, nil, jwa.SignatureAlgorithmHS256.New().NewSigningKey(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// sign by one key under different algorithms.
+	algorithms := NewMessage([]byte("release v1.0.0"))
+	for kid, alg := range map[string]jwa.SignatureAlgorithm{
+		"alice": jwa.SignatureAlgorithmHS256,
+		"bob":   jwa.SignatureAlgorithmHS384,
+		"carol": jwa.SignatureAlgorithmHS512,
+	} {
+		protected := NewHeader()
+		protected.SetAlgorithm(alg)
+		protected.SetKeyID(kid)
+		if err := algorithms.Sign(protected, nil, alg.New().NewSigningKey(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, msg := range map[string]*Message{"copied": copied, "resigned": resigned, "algorithms": algorithms} {
+		for _, policy := range []SignaturePolicy{SignatureThreshold(2), RequiredKeyIDs{"alice", "bob"}} {
+			v := &Verifier{
+				AlgorithmVerifier: AllowedAlgorithms{jwa.SignatureAlgorithmHS256, jwa.SignatureAlgorithmHS384, jwa.SignatureAlgorithmHS512},
+				KeyFinder:         finder,
+				SignaturePolicy:   policy,
+			}
+			results, _, err := v.VerifySignatures(t.Context(), msg)
+			if err == nil {
+				t.Errorf("%s, %v: want error, but not", name, policy)
+			}
+			for i, r := range results {
+				if r.Err != nil {
+					t.Errorf("%s, %v: results[%d]: unexpected error: %v", name, policy, i, r.Err)
+				}
+			}
+		}
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"errors"
 	"reflect"
 	"testing"
-	"time"
 
 	_ "github.com/shogo82148/goat/jwa/es" // for ECDSA
 	_ "github.com/shogo82148/goat/jwa/hs" // for HMAC SHA-256
@@ -64,6 +63,7 @@ func NewFuzzJWTFunc(key *jwk.Key) func(t *testing.T, data string) {
 			AlgorithmVerifier:     UnsecureAnyAlgorithm,
 			IssuerSubjectVerifier: UnsecureAnyIssuerSubject,
 			AudienceVerifier:      UnsecureAnyAudience,
+			Clock:                 testClock,
 		}
 		token1, err := p.Parse(ctx, []byte(data))
 		if err != nil {
@@ -84,6 +84,7 @@ func NewFuzzJWTFunc(key *jwk.Key) func(t *testing.T, data string) {
 			AlgorithmVerifier:     UnsecureAnyAlgorithm,
 			IssuerSubjectVerifier: UnsecureAnyIssuerSubject,
 			AudienceVerifier:      UnsecureAnyAudience,
+			Clock:                 testClock,
 		}
 		token2, err := p.Parse(ctx, signed)
 		if err != nil {
@@ -97,10 +98,6 @@ func NewFuzzJWTFunc(key *jwk.Key) func(t *testing.T, data string) {
 }
 
 func FuzzJWT_octKey(f *testing.F) {
-	mockTime(f, func() time.Time {
-		return time.Unix(1300819379, 0)
-	})
-
 	for _, data := range jwtPayloads {
 		f.Add(data)
 	}
@@ -117,10 +114,6 @@ func FuzzJWT_octKey(f *testing.F) {
 }
 
 func FuzzJWT_RSAKey(f *testing.F) {
-	mockTime(f, func() time.Time {
-		return time.Unix(1300819379, 0)
-	})
-
 	for _, data := range jwtPayloads {
 		f.Add(data)
 	}
@@ -163,10 +156,6 @@ func FuzzJWT_RSAKey(f *testing.F) {
 }
 
 func FuzzJWT_ECKey(f *testing.F) {
-	mockTime(f, func() time.Time {
-		return time.Unix(1300819379, 0)
-	})
-
 	for _, data := range jwtPayloads {
 		f.Add(data)
 	}
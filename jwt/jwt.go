@@ -2,6 +2,7 @@
 package jwt
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -14,7 +15,6 @@ import (
 )
 
 var b64 = base64.RawURLEncoding
-var nowFunc = time.Now // for testing
 
 // Claims is a JWT Claims Set defined in RFC 7519.
 type Claims struct {
@@ -51,6 +51,12 @@ type Token struct {
 }
 
 func Sign(header *jws.Header, claims *Claims, key sig.SigningKey) ([]byte, error) {
+	return SignContext(context.Background(), header, claims, key)
+}
+
+// SignContext is same as Sign, but ctx is passed to key if it implements [sig.ContextSigningKey],
+// so signing by a remote key can be cancelled and time-limited.
+func SignContext(ctx context.Context, header *jws.Header, claims *Claims, key sig.SigningKey) ([]byte, error) {
 	payload, err := encodeClaims(claims)
 	if err != nil {
 		return nil, err
@@ -69,13 +75,13 @@ func Sign(header *jws.Header, claims *Claims, key sig.SigningKey) ([]byte, error
 	b64.Encode(buf[l1+1:l1+1+l2:l1+1+l2], payload)
 
 	// sign
-	sig, err := key.Sign(buf[:l1+1+l2])
+	signature, err := sig.SignContext(ctx, key, buf[:l1+1+l2])
 	if err != nil {
 		return nil, err
 	}
 
 	// encode signature to base64
-	l3 := b64.EncodedLen(len(sig))
+	l3 := b64.EncodedLen(len(signature))
 	if len(buf) < l1+l2+l3+2 {
 		tmp := make([]byte, l1+l2+l3+2)
 		copy(tmp, buf)
@@ -84,7 +90,7 @@ func Sign(header *jws.Header, claims *Claims, key sig.SigningKey) ([]byte, error
 		buf = buf[:l1+l2+l3+2]
 	}
 	buf[l1+1+l2] = '.'
-	b64.Encode(buf[l1+l2+2:], sig)
+	b64.Encode(buf[l1+l2+2:], signature)
 	return buf, nil
 }
 
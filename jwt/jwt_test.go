@@ -2,11 +2,19 @@ package jwt
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/base64"
+	"math/big"
 	"testing"
 	"time"
 
+	"github.com/shogo82148/goat"
 	"github.com/shogo82148/goat/jwa"
+	_ "github.com/shogo82148/goat/jwa/es"   // for ECDSA
 	_ "github.com/shogo82148/goat/jwa/hs"   // for HMAC SHA-256
 	_ "github.com/shogo82148/goat/jwa/none" // for none
 	"github.com/shogo82148/goat/jwk"
@@ -14,18 +22,12 @@ import (
 	"github.com/shogo82148/goat/sig"
 )
 
-// mockTime overwrites current time for testing.
-func mockTime(t testing.TB, f func() time.Time) {
-	g := nowFunc
-	nowFunc = f
-	t.Cleanup(func() { nowFunc = g })
-}
+// testClock is the clock for testing.
+var testClock = goat.ClockFunc(func() time.Time {
+	return time.Unix(1300819379, 0)
+})
 
 func TestParse(t *testing.T) {
-	mockTime(t, func() time.Time {
-		return time.Unix(1300819379, 0)
-	})
-
 	t.Run("RFC 7519 Section 3.1. Example JWT", func(t *testing.T) {
 		ctx := t.Context()
 
@@ -50,6 +52,7 @@ func TestParse(t *testing.T) {
 			AlgorithmVerifier:     AllowedAlgorithms{jwa.SignatureAlgorithmHS256},
 			IssuerSubjectVerifier: Issuer("joe"),
 			AudienceVerifier:      UnsecureAnyAudience,
+			Clock:                 testClock,
 		}
 		token, err := p.Parse(ctx, raw)
 		if err != nil {
@@ -86,6 +89,7 @@ func TestParse(t *testing.T) {
 			AlgorithmVerifier:     AllowedAlgorithms{jwa.SignatureAlgorithmNone},
 			IssuerSubjectVerifier: Issuer("joe"),
 			AudienceVerifier:      UnsecureAnyAudience,
+			Clock:                 testClock,
 		}
 		token, err := p.Parse(ctx, raw)
 		if err != nil {
@@ -109,10 +113,6 @@ func TestParse_Claims(t *testing.T) {
 	ctx := t.Context()
 
 	var now time.Time
-	mockTime(t, func() time.Time {
-		return now
-	})
-
 	p := &Parser{
 		KeyFinder: FindKeyFunc(func(_ context.Context, header *jws.Header) (sig.SigningKey, error) {
 			alg := jwa.SignatureAlgorithmNone.New()
@@ -121,6 +121,9 @@ func TestParse_Claims(t *testing.T) {
 		AlgorithmVerifier:     AllowedAlgorithms{jwa.SignatureAlgorithmNone},
 		IssuerSubjectVerifier: UnsecureAnyIssuerSubject,
 		AudienceVerifier:      UnsecureAnyAudience,
+		Clock: goat.ClockFunc(func() time.Time {
+			return now
+		}),
 	}
 
 	var err error
@@ -161,6 +164,208 @@ func TestParse_Claims(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
+
+	// test "iat" claim
+	token = []byte(`{"iat":1300819380}`)
+	data = []byte(
+		"eyJhbGciOiJub25lIn0." + // {"alg":"none"}
+			base64.RawURLEncoding.EncodeToString(token) + ".")
+
+	now = time.Unix(1300819380, -1) // 1ns before the token is issued
+	_, err = p.Parse(ctx, data)
+	if err == nil {
+		t.Error("want some error, but not")
+	}
+
+	now = time.Unix(1300819380, 0) // just issued
+	_, err = p.Parse(ctx, data)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParse_Leeway(t *testing.T) {
+	ctx := t.Context()
+
+	now := time.Unix(1300819380, 0)
+	p := &Parser{
+		KeyFinder: FindKeyFunc(func(_ context.Context, header *jws.Header) (sig.SigningKey, error) {
+			alg := jwa.SignatureAlgorithmNone.New()
+			return alg.NewSigningKey(nil), nil
+		}),
+		AlgorithmVerifier:     AllowedAlgorithms{jwa.SignatureAlgorithmNone},
+		IssuerSubjectVerifier: UnsecureAnyIssuerSubject,
+		AudienceVerifier:      UnsecureAnyAudience,
+		Clock: goat.ClockFunc(func() time.Time {
+			return now
+		}),
+		Leeway: 5 * time.Second,
+		MaxAge: time.Minute,
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{
+			name:  "expired within leeway",
+			token: `{"exp":1300819376,"iat":1300819320}`,
+			ok:    true,
+		},
+		{
+			name:  "expired",
+			token: `{"exp":1300819375,"iat":1300819320}`,
+		},
+		{
+			name:  "not valid yet within leeway",
+			token: `{"nbf":1300819385,"iat":1300819380}`,
+			ok:    true,
+		},
+		{
+			name:  "not valid yet",
+			token: `{"nbf":1300819386,"iat":1300819380}`,
+		},
+		{
+			name:  "issued in the future within leeway",
+			token: `{"iat":1300819385}`,
+			ok:    true,
+		},
+		{
+			name:  "issued in the future",
+			token: `{"iat":1300819386}`,
+		},
+		{
+			name:  "max age within leeway",
+			token: `{"iat":1300819315}`,
+			ok:    true,
+		},
+		{
+			name:  "too old",
+			token: `{"iat":1300819314}`,
+		},
+		{
+			name:  "iat is missing",
+			token: `{}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := []byte(
+				"eyJhbGciOiJub25lIn0." + // {"alg":"none"}
+					base64.RawURLEncoding.EncodeToString([]byte(tt.token)) + ".")
+			_, err := p.Parse(ctx, data)
+			if tt.ok && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Error("want some error, but not")
+			}
+		})
+	}
+}
+
+func TestParse_Critical(t *testing.T) {
+	p := &Parser{
+		KeyFinder: FindKeyFunc(func(_ context.Context, header *jws.Header) (sig.SigningKey, error) {
+			alg := jwa.SignatureAlgorithmNone.New()
+			return alg.NewSigningKey(nil), nil
+		}),
+		AlgorithmVerifier:     AllowedAlgorithms{jwa.SignatureAlgorithmNone},
+		IssuerSubjectVerifier: UnsecureAnyIssuerSubject,
+		AudienceVerifier:      UnsecureAnyAudience,
+	}
+
+	header := []byte(`{"alg":"none","ppt":"shaken","crit":["ppt"]}`)
+	data := []byte(
+		base64.RawURLEncoding.EncodeToString(header) + "." +
+			base64.RawURLEncoding.EncodeToString([]byte(`{}`)) + ".")
+	if _, err := p.Parse(t.Context(), data); err == nil {
+		t.Error("want some error, but not")
+	}
+}
+
+func TestParse_X509(t *testing.T) {
+	// create a certificate authority and a certificate for signing.
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Root CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "partner"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, priv.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := jwk.NewPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := jws.NewHeader()
+	header.SetAlgorithm(jwa.SignatureAlgorithmES256)
+	header.SetX509CertificateChain([]*x509.Certificate{cert})
+	data, err := Sign(header, &Claims{Issuer: "partner"}, jwa.SignatureAlgorithmES256.New().NewSigningKey(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	p := &Parser{
+		KeyFinder: &JWSKeyFinder{
+			KeyFinder: &jws.X509KeyFinder{
+				Roots:      roots,
+				CommonName: "partner",
+			},
+		},
+		AlgorithmVerifier:     AllowedAlgorithms{jwa.SignatureAlgorithmES256},
+		IssuerSubjectVerifier: Issuer("partner"),
+		AudienceVerifier:      UnsecureAnyAudience,
+	}
+	if _, err := p.Parse(t.Context(), data); err != nil {
+		t.Fatal(err)
+	}
+
+	// untrusted certificate
+	p.KeyFinder = &JWSKeyFinder{
+		KeyFinder: &jws.X509KeyFinder{
+			Roots: x509.NewCertPool(),
+		},
+	}
+	if _, err := p.Parse(t.Context(), data); err == nil {
+		t.Error("want some error, but not")
+	}
 }
 
 func TestSign(t *testing.T) {
@@ -237,10 +442,6 @@ func TestSign(t *testing.T) {
 func BenchmarkParse(b *testing.B) {
 	ctx := b.Context()
 
-	mockTime(b, func() time.Time {
-		return time.Unix(1300819379, 0)
-	})
-
 	raw := []byte(
 		"eyJ0eXAiOiJKV1QiLA0KICJhbGciOiJIUzI1NiJ9" +
 			"." +
@@ -262,6 +463,7 @@ func BenchmarkParse(b *testing.B) {
 		AlgorithmVerifier:     AllowedAlgorithms{jwa.SignatureAlgorithmHS256},
 		IssuerSubjectVerifier: Issuer("joe"),
 		AudienceVerifier:      UnsecureAnyAudience,
+		Clock:                 testClock,
 	}
 
 	for b.Loop() {
@@ -11,6 +11,16 @@ import (
 	"github.com/shogo82148/goat/sig"
 )
 
+// JWSKeyFinder is an adapter to allow the use of [jws.KeyFinder], such as [jws.X509KeyFinder], as KeyFinder.
+type JWSKeyFinder struct {
+	KeyFinder jws.KeyFinder
+}
+
+// FindKey calls f.KeyFinder.FindKey with the header as the protected header.
+func (f *JWSKeyFinder) FindKey(ctx context.Context, header *jws.Header) (sig.SigningKey, error) {
+	return f.KeyFinder.FindKey(ctx, header, nil)
+}
+
 type JWKSKeyFinder struct {
 	JWKS *jwk.Set
 }
@@ -7,7 +7,10 @@ import (
 	"errors"
 	"fmt"
 	"slices"
+	"time"
 
+	"github.com/shogo82148/goat"
+	"github.com/shogo82148/goat/internal/clockutils"
 	"github.com/shogo82148/goat/internal/jsonutils"
 	"github.com/shogo82148/goat/jwa"
 	"github.com/shogo82148/goat/jws"
@@ -108,6 +111,19 @@ type Parser struct {
 	AlgorithmVerifier     AlgorithmVerifier
 	IssuerSubjectVerifier IssuerSubjectVerifier
 	AudienceVerifier      AudienceVerifier
+
+	// Clock provides the current time to validate the "exp", "nbf" and "iat" claims.
+	// If it is nil, the system clock is used.
+	Clock goat.Clock
+
+	// Leeway is the allowed clock skew between the issuer and the parser.
+	// It is applied to the "exp", "nbf" and "iat" claims.
+	Leeway time.Duration
+
+	// MaxAge is the maximum age of the token calculated from the "iat" claim.
+	// If it is zero, the age is not checked.
+	// Otherwise, the "iat" claim is required.
+	MaxAge time.Duration
 }
 
 func (p *Parser) Parse(ctx context.Context, data []byte) (*Token, error) {
@@ -116,6 +132,9 @@ func (p *Parser) Parse(ctx context.Context, data []byte) (*Token, error) {
 	if p.KeyFinder == nil || p.AlgorithmVerifier == nil || p.IssuerSubjectVerifier == nil || p.AudienceVerifier == nil {
 		return nil, errors.New("jwt: parser is not configured")
 	}
+	if p.Leeway < 0 || p.MaxAge < 0 {
+		return nil, errors.New("jwt: negative leeway or max age")
+	}
 
 	// split to segments
 	idx1 := bytes.IndexByte(data, '.')
@@ -145,6 +164,10 @@ func (p *Parser) Parse(ctx context.Context, data []byte) (*Token, error) {
 	if header.UnmarshalJSON(buf[:n]) != nil {
 		return nil, fmt.Errorf("jwt: failed to parse header: %w", err)
 	}
+	if crit := header.Critical(); len(crit) > 0 {
+		// no extension is understood by the JWT parser.
+		return nil, fmt.Errorf("jwt: unknown parameter is in crit: %q", crit)
+	}
 	if err := p.AlgorithmVerifier.VerifyAlgorithm(ctx, header.Algorithm()); err != nil {
 		return nil, fmt.Errorf("jwt: failed to verify algorithm: %w", err)
 	}
@@ -183,7 +206,7 @@ func (p *Parser) Parse(ctx context.Context, data []byte) (*Token, error) {
 }
 
 func (p *Parser) parseClaims(ctx context.Context, data []byte) (*Claims, error) {
-	now := nowFunc()
+	now := clockutils.Now(p.Clock)
 
 	var raw map[string]any
 	dec := json.NewDecoder(bytes.NewReader(data))
@@ -223,19 +246,29 @@ func (p *Parser) parseClaims(ctx context.Context, data []byte) (*Claims, error)
 
 	if t, ok := d.GetTime("exp"); ok {
 		c.ExpirationTime = t
-		if !now.Before(t) {
+		if !now.Before(t.Add(p.Leeway)) {
 			d.SaveError(fmt.Errorf("jwt: token is expired"))
 		}
 	}
 
 	if t, ok := d.GetTime("nbf"); ok {
 		c.NotBefore = t
-		if now.Before(t) {
+		if now.Add(p.Leeway).Before(t) {
 			d.SaveError(fmt.Errorf("jwt: token is not valid yet"))
 		}
 	}
 
-	c.IssuedAt, _ = d.GetTime("iat")
+	if t, ok := d.GetTime("iat"); ok {
+		c.IssuedAt = t
+		if now.Add(p.Leeway).Before(t) {
+			d.SaveError(fmt.Errorf("jwt: token is issued in the future"))
+		}
+		if p.MaxAge > 0 && now.Sub(t) > p.MaxAge+p.Leeway {
+			d.SaveError(fmt.Errorf("jwt: token is too old"))
+		}
+	} else if p.MaxAge > 0 {
+		d.SaveError(fmt.Errorf("jwt: iat claim is required"))
+	}
 	c.JWTID, _ = d.GetString("jti")
 
 	if err := d.Err(); err != nil {
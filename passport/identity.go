@@ -0,0 +1,130 @@
+package passport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/shogo82148/goat/jwa"
+)
+
+// Identity is the value of the SIP Identity header field defined in RFC 8224 Section 4.1.
+//
+//	Identity: <PASSporT>;info=<https://cert.example.org/passport.cer>;alg=ES256;ppt=shaken
+type Identity struct {
+	// Token is the PASSporT in JWS Compact Serialization.
+	Token []byte
+
+	// Info is the "info" parameter, the URI of the certificate.
+	// It is the same as the "x5u" header parameter of Token.
+	Info string
+
+	// Algorithm is the "alg" parameter.
+	Algorithm jwa.SignatureAlgorithm
+
+	// Extension is the "ppt" parameter.
+	Extension string
+}
+
+// NewIdentity returns the SIP Identity header field for the token signed by s.
+func (s *Signer) NewIdentity(token []byte) *Identity {
+	_ = s._NamedFieldsRequired
+	return &Identity{
+		Token:     token,
+		Info:      s.X509URL,
+		Algorithm: s.algorithm(),
+		Extension: s.Extension,
+	}
+}
+
+// String returns the value of the Identity header field.
+func (id *Identity) String() string {
+	var buf strings.Builder
+	buf.Write(id.Token)
+	if id.Info != "" {
+		buf.WriteString(";info=<")
+		buf.WriteString(id.Info)
+		buf.WriteString(">")
+	}
+	if id.Algorithm != "" {
+		buf.WriteString(";alg=")
+		buf.WriteString(id.Algorithm.String())
+	}
+	if id.Extension != "" {
+		buf.WriteString(";ppt=")
+		buf.WriteString(id.Extension)
+	}
+	return buf.String()
+}
+
+// ParseIdentity parses the value of the SIP Identity header field.
+// The unknown parameters are ignored.
+func ParseIdentity(s string) (*Identity, error) {
+	token, params, _ := strings.Cut(strings.TrimSpace(s), ";")
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, errors.New("passport: token is missing in Identity header")
+	}
+	id := &Identity{
+		Token: []byte(token),
+	}
+
+	for params != "" {
+		var name, value string
+		name, params, _ = strings.Cut(params, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		params = strings.TrimLeft(params, " \t")
+		if strings.HasPrefix(params, "<") {
+			// the URI may contain ';'.
+			end := strings.IndexByte(params, '>')
+			if end < 0 {
+				return nil, errors.New("passport: unterminated URI in Identity header")
+			}
+			value = params[1:end]
+			params = strings.TrimLeft(params[end+1:], " \t")
+			if params != "" && params[0] != ';' {
+				return nil, fmt.Errorf("passport: unexpected character %q in Identity header", params[0])
+			}
+			params = strings.TrimPrefix(params, ";")
+		} else {
+			value, params, _ = strings.Cut(params, ";")
+			value = strings.TrimSpace(value)
+			if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+				value = value[1 : len(value)-1]
+			}
+		}
+
+		switch name {
+		case "info":
+			id.Info = value
+		case "alg":
+			id.Algorithm = jwa.SignatureAlgorithm(value)
+		case "ppt":
+			id.Extension = value
+		}
+	}
+	if id.Info == "" {
+		return nil, errors.New("passport: info parameter is missing in Identity header")
+	}
+	return id, nil
+}
+
+// VerifyIdentity verifies the PASSporT in the SIP Identity header field id,
+// and checks that the parameters of id match the header of the PASSporT.
+func (v *Verifier) VerifyIdentity(ctx context.Context, id *Identity) (*Token, error) {
+	token, err := v.Verify(ctx, id.Token)
+	if err != nil {
+		return nil, err
+	}
+	if x5u := token.Header.X509URL(); x5u.String() != id.Info {
+		return nil, fmt.Errorf("passport: info parameter %q doesn't match x5u %q", id.Info, x5u)
+	}
+	if id.Algorithm != "" && id.Algorithm != token.Header.Algorithm() {
+		return nil, fmt.Errorf("passport: alg parameter %q doesn't match alg %q", id.Algorithm, token.Header.Algorithm())
+	}
+	if ppt := token.Extension(); id.Extension != ppt {
+		return nil, fmt.Errorf("passport: ppt parameter %q doesn't match ppt %q", id.Extension, ppt)
+	}
+	return token, nil
+}
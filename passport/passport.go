@@ -0,0 +1,455 @@
+// Package passport handles Personal Assertion Token (PASSporT) defined in RFC 8225,
+// and its SHAKEN extension defined in RFC 8588.
+package passport
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shogo82148/goat"
+	"github.com/shogo82148/goat/internal/clockutils"
+	"github.com/shogo82148/goat/internal/jsonutils"
+	"github.com/shogo82148/goat/jwa"
+	"github.com/shogo82148/goat/jws"
+	"github.com/shogo82148/goat/sig"
+)
+
+var b64 = base64.RawURLEncoding
+
+// Type is the value of the "typ" header parameter defined in RFC 8225 Section 4.1.
+const Type = "passport"
+
+// ExtensionSHAKEN is the value of the "ppt" header parameter for SHAKEN defined in RFC 8588 Section 3.
+const ExtensionSHAKEN = "shaken"
+
+// Attestation is the value of the "attest" claim defined in RFC 8588 Section 4.
+type Attestation string
+
+const (
+	// AttestationFull is full attestation.
+	AttestationFull Attestation = "A"
+
+	// AttestationPartial is partial attestation.
+	AttestationPartial Attestation = "B"
+
+	// AttestationGateway is gateway attestation.
+	AttestationGateway Attestation = "C"
+)
+
+// Originator is the "orig" claim defined in RFC 8225 Section 5.2.1.
+// Either TelephoneNumber or URI must be set.
+type Originator struct {
+	// TelephoneNumber is the telephone number in the canonical form
+	// defined in RFC 8224 Section 8.3.
+	// Signer converts it into the canonical form by [CanonicalTelephoneNumber].
+	TelephoneNumber string
+
+	// URI is the URI of the originator.
+	URI string
+}
+
+// Destination is the "dest" claim defined in RFC 8225 Section 5.2.1.
+type Destination struct {
+	// TelephoneNumbers are the telephone numbers in the canonical form
+	// defined in RFC 8224 Section 8.3.
+	// Signer converts them into the canonical form by [CanonicalTelephoneNumber].
+	TelephoneNumbers []string
+
+	// URIs are the URIs of the destination.
+	URIs []string
+}
+
+// Claims is a PASSporT Claims Set defined in RFC 8225 Section 5.
+type Claims struct {
+	// RFC 8225 Section 5.2.1. "orig" (Originating Identity) Claim
+	Originator Originator
+
+	// RFC 8225 Section 5.2.1. "dest" (Destination Identity) Claim
+	Destination Destination
+
+	// RFC 8225 Section 5.1.1. "iat" (Issued At) Claim
+	IssuedAt time.Time
+
+	// RFC 8588 Section 4. "attest" (Attestation) Claim
+	Attestation Attestation
+
+	// RFC 8588 Section 4. "origid" (Origination Identifier) Claim
+	OriginationID string
+
+	// Raw is the raw data of JSON-decoded claims.
+	// JSON numbers are decoded as json.Number to avoid data loss.
+	Raw map[string]any
+}
+
+// Token is a decoded PASSporT.
+type Token struct {
+	Header *jws.Header
+	Claims *Claims
+}
+
+// Extension returns the "ppt" header parameter defined in RFC 8225 Section 8.1.
+func (t *Token) Extension() string {
+	ppt, _ := jws.Get[string](t.Header, jwa.PASSporTExtensionIdentifierKey)
+	return ppt
+}
+
+// CanonicalTelephoneNumber returns the canonical form of the telephone number tn
+// defined in RFC 8224 Section 8.3.
+// Only the digits, "*" and "#" are kept, so the visual separators and the leading "+" are removed.
+func CanonicalTelephoneNumber(tn string) string {
+	var buf strings.Builder
+	buf.Grow(len(tn))
+	for i := 0; i < len(tn); i++ {
+		c := tn[i]
+		if (c >= '0' && c <= '9') || c == '*' || c == '#' {
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String()
+}
+
+// Signer signs PASSporTs.
+type Signer struct {
+	_NamedFieldsRequired struct{}
+
+	// Algorithm is the signature algorithm.
+	// If it is empty, ES256 is used, as required by RFC 8225 Section 4.3.
+	Algorithm jwa.SignatureAlgorithm
+
+	// Key is the private key of the certificate referenced by X509URL.
+	Key sig.Key
+
+	// X509URL is the URL of the certificate set to the "x5u" header parameter.
+	X509URL string
+
+	// Extension is the PASSporT extension set to the "ppt" header parameter, e.g. [ExtensionSHAKEN].
+	// If it is set, "ppt" is also listed in the "crit" header parameter.
+	Extension string
+}
+
+// Sign returns the PASSporT in JWS Compact Serialization.
+// The header and the claims are serialized in the deterministic JSON form
+// defined in RFC 8225 Section 9.
+func (s *Signer) Sign(ctx context.Context, claims *Claims) ([]byte, error) {
+	_ = s._NamedFieldsRequired
+	alg := s.algorithm()
+	if !alg.Available() {
+		return nil, errors.New("passport: algorithm not available")
+	}
+	if s.Key == nil {
+		return nil, errors.New("passport: key is missing")
+	}
+	if s.X509URL == "" {
+		return nil, errors.New("passport: x5u is missing")
+	}
+	if s.Extension == ExtensionSHAKEN {
+		if err := checkSHAKEN(claims); err != nil {
+			return nil, err
+		}
+	}
+
+	header := map[string]any{
+		"alg": alg.String(),
+		"typ": Type,
+		"x5u": s.X509URL,
+	}
+	if s.Extension != "" {
+		header[jwa.PASSporTExtensionIdentifierKey] = s.Extension
+		header["crit"] = []string{jwa.PASSporTExtensionIdentifierKey}
+	}
+	headerBytes, err := canonicalJSON(header)
+	if err != nil {
+		return nil, fmt.Errorf("passport: failed to encode header: %w", err)
+	}
+	payload, err := encodeClaims(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	l1 := b64.EncodedLen(len(headerBytes))
+	l2 := b64.EncodedLen(len(payload))
+	buf := make([]byte, l1+1+l2)
+	b64.Encode(buf[:l1], headerBytes)
+	buf[l1] = '.'
+	b64.Encode(buf[l1+1:], payload)
+
+	signature, err := sig.SignContext(ctx, alg.New().NewSigningKey(s.Key), buf)
+	if err != nil {
+		return nil, err
+	}
+	buf = append(buf, '.')
+	buf = b64.AppendEncode(buf, signature)
+	return buf, nil
+}
+
+func (s *Signer) algorithm() jwa.SignatureAlgorithm {
+	if s.Algorithm == "" {
+		return jwa.SignatureAlgorithmES256
+	}
+	return s.Algorithm
+}
+
+// canonicalJSON encodes v in the deterministic JSON form defined in RFC 8225 Section 9:
+// the keys are sorted in lexicographic order, and there is no whitespace.
+func canonicalJSON(v map[string]any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func encodeClaims(c *Claims) ([]byte, error) {
+	if c == nil {
+		return nil, errors.New("passport: claims are nil")
+	}
+	raw := make(map[string]any, len(c.Raw))
+	maps.Copy(raw, c.Raw)
+	e := jsonutils.NewEncoder(raw)
+
+	switch orig := c.Originator; {
+	case orig.TelephoneNumber != "" && orig.URI != "":
+		return nil, errors.New("passport: orig must have either tn or uri")
+	case orig.TelephoneNumber != "":
+		tn := CanonicalTelephoneNumber(orig.TelephoneNumber)
+		if tn == "" {
+			return nil, fmt.Errorf("passport: invalid telephone number: %q", orig.TelephoneNumber)
+		}
+		e.Set("orig", map[string]any{"tn": tn})
+	case orig.URI != "":
+		e.Set("orig", map[string]any{"uri": orig.URI})
+	default:
+		return nil, errors.New("passport: orig is missing")
+	}
+
+	dest := map[string]any{}
+	if numbers := c.Destination.TelephoneNumbers; len(numbers) > 0 {
+		tn := make([]string, 0, len(numbers))
+		for _, number := range numbers {
+			canonical := CanonicalTelephoneNumber(number)
+			if canonical == "" {
+				return nil, fmt.Errorf("passport: invalid telephone number: %q", number)
+			}
+			tn = append(tn, canonical)
+		}
+		dest["tn"] = tn
+	}
+	if uri := c.Destination.URIs; len(uri) > 0 {
+		dest["uri"] = uri
+	}
+	if len(dest) == 0 {
+		return nil, errors.New("passport: dest is missing")
+	}
+	e.Set("dest", dest)
+
+	if c.IssuedAt.IsZero() {
+		return nil, errors.New("passport: iat is missing")
+	}
+	// the "iat" claim is an integer in PASSporT.
+	e.SetTime("iat", c.IssuedAt.Truncate(time.Second))
+
+	if attest := c.Attestation; attest != "" {
+		e.Set("attest", string(attest))
+	}
+	if origid := c.OriginationID; origid != "" {
+		e.Set("origid", origid)
+	}
+
+	if err := e.Err(); err != nil {
+		return nil, err
+	}
+	data, err := canonicalJSON(e.Data())
+	if err != nil {
+		return nil, fmt.Errorf("passport: failed to encode claims: %w", err)
+	}
+	return data, nil
+}
+
+// checkSHAKEN checks the claims required by RFC 8588 Section 4.
+func checkSHAKEN(c *Claims) error {
+	if c == nil {
+		return errors.New("passport: claims are nil")
+	}
+	switch c.Attestation {
+	case AttestationFull, AttestationPartial, AttestationGateway:
+	case "":
+		return errors.New("passport: attest is missing")
+	default:
+		return fmt.Errorf("passport: invalid attest: %q", c.Attestation)
+	}
+	if c.OriginationID == "" {
+		return errors.New("passport: origid is missing")
+	}
+	return nil
+}
+
+// defaultMaxAge is the freshness of PASSporTs recommended by RFC 8224 Section 6.2.1.
+const defaultMaxAge = 60 * time.Second
+
+// Verifier verifies PASSporTs.
+type Verifier struct {
+	_NamedFieldsRequired struct{}
+
+	// AlgorithmVerifier verifies the "alg" header parameter.
+	// ES256 is typically the only allowed algorithm.
+	AlgorithmVerifier jws.AlgorithmVerifier
+
+	// KeyFinder finds the key from the "x5u" header parameter,
+	// e.g. [jws.RemoteKeyFinder].
+	KeyFinder jws.KeyFinder
+
+	// Extensions is the list of the accepted values of the "ppt" header parameter.
+	// The PASSporTs without "ppt" are always accepted.
+	Extensions []string
+
+	// MaxAge is the maximum age of the token calculated from the "iat" claim.
+	// If it is zero, 60 seconds is used.
+	// The tokens issued in the future are always rejected.
+	MaxAge time.Duration
+
+	// Leeway is the allowed clock skew between the signer and the verifier.
+	// It is applied to the age and the future "iat" claim.
+	Leeway time.Duration
+
+	// Clock provides the current time to validate the "iat" claim.
+	// If it is nil, the system clock is used.
+	Clock goat.Clock
+}
+
+// Verify verifies the PASSporT in JWS Compact Serialization.
+func (v *Verifier) Verify(ctx context.Context, data []byte) (*Token, error) {
+	_ = v._NamedFieldsRequired
+	if v.AlgorithmVerifier == nil || v.KeyFinder == nil {
+		return nil, errors.New("passport: verifier is not configured")
+	}
+
+	msg, err := jws.ParseCompact(data)
+	if err != nil {
+		return nil, err
+	}
+	verifier := &jws.Verifier{
+		AlgorithmVerifier: v.AlgorithmVerifier,
+		KeyFinder:         v.KeyFinder,
+		CriticalParameters: map[string]jws.CriticalParameterVerifier{
+			jwa.PASSporTExtensionIdentifierKey: jws.VerifyCriticalParameterFunc(v.verifyExtension),
+		},
+	}
+	header, _, payload, err := verifier.Verify(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	if typ := header.Type(); typ != Type {
+		return nil, fmt.Errorf("passport: invalid typ: %q", typ)
+	}
+	if header.X509URL() == nil {
+		return nil, errors.New("passport: x5u is missing")
+	}
+	ppt, hasExtension := jws.Get[string](header, jwa.PASSporTExtensionIdentifierKey)
+	if hasExtension && !slices.Contains(header.Critical(), jwa.PASSporTExtensionIdentifierKey) {
+		// RFC 8225 Section 8.1: "ppt" is listed in "crit",
+		// so that the verifiers that don't understand the extension reject the token.
+		return nil, errors.New("passport: ppt must be listed in crit")
+	}
+
+	claims, err := v.parseClaims(payload)
+	if err != nil {
+		return nil, err
+	}
+	if ppt == ExtensionSHAKEN {
+		if err := checkSHAKEN(claims); err != nil {
+			return nil, err
+		}
+	}
+	return &Token{
+		Header: header,
+		Claims: claims,
+	}, nil
+}
+
+func (v *Verifier) verifyExtension(ctx context.Context, name string, value any, protected *jws.Header) error {
+	ppt, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("passport: invalid type of ppt: %T", value)
+	}
+	if !slices.Contains(v.Extensions, ppt) {
+		return fmt.Errorf("passport: unsupported ppt: %q", ppt)
+	}
+	return nil
+}
+
+func (v *Verifier) parseClaims(data []byte) (*Claims, error) {
+	var raw map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("passport: failed to parse claims: %w", err)
+	}
+	c := &Claims{
+		Raw: raw,
+	}
+	d := jsonutils.NewDecoder("passport", raw)
+
+	if orig, ok := d.GetObject("orig"); ok {
+		od := jsonutils.NewDecoder("passport", orig)
+		c.Originator.TelephoneNumber, _ = od.GetString("tn")
+		c.Originator.URI, _ = od.GetString("uri")
+		if err := od.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if c.Originator.TelephoneNumber == "" && c.Originator.URI == "" {
+		return nil, errors.New("passport: orig is missing")
+	}
+
+	if dest, ok := d.GetObject("dest"); ok {
+		dd := jsonutils.NewDecoder("passport", dest)
+		c.Destination.TelephoneNumbers, _ = dd.GetStringArray("tn")
+		c.Destination.URIs, _ = dd.GetStringArray("uri")
+		if err := dd.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if len(c.Destination.TelephoneNumbers) == 0 && len(c.Destination.URIs) == 0 {
+		return nil, errors.New("passport: dest is missing")
+	}
+
+	iat, ok := d.GetTime("iat")
+	if !ok {
+		if err := d.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("passport: iat is missing")
+	}
+	c.IssuedAt = iat
+	maxAge := v.MaxAge
+	if maxAge <= 0 {
+		maxAge = defaultMaxAge
+	}
+	now := clockutils.Now(v.Clock)
+	if now.Sub(iat) > maxAge+v.Leeway {
+		return nil, errors.New("passport: token is too old")
+	}
+	if now.Add(v.Leeway).Before(iat) {
+		return nil, errors.New("passport: token is issued in the future")
+	}
+
+	attest, _ := d.GetString("attest")
+	c.Attestation = Attestation(attest)
+	c.OriginationID, _ = d.GetString("origid")
+
+	if err := d.Err(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
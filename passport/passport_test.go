@@ -0,0 +1,395 @@
+package passport
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shogo82148/goat"
+	"github.com/shogo82148/goat/jwa"
+	_ "github.com/shogo82148/goat/jwa/es" // for ECDSA
+	"github.com/shogo82148/goat/jwk"
+	"github.com/shogo82148/goat/jws"
+	"github.com/shogo82148/goat/sig"
+)
+
+const testX5U = "https://cert.example.org/passport.cer"
+
+func newTestKey(t *testing.T) *jwk.Key {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := jwk.NewPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newTestVerifier(key *jwk.Key) *Verifier {
+	return &Verifier{
+		AlgorithmVerifier: jws.AllowedAlgorithms{jwa.SignatureAlgorithmES256},
+		KeyFinder: jws.FindKeyFunc(func(ctx context.Context, protected, unprotected *jws.Header) (sig.SigningKey, error) {
+			if x5u := protected.X509URL(); x5u == nil || x5u.String() != testX5U {
+				return nil, errors.New("unknown x5u")
+			}
+			return jwa.SignatureAlgorithmES256.New().NewSigningKey(key), nil
+		}),
+		Extensions: []string{ExtensionSHAKEN},
+		Clock: goat.ClockFunc(func() time.Time {
+			return time.Unix(1443208345, 0)
+		}),
+	}
+}
+
+func testClaims() *Claims {
+	return &Claims{
+		Originator: Originator{
+			TelephoneNumber: "12155550112",
+		},
+		Destination: Destination{
+			TelephoneNumbers: []string{"12355550131"},
+		},
+		IssuedAt:      time.Unix(1443208345, 0),
+		Attestation:   AttestationFull,
+		OriginationID: "123e4567-e89b-12d3-a456-426655440000",
+	}
+}
+
+func TestSign(t *testing.T) {
+	ctx := context.Background()
+	key := newTestKey(t)
+	s := &Signer{
+		Key:       key,
+		X509URL:   testX5U,
+		Extension: ExtensionSHAKEN,
+	}
+	token, err := s.Sign(ctx, testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// RFC 8225 Section 9. Deterministic JSON Serialization
+	parts := bytes.Split(token, []byte("."))
+	if len(parts) != 3 {
+		t.Fatalf("invalid token: %s", token)
+	}
+	header, err := base64.RawURLEncoding.DecodeString(string(parts[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantHeader := `{"alg":"ES256","crit":["ppt"],"ppt":"shaken","typ":"passport","x5u":"https://cert.example.org/passport.cer"}`
+	if string(header) != wantHeader {
+		t.Errorf("unexpected header: got %s, want %s", header, wantHeader)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(string(parts[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPayload := `{"attest":"A","dest":{"tn":["12355550131"]},"iat":1443208345,"orig":{"tn":"12155550112"},"origid":"123e4567-e89b-12d3-a456-426655440000"}`
+	if string(payload) != wantPayload {
+		t.Errorf("unexpected payload: got %s, want %s", payload, wantPayload)
+	}
+
+	v := newTestVerifier(key)
+	got, err := v.Verify(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Extension() != ExtensionSHAKEN {
+		t.Errorf("unexpected ppt: %q", got.Extension())
+	}
+	if got.Claims.Originator.TelephoneNumber != "12155550112" {
+		t.Errorf("unexpected orig: %v", got.Claims.Originator)
+	}
+	if len(got.Claims.Destination.TelephoneNumbers) != 1 || got.Claims.Destination.TelephoneNumbers[0] != "12355550131" {
+		t.Errorf("unexpected dest: %v", got.Claims.Destination)
+	}
+	if !got.Claims.IssuedAt.Equal(time.Unix(1443208345, 0)) {
+		t.Errorf("unexpected iat: %v", got.Claims.IssuedAt)
+	}
+	if got.Claims.Attestation != AttestationFull {
+		t.Errorf("unexpected attest: %q", got.Claims.Attestation)
+	}
+	if got.Claims.OriginationID != "123e4567-e89b-12d3-a456-426655440000" {
+		t.Errorf("unexpected origid: %q", got.Claims.OriginationID)
+	}
+}
+
+func TestSign_SHAKEN(t *testing.T) {
+	ctx := context.Background()
+	s := &Signer{
+		Key:       newTestKey(t),
+		X509URL:   testX5U,
+		Extension: ExtensionSHAKEN,
+	}
+
+	c := testClaims()
+	c.Attestation = ""
+	if _, err := s.Sign(ctx, c); err == nil {
+		t.Error("want error, but not")
+	}
+
+	c = testClaims()
+	c.Attestation = "D"
+	if _, err := s.Sign(ctx, c); err == nil {
+		t.Error("want error, but not")
+	}
+
+	c = testClaims()
+	c.OriginationID = ""
+	if _, err := s.Sign(ctx, c); err == nil {
+		t.Error("want error, but not")
+	}
+}
+
+func TestSign_CanonicalTelephoneNumber(t *testing.T) {
+	ctx := context.Background()
+	key := newTestKey(t)
+	s := &Signer{
+		Key:     key,
+		X509URL: testX5U,
+	}
+	c := testClaims()
+	c.Originator.TelephoneNumber = "+1 (555) 123-4567"
+	c.Destination.TelephoneNumbers = []string{"+1-235-555-0131"}
+	token, err := s.Sign(ctx, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := bytes.Split(token, []byte("."))
+	if len(parts) != 3 {
+		t.Fatalf("invalid token: %s", token)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(string(parts[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPayload := `{"attest":"A","dest":{"tn":["12355550131"]},"iat":1443208345,"orig":{"tn":"15551234567"},"origid":"123e4567-e89b-12d3-a456-426655440000"}`
+	if string(payload) != wantPayload {
+		t.Errorf("unexpected payload: got %s, want %s", payload, wantPayload)
+	}
+
+	c.Originator.TelephoneNumber = "+"
+	if _, err := s.Sign(ctx, c); err == nil {
+		t.Error("want error, but not")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	key := newTestKey(t)
+	v := newTestVerifier(key)
+
+	// sign signs claims with the raw header, without any checks by Signer.
+	sign := func(t *testing.T, header string, claims string) []byte {
+		t.Helper()
+		buf := []byte(b64.EncodeToString([]byte(header)) + "." + b64.EncodeToString([]byte(claims)))
+		signature, err := jwa.SignatureAlgorithmES256.New().NewSigningKey(key).Sign(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return []byte(string(buf) + "." + b64.EncodeToString(signature))
+	}
+	const claims = `{"dest":{"uri":["sip:alice@example.com"]},"iat":1443208345,"orig":{"tn":"12155550112"}}`
+
+	t.Run("no extension", func(t *testing.T) {
+		token := sign(t, `{"alg":"ES256","typ":"passport","x5u":"https://cert.example.org/passport.cer"}`, claims)
+		got, err := v.Verify(ctx, token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Claims.Destination.URIs[0] != "sip:alice@example.com" {
+			t.Errorf("unexpected dest: %v", got.Claims.Destination)
+		}
+	})
+
+	tests := []struct {
+		name   string
+		header string
+		claims string
+	}{
+		{
+			name:   "invalid typ",
+			header: `{"alg":"ES256","typ":"JWT","x5u":"https://cert.example.org/passport.cer"}`,
+			claims: claims,
+		},
+		{
+			name:   "no x5u",
+			header: `{"alg":"ES256","typ":"passport"}`,
+			claims: claims,
+		},
+		{
+			name:   "ppt is not in crit",
+			header: `{"alg":"ES256","ppt":"shaken","typ":"passport","x5u":"https://cert.example.org/passport.cer"}`,
+			claims: `{"attest":"A","dest":{"tn":["12355550131"]},"iat":1443208345,"orig":{"tn":"12155550112"},"origid":"123e4567-e89b-12d3-a456-426655440000"}`,
+		},
+		{
+			name:   "unsupported ppt",
+			header: `{"alg":"ES256","crit":["ppt"],"ppt":"div","typ":"passport","x5u":"https://cert.example.org/passport.cer"}`,
+			claims: claims,
+		},
+		{
+			name:   "SHAKEN without attest",
+			header: `{"alg":"ES256","crit":["ppt"],"ppt":"shaken","typ":"passport","x5u":"https://cert.example.org/passport.cer"}`,
+			claims: claims,
+		},
+		{
+			name:   "no orig",
+			header: `{"alg":"ES256","typ":"passport","x5u":"https://cert.example.org/passport.cer"}`,
+			claims: `{"dest":{"uri":["sip:alice@example.com"]},"iat":1443208345}`,
+		},
+		{
+			name:   "no dest",
+			header: `{"alg":"ES256","typ":"passport","x5u":"https://cert.example.org/passport.cer"}`,
+			claims: `{"iat":1443208345,"orig":{"tn":"12155550112"}}`,
+		},
+		{
+			name:   "too old",
+			header: `{"alg":"ES256","typ":"passport","x5u":"https://cert.example.org/passport.cer"}`,
+			claims: `{"dest":{"uri":["sip:alice@example.com"]},"iat":1443208000,"orig":{"tn":"12155550112"}}`,
+		},
+		{
+			name:   "issued in the future",
+			header: `{"alg":"ES256","typ":"passport","x5u":"https://cert.example.org/passport.cer"}`,
+			claims: `{"dest":{"uri":["sip:alice@example.com"]},"iat":1443208375,"orig":{"tn":"12155550112"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := sign(t, tt.header, tt.claims)
+			if _, err := v.Verify(ctx, token); err == nil {
+				t.Error("want error, but not")
+			}
+		})
+	}
+}
+
+func TestVerify_Leeway(t *testing.T) {
+	ctx := context.Background()
+	key := newTestKey(t)
+	s := &Signer{
+		Key:     key,
+		X509URL: testX5U,
+	}
+	token, err := s.Sign(ctx, testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the token is issued at 1443208345.
+	now := time.Unix(1443208345+65, 0)
+	v := newTestVerifier(key)
+	v.Clock = goat.ClockFunc(func() time.Time { return now })
+	if _, err := v.Verify(ctx, token); err == nil {
+		t.Error("want error, but not")
+	}
+
+	v.Leeway = 10 * time.Second
+	if _, err := v.Verify(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+
+	now = time.Unix(1443208345-5, 0)
+	if _, err := v.Verify(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+
+	now = time.Unix(1443208345-30, 0)
+	if _, err := v.Verify(ctx, token); err == nil {
+		t.Error("want error, but not")
+	}
+
+	// the token issued in the future is rejected without leeway.
+	v.Leeway = 0
+	if _, err := v.Verify(ctx, token); err == nil {
+		t.Error("want error, but not")
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	ctx := context.Background()
+	key := newTestKey(t)
+	s := &Signer{
+		Key:       key,
+		X509URL:   testX5U,
+		Extension: ExtensionSHAKEN,
+	}
+	token, err := s.Sign(ctx, testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := s.NewIdentity(token).String()
+	want := string(token) + ";info=<https://cert.example.org/passport.cer>;alg=ES256;ppt=shaken"
+	if header != want {
+		t.Errorf("unexpected Identity header: got %s, want %s", header, want)
+	}
+
+	id, err := ParseIdentity(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := newTestVerifier(key)
+	if _, err := v.VerifyIdentity(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+
+	// the info parameter doesn't match x5u.
+	id.Info = "https://cert.example.org/another.cer"
+	if _, err := v.VerifyIdentity(ctx, id); err == nil {
+		t.Error("want error, but not")
+	}
+}
+
+func TestParseIdentity(t *testing.T) {
+	id, err := ParseIdentity("header.payload.signature ; info=<https://example.com/a;b.cer> ; ALG=ES256;ppt=\"shaken\";unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(id.Token) != "header.payload.signature" {
+		t.Errorf("unexpected token: %q", id.Token)
+	}
+	if id.Info != "https://example.com/a;b.cer" {
+		t.Errorf("unexpected info: %q", id.Info)
+	}
+	if id.Algorithm != jwa.SignatureAlgorithmES256 {
+		t.Errorf("unexpected alg: %q", id.Algorithm)
+	}
+	if id.Extension != ExtensionSHAKEN {
+		t.Errorf("unexpected ppt: %q", id.Extension)
+	}
+
+	if _, err := ParseIdentity("header.payload.signature;alg=ES256"); err == nil {
+		t.Error("want error, but not")
+	}
+	if _, err := ParseIdentity("header.payload.signature;info=<https://example.com"); err == nil || !strings.Contains(err.Error(), "unterminated") {
+		t.Errorf("want unterminated error, got %v", err)
+	}
+}
+
+func TestCanonicalTelephoneNumber(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"+1 (215) 555-0112", "12155550112"},
+		{"1.215.555.0112", "12155550112"},
+		{"*67#", "*67#"},
+	}
+	for _, tt := range tests {
+		if got := CanonicalTelephoneNumber(tt.in); got != tt.want {
+			t.Errorf("CanonicalTelephoneNumber(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
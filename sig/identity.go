@@ -0,0 +1,43 @@
+package sig
+
+import (
+	"crypto"
+	"crypto/subtle"
+
+	"github.com/shogo82148/goat"
+)
+
+// IdentifiableSigningKey is a SigningKey that exposes the key that verifies the signatures.
+// It is used to tell whether two signing keys are the same key,
+// even if they are used with different algorithms, e.g. RS256 and PS256.
+type IdentifiableSigningKey interface {
+	SigningKey
+
+	// PublicKey returns the public key, or the shared secret for the MAC algorithms.
+	PublicKey() goat.PublicKey
+}
+
+// SameKey reports whether a and b are the same key.
+// The keys are compared by their public keys or their shared secrets,
+// not by the algorithms they are used with.
+// If either of them doesn't implement IdentifiableSigningKey,
+// they can't be distinguished and SameKey reports true.
+func SameKey(a, b SigningKey) bool {
+	ka, ok := a.(IdentifiableSigningKey)
+	if !ok {
+		return true
+	}
+	kb, ok := b.(IdentifiableSigningKey)
+	if !ok {
+		return true
+	}
+
+	switch pub := ka.PublicKey().(type) {
+	case []byte:
+		other, ok := kb.PublicKey().([]byte)
+		return ok && subtle.ConstantTimeCompare(pub, other) == 1
+	case interface{ Equal(x crypto.PublicKey) bool }:
+		return pub.Equal(kb.PublicKey())
+	}
+	return true
+}
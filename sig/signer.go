@@ -0,0 +1,82 @@
+package sig
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"io"
+
+	"github.com/shogo82148/goat"
+)
+
+// ContextSigningKey is a SigningKey that can sign with a context.
+// It is implemented by the signing keys backed by [crypto.Signer],
+// so remote signing, such as KMS or HSM, can be cancelled and time-limited.
+type ContextSigningKey interface {
+	SigningKey
+
+	// SignContext is same as Sign, but it can be cancelled by ctx.
+	SignContext(ctx context.Context, payload []byte) (signature []byte, err error)
+}
+
+// SignContext signs payload with key.
+// If key implements ContextSigningKey, ctx is passed to key.SignContext.
+// Otherwise, ctx is checked only before signing.
+func SignContext(ctx context.Context, key SigningKey, payload []byte) (signature []byte, err error) {
+	if key, ok := key.(ContextSigningKey); ok {
+		return key.SignContext(ctx, payload)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return key.Sign(payload)
+}
+
+// ContextSigner is a [crypto.Signer] that can sign with a context.
+// The signers for remote keys, such as KMS or HSM, should implement it.
+type ContextSigner interface {
+	crypto.Signer
+
+	// SignContext is same as Sign, but it can be cancelled by ctx.
+	SignContext(ctx context.Context, rand io.Reader, digest []byte, opts crypto.SignerOpts) (signature []byte, err error)
+}
+
+// SignDigest signs digest with signer.
+// If signer implements ContextSigner, ctx is passed to signer.SignContext.
+// Otherwise, ctx is checked only before signing.
+func SignDigest(ctx context.Context, signer crypto.Signer, digest []byte, opts crypto.SignerOpts) (signature []byte, err error) {
+	if signer, ok := signer.(ContextSigner); ok {
+		return signer.SignContext(ctx, rand.Reader, digest, opts)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return signer.Sign(rand.Reader, digest, opts)
+}
+
+var _ Key = (*signerKey)(nil)
+
+type signerKey struct {
+	signer crypto.Signer
+}
+
+// NewSignerKey returns a new Key backed by signer.
+// The private key is kept in signer, e.g. KMS or HSM,
+// and signatures are created by the Sign method of signer.
+// The returned key can be passed to the NewSigningKey method of
+// RS*, PS*, ES* and EdDSA algorithms.
+func NewSignerKey(signer crypto.Signer) Key {
+	return &signerKey{
+		signer: signer,
+	}
+}
+
+// PrivateKey implements Key.
+func (key *signerKey) PrivateKey() goat.PrivateKey {
+	return key.signer
+}
+
+// PublicKey implements Key.
+func (key *signerKey) PublicKey() goat.PublicKey {
+	return key.signer.Public()
+}
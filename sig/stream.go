@@ -0,0 +1,57 @@
+package sig
+
+import (
+	"bytes"
+	"io"
+)
+
+// StreamingSigningKey is a SigningKey that can sign and verify the payload incrementally.
+// It is implemented by the signing keys that sign the digest or the MAC of the payload,
+// so large payloads can be processed without buffering them in memory.
+type StreamingSigningKey interface {
+	SigningKey
+
+	// NewStream returns a new Stream for signing or verifying a payload.
+	NewStream() (Stream, error)
+}
+
+// Stream signs or verifies the payload written to it.
+type Stream interface {
+	io.Writer
+
+	// Sign returns the signature of the payload written so far.
+	Sign() (signature []byte, err error)
+
+	// Verify verifies the signature of the payload written so far.
+	Verify(signature []byte) error
+}
+
+// NewStream returns a new Stream for key.
+// If key doesn't implement StreamingSigningKey, e.g. EdDSA keys,
+// the payload is buffered in memory and it is signed or verified at once.
+func NewStream(key SigningKey) (Stream, error) {
+	if key, ok := key.(StreamingSigningKey); ok {
+		return key.NewStream()
+	}
+	return &bufferedStream{key: key}, nil
+}
+
+type bufferedStream struct {
+	key SigningKey
+	buf bytes.Buffer
+}
+
+// Write implements [io.Writer].
+func (s *bufferedStream) Write(p []byte) (int, error) {
+	return s.buf.Write(p)
+}
+
+// Sign implements Stream.
+func (s *bufferedStream) Sign() (signature []byte, err error) {
+	return s.key.Sign(s.buf.Bytes())
+}
+
+// Verify implements Stream.
+func (s *bufferedStream) Verify(signature []byte) error {
+	return s.key.Verify(s.buf.Bytes(), signature)
+}